@@ -16,6 +16,8 @@ type ClusterCatalog struct {
 	ClusterName               string `yaml:"kubernetes_cluster_name"`
 	AdminPassword             string `yaml:"kubernetes_admin_password"`
 	TLSDirectory              string `yaml:"tls_directory"`
+	CACertificateFile         string `yaml:"ca_certificate_file"`
+	CRLFile                   string `yaml:"crl_file"`
 	ServicesCIDR              string `yaml:"kubernetes_services_cidr"`
 	PodCIDR                   string `yaml:"kubernetes_pods_cidr"`
 	DNSServiceIP              string `yaml:"kubernetes_dns_service_ip"`
@@ -50,12 +52,20 @@ type ClusterCatalog struct {
 
 	EnableConfigureIngress bool `yaml:"configure_ingress"`
 
+	TolerateWorkerFailures bool `yaml:"tolerate_worker_failures"`
+
 	KismaticPreflightCheckerLinux string `yaml:"kismatic_preflight_checker"`
 
 	NewNode string `yaml:"new_node"`
 
 	NFSVolumes []NFSVolume `yaml:"nfs_volumes"`
 
+	UserBindings []UserBinding `yaml:"user_bindings"`
+	// ReconcileUserBindings deletes the bindings of the users that were removed from the plan file
+	ReconcileUserBindings bool `yaml:"reconcile_user_bindings"`
+	// RevokedUser is the user whose role bindings are deleted, after its certificate was revoked
+	RevokedUser string `yaml:"revoked_user"`
+
 	EnableGluster bool `yaml:"configure_storage"`
 
 	// volume add vars
@@ -74,8 +84,12 @@ type ClusterCatalog struct {
 
 	OnlineUpgrade bool `yaml:"online_upgrade"`
 
+	MaintenanceCommand string `yaml:"maintenance_command"`
+
 	DiagnosticsDirectory string `yaml:"diagnostics_dir"`
 	DiagnosticsDateTime  string `yaml:"diagnostics_date_time"`
+	// DiagnosticsSince is the start of the time window of the logs that are collected, in journalctl format
+	DiagnosticsSince string `yaml:"diagnostics_since"`
 
 	Docker struct {
 		Enabled bool
@@ -176,6 +190,17 @@ type NFSVolume struct {
 	Path string
 }
 
+// UserBinding binds a ClusterRole to a user, in a namespace or in all namespaces
+type UserBinding struct {
+	Name      string
+	Kind      string
+	User      string
+	Role      string
+	Namespace string
+	// Key identifies the binding in the cluster, in the form "kind,namespace,name"
+	Key string
+}
+
 type AdditionalFile struct {
 	Source      string
 	Destination string
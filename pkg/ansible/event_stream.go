@@ -1,6 +1,7 @@
 package ansible
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -8,8 +9,13 @@ import (
 	"github.com/apprenda/kismatic/pkg/util"
 )
 
+// endOfStream is written to the named pipe once ansible exits. The pipe is held open
+// for writing by the reader, so the end of the stream is never reached otherwise.
+var endOfStream = []byte(`{"eventType":"END_OF_STREAM"}`)
+
 // EventStream reads JSON lines from the incoming stream, and convert them
-// into a stream of events.
+// into a stream of events. The stream ends at the end of the input, or at
+// the end of stream marker.
 func EventStream(in io.Reader) <-chan Event {
 	lr := util.NewLineReader(in, 64*1024)
 	out := make(chan Event)
@@ -21,6 +27,10 @@ func EventStream(in io.Reader) <-chan Event {
 			if err != nil { // we are done with the stream
 				break
 			}
+			if bytes.Equal(bytes.TrimSpace(line), endOfStream) {
+				err = io.EOF
+				break
+			}
 			event, err := eventFromJSONLine(line)
 			if err != nil {
 				// handle this error? Maybe have an outErr channel
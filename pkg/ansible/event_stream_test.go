@@ -2,6 +2,7 @@ package ansible
 
 import (
 	"bytes"
+	"io"
 	"testing"
 )
 
@@ -58,6 +59,24 @@ func TestEventStreamNoEvents(t *testing.T) {
 	}
 }
 
+func TestEventStreamEndsAtMarker(t *testing.T) {
+	// The reader keeps the named pipe open, so the input does not end
+	r, w := io.Pipe()
+	defer w.Close()
+	es := EventStream(r)
+	go func() {
+		w.Write([]byte(`{"eventType":"PLAY_START", "eventData": {"name":"somePlay"}}` + "\n"))
+		w.Write(append(endOfStream, '\n'))
+	}()
+	gotEvents := 0
+	for range es {
+		gotEvents++
+	}
+	if gotEvents != 1 {
+		t.Errorf("got %d events, but expected 1", gotEvents)
+	}
+}
+
 func TestEventStreamBadEventIsIgnored(t *testing.T) {
 	in := bytes.NewBufferString(`{"eventType":"PLAY_START", "eventData": {"name":"somePlay"}}
 {"eventType":"BAD_EVENT", "eventData": {"name":"somePlay"}}
@@ -36,6 +36,9 @@ type Runner interface {
 	// against the specific node.
 	// It returns a read-only channel that must be consumed for the playbook execution to proceed.
 	StartPlaybookOnNode(playbookFile string, inventory Inventory, cc ClusterCatalog, node ...string) (<-chan Event, error)
+	// StopPlaybook kills the ansible process that is running the playbook.
+	// WaitPlaybook must still be called to release the resources held by the process.
+	StopPlaybook() error
 }
 
 type runner struct {
@@ -48,7 +51,9 @@ type runner struct {
 	ansibleDir   string
 	runDir       string
 	waitPlaybook func() error
+	stopPlaybook func() error
 	namedPipe    string
+	eventStream  *os.File
 }
 
 // NewRunner returns a new runner for running Ansible playbooks.
@@ -80,7 +85,15 @@ func (r *runner) WaitPlaybook() error {
 		return fmt.Errorf("wait called, but playbook not started")
 	}
 	execErr := r.waitPlaybook()
-	// Process exited, we can clean up named pipe
+	// Process exited, mark the end of the event stream after the events written by ansible
+	if r.eventStream != nil {
+		if _, err := r.eventStream.Write(append(endOfStream, '\n')); err != nil {
+			// Closing the pipe ends the stream, without the events that were not read yet
+			r.eventStream.Close()
+			return fmt.Errorf("error ending the ansible event stream: %v", err)
+		}
+	}
+	// We can clean up named pipe
 	removeErr := os.Remove(r.namedPipe)
 	if removeErr != nil && execErr != nil {
 		return fmt.Errorf("an error occurred running ansible: %v. Removing named pipe at %q failed: %v", execErr, r.namedPipe, removeErr)
@@ -94,6 +107,17 @@ func (r *runner) WaitPlaybook() error {
 	return nil
 }
 
+// StopPlaybook kills the ansible process that is running the playbook.
+func (r *runner) StopPlaybook() error {
+	if r.stopPlaybook == nil {
+		return fmt.Errorf("stop called, but playbook not started")
+	}
+	if err := r.stopPlaybook(); err != nil {
+		return fmt.Errorf("error stopping ansible: %v", err)
+	}
+	return nil
+}
+
 // RunPlaybook with the given inventory and extra vars
 func (r *runner) StartPlaybook(playbookFile string, inv Inventory, cc ClusterCatalog) (<-chan Event, error) {
 	return r.startPlaybook(playbookFile, inv, cc) // Don't set the --limit arg
@@ -177,12 +201,14 @@ func (r *runner) startPlaybook(playbookFile string, inv Inventory, cc ClusterCat
 		return nil, fmt.Errorf("error running playbook: %v", err)
 	}
 	r.waitPlaybook = cmd.Wait
+	r.stopPlaybook = cmd.Process.Kill
 
 	// Create the event stream out of the named pipe
 	eventStreamFile, err := os.OpenFile(r.namedPipe, os.O_RDWR, os.ModeNamedPipe)
 	if err != nil {
 		return nil, fmt.Errorf("error openning event stream pipe: %v", err)
 	}
+	r.eventStream = eventStreamFile
 	eventStream := EventStream(eventStreamFile)
 	return eventStream, nil
 }
@@ -0,0 +1,257 @@
+package ansible
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// WatchdogOptions are the timeouts enforced by the watchdog on a playbook's
+// event stream. A zero value disables the corresponding timeout.
+type WatchdogOptions struct {
+	// StallTimeout is the maximum amount of time to wait for the next event
+	StallTimeout time.Duration
+	// PlayTimeout is the maximum amount of time a single play can take
+	PlayTimeout time.Duration
+	// Timeout is the maximum amount of time the whole playbook can take
+	Timeout time.Duration
+}
+
+// TimeoutError is returned by the watchdog when the playbook exceeded one of
+// the configured timeouts.
+type TimeoutError struct {
+	// Reason describes the timeout that was exceeded
+	Reason string
+	// Play that was running when the timeout was reached
+	Play string
+	// Task that was running when the timeout was reached
+	Task string
+	// Hosts that had not reported a result for the task
+	Hosts []string
+}
+
+func (e TimeoutError) Error() string {
+	msg := e.Reason
+	if e.Task != "" {
+		msg = fmt.Sprintf("%s while running task %q", msg, e.Task)
+	}
+	if e.Play != "" {
+		msg = fmt.Sprintf("%s of play %q", msg, e.Play)
+	}
+	if len(e.Hosts) > 0 {
+		msg = fmt.Sprintf("%s. Still waiting on host(s): %s", msg, strings.Join(e.Hosts, ", "))
+	}
+	return msg
+}
+
+// Watchdog keeps track of the events produced by a playbook, and raises an
+// error if the playbook stops making progress or runs for too long.
+type Watchdog struct {
+	options WatchdogOptions
+	hosts   []string
+	expired chan error
+	done    chan struct{}
+
+	// state of the playbook, only accessed by the watching go routine
+	play      string
+	task      string
+	playHosts map[string]bool
+	reported  map[string]bool
+	dead      map[string]bool
+}
+
+// NewWatchdog returns a watchdog for a playbook that is running against the
+// nodes in the inventory.
+func NewWatchdog(options WatchdogOptions, inventory Inventory) *Watchdog {
+	hosts := []string{}
+	seen := map[string]bool{}
+	for _, r := range inventory.Roles {
+		for _, n := range r.Nodes {
+			if !seen[n.Host] {
+				seen[n.Host] = true
+				hosts = append(hosts, n.Host)
+			}
+		}
+	}
+	return &Watchdog{
+		options:   options,
+		hosts:     hosts,
+		expired:   make(chan error, 1),
+		done:      make(chan struct{}),
+		playHosts: map[string]bool{},
+		reported:  map[string]bool{},
+		dead:      map[string]bool{},
+	}
+}
+
+// Watch the incoming event stream. All events are forwarded to the returned
+// channel, which must be consumed for the playbook execution to proceed.
+// The returned channel is closed once the incoming stream is closed.
+func (w *Watchdog) Watch(in <-chan Event) <-chan Event {
+	out := make(chan Event)
+	go func() {
+		defer close(out)
+		overall := newWatchdogTimer(w.options.Timeout)
+		play := newWatchdogTimer(w.options.PlayTimeout)
+		stall := newWatchdogTimer(w.options.StallTimeout)
+		defer overall.Stop()
+		defer play.Stop()
+		defer stall.Stop()
+		fired := false
+		for {
+			var reason string
+			select {
+			case e, ok := <-in:
+				if !ok {
+					return
+				}
+				if _, ok := e.(*PlayStartEvent); ok {
+					play.Reset(w.options.PlayTimeout)
+				}
+				stall.Reset(w.options.StallTimeout)
+				w.track(e)
+				out <- e
+				continue
+			case <-w.done:
+				// The timeouts are no longer enforced, but the events that are
+				// still in flight are forwarded until the stream is closed, so
+				// that they are not lost and the producer is not blocked.
+				for e := range in {
+					out <- e
+				}
+				return
+			case <-overall.C():
+				reason = fmt.Sprintf("playbook did not complete within %v", w.options.Timeout)
+			case <-play.C():
+				reason = fmt.Sprintf("play did not complete within %v", w.options.PlayTimeout)
+			case <-stall.C():
+				reason = fmt.Sprintf("no progress was reported for %v", w.options.StallTimeout)
+			}
+			// Only report the first timeout, the playbook is expected to
+			// be stopped once it is received.
+			if !fired {
+				fired = true
+				w.expired <- TimeoutError{
+					Reason: reason,
+					Play:   w.play,
+					Task:   w.task,
+					Hosts:  w.outstanding(),
+				}
+			}
+		}
+	}()
+	return out
+}
+
+// Expired returns a channel that receives a TimeoutError when one of the
+// timeouts is exceeded.
+func (w *Watchdog) Expired() <-chan error {
+	return w.expired
+}
+
+// Stop enforcing the timeouts. The events are still forwarded until the
+// incoming stream is closed.
+func (w *Watchdog) Stop() {
+	select {
+	case <-w.done:
+	default:
+		close(w.done)
+	}
+}
+
+func (w *Watchdog) track(e Event) {
+	switch event := e.(type) {
+	case *PlayStartEvent:
+		w.play = event.Name
+		w.task = ""
+		w.playHosts = map[string]bool{}
+		w.reported = map[string]bool{}
+	case *TaskStartEvent:
+		w.task = event.Name
+		w.reported = map[string]bool{}
+	case *HandlerTaskStartEvent:
+		w.task = event.Name
+		w.reported = map[string]bool{}
+	case *RunnerOKEvent:
+		w.report(event.Host)
+	case *RunnerSkippedEvent:
+		w.report(event.Host)
+	case *RunnerFailedEvent:
+		w.report(event.Host)
+		if !event.IgnoreErrors {
+			w.dead[event.Host] = true
+		}
+	case *RunnerUnreachableEvent:
+		w.report(event.Host)
+		w.dead[event.Host] = true
+	case *RunnerItemOKEvent, *RunnerItemFailedEvent, *RunnerItemRetryEvent:
+		// Item events are produced while the task is still running on the host
+	}
+}
+
+func (w *Watchdog) report(host string) {
+	w.reported[host] = true
+	w.playHosts[host] = true
+}
+
+// outstanding returns the hosts that have not reported a result for the
+// current task. The hosts targeted by a play are not part of the event
+// stream, so we use the hosts that have reported during the play, or all
+// the hosts in the inventory if none have reported yet.
+func (w *Watchdog) outstanding() []string {
+	candidates := []string{}
+	for h := range w.playHosts {
+		candidates = append(candidates, h)
+	}
+	if len(candidates) == 0 {
+		candidates = append(candidates, w.hosts...)
+	}
+	hosts := []string{}
+	for _, h := range candidates {
+		if !w.reported[h] && !w.dead[h] {
+			hosts = append(hosts, h)
+		}
+	}
+	sort.Strings(hosts)
+	return hosts
+}
+
+// watchdogTimer is a timer that never fires when the duration is zero
+type watchdogTimer struct {
+	t *time.Timer
+}
+
+func newWatchdogTimer(d time.Duration) *watchdogTimer {
+	wt := &watchdogTimer{}
+	if d > 0 {
+		wt.t = time.NewTimer(d)
+	}
+	return wt
+}
+
+func (wt *watchdogTimer) C() <-chan time.Time {
+	if wt.t == nil {
+		return nil
+	}
+	return wt.t.C
+}
+
+func (wt *watchdogTimer) Reset(d time.Duration) {
+	if wt.t == nil {
+		return
+	}
+	if !wt.t.Stop() {
+		select {
+		case <-wt.t.C:
+		default:
+		}
+	}
+	wt.t.Reset(d)
+}
+
+func (wt *watchdogTimer) Stop() {
+	if wt.t != nil {
+		wt.t.Stop()
+	}
+}
@@ -0,0 +1,175 @@
+package ansible
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func testInventory(hosts ...string) Inventory {
+	nodes := []Node{}
+	for _, h := range hosts {
+		nodes = append(nodes, Node{Host: h})
+	}
+	return Inventory{Roles: []Role{{Name: "worker", Nodes: nodes}}}
+}
+
+func okEvent(host string) *RunnerOKEvent {
+	e := &RunnerOKEvent{}
+	e.Host = host
+	return e
+}
+
+func TestWatchdogStallReportsOutstandingHostAndTask(t *testing.T) {
+	in := make(chan Event)
+	w := NewWatchdog(WatchdogOptions{StallTimeout: 50 * time.Millisecond}, testInventory("node1", "node2", "node3"))
+	defer w.Stop()
+	out := w.Watch(in)
+	go func() {
+		for range out {
+		}
+	}()
+
+	in <- &PlayStartEvent{namedEvent{Name: "Install packages"}}
+	in <- &TaskStartEvent{namedEvent{Name: "gather facts"}}
+	in <- okEvent("node1")
+	in <- okEvent("node2")
+	in <- okEvent("node3")
+	in <- &TaskStartEvent{namedEvent{Name: "install docker"}}
+	in <- okEvent("node2")
+
+	select {
+	case err := <-w.Expired():
+		te, ok := err.(TimeoutError)
+		if !ok {
+			t.Fatalf("expected a TimeoutError, but got %T", err)
+		}
+		if te.Task != "install docker" {
+			t.Errorf("expected task %q, got %q", "install docker", te.Task)
+		}
+		if te.Play != "Install packages" {
+			t.Errorf("expected play %q, got %q", "Install packages", te.Play)
+		}
+		expectedHosts := []string{"node1", "node3"}
+		if !reflect.DeepEqual(te.Hosts, expectedHosts) {
+			t.Errorf("expected outstanding hosts %v, got %v", expectedHosts, te.Hosts)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("watchdog did not expire")
+	}
+}
+
+func TestWatchdogIgnoresFailedHosts(t *testing.T) {
+	in := make(chan Event)
+	w := NewWatchdog(WatchdogOptions{StallTimeout: 50 * time.Millisecond}, testInventory("node1", "node2"))
+	defer w.Stop()
+	out := w.Watch(in)
+	go func() {
+		for range out {
+		}
+	}()
+
+	unreachable := &RunnerUnreachableEvent{}
+	unreachable.Host = "node1"
+	in <- &PlayStartEvent{namedEvent{Name: "Install packages"}}
+	in <- &TaskStartEvent{namedEvent{Name: "gather facts"}}
+	in <- unreachable
+	in <- okEvent("node2")
+	in <- &TaskStartEvent{namedEvent{Name: "install docker"}}
+
+	select {
+	case err := <-w.Expired():
+		te := err.(TimeoutError)
+		if !reflect.DeepEqual(te.Hosts, []string{"node2"}) {
+			t.Errorf("expected outstanding hosts [node2], got %v", te.Hosts)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("watchdog did not expire")
+	}
+}
+
+func TestWatchdogOverallTimeout(t *testing.T) {
+	in := make(chan Event)
+	w := NewWatchdog(WatchdogOptions{Timeout: 100 * time.Millisecond}, testInventory("node1"))
+	defer w.Stop()
+	out := w.Watch(in)
+	go func() {
+		for range out {
+		}
+	}()
+	// Keep sending events, the overall timeout must still fire
+	go func() {
+		for {
+			select {
+			case in <- &TaskStartEvent{namedEvent{Name: "wait"}}:
+			case <-w.done:
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+	select {
+	case err := <-w.Expired():
+		if _, ok := err.(TimeoutError); !ok {
+			t.Errorf("expected a TimeoutError, but got %T", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("watchdog did not expire")
+	}
+}
+
+func TestWatchdogForwardsEventsWithoutTimeouts(t *testing.T) {
+	in := make(chan Event)
+	w := NewWatchdog(WatchdogOptions{}, testInventory("node1"))
+	defer w.Stop()
+	out := w.Watch(in)
+	go func() {
+		in <- &PlaybookStartEvent{}
+		in <- &PlaybookEndEvent{}
+		close(in)
+	}()
+	var count int
+	for range out {
+		count++
+	}
+	if count != 2 {
+		t.Errorf("expected 2 events, got %d", count)
+	}
+	select {
+	case err := <-w.Expired():
+		t.Errorf("unexpected timeout: %v", err)
+	default:
+	}
+}
+
+func TestWatchdogForwardsEventsAfterStop(t *testing.T) {
+	in := make(chan Event)
+	w := NewWatchdog(WatchdogOptions{StallTimeout: time.Millisecond}, testInventory("node1"))
+	out := w.Watch(in)
+	w.Stop()
+	go func() {
+		in <- &PlaybookStartEvent{}
+		in <- &PlaybookEndEvent{}
+		close(in)
+	}()
+	var count int
+	for range out {
+		count++
+	}
+	if count != 2 {
+		t.Errorf("expected 2 events, got %d", count)
+	}
+}
+
+func TestTimeoutErrorMessage(t *testing.T) {
+	err := TimeoutError{
+		Reason: "no progress was reported for 30m0s",
+		Play:   "Install packages",
+		Task:   "install docker",
+		Hosts:  []string{"node1", "node3"},
+	}
+	expected := `no progress was reported for 30m0s while running task "install docker" of play "Install packages". Still waiting on host(s): node1, node3`
+	if err.Error() != expected {
+		t.Errorf("unexpected error message.\nexpected: %s\ngot: %s", expected, err.Error())
+	}
+}
@@ -20,6 +20,8 @@ type addNodeOpts struct {
 	OutputFormat             string
 	Verbose                  bool
 	SkipPreFlight            bool
+	Timeouts                 timeoutOpts
+	FailureTolerance         failureToleranceOpts
 }
 
 var validRoles = []string{"worker", "ingress", "storage"}
@@ -35,6 +37,9 @@ func NewCmdAddNode(out io.Writer, installOpts *installOpts) *cobra.Command {
 			if len(args) < 2 || len(args) > 3 {
 				return cmd.Usage()
 			}
+			if err := opts.FailureTolerance.validate(); err != nil {
+				return err
+			}
 			newNode := install.Node{
 				Host: args[0],
 				IP:   args[1],
@@ -71,6 +76,8 @@ func NewCmdAddNode(out io.Writer, installOpts *installOpts) *cobra.Command {
 	cmd.Flags().BoolVar(&opts.Verbose, "verbose", false, "enable verbose logging from the installation")
 	cmd.Flags().StringVarP(&opts.OutputFormat, "output", "o", "simple", "installation output format (options \"simple\"|\"raw\")")
 	cmd.Flags().BoolVar(&opts.SkipPreFlight, "skip-preflight", false, "skip pre-flight checks, useful when rerunning kismatic")
+	addTimeoutFlags(cmd.Flags(), &opts.Timeouts)
+	addFailureToleranceFlags(cmd.Flags(), &opts.FailureTolerance)
 	return cmd
 }
 
@@ -83,6 +90,11 @@ func doAddNode(out io.Writer, planFile string, opts *addNodeOpts, newNode instal
 		GeneratedAssetsDirectory: opts.GeneratedAssetsDirectory,
 		OutputFormat:             opts.OutputFormat,
 		Verbose:                  opts.Verbose,
+		StallTimeout:             opts.Timeouts.stallTimeout,
+		PlayTimeout:              opts.Timeouts.playTimeout,
+		Timeout:                  opts.Timeouts.timeout,
+		MaxFailedWorkers:         opts.FailureTolerance.maxFailedWorkers,
+		MaxFailPercentage:        opts.FailureTolerance.maxFailPercentage,
 	}
 	executor, err := install.NewExecutor(out, os.Stderr, execOpts)
 	if err != nil {
@@ -120,12 +132,17 @@ func doAddNode(out io.Writer, planFile string, opts *addNodeOpts, newNode instal
 		}
 	}
 	updatedPlan, err := executor.AddNode(plan, newNode, opts.Roles, opts.RestartServices)
-	if err != nil {
+	fwErr, failedWorkers := err.(install.FailedWorkersError)
+	if err != nil && !failedWorkers {
 		return err
 	}
 	if err := planner.Write(updatedPlan); err != nil {
 		return fmt.Errorf("error updating plan file to include the new node: %v", err)
 	}
+	if failedWorkers {
+		printFailedWorkers(out, fwErr, "kismatic install step _hosts.yaml")
+		return fmt.Errorf("the node was added, but %v", fwErr)
+	}
 	return nil
 }
 
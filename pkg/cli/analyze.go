@@ -0,0 +1,105 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/apprenda/kismatic/pkg/install/analyze"
+	"github.com/spf13/cobra"
+)
+
+type analyzeOpts struct {
+	rulesFile    string
+	outputFormat string
+}
+
+// NewCmdAnalyze returns the analyze command
+func NewCmdAnalyze(out io.Writer) *cobra.Command {
+	opts := &analyzeOpts{}
+	cmd := &cobra.Command{
+		Use:   "analyze PATH",
+		Short: "Look for known failure signatures in a run or a diagnostics archive",
+		Long: `Look for known failure signatures in a run or a diagnostics archive, and explain their
+probable cause and remediation.
+
+PATH is one of:
+- The directory of a run, such as "runs/apply/2018-04-01-10-00-00"
+- A diagnostics archive created by "kismatic diagnose"
+- Any other log file
+
+Known failure signatures include a locked package manager, image pull authentication
+failures, port conflicts, clock skew, loss of etcd quorum, unreachable nodes and full
+disks. Additional signatures can be provided with a rules file. The same analysis is
+performed automatically when a run of kismatic fails.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				return cmd.Usage()
+			}
+			return doAnalyze(out, args[0], opts)
+		},
+	}
+	cmd.Flags().StringVar(&opts.rulesFile, "rules-file", "", "path to a file with additional failure signatures")
+	cmd.Flags().StringVarP(&opts.outputFormat, "output", "o", "simple", `output format (options "simple"|"json")`)
+	return cmd
+}
+
+func doAnalyze(out io.Writer, path string, opts *analyzeOpts) error {
+	if opts.outputFormat != "simple" && opts.outputFormat != "json" {
+		return fmt.Errorf("output format %q is not supported", opts.outputFormat)
+	}
+	rules := analyze.DefaultRules()
+	if opts.rulesFile != "" {
+		custom, err := analyze.ReadFromFile(opts.rulesFile)
+		if err != nil {
+			return err
+		}
+		rules = append(rules, custom...)
+	}
+	analyzer, err := analyze.NewAnalyzer(rules)
+	if err != nil {
+		return err
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("error reading %q: %v", path, err)
+	}
+	switch {
+	case fi.IsDir():
+		err = analyzer.AnalyzeRun(path)
+	case strings.HasSuffix(path, ".tar.gz"):
+		err = analyzer.AnalyzeBundle(path)
+	default:
+		err = analyzeFile(analyzer, path)
+	}
+	if err != nil {
+		return err
+	}
+
+	findings := analyzer.Findings()
+	if opts.outputFormat == "json" {
+		b, err := json.MarshalIndent(findings, "", "  ")
+		if err != nil {
+			return fmt.Errorf("error marshalling findings: %v", err)
+		}
+		fmt.Fprintln(out, string(b))
+		return nil
+	}
+	if len(findings) == 0 {
+		fmt.Fprintln(out, "No known failure signatures were found")
+		return nil
+	}
+	analyze.PrintFindings(out, findings)
+	return nil
+}
+
+func analyzeFile(analyzer *analyze.Analyzer, file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return fmt.Errorf("error reading %q: %v", file, err)
+	}
+	defer f.Close()
+	return analyzer.AnalyzeText(file, f)
+}
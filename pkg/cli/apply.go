@@ -30,6 +30,8 @@ type applyOpts struct {
 	outputFormat       string
 	skipPreFlight      bool
 	limit              []string
+	timeouts           timeoutOpts
+	failureTolerance   failureToleranceOpts
 }
 
 // NewCmdApply creates a cluter using the plan file
@@ -42,11 +44,19 @@ func NewCmdApply(out io.Writer, installOpts *installOpts) *cobra.Command {
 			if len(args) != 0 {
 				return fmt.Errorf("Unexpected args: %v", args)
 			}
+			if err := applyOpts.failureTolerance.validate(); err != nil {
+				return err
+			}
 			planner := &install.FilePlanner{File: installOpts.planFilename}
 			executorOpts := install.ExecutorOptions{
 				GeneratedAssetsDirectory: applyOpts.generatedAssetsDir,
 				OutputFormat:             applyOpts.outputFormat,
 				Verbose:                  applyOpts.verbose,
+				StallTimeout:             applyOpts.timeouts.stallTimeout,
+				PlayTimeout:              applyOpts.timeouts.playTimeout,
+				Timeout:                  applyOpts.timeouts.timeout,
+				MaxFailedWorkers:         applyOpts.failureTolerance.maxFailedWorkers,
+				MaxFailPercentage:        applyOpts.failureTolerance.maxFailPercentage,
 			}
 			executor, err := install.NewExecutor(out, os.Stderr, executorOpts)
 			if err != nil {
@@ -76,6 +86,8 @@ func NewCmdApply(out io.Writer, installOpts *installOpts) *cobra.Command {
 	cmd.Flags().BoolVar(&applyOpts.verbose, "verbose", false, "enable verbose logging from the installation")
 	cmd.Flags().StringVarP(&applyOpts.outputFormat, "output", "o", "simple", "installation output format (options \"simple\"|\"raw\")")
 	cmd.Flags().BoolVar(&applyOpts.skipPreFlight, "skip-preflight", false, "skip pre-flight checks, useful when rerunning kismatic")
+	addTimeoutFlags(cmd.Flags(), &applyOpts.timeouts)
+	addFailureToleranceFlags(cmd.Flags(), &applyOpts.failureTolerance)
 
 	return cmd
 }
@@ -111,10 +123,22 @@ func (c *applyCmd) run() error {
 		return fmt.Errorf("error generating kubeconfig file: %v", err)
 	}
 	util.PrettyPrintOk(c.out, "Generated kubeconfig file in the %q directory", c.generatedAssetsDir)
+	if len(plan.Users) > 0 {
+		if err = install.GenerateUserKubeconfigs(plan, c.generatedAssetsDir); err != nil {
+			return err
+		}
+		util.PrettyPrintOk(c.out, "Generated kubeconfig files for %d users in the %q directory", len(plan.Users), c.generatedAssetsDir)
+	}
 
 	// Perform the installation
+	var failedWorkers *install.FailedWorkersError
 	if err := c.executor.Install(plan, c.restartServices, c.limit...); err != nil {
-		return fmt.Errorf("error installing: %v", err)
+		fwErr, ok := err.(install.FailedWorkersError)
+		if !ok {
+			return fmt.Errorf("error installing: %v", err)
+		}
+		util.PrettyPrintWarn(c.out, "Installation failed on %d worker node(s), continuing", len(fwErr.Hosts))
+		failedWorkers = &fwErr
 	}
 
 	// Run smoketest
@@ -125,12 +149,17 @@ func (c *applyCmd) run() error {
 		}
 	}
 
+	if failedWorkers != nil {
+		printFailedWorkers(c.out, *failedWorkers, "kismatic install apply")
+		return fmt.Errorf("the cluster was installed, but %v", failedWorkers)
+	}
+
 	util.PrintColor(c.out, util.Green, "\nThe cluster was installed successfully!\n")
 	fmt.Fprintln(c.out)
 
 	msg := "- To use the generated kubeconfig file with kubectl:" +
 		"\n    * use \"./kubectl --kubeconfig %s/kubeconfig\"" +
-		"\n    * or copy the config file \"cp %[1]s/kubeconfig ~/.kube/config\"\n"
+		"\n    * or merge it into the kubectl config file \"./kismatic kubeconfig merge --set-current\"\n"
 	util.PrintColor(c.out, util.Blue, msg, c.generatedAssetsDir)
 	util.PrintColor(c.out, util.Blue, "- To view the Kubernetes dashboard: \"./kismatic dashboard\"\n")
 	util.PrintColor(c.out, util.Blue, "- To SSH into a cluster node: \"./kismatic ssh etcd|master|worker|storage|$node.host\"\n")
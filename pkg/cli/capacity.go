@@ -0,0 +1,143 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/apprenda/kismatic/pkg/install"
+	"github.com/apprenda/kismatic/pkg/util"
+	"github.com/spf13/cobra"
+)
+
+type capacityOpts struct {
+	planFilename       string
+	outputFormat       string
+	generatedAssetsDir string
+}
+
+// NewCmdCapacity returns the capacity command
+func NewCmdCapacity(out io.Writer) *cobra.Command {
+	opts := &capacityOpts{}
+	cmd := &cobra.Command{
+		Use:   "capacity",
+		Short: "Display the compute capacity of the cluster",
+		Long: `Display the allocatable CPU, memory and pods of each node, along with the
+resources requested by, and the limits of, the pods running on them.
+
+The nodes are also grouped by role, and by the labels set in the plan file.
+Nodes that have more resources allocated than they can provide are reported as overcommitted.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 0 {
+				return cmd.Usage()
+			}
+			return doCapacity(out, opts)
+		},
+	}
+	addPlanFileFlag(cmd.Flags(), &opts.planFilename)
+	cmd.Flags().StringVarP(&opts.outputFormat, "output", "o", "simple", `output format (options "simple"|"json")`)
+	cmd.Flags().StringVar(&opts.generatedAssetsDir, "generated-assets-dir", "generated", "path to the directory where assets generated during the installation process will be stored")
+	return cmd
+}
+
+func doCapacity(out io.Writer, opts *capacityOpts) error {
+	if opts.outputFormat != "simple" && opts.outputFormat != "json" {
+		return fmt.Errorf("output format %q is not supported", opts.outputFormat)
+	}
+	planner := &install.FilePlanner{File: opts.planFilename}
+	if !planner.PlanExists() {
+		return planFileNotFoundErr{filename: opts.planFilename}
+	}
+	plan, err := planner.Read()
+	if err != nil {
+		return fmt.Errorf("error reading plan file: %v", err)
+	}
+	kubeClient, err := kubernetesClient(*plan, opts.generatedAssetsDir)
+	if err != nil {
+		return err
+	}
+	report, err := install.ClusterCapacity(*plan, kubeClient)
+	if err != nil {
+		return fmt.Errorf("error getting cluster capacity: %v", err)
+	}
+
+	if opts.outputFormat == "json" {
+		b, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return fmt.Errorf("error marshalling capacity report: %v", err)
+		}
+		fmt.Fprintln(out, string(b))
+		return nil
+	}
+
+	fmt.Fprintf(out, "Nodes:\n")
+	w := tabwriter.NewWriter(out, 0, 0, 3, ' ', 0)
+	fmt.Fprint(w, "Name\tRoles\tCPU Requests\tCPU Limits\tMemory Requests\tMemory Limits\tPods\tOvercommitted\n")
+	for _, n := range report.Nodes {
+		if !n.Registered {
+			fmt.Fprintf(w, "%s\t%s\tnot registered with the API server\n", n.Node, strings.Join(n.Roles, ","))
+			continue
+		}
+		overcommitted := "-"
+		if len(n.Overcommitted) > 0 {
+			overcommitted = strings.Join(n.Overcommitted, ", ")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", n.Node, strings.Join(n.Roles, ","), capacityColumns(n.Allocatable, n.Allocated), overcommitted)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintln(out)
+	if err := printCapacityGroups(out, "Roles", report.Roles); err != nil {
+		return err
+	}
+	if len(report.Labels) > 0 {
+		fmt.Fprintln(out)
+		if err := printCapacityGroups(out, "Labels", report.Labels); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintln(out)
+	if err := printCapacityGroups(out, "Cluster", []install.CapacityGroup{report.Total}); err != nil {
+		return err
+	}
+	if report.Total.OvercommittedNodes > 0 {
+		fmt.Fprintln(out)
+		util.PrettyPrintWarn(out, "%d node(s) are overcommitted", report.Total.OvercommittedNodes)
+	}
+	return nil
+}
+
+func printCapacityGroups(out io.Writer, title string, groups []install.CapacityGroup) error {
+	fmt.Fprintf(out, "%s:\n", title)
+	w := tabwriter.NewWriter(out, 0, 0, 3, ' ', 0)
+	fmt.Fprint(w, "Name\tNodes\tCPU Requests\tCPU Limits\tMemory Requests\tMemory Limits\tPods\tOvercommitted Nodes\n")
+	for _, g := range groups {
+		fmt.Fprintf(w, "%s\t%d\t%s\t%d\n", g.Name, g.Nodes, capacityColumns(g.Allocatable, g.Allocated), g.OvercommittedNodes)
+	}
+	return w.Flush()
+}
+
+// capacityColumns returns the allocated resources, out of the allocatable resources,
+// as tab separated columns
+func capacityColumns(a install.Allocatable, u install.ResourceUsage) string {
+	cpu := func(m int64) string { return fmt.Sprintf("%.2f", float64(m)/1000) }
+	mem := func(b int64) string { return formatBytes(uint64(b)) }
+	columns := []string{
+		fmt.Sprintf("%s/%s (%s)", cpu(u.CPURequests), cpu(a.CPU), percent(u.CPURequests, a.CPU)),
+		fmt.Sprintf("%s/%s (%s)", cpu(u.CPULimits), cpu(a.CPU), percent(u.CPULimits, a.CPU)),
+		fmt.Sprintf("%s/%s (%s)", mem(u.MemoryRequests), mem(a.Memory), percent(u.MemoryRequests, a.Memory)),
+		fmt.Sprintf("%s/%s (%s)", mem(u.MemoryLimits), mem(a.Memory), percent(u.MemoryLimits, a.Memory)),
+		fmt.Sprintf("%d/%d", u.Pods, a.Pods),
+	}
+	return strings.Join(columns, "\t")
+}
+
+func percent(used, total int64) string {
+	if total == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%d%%", used*100/total)
+}
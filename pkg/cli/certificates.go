@@ -17,6 +17,11 @@ func NewCmdCertificates(out io.Writer) *cobra.Command {
 	}
 
 	cmd.AddCommand(NewCmdGenerate(out))
+	cmd.AddCommand(NewCmdCertificatesList(out))
+	cmd.AddCommand(NewCmdCertificatesRotate(out))
+	cmd.AddCommand(NewCmdCertificatesCSR(out))
+	cmd.AddCommand(NewCmdCertificatesImport(out))
+	cmd.AddCommand(NewCmdCertificatesRevoke(out))
 
 	return cmd
 }
@@ -0,0 +1,90 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"github.com/apprenda/kismatic/pkg/install"
+	"github.com/apprenda/kismatic/pkg/util"
+	"github.com/spf13/cobra"
+)
+
+type certificatesCSROpts struct {
+	planFilename       string
+	generatedAssetsDir string
+	overwrite          bool
+}
+
+// NewCmdCertificatesCSR creates a new certificates csr command
+func NewCmdCertificatesCSR(out io.Writer) *cobra.Command {
+	opts := &certificatesCSROpts{}
+	cmd := &cobra.Command{
+		Use:   "csr",
+		Short: "Generate the CSRs of the cluster certificates, to be signed by an external CA",
+		Long: `Generate a private key and a certificate signing request (CSR) for each certificate of the cluster
+that does not exist in the generated assets directory.
+
+The CSRs are written to the "keys" directory of the generated assets directory, with the ".csr" extension.
+Once signed by the CA, the certificates are imported with "kismatic certificates import". The private keys of
+the CAs are not required, as long as the certificates of the CAs ("ca.pem" and "proxy-client-ca.pem") are placed
+in the "keys" directory before the installation.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 0 {
+				return cmd.Usage()
+			}
+			return doCertificatesCSR(out, opts)
+		},
+	}
+	addPlanFileFlag(cmd.Flags(), &opts.planFilename)
+	cmd.Flags().StringVar(&opts.generatedAssetsDir, "generated-assets-dir", "generated", "path to the directory where assets generated during the installation process will be stored")
+	cmd.Flags().BoolVar(&opts.overwrite, "overwrite", false, "overwrite the existing CSRs and their private keys")
+	return cmd
+}
+
+func doCertificatesCSR(out io.Writer, opts *certificatesCSROpts) error {
+	planner := &install.FilePlanner{File: opts.planFilename}
+	if !planner.PlanExists() {
+		return planFileNotFoundErr{filename: opts.planFilename}
+	}
+	plan, err := planner.Read()
+	if err != nil {
+		return fmt.Errorf("error reading plan file: %v", err)
+	}
+	pki := &install.LocalPKI{
+		GeneratedCertsDirectory: filepath.Join(opts.generatedAssetsDir, "keys"),
+		Log:                     out,
+	}
+	util.PrintHeader(out, "Generating Certificate Signing Requests", '=')
+	csrs, err := pki.GenerateClusterCSRs(plan, opts.overwrite)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(out)
+	if len(csrs) == 0 {
+		util.PrintColor(out, util.Green, "All the certificates of the cluster exist, no CSRs were generated.\n")
+		return nil
+	}
+	printCSRs(out, csrs)
+	fmt.Fprintln(out)
+	fmt.Fprintf(out, "Sign the CSRs with their CA, for a validity period of %s, and import the signed certificates with \"kismatic certificates import\".\n", plan.Cluster.Certificates.Expiry)
+	return nil
+}
+
+func printCSRs(out io.Writer, csrs []install.CertificateSigningRequest) {
+	for _, signer := range []string{install.ClusterCASigner, install.ProxyClientCASigner} {
+		var files []string
+		for _, r := range csrs {
+			if r.Signer == signer {
+				files = append(files, r.File)
+			}
+		}
+		if len(files) == 0 {
+			continue
+		}
+		fmt.Fprintf(out, "CSRs to be signed by the %s:\n", signer)
+		for _, f := range files {
+			fmt.Fprintf(out, "  %s\n", f)
+		}
+	}
+}
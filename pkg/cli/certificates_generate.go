@@ -17,6 +17,7 @@ type certificatesGenerateOpts struct {
 	organizations      []string
 	overwrite          bool
 	generatedAssetsDir string
+	planFilename       string
 }
 
 // NewCmdGenerate creates a new certificates generate command
@@ -52,11 +53,20 @@ func NewCmdGenerate(out io.Writer) *cobra.Command {
 	cmd.Flags().StringSliceVar(&opts.organizations, "organizations", []string{}, "comma-separated list of names that should be included in the certificate's organization field.")
 	cmd.Flags().BoolVar(&opts.overwrite, "overwrite", false, "overwrite existing certificate if it already exists in the target directory.")
 	cmd.Flags().StringVar(&opts.generatedAssetsDir, "generated-assets-dir", "generated", "path to the directory where assets generated during the installation process will be stored")
+	addPlanFileFlag(cmd.Flags(), &opts.planFilename)
 
 	return cmd
 }
 
 func doCertificatesGenerate(name string, opts *certificatesGenerateOpts, out io.Writer) error {
+	planner := &install.FilePlanner{File: opts.planFilename}
+	if !planner.PlanExists() {
+		return planFileNotFoundErr{filename: opts.planFilename}
+	}
+	plan, err := planner.Read()
+	if err != nil {
+		return fmt.Errorf("error reading plan file: %v", err)
+	}
 	ansibleDir := "ansible"
 	certsDir := filepath.Join(opts.generatedAssetsDir, "keys")
 	pki := &install.LocalPKI{
@@ -73,7 +83,7 @@ func doCertificatesGenerate(name string, opts *certificatesGenerateOpts, out io.
 		commonName = name
 	}
 	validityPeriod := fmt.Sprintf("%dh", opts.validityPeriod*24)
-	exists, err := pki.GenerateCertificate(name, validityPeriod, commonName, opts.subjAltNames, opts.organizations, ca, opts.overwrite)
+	exists, err := pki.GenerateCertificate(plan, name, validityPeriod, commonName, opts.subjAltNames, opts.organizations, ca, opts.overwrite)
 	if err != nil {
 		return err
 	}
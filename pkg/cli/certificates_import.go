@@ -0,0 +1,71 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"github.com/apprenda/kismatic/pkg/install"
+	"github.com/apprenda/kismatic/pkg/util"
+	"github.com/spf13/cobra"
+)
+
+type certificatesImportOpts struct {
+	planFilename       string
+	generatedAssetsDir string
+}
+
+// NewCmdCertificatesImport creates a new certificates import command
+func NewCmdCertificatesImport(out io.Writer) *cobra.Command {
+	opts := &certificatesImportOpts{}
+	cmd := &cobra.Command{
+		Use:   "import <directory>",
+		Short: "Import the certificates that were signed for the CSRs of the cluster",
+		Long: `Import the certificates that were signed by an external CA for the CSRs generated with "kismatic certificates csr".
+
+The signed certificates are read from the directory, and must have the name of their CSR with the ".pem" extension.
+For example, the certificate signed for "generated/keys/admin.csr" is read from "<directory>/admin.pem". Each certificate
+is verified against its CSR and its CA before it is imported into the generated assets directory.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				return cmd.Usage()
+			}
+			return doCertificatesImport(out, args[0], opts)
+		},
+	}
+	addPlanFileFlag(cmd.Flags(), &opts.planFilename)
+	cmd.Flags().StringVar(&opts.generatedAssetsDir, "generated-assets-dir", "generated", "path to the directory where assets generated during the installation process will be stored")
+	return cmd
+}
+
+func doCertificatesImport(out io.Writer, dir string, opts *certificatesImportOpts) error {
+	planner := &install.FilePlanner{File: opts.planFilename}
+	if !planner.PlanExists() {
+		return planFileNotFoundErr{filename: opts.planFilename}
+	}
+	plan, err := planner.Read()
+	if err != nil {
+		return fmt.Errorf("error reading plan file: %v", err)
+	}
+	pki := &install.LocalPKI{
+		GeneratedCertsDirectory: filepath.Join(opts.generatedAssetsDir, "keys"),
+		Log:                     out,
+	}
+	util.PrintHeader(out, "Importing Certificates", '=')
+	imported, err := pki.ImportCertificates(plan, dir)
+	if err != nil {
+		return err
+	}
+	pending, err := pki.PendingCSRs(plan)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(out)
+	if len(pending) > 0 {
+		util.PrettyPrintWarn(out, "Imported %d certificates, %d CSRs are pending", len(imported), len(pending))
+		printCSRs(out, pending)
+		return nil
+	}
+	util.PrintColor(out, util.Green, "Imported %d certificates, no CSRs are pending.\n", len(imported))
+	return nil
+}
@@ -0,0 +1,154 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/apprenda/kismatic/pkg/data"
+	"github.com/apprenda/kismatic/pkg/install"
+	"github.com/apprenda/kismatic/pkg/util"
+	"github.com/spf13/cobra"
+)
+
+type certificatesListOpts struct {
+	planFilename       string
+	generatedAssetsDir string
+	remote             bool
+	expiryWarning      int
+	outputFormat       string
+}
+
+// NewCmdCertificatesList creates a new certificates list command
+func NewCmdCertificatesList(out io.Writer) *cobra.Command {
+	opts := &certificatesListOpts{}
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List the certificates of the cluster",
+		Long: `List the certificates in the generated assets directory, along with the entity of the plan file they belong to.
+
+Certificates that expire within the --expiry-warning period are reported as warnings. With --remote, the certificates
+deployed on each node are read over SSH, and compared with the local copies. The command exits with a non-zero exit code
+if a certificate has expired, or if a deployed certificate does not match its local copy.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 0 {
+				return cmd.Usage()
+			}
+			return doCertificatesList(out, opts)
+		},
+	}
+	addPlanFileFlag(cmd.Flags(), &opts.planFilename)
+	cmd.Flags().StringVar(&opts.generatedAssetsDir, "generated-assets-dir", "generated", "path to the directory where assets generated during the installation process will be stored")
+	cmd.Flags().BoolVar(&opts.remote, "remote", false, "compare the certificates deployed on the nodes with the local copies")
+	cmd.Flags().IntVar(&opts.expiryWarning, "expiry-warning", 30, "the number of days before their expiry at which certificates are reported as expiring")
+	cmd.Flags().StringVarP(&opts.outputFormat, "output", "o", "simple", `output format (options "simple"|"json")`)
+	return cmd
+}
+
+func doCertificatesList(out io.Writer, opts *certificatesListOpts) error {
+	if opts.outputFormat != "simple" && opts.outputFormat != "json" {
+		return fmt.Errorf("output format %q is not supported", opts.outputFormat)
+	}
+	planner := &install.FilePlanner{File: opts.planFilename}
+	if !planner.PlanExists() {
+		return planFileNotFoundErr{filename: opts.planFilename}
+	}
+	plan, err := planner.Read()
+	if err != nil {
+		return fmt.Errorf("error reading plan file: %v", err)
+	}
+	listOpts := install.CertificateListOptions{
+		Now:           time.Now(),
+		ExpiryWarning: time.Duration(opts.expiryWarning) * 24 * time.Hour,
+	}
+	if opts.remote {
+		if ok, errs := install.ValidatePlanSSHConnections(plan); !ok {
+			util.PrintValidationErrors(out, errs)
+			return fmt.Errorf("error connecting to the cluster nodes")
+		}
+		listOpts.Remote = map[string]data.CertificateGetter{}
+		for _, n := range plan.GetUniqueNodes() {
+			client, err := plan.GetSSHClient(n.Host)
+			if err != nil {
+				return fmt.Errorf("error getting SSH client: %v", err)
+			}
+			listOpts.Remote[n.Host] = data.RemoteCertificates{SSHClient: client}
+		}
+	}
+	certs, err := install.ListCertificates(*plan, filepath.Join(opts.generatedAssetsDir, "keys"), listOpts)
+	if err != nil {
+		return err
+	}
+
+	failed := false
+	for _, c := range certs {
+		if c.Status == install.CertificateExpired {
+			failed = true
+		}
+		for _, d := range c.Deployed {
+			if d.Status != install.DeployedCertificateMatch {
+				failed = true
+			}
+		}
+	}
+	if opts.outputFormat == "json" {
+		b, err := json.MarshalIndent(certs, "", "  ")
+		if err != nil {
+			return fmt.Errorf("error marshalling certificates: %v", err)
+		}
+		fmt.Fprintln(out, string(b))
+	} else {
+		if err := printCertificates(out, certs); err != nil {
+			return err
+		}
+	}
+	if failed {
+		return fmt.Errorf("certificates have expired or do not match the local copies")
+	}
+	return nil
+}
+
+func printCertificates(out io.Writer, certs []install.CertificateInfo) error {
+	w := tabwriter.NewWriter(out, 0, 0, 3, ' ', 0)
+	fmt.Fprint(w, "Name\tEntity\tCommon Name\tOrganizations\tSANs\tIssuer\tNot After\tStatus\n")
+	for _, c := range certs {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", c.Name, orNone(c.Entity), c.CommonName, orNone(strings.Join(c.Organizations, ",")),
+			orNone(strings.Join(c.SubjectAlternateNames, ",")), c.Issuer, c.NotAfter.Format("2006-01-02"), c.Status)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintln(out)
+	for _, c := range certs {
+		switch c.Status {
+		case install.CertificateExpired:
+			util.PrettyPrintErr(out, "Certificate %q expired on %s", c.Name, c.NotAfter.Format("2006-01-02"))
+		case install.CertificateExpiring:
+			util.PrettyPrintWarn(out, "Certificate %q expires on %s", c.Name, c.NotAfter.Format("2006-01-02"))
+		case install.CertificateRevoked:
+			util.PrettyPrintWarn(out, "Certificate %q is revoked", c.Name)
+		}
+		for _, d := range c.Deployed {
+			switch d.Status {
+			case install.DeployedCertificateMismatch:
+				util.PrettyPrintErr(out, "Certificate %q deployed on %s at %s does not match the local copy", c.Name, d.Node, d.Path)
+			case install.DeployedCertificateMissing:
+				util.PrettyPrintErr(out, "Certificate %q is not deployed on %s at %s", c.Name, d.Node, d.Path)
+			case install.DeployedCertificateError:
+				util.PrettyPrintErr(out, "Could not read certificate %q on %s: %s", c.Name, d.Node, d.Error)
+			}
+		}
+	}
+	return nil
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
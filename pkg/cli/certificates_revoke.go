@@ -0,0 +1,125 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/apprenda/kismatic/pkg/install"
+	"github.com/apprenda/kismatic/pkg/tls"
+	"github.com/apprenda/kismatic/pkg/util"
+	"github.com/spf13/cobra"
+)
+
+type certificatesRevokeOpts struct {
+	planFilename       string
+	generatedAssetsDir string
+	skipDeploy         bool
+	verbose            bool
+	outputFormat       string
+}
+
+// NewCmdCertificatesRevoke creates a new certificates revoke command
+func NewCmdCertificatesRevoke(out io.Writer) *cobra.Command {
+	opts := &certificatesRevokeOpts{}
+	cmd := &cobra.Command{
+		Use:   "revoke <name>",
+		Short: "Revoke a certificate issued by the cluster CA",
+		Long: `Revoke a certificate issued by the cluster CA, such as a client certificate created with "kismatic certificates generate".
+
+The certificate is read from the generated assets directory, and added to the certificate revocation list (CRL)
+of the cluster CA, which is written to "generated/keys/ca.crl". The CRL is then deployed to the etcd and master nodes.
+The certificates of the components of the cluster cannot be revoked, and must be rotated instead.
+
+The CRL is not enforced by etcd or the Kubernetes API server. Instead, the RBAC role bindings of the user of the
+certificate, which is the common name of its subject, are deleted to deny its access to the cluster. Role bindings
+that also bind other subjects are not deleted, and must be edited. The roles bound to the groups of the certificate
+are still granted.
+`,
+		Example: `  # Revoke the client certificate of alice
+  kismatic certificates revoke alice
+`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				return cmd.Usage()
+			}
+			return doCertificatesRevoke(out, args[0], opts)
+		},
+	}
+	addPlanFileFlag(cmd.Flags(), &opts.planFilename)
+	cmd.Flags().StringVar(&opts.generatedAssetsDir, "generated-assets-dir", "generated", "path to the directory where assets generated during the installation process will be stored")
+	cmd.Flags().BoolVar(&opts.skipDeploy, "skip-deploy", false, "only update the CRL in the generated assets directory, without deploying it to the nodes or deleting the role bindings of the user")
+	cmd.Flags().BoolVar(&opts.verbose, "verbose", false, "enable verbose logging")
+	cmd.Flags().StringVarP(&opts.outputFormat, "output", "o", "simple", "installation output format (options \"simple\"|\"raw\")")
+	return cmd
+}
+
+func doCertificatesRevoke(out io.Writer, name string, opts *certificatesRevokeOpts) error {
+	planner := &install.FilePlanner{File: opts.planFilename}
+	if !planner.PlanExists() {
+		return planFileNotFoundErr{filename: opts.planFilename}
+	}
+	plan, err := planner.Read()
+	if err != nil {
+		return fmt.Errorf("error reading plan file: %v", err)
+	}
+	pki := &install.LocalPKI{
+		GeneratedCertsDirectory: filepath.Join(opts.generatedAssetsDir, "keys"),
+		Log:                     out,
+	}
+	util.PrintHeader(out, "Revoke Certificate", '=')
+	if err = pki.RevokeCertificate(plan, name); err != nil {
+		return err
+	}
+	// The user of the certificate, which is bound to roles, is the common name of its subject
+	cert, err := tls.ReadCert(name, pki.GeneratedCertsDirectory)
+	if err != nil {
+		return err
+	}
+	user := cert.Subject.CommonName
+	if opts.skipDeploy {
+		printRevokedAccess(out, plan, user, cert.Subject.Organization, false)
+		return nil
+	}
+	executorOpts := install.ExecutorOptions{
+		GeneratedAssetsDirectory: opts.generatedAssetsDir,
+		OutputFormat:             opts.outputFormat,
+		Verbose:                  opts.verbose,
+	}
+	executor, err := install.NewExecutor(out, os.Stderr, executorOpts)
+	if err != nil {
+		return err
+	}
+	if err = validateSSHConnectivity(out, plan); err != nil {
+		return err
+	}
+	if err = executor.DeployCRL(*plan); err != nil {
+		return fmt.Errorf("Failed to deploy the certificate revocation list: %v", err)
+	}
+	if err = executor.UnbindUser(*plan, user); err != nil {
+		return fmt.Errorf("Failed to delete the role bindings of user %q: %v", user, err)
+	}
+	printRevokedAccess(out, plan, user, cert.Subject.Organization, true)
+	return nil
+}
+
+// printRevokedAccess warns about the access to the cluster that is still granted to the
+// revoked certificate, as neither etcd nor the Kubernetes API server check the CRL
+func printRevokedAccess(out io.Writer, plan *install.Plan, user string, groups []string, unbound bool) {
+	fmt.Fprintln(out)
+	util.PrintColor(out, util.Orange, "The CRL is NOT enforced by the cluster, access is denied by deleting the RBAC role bindings of user %q.\n", user)
+	if !unbound {
+		util.PrintColor(out, util.Orange, "The role bindings were not deleted. Run the command again without --skip-deploy to delete them.\n")
+	}
+	if len(groups) > 0 {
+		util.PrintColor(out, util.Orange, "The roles bound to the groups of the certificate are still granted: %s\n", strings.Join(groups, ", "))
+	}
+	for _, u := range plan.Users {
+		if u.Name == user {
+			util.PrintColor(out, util.Orange, "User %q is in the plan file, remove it or its role will be bound again by \"kismatic apply\".\n", user)
+		}
+	}
+	fmt.Fprintln(out)
+}
@@ -0,0 +1,173 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/apprenda/kismatic/pkg/install"
+	"github.com/apprenda/kismatic/pkg/util"
+	"github.com/spf13/cobra"
+)
+
+type certificatesRotateOpts struct {
+	planFilename       string
+	hosts              []string
+	components         []string
+	ca                 bool
+	generatedAssetsDir string
+	verbose            bool
+	outputFormat       string
+	timeouts           timeoutOpts
+}
+
+// NewCmdCertificatesRotate creates a new certificates rotate command
+func NewCmdCertificatesRotate(out io.Writer) *cobra.Command {
+	opts := &certificatesRotateOpts{}
+	cmd := &cobra.Command{
+		Use:   "rotate",
+		Short: "Rotate the certificates of the cluster",
+		Long: `Rotate the certificates of the cluster, using the existing Certificate Authority.
+
+The certificates are backed up and regenerated in the generated assets directory, and deployed
+to the nodes that use them. The components that use the certificates are restarted one node at a
+time, and the health of each node is verified before moving on to the next node.
+
+All the certificates of the cluster are rotated, unless they are selected with --nodes or --component.
+Certificates that are shared by the nodes are not rotated when --nodes is set. The service account
+certificate is only rotated when selected with --component, as rotating it invalidates the service
+account tokens of the cluster.
+
+With --ca, the cluster Certificate Authority is rotated instead. The rotation of the CA is performed in
+three stages, and each run of the command performs the next stage:
+- trust: a new CA is generated, and the nodes are set to trust both the current and the new CA
+- sign: the new CA replaces the current CA, and the certificates of the cluster are signed by it
+- finish: the nodes are set to trust only the new CA
+
+If the certificates cannot be deployed to the nodes, the same stage is deployed again the next time the command is run.
+`,
+		Example: `  # Rotate the certificates of the kubelets of two nodes
+  kismatic certificates rotate --component kubelet --nodes worker01,worker02
+
+  # Rotate the cluster CA, running the command once per stage
+  kismatic certificates rotate --ca
+`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 0 {
+				return fmt.Errorf("Unexpected args: %v", args)
+			}
+			return doCertificatesRotate(out, opts)
+		},
+	}
+	addPlanFileFlag(cmd.Flags(), &opts.planFilename)
+	cmd.Flags().StringSliceVar(&opts.hosts, "nodes", []string{}, "comma-separated list of hostnames of the nodes whose certificates are rotated")
+	cmd.Flags().StringSliceVar(&opts.components, "component", []string{}, "comma-separated list of the components whose certificates are rotated (options \"etcd\"|\"apiserver\"|\"kubelet\"|\"controller-manager\"|\"scheduler\"|\"etcd-client\"|\"apiserver-kubelet-client\"|\"proxy-client\"|\"admin\"|\"service-account\")")
+	cmd.Flags().BoolVar(&opts.ca, "ca", false, "perform the next stage of the rotation of the cluster CA")
+	cmd.Flags().StringVar(&opts.generatedAssetsDir, "generated-assets-dir", "generated", "path to the directory where assets generated during the installation process will be stored")
+	cmd.Flags().BoolVar(&opts.verbose, "verbose", false, "enable verbose logging")
+	cmd.Flags().StringVarP(&opts.outputFormat, "output", "o", "simple", "installation output format (options \"simple\"|\"raw\")")
+	addTimeoutFlags(cmd.Flags(), &opts.timeouts)
+	return cmd
+}
+
+func doCertificatesRotate(out io.Writer, opts *certificatesRotateOpts) error {
+	if opts.ca && (len(opts.hosts) > 0 || len(opts.components) > 0) {
+		return errors.New("--nodes and --component cannot be used with --ca")
+	}
+	planner := install.FilePlanner{File: opts.planFilename}
+	if !planner.PlanExists() {
+		return planFileNotFoundErr{filename: opts.planFilename}
+	}
+	plan, err := planner.Read()
+	if err != nil {
+		return fmt.Errorf("error reading plan file %q: %v", opts.planFilename, err)
+	}
+	executorOpts := install.ExecutorOptions{
+		GeneratedAssetsDirectory: opts.generatedAssetsDir,
+		OutputFormat:             opts.outputFormat,
+		Verbose:                  opts.verbose,
+		StallTimeout:             opts.timeouts.stallTimeout,
+		PlayTimeout:              opts.timeouts.playTimeout,
+		Timeout:                  opts.timeouts.timeout,
+	}
+	executor, err := install.NewExecutor(out, os.Stderr, executorOpts)
+	if err != nil {
+		return err
+	}
+	if err = validateSSHConnectivity(out, plan); err != nil {
+		return err
+	}
+	pki := &install.LocalPKI{
+		CACsr: filepath.Join("ansible", "playbooks", "tls", "ca-csr.json"),
+		GeneratedCertsDirectory: filepath.Join(opts.generatedAssetsDir, "keys"),
+		Log: out,
+	}
+	proxyClientCA, err := pki.GetProxyClientCA()
+	if err != nil {
+		return err
+	}
+
+	util.PrintHeader(out, "Rotate Certificates", '=')
+	var nodes []install.ListableNode
+	var stage string
+	if opts.ca {
+		stage, err = pki.RotateClusterCA(plan, proxyClientCA)
+		if err != nil {
+			return fmt.Errorf("error rotating the cluster CA: %v", err)
+		}
+		nodes, err = install.SelectNodes(*plan, nil, nil)
+		if err != nil {
+			return err
+		}
+	} else {
+		names, err := install.CertificatesToRotate(*plan, install.RotateOptions{Nodes: opts.hosts, Components: opts.components})
+		if err != nil {
+			return err
+		}
+		if len(names) == 0 {
+			return errors.New("no certificates were selected for rotation")
+		}
+		exists, err := pki.CertificateAuthorityExists()
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return errors.New("the cluster CA was not found in the generated assets directory")
+		}
+		// Reads the existing CA, and updates the CAs trusted by the nodes
+		ca, err := pki.GenerateClusterCA(plan)
+		if err != nil {
+			return err
+		}
+		if err = pki.RotateCertificates(plan, names, ca, proxyClientCA); err != nil {
+			return fmt.Errorf("error rotating certificates: %v", err)
+		}
+		nodes = install.NodesWithCertificates(*plan, names)
+	}
+	// The admin certificate, or the CA, of the kubeconfig might have changed
+	if err = install.GenerateKubeconfig(plan, opts.generatedAssetsDir); err != nil {
+		return fmt.Errorf("error generating kubeconfig file: %v", err)
+	}
+	if err = executor.RotateCertificates(*plan, nodes); err != nil {
+		return fmt.Errorf("Failed to deploy the rotated certificates: %v", err)
+	}
+	if opts.ca {
+		if err = pki.CompleteCARotationStage(); err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintln(out)
+	switch stage {
+	case install.CARotationTrust:
+		util.PrintColor(out, util.Green, "The nodes trust the current and the new CA. Run the command again to sign the certificates with the new CA.\n")
+	case install.CARotationSign:
+		util.PrintColor(out, util.Green, "The certificates are signed by the new CA. Once the clients of the cluster trust the new CA, run the command again to stop trusting the previous CA.\n")
+	default:
+		util.PrintColor(out, util.Green, "The certificates were rotated successfully!\n")
+	}
+	fmt.Fprintln(out)
+	return nil
+}
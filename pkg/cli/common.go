@@ -2,7 +2,13 @@ package cli
 
 import (
 	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
 
+	"github.com/apprenda/kismatic/pkg/install"
+	"github.com/apprenda/kismatic/pkg/util"
 	"github.com/spf13/pflag"
 )
 
@@ -10,6 +16,52 @@ func addPlanFileFlag(flagSet *pflag.FlagSet, p *string) {
 	flagSet.StringVarP(p, "plan-file", "f", "kismatic-cluster.yaml", "path to the installation plan file")
 }
 
+// timeoutOpts are the timeouts enforced on ansible runs
+type timeoutOpts struct {
+	stallTimeout time.Duration
+	playTimeout  time.Duration
+	timeout      time.Duration
+}
+
+func addTimeoutFlags(flagSet *pflag.FlagSet, t *timeoutOpts) {
+	flagSet.DurationVar(&t.stallTimeout, "stall-timeout", 30*time.Minute, "fail if no progress is reported by the nodes for this amount of time (0 to disable)")
+	flagSet.DurationVar(&t.playTimeout, "play-timeout", 0, "maximum amount of time a single play can take (0 to disable)")
+	flagSet.DurationVar(&t.timeout, "timeout", 0, "maximum amount of time each ansible run can take (0 to disable)")
+}
+
+// failureToleranceOpts are the limits on the number of worker nodes
+// that are allowed to fail
+type failureToleranceOpts struct {
+	maxFailedWorkers  int
+	maxFailPercentage int
+}
+
+func addFailureToleranceFlags(flagSet *pflag.FlagSet, f *failureToleranceOpts) {
+	flagSet.IntVar(&f.maxFailedWorkers, "max-failed-workers", 0, "maximum number of worker nodes that are allowed to fail without stopping the installation")
+	flagSet.IntVar(&f.maxFailPercentage, "max-fail-percentage", 0, "maximum percentage of worker nodes that are allowed to fail without stopping the installation")
+}
+
+func (f failureToleranceOpts) validate() error {
+	if f.maxFailedWorkers < 0 {
+		return fmt.Errorf("max-failed-workers must be greater or equal to 0, got: %d", f.maxFailedWorkers)
+	}
+	if f.maxFailPercentage < 0 || f.maxFailPercentage > 100 {
+		return fmt.Errorf("max-fail-percentage must be between 0 and 100, got: %d", f.maxFailPercentage)
+	}
+	return nil
+}
+
+// printFailedWorkers prints the worker nodes that failed, and the command
+// that can be used to retry them
+func printFailedWorkers(out io.Writer, err install.FailedWorkersError, retryCmd string) {
+	util.PrintColor(out, util.Orange, "\nThe following worker nodes failed:\n")
+	for _, h := range err.Hosts {
+		util.PrintColor(out, util.Orange, "  - %s\n", h)
+	}
+	util.PrintColor(out, util.Orange, "The list of failed nodes was recorded in %q\n", filepath.Join(err.RunDirectory, "failed-hosts"))
+	util.PrintColor(out, util.Blue, "- To retry the failed nodes: \"./%s --limit %s\"\n\n", retryCmd, strings.Join(err.Hosts, ","))
+}
+
 type planFileNotFoundErr struct {
 	filename string
 }
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
+	"time"
 
 	"github.com/apprenda/kismatic/pkg/install"
 	"github.com/apprenda/kismatic/pkg/util"
@@ -11,9 +13,12 @@ import (
 )
 
 type diagsOpts struct {
-	planFilename string
-	verbose      bool
-	outputFormat string
+	planFilename       string
+	verbose            bool
+	outputFormat       string
+	generatedAssetsDir string
+	since              time.Duration
+	runs               int
 }
 
 // NewCmdDiagnostic collects diagnostic data on remote nodes
@@ -23,6 +28,14 @@ func NewCmdDiagnostic(out io.Writer) *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "diagnose",
 		Short: "Collects diagnostics about the nodes in the cluster",
+		Long: `Collects diagnostics about the nodes in the cluster, such as the logs of the
+kubelet, docker and etcd, the state of the unhealthy resources in the cluster and
+the members of the etcd clusters. The plan file, the most recent installation runs
+and the metadata of the certificates are also collected.
+
+The diagnostics are written to a single compressed archive in the ./diagnostics directory.
+Secrets such as passwords, tokens and private keys are redacted from the archive, so that
+it can be shared.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			if len(args) != 0 {
 				return fmt.Errorf("Unexpected args: %v", args)
@@ -36,6 +49,9 @@ func NewCmdDiagnostic(out io.Writer) *cobra.Command {
 	addPlanFileFlag(cmd.PersistentFlags(), &opts.planFilename)
 	cmd.Flags().BoolVar(&opts.verbose, "verbose", false, "enable verbose logging from the installation")
 	cmd.Flags().StringVarP(&opts.outputFormat, "output", "o", "simple", "installation output format (options \"simple\"|\"raw\")")
+	cmd.Flags().StringVar(&opts.generatedAssetsDir, "generated-assets-dir", "generated", "path to the directory where assets generated during the installation process will be stored")
+	cmd.Flags().DurationVar(&opts.since, "since", 24*time.Hour, "collect the logs of the nodes that are more recent than this duration")
+	cmd.Flags().IntVar(&opts.runs, "runs", 5, "number of most recent installation runs to collect")
 
 	return cmd
 }
@@ -68,20 +84,41 @@ func doDiagnostics(out io.Writer, opts *diagsOpts) error {
 
 	// Get diagnostics from nodes
 	options := install.ExecutorOptions{
-		OutputFormat: opts.outputFormat,
-		Verbose:      opts.verbose,
+		OutputFormat:     opts.outputFormat,
+		Verbose:          opts.verbose,
+		DiagnosticsSince: opts.since,
 	}
 	executor, err := install.NewDiagnosticsExecutor(out, os.Stderr, options)
 	if err != nil {
 		return err
 	}
 
-	if err := executor.DiagnoseNodes(*plan); err != nil {
+	nodesDir, err := executor.DiagnoseNodes(*plan)
+	if err != nil {
+		return err
+	}
+
+	// Bundle everything in a single archive, and remove the diagnostics of the nodes,
+	// as they have not been redacted
+	archive := filepath.Join(filepath.Dir(nodesDir), fmt.Sprintf("diagnostics-%s.tar.gz", filepath.Base(nodesDir)))
+	bundleOpts := install.DiagnosticsBundleOptions{
+		NodesDirectory:           nodesDir,
+		PlanFile:                 planFile,
+		GeneratedAssetsDirectory: opts.generatedAssetsDir,
+		RunsDirectory:            "./runs",
+		Runs:                     opts.runs,
+	}
+	if err := install.WriteDiagnosticsBundle(*plan, bundleOpts, archive); err != nil {
+		util.PrettyPrintErr(out, "Creating diagnostics archive")
 		return err
 	}
+	if err := os.RemoveAll(nodesDir); err != nil {
+		return fmt.Errorf("error removing %s: %v", nodesDir, err)
+	}
+	util.PrettyPrintOk(out, "Creating diagnostics archive")
 
 	util.PrintColor(out, util.Green, "\nFinished gathering diagnostic information from all nodes in the cluster.\n")
-	util.PrintColor(out, util.Green, "You may find the diagnostic data in the ./diagnostics directory.\n\n")
+	util.PrintColor(out, util.Green, "You may find the diagnostic data in %s\n\n", archive)
 
 	return nil
 }
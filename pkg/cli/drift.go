@@ -0,0 +1,144 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/apprenda/kismatic/pkg/data"
+	"github.com/apprenda/kismatic/pkg/install"
+	"github.com/apprenda/kismatic/pkg/util"
+	"github.com/spf13/cobra"
+)
+
+type driftOpts struct {
+	planFilename       string
+	outputFormat       string
+	generatedAssetsDir string
+}
+
+// NewCmdDrift returns the drift command
+func NewCmdDrift(out io.Writer) *cobra.Command {
+	opts := &driftOpts{}
+	cmd := &cobra.Command{
+		Use:   "drift",
+		Short: "Compare the live state of the cluster with the plan file",
+		Long: `Compare the live state of the cluster with the plan file, and report the differences.
+
+The following are compared:
+- The labels of the nodes, including the labels set by KET
+- The flags of the kubelet, kube-proxy and control plane components, including the option overrides
+- The versions of KET and Kubernetes installed on the nodes
+- The add-ons that are enabled in the plan file
+- The NFS volumes in the plan file
+
+Flags that are not overridden in the plan file are compared with the flags of the same
+component on the other nodes. Each drift is reported along with the command that fixes it,
+which is usually a play of "kismatic install step". The command exits with a non-zero
+exit code if the cluster has drifted from the plan file.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 0 {
+				return cmd.Usage()
+			}
+			return doDrift(out, opts)
+		},
+	}
+	addPlanFileFlag(cmd.Flags(), &opts.planFilename)
+	cmd.Flags().StringVarP(&opts.outputFormat, "output", "o", "simple", `output format (options "simple"|"json")`)
+	cmd.Flags().StringVar(&opts.generatedAssetsDir, "generated-assets-dir", "generated", "path to the directory where assets generated during the installation process will be stored")
+	return cmd
+}
+
+func doDrift(out io.Writer, opts *driftOpts) error {
+	if opts.outputFormat != "simple" && opts.outputFormat != "json" {
+		return fmt.Errorf("output format %q is not supported", opts.outputFormat)
+	}
+	planner := &install.FilePlanner{File: opts.planFilename}
+	if !planner.PlanExists() {
+		return planFileNotFoundErr{filename: opts.planFilename}
+	}
+	plan, err := planner.Read()
+	if err != nil {
+		return fmt.Errorf("error reading plan file: %v", err)
+	}
+	if ok, errs := install.ValidatePlanSSHConnections(plan); !ok {
+		util.PrintValidationErrors(out, errs)
+		return fmt.Errorf("error connecting to the cluster nodes")
+	}
+	versions, err := install.ListVersions(plan)
+	if err != nil {
+		return fmt.Errorf("error listing cluster versions: %v", err)
+	}
+	clients, err := driftClients(*plan, opts.generatedAssetsDir)
+	if err != nil {
+		return err
+	}
+	report, err := install.DetectDrift(*plan, opts.generatedAssetsDir, versions.Nodes, clients)
+	if err != nil {
+		return fmt.Errorf("error detecting drift: %v", err)
+	}
+
+	if opts.outputFormat == "json" {
+		b, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return fmt.Errorf("error marshalling drift report: %v", err)
+		}
+		fmt.Fprintln(out, string(b))
+	} else {
+		if len(report.Drifts) > 0 {
+			w := tabwriter.NewWriter(out, 0, 0, 3, ' ', 0)
+			fmt.Fprint(w, "Node\tCategory\tItem\tExpected\tActual\tFix\n")
+			for _, d := range report.Drifts {
+				node := d.Node
+				if node == "" {
+					node = "-"
+				}
+				expected := d.Expected
+				if d.Source == "peers" {
+					expected += " (most nodes)"
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", node, d.Category, d.Item, expected, d.Actual, d.Fix)
+			}
+			if err := w.Flush(); err != nil {
+				return err
+			}
+			fmt.Fprintln(out)
+		}
+		for _, e := range report.Errors {
+			util.PrettyPrintWarn(out, "%s", e)
+		}
+		if len(report.Drifts) == 0 {
+			util.PrettyPrintOk(out, "The cluster matches the plan file")
+		}
+	}
+	if len(report.Drifts) > 0 {
+		return fmt.Errorf("the cluster has drifted from the plan file")
+	}
+	return nil
+}
+
+// driftClients returns the clients used to get the live state of the cluster.
+// The processes are inspected on each node that runs Kubernetes components.
+func driftClients(plan install.Plan, generatedAssetsDir string) (install.DriftClients, error) {
+	kubeClient, err := kubernetesClient(plan, generatedAssetsDir)
+	if err != nil {
+		return install.DriftClients{}, err
+	}
+	clients := install.DriftClients{
+		Kube:      kubeClient,
+		Processes: map[string]data.ProcessFlagsGetter{},
+	}
+	for _, n := range plan.GetUniqueNodes() {
+		roles := plan.GetRolesForIP(n.IP)
+		if len(roles) == 1 && roles[0] == "etcd" {
+			continue
+		}
+		client, err := plan.GetSSHClient(n.Host)
+		if err != nil {
+			return install.DriftClients{}, fmt.Errorf("error getting SSH client: %v", err)
+		}
+		clients.Processes[n.Host] = data.RemoteProcesses{SSHClient: client}
+	}
+	return clients, nil
+}
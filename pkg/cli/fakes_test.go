@@ -56,7 +56,39 @@ func (fe *fakeExecutor) RunUpgradePreFlightCheck(*install.Plan, install.Listable
 	return nil
 }
 
-func (fe *fakeExecutor) UpgradeNodes(install.Plan, []install.ListableNode, bool, int, bool) error {
+func (fe *fakeExecutor) UpgradeNodes(install.Plan, []install.ListableNode, bool, install.UpgradeStrategy, bool) error {
+	return nil
+}
+
+func (fe *fakeExecutor) RebootNodes(install.Plan, []install.ListableNode, install.RebootOptions) error {
+	return nil
+}
+
+func (fe *fakeExecutor) RotateCertificates(install.Plan, []install.ListableNode) error {
+	return nil
+}
+
+func (fe *fakeExecutor) DeployCRL(install.Plan) error {
+	return nil
+}
+
+func (fe *fakeExecutor) BindUser(install.Plan, install.User) error {
+	return nil
+}
+
+func (fe *fakeExecutor) UnbindUser(install.Plan, string) error {
+	return nil
+}
+
+func (fe *fakeExecutor) CordonNodes(install.Plan, ...string) error {
+	return nil
+}
+
+func (fe *fakeExecutor) DrainNodes(install.Plan, ...string) error {
+	return nil
+}
+
+func (fe *fakeExecutor) UncordonNodes(install.Plan, ...string) error {
 	return nil
 }
 
@@ -109,7 +141,7 @@ func (fp *fakePKI) GenerateClusterCerts(p *install.Plan, ca *tls.CA, users []str
 	return fp.err
 }
 
-func (fp *fakePKI) GenerateCertificate(name string, validityPeriod string, commonName string, subjectAlternateNames []string, organizations []string, ca *tls.CA, overwrite bool) (bool, error) {
+func (fp *fakePKI) GenerateCertificate(p *install.Plan, name string, validityPeriod string, commonName string, subjectAlternateNames []string, organizations []string, ca *tls.CA, overwrite bool) (bool, error) {
 	fp.called = true
 	return false, fp.err
 }
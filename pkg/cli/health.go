@@ -0,0 +1,112 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/apprenda/kismatic/pkg/data"
+	"github.com/apprenda/kismatic/pkg/install"
+	"github.com/apprenda/kismatic/pkg/util"
+	"github.com/spf13/cobra"
+)
+
+type healthOpts struct {
+	planFilename       string
+	outputFormat       string
+	generatedAssetsDir string
+}
+
+// NewCmdHealth returns the health command
+func NewCmdHealth(out io.Writer) *cobra.Command {
+	opts := &healthOpts{}
+	cmd := &cobra.Command{
+		Use:   "health",
+		Short: "Check the health of the cluster",
+		Long: `Check the health of the etcd cluster, the control plane, the nodes, the add-ons
+and the certificates of the cluster.
+
+Each check is reported as "pass", "warn" or "fail". The command exits with a non-zero
+exit code if any of the checks failed.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 0 {
+				return cmd.Usage()
+			}
+			return doHealth(out, opts)
+		},
+	}
+	addPlanFileFlag(cmd.Flags(), &opts.planFilename)
+	cmd.Flags().StringVarP(&opts.outputFormat, "output", "o", "simple", `output format (options "simple"|"json")`)
+	cmd.Flags().StringVar(&opts.generatedAssetsDir, "generated-assets-dir", "generated", "path to the directory where assets generated during the installation process will be stored")
+	return cmd
+}
+
+func doHealth(out io.Writer, opts *healthOpts) error {
+	if opts.outputFormat != "simple" && opts.outputFormat != "json" {
+		return fmt.Errorf("output format %q is not supported", opts.outputFormat)
+	}
+	planner := &install.FilePlanner{File: opts.planFilename}
+	if !planner.PlanExists() {
+		return planFileNotFoundErr{filename: opts.planFilename}
+	}
+	plan, err := planner.Read()
+	if err != nil {
+		return fmt.Errorf("error reading plan file: %v", err)
+	}
+	if ok, errs := install.ValidatePlanSSHConnections(plan); !ok {
+		util.PrintValidationErrors(out, errs)
+		return fmt.Errorf("error connecting to the cluster nodes")
+	}
+	clients, err := healthClients(*plan, opts.generatedAssetsDir)
+	if err != nil {
+		return err
+	}
+
+	report := install.CheckClusterHealth(*plan, clients, filepath.Join(opts.generatedAssetsDir, "keys"), time.Now())
+	if opts.outputFormat == "json" {
+		b, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return fmt.Errorf("error marshalling health report: %v", err)
+		}
+		fmt.Fprintln(out, string(b))
+	} else {
+		w := tabwriter.NewWriter(out, 0, 0, 3, ' ', 0)
+		fmt.Fprint(w, "Component\tCheck\tStatus\tMessage\n")
+		for _, c := range report.Checks {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", c.Component, c.Check, c.Status, c.Message)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		fmt.Fprintln(out)
+		fmt.Fprintf(out, "Cluster health: %s\n", report.Status)
+	}
+	if report.Status == install.HealthFail {
+		return fmt.Errorf("the cluster is not healthy")
+	}
+	return nil
+}
+
+// healthClients returns the clients used by the health checks. Etcd is queried
+// on each etcd node.
+func healthClients(plan install.Plan, generatedAssetsDir string) (install.HealthClients, error) {
+	kubeClient, err := kubernetesClient(plan, generatedAssetsDir)
+	if err != nil {
+		return install.HealthClients{}, err
+	}
+	clients := install.HealthClients{
+		Kube: kubeClient,
+		Etcd: map[string]data.EtcdMemberStatusGetter{},
+	}
+	for _, n := range plan.Etcd.Nodes {
+		client, err := plan.GetSSHClient(n.Host)
+		if err != nil {
+			return install.HealthClients{}, fmt.Errorf("error getting SSH client: %v", err)
+		}
+		clients.Etcd[n.Host] = data.RemoteEtcd{SSHClient: client, Port: 2379, CertDir: "/etc/etcd_k8s"}
+	}
+	return clients, nil
+}
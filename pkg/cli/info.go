@@ -13,8 +13,9 @@ import (
 )
 
 type infoOpts struct {
-	planFilename string
-	outputFormat string
+	planFilename       string
+	outputFormat       string
+	generatedAssetsDir string
 }
 
 // NewCmdInfo returns the info command
@@ -25,13 +26,18 @@ func NewCmdInfo(out io.Writer) *cobra.Command {
 		Short: "Display info about nodes in the cluster",
 		Long: `will list the nodes that make up the cluster, along with their current versions & roles.
 
-This will be retrieved by connecting to each node via ssh`,
+This will be retrieved by connecting to each node via ssh. The operating system, kernel,
+docker, etcd and CNI versions, the kubelet status and the capacity of each node are also
+collected. Facts that differ from the plan file, or from most of the other nodes, are highlighted.
+Nodes that were taken out of rotation with "kismatic nodes cordon" or "kismatic nodes drain"
+are listed as being in maintenance.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			return list(out, opts)
 		},
 	}
 	cmd.Flags().StringVarP(&opts.planFilename, "plan-file", "f", "kismatic-cluster.yaml", "path to the installation plan file")
 	cmd.Flags().StringVarP(&opts.outputFormat, "output", "o", "simple", `output format (options "simple"|"json")`)
+	cmd.Flags().StringVar(&opts.generatedAssetsDir, "generated-assets-dir", "generated", "path to the directory where assets generated during the installation process will be stored")
 	return cmd
 }
 
@@ -62,6 +68,16 @@ func list(out io.Writer, opts *infoOpts) error {
 	if err != nil {
 		return fmt.Errorf("error getting version: %v", err)
 	}
+	maintenance, err := install.ReadMaintenanceState(install.MaintenanceStateFile(opts.generatedAssetsDir))
+	if err != nil {
+		return err
+	}
+	for i, n := range lv.Nodes {
+		if m, ok := maintenance[n.Node.Host]; ok {
+			lv.Nodes[i].Maintenance = &m
+		}
+	}
+	install.GatherNodeFacts(plan, lv.Nodes)
 
 	if opts.outputFormat == "json" {
 		b, err := json.MarshalIndent(lv, "", "  ")
@@ -81,9 +97,93 @@ func list(out io.Writer, opts *infoOpts) error {
 	fmt.Fprintln(out)
 	fmt.Fprintf(out, "Nodes:\n")
 	w := tabwriter.NewWriter(out, 0, 0, 3, ' ', 0)
-	fmt.Fprint(w, "Name\tIP\tRoles\tKismatic Version\n")
+	fmt.Fprint(w, "Name\tIP\tRoles\tKismatic Version\tMaintenance\n")
 	for _, listNode := range lv.Nodes {
-		fmt.Fprintf(w, "%v\t%v\t%v\t%v\n", listNode.Node.Host, listNode.Node.IP, strings.Join(listNode.Roles, ","), listNode.Version)
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\n", listNode.Node.Host, listNode.Node.IP, strings.Join(listNode.Roles, ","), listNode.Version, maintenanceSummary(listNode.Maintenance))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintln(out)
+	return printNodeFacts(out, lv.Nodes)
+}
+
+func printNodeFacts(out io.Writer, nodes []install.ListableNode) error {
+	fmt.Fprintf(out, "Node Facts:\n")
+	w := tabwriter.NewWriter(out, 0, 0, 3, ' ', 0)
+	fmt.Fprint(w, "Name\tOS\tKernel\tDocker\tStorage Driver\tEtcd\tCNI\tKubelet\tCPUs\tMemory\tDisk (free/total)\n")
+	differences := false
+	for _, n := range nodes {
+		f := n.Facts
+		if f == nil {
+			continue
+		}
+		if f.Error != "" {
+			fmt.Fprintf(w, "%s\t%s\n", n.Node.Host, f.Error)
+			continue
+		}
+		differences = differences || len(f.Differences) > 0
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%d\t%s\t%s/%s\n",
+			n.Node.Host,
+			factValue(f, install.FactOS, f.OS.String()),
+			factValue(f, install.FactKernel, f.Kernel),
+			factValue(f, install.FactDockerVersion, f.Docker.Version),
+			factValue(f, install.FactDockerStorageDriver, f.Docker.StorageDriver),
+			factValue(f, install.FactEtcdVersion, f.EtcdVersion),
+			factValue(f, install.FactCNIVersion, f.CNIVersion),
+			factValue(f, install.FactKubeletStatus, f.KubeletStatus),
+			f.CPUs,
+			formatBytes(f.MemoryBytes),
+			formatBytes(f.DiskAvailableBytes),
+			formatBytes(f.DiskBytes))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if !differences {
+		return nil
+	}
+	fmt.Fprintln(out)
+	fmt.Fprintf(out, "Differences (marked with *):\n")
+	for _, n := range nodes {
+		if n.Facts == nil {
+			continue
+		}
+		for _, d := range n.Facts.Differences {
+			fmt.Fprintf(out, "- %s: %s\n", n.Node.Host, d)
+		}
+	}
+	return nil
+}
+
+// factValue returns the value of the fact, marked with an asterisk if it differs
+// from the plan or from the other nodes
+func factValue(f *install.NodeFacts, fact, value string) string {
+	if value == "" {
+		value = "-"
+	}
+	if f.Differs(fact) {
+		return value + "*"
+	}
+	return value
+}
+
+func formatBytes(b uint64) string {
+	const gib = 1 << 30
+	const mib = 1 << 20
+	if b >= gib {
+		return fmt.Sprintf("%.1fGiB", float64(b)/gib)
+	}
+	return fmt.Sprintf("%.0fMiB", float64(b)/mib)
+}
+
+func maintenanceSummary(m *install.NodeMaintenance) string {
+	if m == nil {
+		return "-"
+	}
+	summary := fmt.Sprintf("%s since %s", m.State, m.Since.Format("2006-01-02 15:04"))
+	if m.Reason != "" {
+		summary = fmt.Sprintf("%s (%s)", summary, m.Reason)
 	}
-	return w.Flush()
+	return summary
 }
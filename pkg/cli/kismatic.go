@@ -27,9 +27,15 @@ more documentation is available at https://github.com/apprenda/kismatic`,
 	cmd.AddCommand(NewCmdDashboard(in, out))
 	cmd.AddCommand(NewCmdSSH(out))
 	cmd.AddCommand(NewCmdInfo(out))
+	cmd.AddCommand(NewCmdHealth(out))
+	cmd.AddCommand(NewCmdCapacity(out))
+	cmd.AddCommand(NewCmdDrift(out))
 	cmd.AddCommand(NewCmdUpgrade(in, out))
+	cmd.AddCommand(NewCmdNodes(in, out))
 	cmd.AddCommand(NewCmdDiagnostic(out))
+	cmd.AddCommand(NewCmdAnalyze(out))
 	cmd.AddCommand(NewCmdCertificates(out))
+	cmd.AddCommand(NewCmdKubeconfig(out))
 	cmd.AddCommand(NewCmdSeedRegistry(out, stderr))
 
 	return cmd, nil
@@ -0,0 +1,123 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/apprenda/kismatic/pkg/install"
+	"github.com/apprenda/kismatic/pkg/util"
+	"github.com/spf13/cobra"
+)
+
+type kubeconfigCreateOpts struct {
+	planFilename       string
+	generatedAssetsDir string
+	user               string
+	groups             []string
+	expiry             string
+	role               string
+	namespace          string
+	overwrite          bool
+	verbose            bool
+	outputFormat       string
+}
+
+// NewCmdKubeconfigCreate creates a new kubeconfig create command
+func NewCmdKubeconfigCreate(out io.Writer) *cobra.Command {
+	opts := &kubeconfigCreateOpts{}
+	cmd := &cobra.Command{
+		Use:   "create",
+		Short: "Create a kubeconfig file for a user of the cluster",
+		Long: `Create a kubeconfig file for a user of the cluster.
+
+A client certificate is issued for the user by the cluster CA, with the groups of the user as its organizations,
+and a kubeconfig file that uses the certificate is written to "<generated-assets-dir>/<user>-kubeconfig".
+
+With --role, the ClusterRole is bound to the user in all namespaces, or in the namespace set with --namespace.
+The users declared in the plan file are created, and their roles are bound, by "kismatic install apply".
+`,
+		Example: `  # Create a kubeconfig file for alice, that can view the resources of the dev namespace
+  kismatic kubeconfig create --user alice --group dev-team --expiry 720h --role view --namespace dev
+`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 0 {
+				return fmt.Errorf("Unexpected args: %v", args)
+			}
+			return doKubeconfigCreate(out, opts)
+		},
+	}
+	addPlanFileFlag(cmd.Flags(), &opts.planFilename)
+	cmd.Flags().StringVar(&opts.generatedAssetsDir, "generated-assets-dir", "generated", "path to the directory where assets generated during the installation process will be stored")
+	cmd.Flags().StringVar(&opts.user, "user", "", "name of the user, set as the common name of its certificate")
+	cmd.Flags().StringSliceVar(&opts.groups, "group", []string{}, "comma-separated list of the groups of the user, set as the organizations of its certificate")
+	cmd.Flags().StringVar(&opts.expiry, "expiry", "", "validity period of the certificate of the user. If left blank, will use the certificate expiry of the plan file")
+	cmd.Flags().StringVar(&opts.role, "role", "", "name of the ClusterRole to bind to the user")
+	cmd.Flags().StringVar(&opts.namespace, "namespace", "", "namespace where the role is bound to the user. If left blank, the role is bound in all namespaces")
+	cmd.Flags().BoolVar(&opts.overwrite, "overwrite", false, "issue a new certificate if the user already has one")
+	cmd.Flags().BoolVar(&opts.verbose, "verbose", false, "enable verbose logging")
+	cmd.Flags().StringVarP(&opts.outputFormat, "output", "o", "simple", "installation output format (options \"simple\"|\"raw\")")
+	return cmd
+}
+
+func doKubeconfigCreate(out io.Writer, opts *kubeconfigCreateOpts) error {
+	if opts.user == "" {
+		return errors.New("--user is required")
+	}
+	if opts.namespace != "" && opts.role == "" {
+		return errors.New("--namespace requires --role")
+	}
+	planner := &install.FilePlanner{File: opts.planFilename}
+	if !planner.PlanExists() {
+		return planFileNotFoundErr{filename: opts.planFilename}
+	}
+	plan, err := planner.Read()
+	if err != nil {
+		return fmt.Errorf("error reading plan file: %v", err)
+	}
+	expiry := opts.expiry
+	if expiry == "" {
+		expiry = plan.Cluster.Certificates.Expiry
+	}
+	pki := &install.LocalPKI{
+		CACsr:                   filepath.Join("ansible", "playbooks", "tls", "ca-csr.json"),
+		GeneratedCertsDirectory: filepath.Join(opts.generatedAssetsDir, "keys"),
+		Log:                     out,
+	}
+	user := install.User{
+		Name:      opts.user,
+		Groups:    opts.groups,
+		Role:      opts.role,
+		Namespace: opts.namespace,
+	}
+
+	util.PrintHeader(out, "Create Kubeconfig", '=')
+	file, err := install.CreateUser(plan, pki, opts.generatedAssetsDir, user, expiry, opts.overwrite)
+	if err != nil {
+		return err
+	}
+	util.PrettyPrintOk(out, "Generated kubeconfig file %q", file)
+	if user.Role != "" {
+		executorOpts := install.ExecutorOptions{
+			GeneratedAssetsDirectory: opts.generatedAssetsDir,
+			OutputFormat:             opts.outputFormat,
+			Verbose:                  opts.verbose,
+		}
+		executor, err := install.NewExecutor(out, os.Stderr, executorOpts)
+		if err != nil {
+			return err
+		}
+		if err = validateSSHConnectivity(out, plan); err != nil {
+			return err
+		}
+		if err = executor.BindUser(*plan, user); err != nil {
+			return fmt.Errorf("Failed to bind the role of the user: %v", err)
+		}
+	}
+	fmt.Fprintln(out)
+	util.PrintColor(out, util.Green, "Use the kubeconfig file with \"./kubectl --kubeconfig %s\"\n", file)
+	fmt.Fprintln(out)
+	return nil
+}
@@ -0,0 +1,79 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/apprenda/kismatic/pkg/install"
+	"github.com/apprenda/kismatic/pkg/util"
+	"github.com/spf13/cobra"
+)
+
+type kubeconfigMergeOpts struct {
+	planFilename       string
+	generatedAssetsDir string
+	target             string
+	setCurrent         bool
+}
+
+// NewCmdKubeconfigMerge creates a new kubeconfig merge command
+func NewCmdKubeconfigMerge(out io.Writer) *cobra.Command {
+	opts := &kubeconfigMergeOpts{}
+	cmd := &cobra.Command{
+		Use:   "merge",
+		Short: "Merge the kubeconfig of the cluster into an existing kubeconfig file",
+		Long: `Merge the kubeconfig of the cluster into an existing kubeconfig file, such as the one used by kubectl.
+
+The cluster, user and context entries of the cluster are named after the name of the cluster in the plan file,
+and are inserted in the target kubeconfig file, or updated if they already exist. The other entries of the target
+are left untouched, and the previous target is backed up with the ".bak" extension.
+
+The target defaults to the first file of the KUBECONFIG environment variable, or to "~/.kube/config".
+`,
+		Example: `  # Merge the kubeconfig of the cluster into ~/.kube/config, and switch kubectl to the cluster
+  kismatic kubeconfig merge --set-current
+`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 0 {
+				return fmt.Errorf("Unexpected args: %v", args)
+			}
+			return doKubeconfigMerge(out, opts)
+		},
+	}
+	addPlanFileFlag(cmd.Flags(), &opts.planFilename)
+	cmd.Flags().StringVar(&opts.generatedAssetsDir, "generated-assets-dir", "generated", "path to the directory where assets generated during the installation process will be stored")
+	cmd.Flags().StringVar(&opts.target, "target", "", "path to the kubeconfig file to merge into. If left blank, will use the kubeconfig file of kubectl")
+	cmd.Flags().BoolVar(&opts.setCurrent, "set-current", false, "set the context of the cluster as the current context of the target")
+	return cmd
+}
+
+func doKubeconfigMerge(out io.Writer, opts *kubeconfigMergeOpts) error {
+	plan, target, err := readKubeconfigTarget(opts.planFilename, opts.target)
+	if err != nil {
+		return err
+	}
+	if err = install.MergeKubeconfig(plan, opts.generatedAssetsDir, target, opts.setCurrent); err != nil {
+		return err
+	}
+	util.PrettyPrintOk(out, "Merged the kubeconfig of cluster %q into %q", plan.Cluster.Name, target)
+	return nil
+}
+
+// readKubeconfigTarget reads the plan file, and returns the kubeconfig file to update
+func readKubeconfigTarget(planFilename string, target string) (*install.Plan, string, error) {
+	planner := &install.FilePlanner{File: planFilename}
+	if !planner.PlanExists() {
+		return nil, "", planFileNotFoundErr{filename: planFilename}
+	}
+	plan, err := planner.Read()
+	if err != nil {
+		return nil, "", fmt.Errorf("error reading plan file: %v", err)
+	}
+	if target == "" {
+		target, err = install.DefaultKubeconfigTarget()
+		if err != nil {
+			return nil, "", err
+		}
+	}
+	return plan, target, nil
+}
@@ -0,0 +1,56 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/apprenda/kismatic/pkg/install"
+	"github.com/apprenda/kismatic/pkg/util"
+	"github.com/spf13/cobra"
+)
+
+type kubeconfigRemoveOpts struct {
+	planFilename string
+	target       string
+}
+
+// NewCmdKubeconfigRemove creates a new kubeconfig remove command
+func NewCmdKubeconfigRemove(out io.Writer) *cobra.Command {
+	opts := &kubeconfigRemoveOpts{}
+	cmd := &cobra.Command{
+		Use:   "remove",
+		Short: "Remove the kubeconfig of the cluster from an existing kubeconfig file",
+		Long: `Remove the cluster, user and context entries of the cluster, that were merged with "kismatic kubeconfig merge",
+from an existing kubeconfig file. The other entries of the target are left untouched, and the previous target is backed up
+with the ".bak" extension. The current context of the target is cleared if it is the context of the cluster.
+
+The target defaults to the first file of the KUBECONFIG environment variable, or to "~/.kube/config".
+`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 0 {
+				return fmt.Errorf("Unexpected args: %v", args)
+			}
+			return doKubeconfigRemove(out, opts)
+		},
+	}
+	addPlanFileFlag(cmd.Flags(), &opts.planFilename)
+	cmd.Flags().StringVar(&opts.target, "target", "", "path to the kubeconfig file to remove the cluster from. If left blank, will use the kubeconfig file of kubectl")
+	return cmd
+}
+
+func doKubeconfigRemove(out io.Writer, opts *kubeconfigRemoveOpts) error {
+	plan, target, err := readKubeconfigTarget(opts.planFilename, opts.target)
+	if err != nil {
+		return err
+	}
+	removed, err := install.RemoveKubeconfig(plan, target)
+	if err != nil {
+		return err
+	}
+	if !removed {
+		util.PrettyPrintWarn(out, "The kubeconfig of cluster %q was not found in %q", plan.Cluster.Name, target)
+		return nil
+	}
+	util.PrettyPrintOk(out, "Removed the kubeconfig of cluster %q from %q", plan.Cluster.Name, target)
+	return nil
+}
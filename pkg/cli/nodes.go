@@ -0,0 +1,117 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"github.com/apprenda/kismatic/pkg/data"
+	"github.com/apprenda/kismatic/pkg/install"
+	"github.com/apprenda/kismatic/pkg/util"
+	"github.com/spf13/cobra"
+)
+
+// NewCmdNodes returns the nodes command
+func NewCmdNodes(in io.Reader, out io.Writer) *cobra.Command {
+	var planFile string
+	cmd := &cobra.Command{
+		Use:   "nodes",
+		Short: "perform maintenance on the nodes of your Kubernetes cluster",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cmd.Usage()
+		},
+	}
+	addPlanFileFlag(cmd.PersistentFlags(), &planFile)
+	cmd.AddCommand(NewCmdNodesCordon(out, &planFile))
+	cmd.AddCommand(NewCmdNodesDrain(in, out, &planFile))
+	cmd.AddCommand(NewCmdNodesUncordon(out, &planFile))
+	cmd.AddCommand(NewCmdNodesReboot(in, out, &planFile))
+	return cmd
+}
+
+// kubeClient is implemented by both the API client and kubectl over SSH
+type kubeClient interface {
+	data.PodLister
+	data.PVLister
+	data.PersistentVolumeGetter
+	data.PersistentVolumeClaimGetter
+	data.DaemonSetGetter
+	data.DaemonSetLister
+	data.ReplicationControllerGetter
+	data.ReplicaSetGetter
+	data.StatefulSetGetter
+	data.DeploymentGetter
+	data.DeploymentLister
+	data.EndpointsGetter
+	data.APIServerHealthGetter
+	data.PodDisruptionBudgetLister
+	data.NodeLister
+}
+
+// kubernetesClient returns a client that talks to the API server through the
+// load balancer, using the admin certificate in the generated assets directory.
+// Kubectl is run on the first master node over SSH when the API server is not
+// reachable from this machine.
+func kubernetesClient(plan install.Plan, generatedAssetsDir string) (kubeClient, error) {
+	keysDir := filepath.Join(generatedAssetsDir, "keys")
+	server := fmt.Sprintf("https://%s:6443", plan.Master.LoadBalancedFQDN)
+	apiClient, err := data.NewAPIClient(server, filepath.Join(keysDir, "ca.pem"), filepath.Join(keysDir, "admin.pem"), filepath.Join(keysDir, "admin-key.pem"))
+	if err == nil && apiClient.Reachable() {
+		return apiClient, nil
+	}
+	client, err := plan.GetSSHClient(plan.Master.Nodes[0].Host)
+	if err != nil {
+		return nil, fmt.Errorf("error getting SSH client: %v", err)
+	}
+	return data.RemoteKubectl{SSHClient: client}, nil
+}
+
+// glusterClient is implemented by the gluster CLI over SSH
+type glusterClient interface {
+	data.GlusterClient
+	data.GlusterVolumeStatusLister
+	data.GlusterHealInfoGetter
+}
+
+// upgradeSafetyClients returns the clients used by the upgrade safety checks.
+// The first storage node is used for running the gluster CLI. The gluster
+// client is nil when the cluster does not have storage nodes.
+func upgradeSafetyClients(plan install.Plan, generatedAssetsDir string) (kubeClient, glusterClient, error) {
+	kubeClient, err := kubernetesClient(plan, generatedAssetsDir)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(plan.Storage.Nodes) == 0 {
+		return kubeClient, nil, nil
+	}
+	storageClient, err := plan.GetSSHClient(plan.Storage.Nodes[0].Host)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error getting SSH client: %v", err)
+	}
+	return kubeClient, data.RemoteGlusterCLI{SSHClient: storageClient}, nil
+}
+
+// printSafetyReport prints the unsafe conditions detected on each node, and
+// returns true if any were found
+func printSafetyReport(out io.Writer, nodes []install.ListableNode, nodeErrs map[string][]error, ignoreSafetyChecks bool) bool {
+	unsafe := false
+	for _, node := range nodes {
+		util.PrettyPrint(out, "%s %v", node.Node.Host, node.Roles)
+		errs := nodeErrs[node.Node.Host]
+		if len(errs) == 0 {
+			util.PrintOkln(out)
+			continue
+		}
+		unsafe = true
+		if ignoreSafetyChecks {
+			util.PrintWarn(out)
+		} else {
+			util.PrintError(out)
+		}
+		fmt.Fprintln(out)
+		for _, err := range errs {
+			fmt.Fprintln(out, "-", err.Error())
+		}
+	}
+	return unsafe
+}
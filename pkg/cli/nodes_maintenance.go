@@ -0,0 +1,235 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/apprenda/kismatic/pkg/install"
+	"github.com/apprenda/kismatic/pkg/util"
+	"github.com/spf13/cobra"
+)
+
+type nodesMaintenanceOpts struct {
+	reason             string
+	ignoreSafetyChecks bool
+	generatedAssetsDir string
+	verbose            bool
+	outputFormat       string
+}
+
+func addNodesMaintenanceFlags(cmd *cobra.Command, opts *nodesMaintenanceOpts) {
+	cmd.Flags().StringVar(&opts.generatedAssetsDir, "generated-assets-dir", "generated", "path to the directory where assets generated during the installation process will be stored")
+	cmd.Flags().BoolVar(&opts.verbose, "verbose", false, "enable verbose logging")
+	cmd.Flags().StringVarP(&opts.outputFormat, "output", "o", "simple", "installation output format (options \"simple\"|\"raw\")")
+}
+
+// NewCmdNodesCordon returns the command for cordoning a node
+func NewCmdNodesCordon(out io.Writer, planFile *string) *cobra.Command {
+	opts := nodesMaintenanceOpts{}
+	cmd := &cobra.Command{
+		Use:   "cordon HOST",
+		Short: "mark a node as unschedulable",
+		Long: `Mark a node as unschedulable.
+
+New workloads are not scheduled on the node, but the workloads that are already
+running on the node are left untouched. The node is recorded as being in maintenance.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				return errors.New("the hostname of the node must be provided as the only argument to cordon")
+			}
+			return doNodesMaintenance(out, *planFile, args[0], install.MaintenanceCordoned, opts)
+		},
+	}
+	cmd.Flags().StringVar(&opts.reason, "reason", "", "the reason the node is being taken out of rotation")
+	addNodesMaintenanceFlags(cmd, &opts)
+	return cmd
+}
+
+// NewCmdNodesDrain returns the command for draining a node
+func NewCmdNodesDrain(in io.Reader, out io.Writer, planFile *string) *cobra.Command {
+	opts := nodesMaintenanceOpts{}
+	cmd := &cobra.Command{
+		Use:   "drain HOST",
+		Short: "evict the workloads running on a node, and mark it as unschedulable",
+		Long: `Evict the workloads running on a node, and mark it as unschedulable.
+
+The safety checks performed during an online upgrade are run before draining the node.
+If any unsafe condition is detected, a report is printed, and the node is not drained
+unless confirmed. The node is recorded as being in maintenance.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				return errors.New("the hostname of the node must be provided as the only argument to drain")
+			}
+			return doNodesDrain(in, out, *planFile, args[0], opts)
+		},
+	}
+	cmd.Flags().StringVar(&opts.reason, "reason", "", "the reason the node is being taken out of rotation")
+	cmd.Flags().BoolVar(&opts.ignoreSafetyChecks, "ignore-safety-checks", false, "ignore safety checks and continue draining the node")
+	addNodesMaintenanceFlags(cmd, &opts)
+	return cmd
+}
+
+// NewCmdNodesUncordon returns the command for uncordoning a node
+func NewCmdNodesUncordon(out io.Writer, planFile *string) *cobra.Command {
+	opts := nodesMaintenanceOpts{}
+	cmd := &cobra.Command{
+		Use:   "uncordon HOST",
+		Short: "mark a node as schedulable",
+		Long:  `Mark a node as schedulable, and remove the record of the node being in maintenance.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				return errors.New("the hostname of the node must be provided as the only argument to uncordon")
+			}
+			return doNodesMaintenance(out, *planFile, args[0], "", opts)
+		},
+	}
+	addNodesMaintenanceFlags(cmd, &opts)
+	return cmd
+}
+
+func readMaintenancePlan(planFile string, host string) (*install.Plan, *install.Node, error) {
+	planner := install.FilePlanner{File: planFile}
+	if !planner.PlanExists() {
+		return nil, nil, planFileNotFoundErr{filename: planFile}
+	}
+	plan, err := planner.Read()
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reading plan file %q: %v", planFile, err)
+	}
+	for _, n := range plan.GetUniqueNodes() {
+		if n.Host == host {
+			return plan, &n, nil
+		}
+	}
+	return nil, nil, fmt.Errorf("node %q is not in the plan file", host)
+}
+
+func newMaintenanceExecutor(out io.Writer, opts nodesMaintenanceOpts) (install.Executor, error) {
+	return install.NewExecutor(out, os.Stderr, install.ExecutorOptions{
+		GeneratedAssetsDirectory: opts.generatedAssetsDir,
+		OutputFormat:             opts.outputFormat,
+		Verbose:                  opts.verbose,
+	})
+}
+
+// doNodesMaintenance cordons the node, or uncordons it when the state is empty
+func doNodesMaintenance(out io.Writer, planFile string, host string, state string, opts nodesMaintenanceOpts) error {
+	plan, node, err := readMaintenancePlan(planFile, host)
+	if err != nil {
+		return err
+	}
+	if err = validateMaintenanceNode(plan, node); err != nil {
+		return err
+	}
+	executor, err := newMaintenanceExecutor(out, opts)
+	if err != nil {
+		return err
+	}
+	stateFile := install.MaintenanceStateFile(opts.generatedAssetsDir)
+	if state == "" {
+		if err = executor.UncordonNodes(*plan, host); err != nil {
+			return fmt.Errorf("error uncordoning node %q: %v", host, err)
+		}
+		if err = install.ClearMaintenance(stateFile, host); err != nil {
+			return err
+		}
+		util.PrettyPrintOk(out, "Node %q is back in rotation", host)
+		return nil
+	}
+	if err = executor.CordonNodes(*plan, host); err != nil {
+		return fmt.Errorf("error cordoning node %q: %v", host, err)
+	}
+	return recordNodeMaintenance(out, stateFile, host, state, opts.reason)
+}
+
+func doNodesDrain(in io.Reader, out io.Writer, planFile string, host string, opts nodesMaintenanceOpts) error {
+	plan, node, err := readMaintenancePlan(planFile, host)
+	if err != nil {
+		return err
+	}
+	if err = validateMaintenanceNode(plan, node); err != nil {
+		return err
+	}
+	executor, err := newMaintenanceExecutor(out, opts)
+	if err != nil {
+		return err
+	}
+
+	util.PrintHeader(out, "Validate Node Drain", '=')
+	kubeClient, glusterClient, err := upgradeSafetyClients(*plan, opts.generatedAssetsDir)
+	if err != nil {
+		return err
+	}
+	listableNode := install.ListableNode{Node: *node, Roles: plan.GetRolesForIP(node.IP)}
+	errs := install.DetectNodeUpgradeSafety(*plan, *node, kubeClient, glusterClient)
+	unsafe := printSafetyReport(out, []install.ListableNode{listableNode}, map[string][]error{host: errs}, opts.ignoreSafetyChecks)
+	if unsafe && !opts.ignoreSafetyChecks {
+		fmt.Fprintln(out)
+		ans, err := util.PromptForString(in, out, "Unsafe conditions detected, drain the node anyway?", "N", []string{"N", "y"})
+		if err != nil {
+			return fmt.Errorf("error getting user response: %v", err)
+		}
+		if strings.ToLower(ans) != "y" {
+			return errors.New("Unable to drain the node due to the unsafe conditions detected.")
+		}
+	}
+
+	if err = executor.DrainNodes(*plan, host); err != nil {
+		return fmt.Errorf("error draining node %q: %v", host, err)
+	}
+	// The drain playbook does not fail when kubectl drain does, so verify that
+	// the workloads were evicted before recording the node as drained
+	pods, err := kubeClient.ListPods()
+	if err != nil {
+		return fmt.Errorf("error verifying that node %q was drained: %v", host, err)
+	}
+	if left := install.EvictablePods(pods, host); len(left) > 0 {
+		return fmt.Errorf("node %q was not drained, %d pod(s) are still running on it: %s", host, len(left), strings.Join(left, ", "))
+	}
+	return recordNodeMaintenance(out, install.MaintenanceStateFile(opts.generatedAssetsDir), host, install.MaintenanceDrained, opts.reason)
+}
+
+// validateMaintenanceNode returns an error if the node cannot be taken out of
+// rotation. Only worker nodes are schedulable.
+func validateMaintenanceNode(plan *install.Plan, node *install.Node) error {
+	if !util.Contains("worker", plan.GetRolesForIP(node.IP)) {
+		return fmt.Errorf("%q is not a worker node, and is never schedulable", node.Host)
+	}
+	return nil
+}
+
+func recordNodeMaintenance(out io.Writer, stateFile string, host string, state string, reason string) error {
+	m := install.NodeMaintenance{
+		Node:   host,
+		State:  state,
+		Reason: reason,
+		Since:  time.Now(),
+	}
+	if err := install.RecordMaintenance(stateFile, m); err != nil {
+		return err
+	}
+	util.PrettyPrintOk(out, "Node %q is %s, and out of rotation", host, state)
+	return nil
+}
+
+// clearNodeMaintenance removes the record of the nodes being in maintenance,
+// once they have been put back in rotation
+func clearNodeMaintenance(out io.Writer, generatedAssetsDir string, nodes []install.ListableNode) error {
+	stateFile := install.MaintenanceStateFile(generatedAssetsDir)
+	state, err := install.ReadMaintenanceState(stateFile)
+	if err != nil {
+		return err
+	}
+	hosts := []string{}
+	for _, n := range nodes {
+		if _, ok := state[n.Node.Host]; ok {
+			util.PrettyPrintOk(out, "Node %q is back in rotation", n.Node.Host)
+			hosts = append(hosts, n.Node.Host)
+		}
+	}
+	return install.ClearMaintenance(stateFile, hosts...)
+}
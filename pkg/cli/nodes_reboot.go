@@ -0,0 +1,146 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/apprenda/kismatic/pkg/install"
+	"github.com/apprenda/kismatic/pkg/util"
+	"github.com/spf13/cobra"
+)
+
+type nodesRebootOpts struct {
+	roles              []string
+	hosts              []string
+	maxParallel        int
+	command            string
+	ignoreSafetyChecks bool
+	dryRun             bool
+	generatedAssetsDir string
+	verbose            bool
+	outputFormat       string
+	timeouts           timeoutOpts
+}
+
+// NewCmdNodesReboot returns the command for rebooting the nodes of the cluster
+func NewCmdNodesReboot(in io.Reader, out io.Writer, planFile *string) *cobra.Command {
+	opts := nodesRebootOpts{}
+	cmd := &cobra.Command{
+		Use:   "reboot",
+		Short: "reboot the nodes of your Kubernetes cluster, one batch at a time",
+		Long: `Reboot the nodes of your Kubernetes cluster, one batch at a time.
+
+The safety checks performed during an online upgrade are run before rebooting the nodes.
+Each node is drained, the maintenance command is run (--command), and the node is rebooted.
+Once SSH is available, and the node is healthy, the node is uncordoned.
+
+Etcd, master, ingress and storage nodes are rebooted one at a time. The health of the etcd
+clusters is verified before and after rebooting an etcd node, to make sure that quorum is
+not lost. The rest of the nodes are rebooted in batches of at most --max-parallel nodes.
+`,
+		Example: `  # Update the packages of the worker nodes, and reboot them two at a time
+  kismatic nodes reboot --roles worker --max-parallel 2 --command "yum update -y"
+`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 0 {
+				return fmt.Errorf("Unexpected args: %v", args)
+			}
+			return doNodesReboot(in, out, *planFile, opts)
+		},
+	}
+	cmd.Flags().StringSliceVar(&opts.roles, "roles", []string{}, "comma-separated list of roles of the nodes to reboot (options \"etcd\"|\"master\"|\"worker\"|\"ingress\"|\"storage\")")
+	cmd.Flags().StringSliceVar(&opts.hosts, "nodes", []string{}, "comma-separated list of hostnames of the nodes to reboot")
+	cmd.Flags().IntVar(&opts.maxParallel, "max-parallel", 1, "the maximum number of worker nodes to be rebooted in parallel")
+	cmd.Flags().StringVar(&opts.command, "command", "", "command to run on each node after it is drained, and before it is rebooted")
+	cmd.Flags().BoolVar(&opts.ignoreSafetyChecks, "ignore-safety-checks", false, "ignore safety checks and continue with the reboot")
+	cmd.Flags().BoolVar(&opts.dryRun, "dry-run", false, "run the safety checks, but don't reboot the nodes")
+	cmd.Flags().StringVar(&opts.generatedAssetsDir, "generated-assets-dir", "generated", "path to the directory where assets generated during the installation process will be stored")
+	cmd.Flags().BoolVar(&opts.verbose, "verbose", false, "enable verbose logging")
+	cmd.Flags().StringVarP(&opts.outputFormat, "output", "o", "simple", "installation output format (options \"simple\"|\"raw\")")
+	addTimeoutFlags(cmd.Flags(), &opts.timeouts)
+	return cmd
+}
+
+func doNodesReboot(in io.Reader, out io.Writer, planFile string, opts nodesRebootOpts) error {
+	if opts.maxParallel < 1 {
+		return fmt.Errorf("max-parallel must be greater or equal to 1, got: %d", opts.maxParallel)
+	}
+	if len(opts.roles) == 0 && len(opts.hosts) == 0 {
+		return errors.New("the nodes to reboot must be selected with --roles or --nodes")
+	}
+	planner := install.FilePlanner{File: planFile}
+	if !planner.PlanExists() {
+		return planFileNotFoundErr{filename: planFile}
+	}
+	plan, err := planner.Read()
+	if err != nil {
+		return fmt.Errorf("error reading plan file %q: %v", planFile, err)
+	}
+	nodes, err := install.SelectNodes(*plan, opts.roles, opts.hosts)
+	if err != nil {
+		return err
+	}
+	if len(nodes) == 0 {
+		return errors.New("no nodes were selected for rebooting")
+	}
+	executorOpts := install.ExecutorOptions{
+		GeneratedAssetsDirectory: opts.generatedAssetsDir,
+		OutputFormat:             opts.outputFormat,
+		Verbose:                  opts.verbose,
+		DryRun:                   opts.dryRun,
+		StallTimeout:             opts.timeouts.stallTimeout,
+		PlayTimeout:              opts.timeouts.playTimeout,
+		Timeout:                  opts.timeouts.timeout,
+	}
+	executor, err := install.NewExecutor(out, os.Stderr, executorOpts)
+	if err != nil {
+		return err
+	}
+	if err = validateSSHConnectivity(out, plan); err != nil {
+		return err
+	}
+
+	util.PrintHeader(out, "Validate Node Reboot", '=')
+	kubeClient, glusterClient, err := upgradeSafetyClients(*plan, opts.generatedAssetsDir)
+	if err != nil {
+		return err
+	}
+	// A reboot has the same availability concerns as an online upgrade
+	strategy := install.UpgradeStrategy{MaxParallelWorkers: opts.maxParallel}
+	nodeErrs, err := install.DetectUpgradeSafety(*plan, nodes, strategy, kubeClient, glusterClient)
+	if err != nil {
+		return err
+	}
+	unsafe := printSafetyReport(out, nodes, nodeErrs, opts.ignoreSafetyChecks)
+	if unsafe && !opts.ignoreSafetyChecks {
+		fmt.Fprintln(out)
+		ans, err := util.PromptForString(in, out, "Unsafe conditions detected, continue with the reboot anyway?", "N", []string{"N", "y"})
+		if err != nil {
+			return fmt.Errorf("error getting user response: %v", err)
+		}
+		if strings.ToLower(ans) != "y" {
+			return errors.New("Unable to reboot the nodes due to the unsafe conditions detected.")
+		}
+	}
+
+	rebootOpts := install.RebootOptions{
+		MaxParallelWorkers: opts.maxParallel,
+		Command:            opts.command,
+	}
+	if err := executor.RebootNodes(*plan, nodes, rebootOpts); err != nil {
+		return fmt.Errorf("Failed to reboot nodes: %v", err)
+	}
+	if !opts.dryRun {
+		// The nodes were uncordoned after the reboot
+		if err := clearNodeMaintenance(out, opts.generatedAssetsDir, nodes); err != nil {
+			return err
+		}
+		fmt.Fprintln(out)
+		util.PrintColor(out, util.Green, "The nodes were rebooted successfully!\n")
+		fmt.Fprintln(out)
+	}
+	return nil
+}
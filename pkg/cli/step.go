@@ -23,6 +23,7 @@ type stepCmd struct {
 	verbose            bool
 	outputFormat       string
 	limit              []string
+	timeouts           timeoutOpts
 }
 
 // NewCmdStep returns the step command
@@ -42,6 +43,9 @@ func NewCmdStep(out io.Writer, opts *installOpts) *cobra.Command {
 				GeneratedAssetsDirectory: stepCmd.generatedAssetsDir,
 				OutputFormat:             stepCmd.outputFormat,
 				Verbose:                  stepCmd.verbose,
+				StallTimeout:             stepCmd.timeouts.stallTimeout,
+				PlayTimeout:              stepCmd.timeouts.playTimeout,
+				Timeout:                  stepCmd.timeouts.timeout,
 			}
 			executor, err := install.NewExecutor(out, os.Stderr, execOpts)
 			if err != nil {
@@ -59,6 +63,7 @@ func NewCmdStep(out io.Writer, opts *installOpts) *cobra.Command {
 	cmd.Flags().BoolVar(&stepCmd.restartServices, "restart-services", false, "force restart cluster services (Use with care)")
 	cmd.Flags().BoolVar(&stepCmd.verbose, "verbose", false, "enable verbose logging from the installation")
 	cmd.Flags().StringVarP(&stepCmd.outputFormat, "output", "o", "simple", "installation output format (options \"simple\"|\"raw\")")
+	addTimeoutFlags(cmd.Flags(), &stepCmd.timeouts)
 	return cmd
 }
 
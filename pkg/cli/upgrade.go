@@ -6,25 +6,31 @@ import (
 	"io"
 	"os"
 	"strings"
+	"time"
 
-	"github.com/apprenda/kismatic/pkg/data"
 	"github.com/apprenda/kismatic/pkg/install"
 	"github.com/apprenda/kismatic/pkg/util"
 	"github.com/spf13/cobra"
 )
 
 type upgradeOpts struct {
-	generatedAssetsDir string
-	verbose            bool
-	outputFormat       string
-	skipPreflight      bool
-	ignoreSafetyChecks bool
-	online             bool
-	planFile           string
-	restartServices    bool
-	partialAllowed     bool
-	maxParallelWorkers int
-	dryRun             bool
+	generatedAssetsDir  string
+	verbose             bool
+	outputFormat        string
+	skipPreflight       bool
+	ignoreSafetyChecks  bool
+	online              bool
+	planFile            string
+	restartServices     bool
+	partialAllowed      bool
+	maxParallelWorkers  int
+	canary              int
+	canaryGate          string
+	batchSelectors      []string
+	pauseBetweenBatches time.Duration
+	rollback            string
+	dryRun              bool
+	timeouts            timeoutOpts
 }
 
 // NewCmdUpgrade returns the upgrade command
@@ -43,7 +49,25 @@ Nodes in the cluster are upgraded in the following order:
 
 1. Etcd nodes
 2. Master nodes
-3. Worker nodes (regardless of specialization)
+3. Ingress and storage nodes, one at a time
+4. Worker nodes
+
+Worker nodes can be upgraded in stages. A canary batch of worker nodes can be upgraded
+first (--canary), followed by a confirmation or a health check of the canary nodes
+(--canary-gate). Worker nodes can also be upgraded in batches of nodes that match a
+set of labels, in the order given by the --batch-selector flags. The progress of the
+worker upgrade is recorded, and an interrupted upgrade will resume where it stopped.
+
+A snapshot of the component versions and configuration of each node is taken before
+the node is upgraded. If the upgrade of a node fails, the node can be rolled back to
+the snapshot (--rollback). Rolled back nodes are uncordoned once they are healthy, and
+the result of the rollback is recorded in the runs directory.
+
+Kubernetes can only be upgraded one minor version at a time. When the cluster is more
+than one minor version behind the version in the plan file, the upgrade path through
+each minor version is printed, along with the plan fields and option overrides that
+are deprecated or removed along the way, and the upgrade is refused. The intermediate
+versions must be installed with the Kismatic releases that support them.
 `,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			return cmd.Help()
@@ -58,6 +82,12 @@ Nodes in the cluster are upgraded in the following order:
 	cmd.PersistentFlags().BoolVar(&opts.partialAllowed, "partial-ok", false, "allow the upgrade of ready nodes, and skip nodes that have been deemed unready for upgrade")
 	cmd.PersistentFlags().BoolVar(&opts.dryRun, "dry-run", false, "simulate the upgrade, but don't actually upgrade the cluster")
 	addPlanFileFlag(cmd.PersistentFlags(), &opts.planFile)
+	addTimeoutFlags(cmd.PersistentFlags(), &opts.timeouts)
+	cmd.PersistentFlags().IntVar(&opts.canary, "canary", 0, "the number of worker nodes to upgrade before the rest of the worker nodes")
+	cmd.PersistentFlags().StringVar(&opts.canaryGate, "canary-gate", "confirm", "how to proceed once the canary nodes are upgraded (options \"confirm\"|\"health\")")
+	cmd.PersistentFlags().StringArrayVar(&opts.batchSelectors, "batch-selector", []string{}, "upgrade the worker nodes that match the comma-separated key=value labels as a batch. Can be repeated, batches are upgraded in order")
+	cmd.PersistentFlags().DurationVar(&opts.pauseBetweenBatches, "pause-between-batches", 0, "amount of time to wait between batches of worker nodes")
+	cmd.PersistentFlags().StringVar(&opts.rollback, "rollback", "prompt", "roll back the nodes that fail to upgrade to the previous version (options \"prompt\"|\"auto\"|\"never\")")
 
 	// Subcommands
 	cmd.AddCommand(NewCmdUpgradeOffline(in, out, &opts))
@@ -115,6 +145,20 @@ func doUpgrade(in io.Reader, out io.Writer, opts *upgradeOpts) error {
 	if opts.maxParallelWorkers < 1 {
 		return fmt.Errorf("max-parallel-workers must be greater or equal to 1, got: %d", opts.maxParallelWorkers)
 	}
+	if opts.canary < 0 {
+		return fmt.Errorf("canary must be greater or equal to 0, got: %d", opts.canary)
+	}
+	if opts.canaryGate != "confirm" && opts.canaryGate != "health" {
+		return fmt.Errorf("canary-gate %q is not supported, options \"confirm\"|\"health\"", opts.canaryGate)
+	}
+	if opts.rollback != "prompt" && opts.rollback != "auto" && opts.rollback != "never" {
+		return fmt.Errorf("rollback %q is not supported, options \"prompt\"|\"auto\"|\"never\"", opts.rollback)
+	}
+	for _, s := range opts.batchSelectors {
+		if _, err := install.ParseBatchSelector(s); err != nil {
+			return err
+		}
+	}
 
 	planFile := opts.planFile
 	planner := install.FilePlanner{File: planFile}
@@ -123,6 +167,9 @@ func doUpgrade(in io.Reader, out io.Writer, opts *upgradeOpts) error {
 		OutputFormat:             opts.outputFormat,
 		Verbose:                  opts.verbose,
 		DryRun:                   opts.dryRun,
+		StallTimeout:             opts.timeouts.stallTimeout,
+		PlayTimeout:              opts.timeouts.playTimeout,
+		Timeout:                  opts.timeouts.timeout,
 	}
 	executor, err := install.NewExecutor(out, os.Stderr, executorOpts)
 	if err != nil {
@@ -180,6 +227,74 @@ func doUpgrade(in io.Reader, out io.Writer, opts *upgradeOpts) error {
 		return fmt.Errorf("error listing cluster versions: %v", err)
 	}
 
+	// Figure out the Kubernetes versions the cluster must go through
+	path, err := install.PlanUpgradePath(*plan, cv)
+	if err != nil {
+		return fmt.Errorf("error computing upgrade path: %v", err)
+	}
+	if err = validateUpgradePath(out, path); err != nil {
+		return err
+	}
+
+	if err = upgradeToVersion(in, out, plan, *opts, cv, executor, preflightExec); err != nil {
+		return err
+	}
+
+	if opts.partialAllowed {
+		util.PrintColor(out, util.Green, `
+
+Partial upgrade complete.
+
+Cluster level services are still left to upgrade. These can only be upgraded
+when performing a full upgrade. When you are ready, you may use "kismatic upgrade"
+without the "--partial-ok" flag to perform a full upgrade.
+
+`)
+		return nil
+	}
+
+	if !opts.dryRun {
+		fmt.Fprintln(out)
+		util.PrintColor(out, util.Green, "The cluster was upgraded successfully!\n")
+		fmt.Fprintln(out)
+	}
+	return nil
+}
+
+// validateUpgradePath prints the upgrade path, and the deprecated and removed
+// plan fields at each hop. An error is returned if the path cannot be walked.
+// Only upgrades within a single hop are supported.
+func validateUpgradePath(out io.Writer, path *install.UpgradePath) error {
+	versions := []string{path.Current}
+	for _, h := range path.Hops {
+		versions = append(versions, h.To)
+	}
+	util.PrettyPrintOk(out, "Upgrade path: %s", strings.Join(versions, " -> "))
+	removed := false
+	for _, h := range path.Hops {
+		for _, c := range h.Changes {
+			if c.Removed {
+				removed = true
+				util.PrettyPrintErr(out, "- Kubernetes %s: %s", h.To, c)
+				continue
+			}
+			util.PrettyPrintWarn(out, "- Kubernetes %s: %s", h.To, c)
+		}
+	}
+	if removed {
+		return errors.New("the plan file contains fields that are removed along the upgrade path, remove them before upgrading")
+	}
+	// The playbooks and the plan validation only support the Kubernetes version
+	// of this release, so the intermediate versions cannot be installed
+	if len(path.Hops) > 1 {
+		return fmt.Errorf("the cluster must be upgraded through %d Kubernetes versions, which is not supported. Upgrade the cluster to %s with the Kismatic release that supports it first", len(path.Hops), path.Hops[len(path.Hops)-2].To)
+	}
+	return nil
+}
+
+// upgradeToVersion upgrades the nodes and cluster services to the Kubernetes
+// version in the plan
+func upgradeToVersion(in io.Reader, out io.Writer, plan *install.Plan, opts upgradeOpts, cv install.ClusterVersion, executor install.Executor, preflightExec install.PreFlightExecutor) error {
 	// Figure out which nodes to upgrade
 	var toUpgrade []install.ListableNode
 	var toSkip []install.ListableNode
@@ -206,21 +321,13 @@ func doUpgrade(in io.Reader, out io.Writer, opts *upgradeOpts) error {
 	if len(toUpgrade) == 0 {
 		fmt.Fprintln(out, "All nodes are at the target version. Skipping node upgrades.")
 	} else {
-		if err = upgradeNodes(in, out, *plan, *opts, toUpgrade, executor, preflightExec); err != nil {
+		if err := upgradeNodes(in, out, *plan, opts, toUpgrade, executor, preflightExec); err != nil {
 			return err
 		}
 	}
 
+	// Cluster services are only upgraded during a full upgrade
 	if opts.partialAllowed {
-		util.PrintColor(out, util.Green, `
-
-Partial upgrade complete.
-
-Cluster level services are still left to upgrade. These can only be upgraded
-when performing a full upgrade. When you are ready, you may use "kismatic upgrade"
-without the "--partial-ok" flag to perform a full upgrade.
-
-`)
 		return nil
 	}
 
@@ -235,29 +342,38 @@ without the "--partial-ok" flag to perform a full upgrade.
 			return fmt.Errorf("Smoke test failed: %v", err)
 		}
 	}
-
-	if !opts.dryRun {
-		fmt.Fprintln(out)
-		util.PrintColor(out, util.Green, "The cluster was upgraded successfully!\n")
-		fmt.Fprintln(out)
-	}
 	return nil
 }
 
 func upgradeNodes(in io.Reader, out io.Writer, plan install.Plan, opts upgradeOpts, nodesNeedUpgrade []install.ListableNode, executor install.Executor, preflightExec install.PreFlightExecutor) error {
+	// The safety checks take into account the order in which the nodes are upgraded
+	strategy := install.UpgradeStrategy{
+		MaxParallelWorkers: opts.maxParallelWorkers,
+		Canary:             opts.canary,
+		CanaryHealthCheck:  opts.canaryGate == "health",
+		BatchSelectors:     opts.batchSelectors,
+		BetweenBatches: func(upgraded install.UpgradeBatch, next install.UpgradeBatch) error {
+			return betweenUpgradeBatches(in, out, opts, upgraded, next)
+		},
+		RollbackOnFailure: func(nodes []string, err error) bool {
+			return rollbackOnFailure(in, out, opts, nodes, err)
+		},
+	}
 	// Run safety checks if doing an online upgrade
 	unsafeNodes := []install.ListableNode{}
 	if opts.online {
 		util.PrintHeader(out, "Validate Online Upgrade", '=')
-		// Use the first master node for running kubectl
-		client, err := plan.GetSSHClient(plan.Master.Nodes[0].Host)
+		kubeClient, glusterClient, err := upgradeSafetyClients(plan, opts.generatedAssetsDir)
 		if err != nil {
-			return fmt.Errorf("error getting SSH client: %v", err)
+			return err
+		}
+		nodeErrs, err := install.DetectUpgradeSafety(plan, nodesNeedUpgrade, strategy, kubeClient, glusterClient)
+		if err != nil {
+			return err
 		}
-		kubeClient := data.RemoteKubectl{SSHClient: client}
 		for _, node := range nodesNeedUpgrade {
 			util.PrettyPrint(out, "%s %v", node.Node.Host, node.Roles)
-			errs := install.DetectNodeUpgradeSafety(plan, node.Node, kubeClient)
+			errs := nodeErrs[node.Node.Host]
 			if len(errs) != 0 {
 				if opts.ignoreSafetyChecks {
 					util.PrintWarn(out)
@@ -366,8 +482,53 @@ func upgradeNodes(in io.Reader, out io.Writer, plan install.Plan, opts upgradeOp
 	}
 
 	// Run the upgrade on the nodes that need it
-	if err := executor.UpgradeNodes(plan, toUpgrade, opts.online, opts.maxParallelWorkers, opts.restartServices); err != nil {
+	if err := executor.UpgradeNodes(plan, toUpgrade, opts.online, strategy, opts.restartServices); err != nil {
 		return fmt.Errorf("Failed to upgrade nodes: %v", err)
 	}
+	if opts.dryRun {
+		return nil
+	}
+	// The nodes were uncordoned after the upgrade
+	return clearNodeMaintenance(out, opts.generatedAssetsDir, toUpgrade)
+}
+
+// betweenUpgradeBatches asks for confirmation once the canary nodes are
+// upgraded, and pauses between batches of worker nodes
+func betweenUpgradeBatches(in io.Reader, out io.Writer, opts upgradeOpts, upgraded install.UpgradeBatch, next install.UpgradeBatch) error {
+	if opts.dryRun {
+		return nil
+	}
+	if upgraded.Canary && !next.Canary && opts.canaryGate == "confirm" {
+		fmt.Fprintln(out)
+		ans, err := util.PromptForString(in, out, "The canary nodes have been upgraded, continue upgrading the rest of the worker nodes?", "N", []string{"N", "y"})
+		if err != nil {
+			return fmt.Errorf("error getting user response: %v", err)
+		}
+		if strings.ToLower(ans) != "y" {
+			return errors.New("upgrade stopped after upgrading the canary nodes. Run the upgrade again to resume")
+		}
+	}
+	if opts.pauseBetweenBatches > 0 {
+		util.PrettyPrint(out, "Waiting %v before upgrading batch %q\n", opts.pauseBetweenBatches, next.Name)
+		time.Sleep(opts.pauseBetweenBatches)
+	}
 	return nil
 }
+
+// rollbackOnFailure decides whether the nodes that failed to upgrade are rolled back
+func rollbackOnFailure(in io.Reader, out io.Writer, opts upgradeOpts, nodes []string, upgradeErr error) bool {
+	switch opts.rollback {
+	case "auto":
+		return true
+	case "never":
+		return false
+	}
+	fmt.Fprintln(out)
+	util.PrettyPrintErr(out, "The upgrade of %s failed: %v", strings.Join(nodes, ", "), upgradeErr)
+	ans, err := util.PromptForString(in, out, "Roll back the node(s) to the previous version?", "y", []string{"y", "N"})
+	if err != nil {
+		util.PrettyPrintErr(out, "Error getting user response: %v", err)
+		return false
+	}
+	return strings.ToLower(ans) == "y"
+}
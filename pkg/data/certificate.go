@@ -0,0 +1,47 @@
+package data
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"strings"
+
+	"github.com/apprenda/kismatic/pkg/ssh"
+)
+
+// CertificateGetter gets a certificate that is deployed on a node
+type CertificateGetter interface {
+	GetCertificate(path string) (*x509.Certificate, error)
+}
+
+// RemoteCertificates reads the certificates deployed on the node of the SSH connection
+type RemoteCertificates struct {
+	SSHClient ssh.Client
+}
+
+// GetCertificate returns the certificate in the file at the given path.
+// Returns nil if the file does not exist.
+func (c RemoteCertificates) GetCertificate(path string) (*x509.Certificate, error) {
+	raw, err := c.SSHClient.Output(true, fmt.Sprintf("sudo cat %s", path))
+	if err != nil {
+		if strings.Contains(raw, "No such file or directory") {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error reading certificate %q: %v", path, err)
+	}
+	return ParseCertificate(raw)
+}
+
+// ParseCertificate returns the first certificate in PEM format of the text
+func ParseCertificate(raw string) (*x509.Certificate, error) {
+	// the output of a terminal uses CRLF line endings
+	block, _ := pem.Decode([]byte(strings.Replace(raw, "\r\n", "\n", -1)))
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, fmt.Errorf("no certificate found")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing certificate: %v", err)
+	}
+	return cert, nil
+}
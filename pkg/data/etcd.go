@@ -0,0 +1,79 @@
+package data
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/apprenda/kismatic/pkg/ssh"
+)
+
+// EtcdMemberStatusGetter gets the status of an etcd member
+type EtcdMemberStatusGetter interface {
+	GetEtcdMemberStatus() (*EtcdMemberStatus, error)
+}
+
+// EtcdMemberStatus is the health and raft state of an etcd member
+type EtcdMemberStatus struct {
+	// Healthy is true when the member reports that it is healthy
+	Healthy bool
+	// Stats are the statistics the member reports about itself
+	Stats EtcdSelfStats
+}
+
+// EtcdSelfStats are the statistics an etcd member reports about itself
+type EtcdSelfStats struct {
+	Name       string `json:"name"`
+	ID         string `json:"id"`
+	State      string `json:"state"`
+	LeaderInfo struct {
+		Leader string `json:"leader"`
+	} `json:"leaderInfo"`
+}
+
+// IsLeader returns true if the member is the leader of the cluster
+func (s EtcdSelfStats) IsLeader() bool {
+	return s.State == "StateLeader"
+}
+
+type etcdHealth struct {
+	Health string `json:"health"`
+}
+
+// RemoteEtcd queries the etcd member running on the node of the SSH connection
+type RemoteEtcd struct {
+	SSHClient ssh.Client
+	// Port is the client port of the etcd member
+	Port int
+	// CertDir is the directory on the node that contains the client certificates of the etcd cluster
+	CertDir string
+}
+
+// GetEtcdMemberStatus returns the health and raft state of the member
+func (e RemoteEtcd) GetEtcdMemberStatus() (*EtcdMemberStatus, error) {
+	healthRaw, err := e.SSHClient.Output(true, e.curl("/health"))
+	if err != nil {
+		return nil, fmt.Errorf("error getting etcd member health: %v", err)
+	}
+	statsRaw, err := e.SSHClient.Output(true, e.curl("/v2/stats/self"))
+	if err != nil {
+		return nil, fmt.Errorf("error getting etcd member stats: %v", err)
+	}
+	return UnmarshalEtcdMemberStatus(healthRaw, statsRaw)
+}
+
+func (e RemoteEtcd) curl(path string) string {
+	return fmt.Sprintf("sudo curl --silent --fail --max-time 10 --cacert %[1]s/ca.pem --cert %[1]s/etcd-client.pem --key %[1]s/etcd-client-key.pem https://127.0.0.1:%[2]d%[3]s", e.CertDir, e.Port, path)
+}
+
+// UnmarshalEtcdMemberStatus unmarshals the responses of the health and self stats endpoints of an etcd member
+func UnmarshalEtcdMemberStatus(healthRaw, statsRaw string) (*EtcdMemberStatus, error) {
+	var health etcdHealth
+	if err := json.Unmarshal([]byte(healthRaw), &health); err != nil {
+		return nil, fmt.Errorf("error unmarshalling etcd member health: %v", err)
+	}
+	status := &EtcdMemberStatus{Healthy: health.Health == "true"}
+	if err := json.Unmarshal([]byte(statsRaw), &status.Stats); err != nil {
+		return nil, fmt.Errorf("error unmarshalling etcd member stats: %v", err)
+	}
+	return status, nil
+}
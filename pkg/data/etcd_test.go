@@ -0,0 +1,33 @@
+package data
+
+import "testing"
+
+func TestUnmarshalEtcdMemberStatus(t *testing.T) {
+	health := `{"health": "true"}`
+	stats := `{"name":"etcd01","id":"8e9e05c52164694d","state":"StateLeader","startTime":"2018-04-03T15:04:05.000000000Z","leaderInfo":{"leader":"8e9e05c52164694d","uptime":"10m"}}`
+	status, err := UnmarshalEtcdMemberStatus(health, stats)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !status.Healthy {
+		t.Errorf("expected the member to be healthy")
+	}
+	if !status.Stats.IsLeader() {
+		t.Errorf("expected the member to be the leader")
+	}
+	if status.Stats.LeaderInfo.Leader != "8e9e05c52164694d" {
+		t.Errorf("unexpected leader %q", status.Stats.LeaderInfo.Leader)
+	}
+
+	status, err = UnmarshalEtcdMemberStatus(`{"health": "false"}`, `{"name":"etcd02","state":"StateFollower"}`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if status.Healthy || status.Stats.IsLeader() {
+		t.Errorf("expected an unhealthy follower, got %+v", status)
+	}
+
+	if _, err = UnmarshalEtcdMemberStatus("curl: (7) Failed to connect", stats); err == nil {
+		t.Errorf("expected an error, but didn't get one")
+	}
+}
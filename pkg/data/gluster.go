@@ -13,6 +13,16 @@ type GlusterClient interface {
 	GetQuota(volume string) (*GlusterVolumeQuotaCliOutput, error)
 }
 
+// GlusterVolumeStatusLister lists the status of the bricks of all gluster volumes
+type GlusterVolumeStatusLister interface {
+	ListVolumeStatus() (*GlusterVolumeStatusCliOutput, error)
+}
+
+// GlusterHealInfoGetter gets the entries that are pending heal on a gluster volume
+type GlusterHealInfoGetter interface {
+	GetHealInfo(volume string) (*GlusterVolumeHealInfoCliOutput, error)
+}
+
 type RemoteGlusterCLI struct {
 	SSHClient ssh.Client
 }
@@ -65,3 +75,49 @@ func UnmarshalVolumeQuota(raw string) (*GlusterVolumeQuotaCliOutput, error) {
 
 	return &glusterVolumeQuota, nil
 }
+
+// ListVolumeStatus returns the status of the gluster volumes using gluster command on the first storage node
+func (g RemoteGlusterCLI) ListVolumeStatus() (*GlusterVolumeStatusCliOutput, error) {
+	glusterVolumeStatusRaw, err := g.SSHClient.Output(true, "sudo gluster volume status all --xml")
+	if err != nil {
+		return nil, fmt.Errorf("error getting volume status data: %v", err)
+	}
+
+	return UnmarshalVolumeStatus(glusterVolumeStatusRaw)
+}
+
+func UnmarshalVolumeStatus(raw string) (*GlusterVolumeStatusCliOutput, error) {
+	var glusterVolumeStatus GlusterVolumeStatusCliOutput
+	err := xml.Unmarshal([]byte(strings.TrimSpace(raw)), &glusterVolumeStatus)
+	if err != nil {
+		return nil, fmt.Errorf("error unmarshalling volume status data: %v", err)
+	}
+	if glusterVolumeStatus.VolumeStatus == nil || glusterVolumeStatus.VolumeStatus.Volumes == nil {
+		return nil, nil
+	}
+
+	return &glusterVolumeStatus, nil
+}
+
+// GetHealInfo returns the entries pending heal on the gluster volume using gluster command on the first storage node
+func (g RemoteGlusterCLI) GetHealInfo(volume string) (*GlusterVolumeHealInfoCliOutput, error) {
+	glusterHealInfoRaw, err := g.SSHClient.Output(true, fmt.Sprintf("sudo gluster volume heal %s info --xml", volume))
+	if err != nil {
+		return nil, fmt.Errorf("error getting heal info data for %s: %v", volume, err)
+	}
+
+	return UnmarshalHealInfo(glusterHealInfoRaw)
+}
+
+func UnmarshalHealInfo(raw string) (*GlusterVolumeHealInfoCliOutput, error) {
+	var glusterHealInfo GlusterVolumeHealInfoCliOutput
+	err := xml.Unmarshal([]byte(strings.TrimSpace(raw)), &glusterHealInfo)
+	if err != nil {
+		return nil, fmt.Errorf("error unmarshalling heal info data: %v", err)
+	}
+	if glusterHealInfo.HealInfo == nil {
+		return nil, fmt.Errorf("error getting heal info data")
+	}
+
+	return &glusterHealInfo, nil
+}
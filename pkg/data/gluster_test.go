@@ -41,3 +41,101 @@ func TestUnmarshalVolumeQuota(t *testing.T) {
 		}
 	}
 }
+
+func TestUnmarshalVolumeStatus(t *testing.T) {
+	raw := `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<cliOutput>
+  <opRet>0</opRet>
+  <opErrno>0</opErrno>
+  <opErrstr/>
+  <volStatus>
+    <volumes>
+      <volume>
+        <volName>storage01</volName>
+        <nodeCount>3</nodeCount>
+        <node>
+          <hostname>node1</hostname>
+          <path>/data/storage01</path>
+          <peerid>1c3e2f84-0a3a-4c3c-b2a9-10f5d2a0a0b1</peerid>
+          <status>1</status>
+          <port>49152</port>
+          <pid>1234</pid>
+        </node>
+        <node>
+          <hostname>node2</hostname>
+          <path>/data/storage01</path>
+          <peerid>2c3e2f84-0a3a-4c3c-b2a9-10f5d2a0a0b1</peerid>
+          <status>0</status>
+          <port>N/A</port>
+          <pid>-1</pid>
+        </node>
+        <node>
+          <hostname>Self-heal Daemon</hostname>
+          <path>localhost</path>
+          <peerid>1c3e2f84-0a3a-4c3c-b2a9-10f5d2a0a0b1</peerid>
+          <status>1</status>
+          <port>N/A</port>
+          <pid>1300</pid>
+        </node>
+      </volume>
+    </volumes>
+  </volStatus>
+</cliOutput>`
+	status, err := UnmarshalVolumeStatus(raw)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if status == nil {
+		t.Fatal("did not expect for status to be nil")
+	}
+	nodes := status.VolumeStatus.Volumes.Volume[0].Nodes
+	if len(nodes) != 3 {
+		t.Fatalf("expected 3 nodes, got %d", len(nodes))
+	}
+	if !nodes[0].IsBrick() || !nodes[0].Online() {
+		t.Errorf("expected the first brick to be online")
+	}
+	if !nodes[1].IsBrick() || nodes[1].Online() {
+		t.Errorf("expected the second brick to be offline")
+	}
+	if nodes[2].IsBrick() {
+		t.Errorf("did not expect the self-heal daemon to be a brick")
+	}
+}
+
+func TestUnmarshalHealInfo(t *testing.T) {
+	raw := `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<cliOutput>
+  <healInfo>
+    <bricks>
+      <brick hostUuid="1c3e2f84-0a3a-4c3c-b2a9-10f5d2a0a0b1">
+        <name>node1:/data/storage01</name>
+        <status>Connected</status>
+        <numberOfEntries>2</numberOfEntries>
+      </brick>
+      <brick hostUuid="-">
+        <name>node2:/data/storage01</name>
+        <status>Transport endpoint is not connected</status>
+        <numberOfEntries>-</numberOfEntries>
+      </brick>
+    </bricks>
+  </healInfo>
+  <opRet>0</opRet>
+  <opErrno>0</opErrno>
+  <opErrstr/>
+</cliOutput>`
+	info, err := UnmarshalHealInfo(raw)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	bricks := info.HealInfo.Bricks.Brick
+	if len(bricks) != 2 {
+		t.Fatalf("expected 2 bricks, got %d", len(bricks))
+	}
+	if bricks[0].PendingHeals() != 2 {
+		t.Errorf("expected 2 entries pending heal, got %d", bricks[0].PendingHeals())
+	}
+	if bricks[1].PendingHeals() != -1 {
+		t.Errorf("expected unknown entries pending heal, got %d", bricks[1].PendingHeals())
+	}
+}
@@ -1,5 +1,10 @@
 package data
 
+import (
+	"strconv"
+	"strings"
+)
+
 // gluster volume quota $VOLUME list --xml
 //==============================================================================
 type GlusterVolumeQuotaCliOutput struct {
@@ -43,9 +48,77 @@ type GlusterVolume struct {
 	DistCount    uint           `xml:" distCount,omitempty" json:"distCount,omitempty"`
 	Name         string         `xml:" name,omitempty" json:"name,omitempty"`
 	ReplicaCount uint           `xml:" replicaCount,omitempty" json:"replicaCount,omitempty"`
+	StatusStr    string         `xml:"statusStr,omitempty" json:"statusStr,omitempty"`
 }
 
 type GlusterVolumes struct {
 	Count  uint             `xml:" count,omitempty" json:"count,omitempty"`
 	Volume []*GlusterVolume `xml:" volume,omitempty" json:"volume,omitempty"`
 }
+
+// gluster volume status all --xml
+//==============================================================================
+type GlusterVolumeStatusCliOutput struct {
+	VolumeStatus *GlusterVolumeStatus `xml:"volStatus,omitempty" json:"volStatus,omitempty"`
+}
+
+type GlusterVolumeStatus struct {
+	Volumes *GlusterVolumeStatusVolumes `xml:"volumes,omitempty" json:"volumes,omitempty"`
+}
+
+type GlusterVolumeStatusVolumes struct {
+	Volume []*GlusterVolumeStatusVolume `xml:"volume,omitempty" json:"volume,omitempty"`
+}
+
+type GlusterVolumeStatusVolume struct {
+	Name  string                     `xml:"volName,omitempty" json:"volName,omitempty"`
+	Nodes []*GlusterVolumeStatusNode `xml:"node,omitempty" json:"node,omitempty"`
+}
+
+// GlusterVolumeStatusNode is a brick or a daemon (NFS server, self-heal daemon) of a volume
+type GlusterVolumeStatusNode struct {
+	Hostname string `xml:"hostname,omitempty" json:"hostname,omitempty"`
+	Path     string `xml:"path,omitempty" json:"path,omitempty"`
+	Status   int    `xml:"status,omitempty" json:"status,omitempty"`
+}
+
+// IsBrick returns true if the status entry is for a brick of the volume
+func (n GlusterVolumeStatusNode) IsBrick() bool {
+	return strings.HasPrefix(n.Path, "/")
+}
+
+// Online returns true if the brick or daemon is running
+func (n GlusterVolumeStatusNode) Online() bool {
+	return n.Status == 1
+}
+
+// gluster volume heal $VOLUME info --xml
+//==============================================================================
+type GlusterVolumeHealInfoCliOutput struct {
+	HealInfo *GlusterHealInfo `xml:"healInfo,omitempty" json:"healInfo,omitempty"`
+}
+
+type GlusterHealInfo struct {
+	Bricks *GlusterHealInfoBricks `xml:"bricks,omitempty" json:"bricks,omitempty"`
+}
+
+type GlusterHealInfoBricks struct {
+	Brick []*GlusterHealInfoBrick `xml:"brick,omitempty" json:"brick,omitempty"`
+}
+
+type GlusterHealInfoBrick struct {
+	Name   string `xml:"name,omitempty" json:"name,omitempty"`
+	Status string `xml:"status,omitempty" json:"status,omitempty"`
+	// NumberOfEntries is "-" when the brick is not connected
+	NumberOfEntries string `xml:"numberOfEntries,omitempty" json:"numberOfEntries,omitempty"`
+}
+
+// PendingHeals returns the number of entries that are pending heal on the brick,
+// or -1 if it cannot be determined
+func (b GlusterHealInfoBrick) PendingHeals() int {
+	n, err := strconv.Atoi(strings.TrimSpace(b.NumberOfEntries))
+	if err != nil {
+		return -1
+	}
+	return n
+}
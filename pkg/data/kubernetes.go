@@ -48,6 +48,41 @@ type StatefulSetGetter interface {
 	GetStatefulSet(namespace, name string) (*StatefulSet, error)
 }
 
+// DeploymentGetter gets a deployment
+type DeploymentGetter interface {
+	GetDeployment(namespace, name string) (*Deployment, error)
+}
+
+// DeploymentLister lists the deployments in a namespace
+type DeploymentLister interface {
+	ListDeployments(namespace string) (*DeploymentList, error)
+}
+
+// DaemonSetLister lists the daemon sets in a namespace
+type DaemonSetLister interface {
+	ListDaemonSets(namespace string) (*DaemonSetList, error)
+}
+
+// EndpointsGetter gets endpoints
+type EndpointsGetter interface {
+	GetEndpoints(namespace, name string) (*Endpoints, error)
+}
+
+// APIServerHealthGetter gets the health of an API server
+type APIServerHealthGetter interface {
+	GetAPIServerHealth(server string) (string, error)
+}
+
+// PodDisruptionBudgetLister lists the pod disruption budgets on a Kubernetes cluster
+type PodDisruptionBudgetLister interface {
+	ListPodDisruptionBudgets() (*PodDisruptionBudgetList, error)
+}
+
+// NodeLister lists the nodes of a Kubernetes cluster
+type NodeLister interface {
+	ListNodes() (*NodeList, error)
+}
+
 type KubernetesClient interface {
 	PodLister
 	PVLister
@@ -210,6 +245,129 @@ func (k RemoteKubectl) GetStatefulSet(namespace, name string) (*StatefulSet, err
 	return &s, nil
 }
 
+// GetDeployment returns the deployment with the given name in the given namespace.
+// If not found, returns an error.
+func (k RemoteKubectl) GetDeployment(namespace, name string) (*Deployment, error) {
+	cmd := fmt.Sprintf("sudo kubectl --kubeconfig /root/.kube/config get deployment --namespace %s -o json %s", namespace, name)
+	raw, err := k.SSHClient.Output(true, cmd)
+	if err != nil {
+		return nil, fmt.Errorf("error getting Deployment: %v", err)
+	}
+	if isNoResourcesResponse(raw) {
+		return nil, fmt.Errorf("Deployment %s/%s was not found", namespace, name)
+	}
+	var d Deployment
+	if err := json.Unmarshal([]byte(raw), &d); err != nil {
+		return nil, fmt.Errorf("error unmarshalling Deployment: %v", err)
+	}
+	return &d, nil
+}
+
+// ListDeployments returns the deployments in the given namespace
+func (k RemoteKubectl) ListDeployments(namespace string) (*DeploymentList, error) {
+	raw, err := k.SSHClient.Output(true, fmt.Sprintf("sudo kubectl --kubeconfig /root/.kube/config get deployments --namespace %s -o json", namespace))
+	if err != nil {
+		return nil, fmt.Errorf("error getting deployment data: %v", err)
+	}
+	return UnmarshalDeployments(raw)
+}
+
+func UnmarshalDeployments(raw string) (*DeploymentList, error) {
+	if isNoResourcesResponse(raw) {
+		return &DeploymentList{}, nil
+	}
+	var deployments DeploymentList
+	if err := json.Unmarshal([]byte(raw), &deployments); err != nil {
+		return nil, fmt.Errorf("error unmarshalling deployment data: %v", err)
+	}
+	return &deployments, nil
+}
+
+// ListDaemonSets returns the daemon sets in the given namespace
+func (k RemoteKubectl) ListDaemonSets(namespace string) (*DaemonSetList, error) {
+	raw, err := k.SSHClient.Output(true, fmt.Sprintf("sudo kubectl --kubeconfig /root/.kube/config get daemonsets --namespace %s -o json", namespace))
+	if err != nil {
+		return nil, fmt.Errorf("error getting daemon set data: %v", err)
+	}
+	if isNoResourcesResponse(raw) {
+		return &DaemonSetList{}, nil
+	}
+	var daemonSets DaemonSetList
+	if err := json.Unmarshal([]byte(raw), &daemonSets); err != nil {
+		return nil, fmt.Errorf("error unmarshalling daemon set data: %v", err)
+	}
+	return &daemonSets, nil
+}
+
+// GetEndpoints returns the endpoints with the given name in the given namespace.
+// If not found, returns an error.
+func (k RemoteKubectl) GetEndpoints(namespace, name string) (*Endpoints, error) {
+	cmd := fmt.Sprintf("sudo kubectl --kubeconfig /root/.kube/config get endpoints --namespace %s -o json %s", namespace, name)
+	raw, err := k.SSHClient.Output(true, cmd)
+	if err != nil {
+		return nil, fmt.Errorf("error getting Endpoints: %v", err)
+	}
+	if isNoResourcesResponse(raw) {
+		return nil, fmt.Errorf("Endpoints %s/%s was not found", namespace, name)
+	}
+	var e Endpoints
+	if err := json.Unmarshal([]byte(raw), &e); err != nil {
+		return nil, fmt.Errorf("error unmarshalling Endpoints: %v", err)
+	}
+	return &e, nil
+}
+
+// GetAPIServerHealth returns the response of the health endpoint of the API server
+// listening on the given address, such as https://10.0.0.1:6443
+func (k RemoteKubectl) GetAPIServerHealth(server string) (string, error) {
+	cmd := fmt.Sprintf("sudo kubectl --kubeconfig /root/.kube/config --server %s --request-timeout 10s get --raw /healthz", server)
+	raw, err := k.SSHClient.Output(true, cmd)
+	if err != nil {
+		return "", fmt.Errorf("error getting API server health: %v", err)
+	}
+	return strings.TrimSpace(raw), nil
+}
+
+// ListNodes returns the nodes of the cluster
+func (k RemoteKubectl) ListNodes() (*NodeList, error) {
+	raw, err := k.SSHClient.Output(true, "sudo kubectl --kubeconfig /root/.kube/config get nodes -o json")
+	if err != nil {
+		return nil, fmt.Errorf("error getting node data: %v", err)
+	}
+	return UnmarshalNodes(raw)
+}
+
+func UnmarshalNodes(raw string) (*NodeList, error) {
+	if isNoResourcesResponse(raw) {
+		return &NodeList{}, nil
+	}
+	var nodes NodeList
+	if err := json.Unmarshal([]byte(raw), &nodes); err != nil {
+		return nil, fmt.Errorf("error unmarshalling node data: %v", err)
+	}
+	return &nodes, nil
+}
+
+// ListPodDisruptionBudgets returns the PodDisruptionBudgets in all namespaces
+func (k RemoteKubectl) ListPodDisruptionBudgets() (*PodDisruptionBudgetList, error) {
+	raw, err := k.SSHClient.Output(true, "sudo kubectl --kubeconfig /root/.kube/config get pdb --all-namespaces=true -o json")
+	if err != nil {
+		return nil, fmt.Errorf("error getting PodDisruptionBudget data: %v", err)
+	}
+	return UnmarshalPodDisruptionBudgets(raw)
+}
+
+func UnmarshalPodDisruptionBudgets(raw string) (*PodDisruptionBudgetList, error) {
+	if isNoResourcesResponse(raw) {
+		return &PodDisruptionBudgetList{}, nil
+	}
+	var pdbs PodDisruptionBudgetList
+	if err := json.Unmarshal([]byte(raw), &pdbs); err != nil {
+		return nil, fmt.Errorf("error unmarshalling PodDisruptionBudget data: %v", err)
+	}
+	return &pdbs, nil
+}
+
 // kubectl will print this message when no resources are returned
 func isNoResourcesResponse(s string) bool {
 	if strings.Contains(strings.TrimSpace(s), "No resources found") {
@@ -0,0 +1,261 @@
+package data
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// APIClient is a Kubernetes client that talks to the API server over HTTPS,
+// authenticating with a client certificate
+type APIClient struct {
+	// Server is the address of the API server, such as https://cluster.example.com:6443
+	Server     string
+	HTTPClient *http.Client
+}
+
+// NewAPIClient returns a client for the API server that authenticates with the
+// given client certificate, and trusts the given CA certificate
+func NewAPIClient(server, caFile, certFile, keyFile string) (*APIClient, error) {
+	caCert, err := ioutil.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("error reading CA certificate: %v", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caCert) {
+		return nil, fmt.Errorf("no certificates found in %q", caFile)
+	}
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("error reading client certificate: %v", err)
+	}
+	transport := &http.Transport{
+		// fail fast when the API server is not reachable
+		DialContext: (&net.Dialer{Timeout: 5 * time.Second}).DialContext,
+		TLSClientConfig: &tls.Config{
+			RootCAs:      pool,
+			Certificates: []tls.Certificate{cert},
+		},
+	}
+	return &APIClient{
+		Server:     strings.TrimSuffix(server, "/"),
+		HTTPClient: &http.Client{Transport: transport, Timeout: 30 * time.Second},
+	}, nil
+}
+
+type apiNotFoundErr struct {
+	path string
+}
+
+func (e apiNotFoundErr) Error() string {
+	return fmt.Sprintf("%s was not found", e.path)
+}
+
+// getRaw returns the body of the response to a GET request to the path of the server
+func (c APIClient) getRaw(server, path string) ([]byte, error) {
+	req, err := http.NewRequest("GET", server+path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %v", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to the API server: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading response from the API server: %v", err)
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, apiNotFoundErr{path: path}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s returned %s: %s", path, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return body, nil
+}
+
+func (c APIClient) get(path string, v interface{}) error {
+	body, err := c.getRaw(c.Server, path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, v)
+}
+
+// getObject gets the object in the path, returning a not found error that uses
+// the kind and name of the object if it doesn't exist
+func (c APIClient) getObject(kind, name, path string, v interface{}) error {
+	err := c.get(path, v)
+	if _, ok := err.(apiNotFoundErr); ok {
+		return fmt.Errorf("%s %s was not found", kind, name)
+	}
+	if err != nil {
+		return fmt.Errorf("error getting %s: %v", kind, err)
+	}
+	return nil
+}
+
+// ListPersistentVolumes returns PersistentVolume data
+func (c APIClient) ListPersistentVolumes() (*PersistentVolumeList, error) {
+	var pvs PersistentVolumeList
+	if err := c.get("/api/v1/persistentvolumes", &pvs); err != nil {
+		return nil, fmt.Errorf("error getting persistent volume data: %v", err)
+	}
+	return &pvs, nil
+}
+
+// ListPods returns the pods in all namespaces
+func (c APIClient) ListPods() (*PodList, error) {
+	var pods PodList
+	if err := c.get("/api/v1/pods", &pods); err != nil {
+		return nil, fmt.Errorf("error getting pod data: %v", err)
+	}
+	return &pods, nil
+}
+
+// GetDaemonSet returns the DaemonSet with the given namespace and name. If not found,
+// returns an error.
+func (c APIClient) GetDaemonSet(namespace, name string) (*DaemonSet, error) {
+	var d DaemonSet
+	path := fmt.Sprintf("/apis/extensions/v1beta1/namespaces/%s/daemonsets/%s", namespace, name)
+	if err := c.getObject("DaemonSet", namespace+"/"+name, path, &d); err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// GetReplicationController returns the ReplicationController with the given name in the given namespace.
+// If not found, returns an error.
+func (c APIClient) GetReplicationController(namespace, name string) (*ReplicationController, error) {
+	var r ReplicationController
+	path := fmt.Sprintf("/api/v1/namespaces/%s/replicationcontrollers/%s", namespace, name)
+	if err := c.getObject("ReplicationController", namespace+"/"+name, path, &r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// GetReplicaSet returns the ReplicaSet with the given name in the given namespace.
+// If not found, returns an error.
+func (c APIClient) GetReplicaSet(namespace, name string) (*ReplicaSet, error) {
+	var r ReplicaSet
+	path := fmt.Sprintf("/apis/extensions/v1beta1/namespaces/%s/replicasets/%s", namespace, name)
+	if err := c.getObject("ReplicaSet", namespace+"/"+name, path, &r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// GetPersistentVolume returns the persistent volume with the given name.
+// If not found, returns an error.
+func (c APIClient) GetPersistentVolume(name string) (*PersistentVolume, error) {
+	var p PersistentVolume
+	if err := c.getObject("PersistentVolume", name, "/api/v1/persistentvolumes/"+name, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// GetPersistentVolumeClaim returns the persistent volume claim with the given name and namespace.
+// If not found, returns an error.
+func (c APIClient) GetPersistentVolumeClaim(namespace, name string) (*PersistentVolumeClaim, error) {
+	var p PersistentVolumeClaim
+	path := fmt.Sprintf("/api/v1/namespaces/%s/persistentvolumeclaims/%s", namespace, name)
+	if err := c.getObject("PersistentVolumeClaim", name, path, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// GetStatefulSet returns the stateful set with the given name in the given namespace.
+// If not found, returns an error.
+func (c APIClient) GetStatefulSet(namespace, name string) (*StatefulSet, error) {
+	var s StatefulSet
+	path := fmt.Sprintf("/apis/apps/v1beta1/namespaces/%s/statefulsets/%s", namespace, name)
+	if err := c.getObject("StatefulSet", namespace+"/"+name, path, &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// GetDeployment returns the deployment with the given name in the given namespace.
+// If not found, returns an error.
+func (c APIClient) GetDeployment(namespace, name string) (*Deployment, error) {
+	var d Deployment
+	path := fmt.Sprintf("/apis/extensions/v1beta1/namespaces/%s/deployments/%s", namespace, name)
+	if err := c.getObject("Deployment", namespace+"/"+name, path, &d); err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// ListDeployments returns the deployments in the given namespace
+func (c APIClient) ListDeployments(namespace string) (*DeploymentList, error) {
+	var deployments DeploymentList
+	if err := c.get(fmt.Sprintf("/apis/extensions/v1beta1/namespaces/%s/deployments", namespace), &deployments); err != nil {
+		return nil, fmt.Errorf("error getting deployment data: %v", err)
+	}
+	return &deployments, nil
+}
+
+// ListDaemonSets returns the daemon sets in the given namespace
+func (c APIClient) ListDaemonSets(namespace string) (*DaemonSetList, error) {
+	var daemonSets DaemonSetList
+	if err := c.get(fmt.Sprintf("/apis/extensions/v1beta1/namespaces/%s/daemonsets", namespace), &daemonSets); err != nil {
+		return nil, fmt.Errorf("error getting daemon set data: %v", err)
+	}
+	return &daemonSets, nil
+}
+
+// GetEndpoints returns the endpoints with the given name in the given namespace.
+// If not found, returns an error.
+func (c APIClient) GetEndpoints(namespace, name string) (*Endpoints, error) {
+	var e Endpoints
+	path := fmt.Sprintf("/api/v1/namespaces/%s/endpoints/%s", namespace, name)
+	if err := c.getObject("Endpoints", namespace+"/"+name, path, &e); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// GetAPIServerHealth returns the response of the health endpoint of the API server
+// listening on the given address, such as https://10.0.0.1:6443
+func (c APIClient) GetAPIServerHealth(server string) (string, error) {
+	body, err := c.getRaw(strings.TrimSuffix(server, "/"), "/healthz")
+	if err != nil {
+		return "", fmt.Errorf("error getting API server health: %v", err)
+	}
+	return strings.TrimSpace(string(body)), nil
+}
+
+// ListNodes returns the nodes of the cluster
+func (c APIClient) ListNodes() (*NodeList, error) {
+	var nodes NodeList
+	if err := c.get("/api/v1/nodes", &nodes); err != nil {
+		return nil, fmt.Errorf("error getting node data: %v", err)
+	}
+	return &nodes, nil
+}
+
+// ListPodDisruptionBudgets returns the PodDisruptionBudgets in all namespaces
+func (c APIClient) ListPodDisruptionBudgets() (*PodDisruptionBudgetList, error) {
+	var pdbs PodDisruptionBudgetList
+	if err := c.get("/apis/policy/v1beta1/poddisruptionbudgets", &pdbs); err != nil {
+		return nil, fmt.Errorf("error getting PodDisruptionBudget data: %v", err)
+	}
+	return &pdbs, nil
+}
+
+// Reachable returns true if the API server is reachable and healthy
+func (c APIClient) Reachable() bool {
+	health, err := c.GetAPIServerHealth(c.Server)
+	return err == nil && health == "ok"
+}
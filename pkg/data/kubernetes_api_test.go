@@ -0,0 +1,123 @@
+package data
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func apiTestServer() *httptest.Server {
+	responses := map[string]string{
+		"/healthz":                     "ok",
+		"/api/v1/nodes":                `{"kind":"NodeList","items":[{"metadata":{"name":"worker01"},"spec":{"unschedulable":true},"status":{"conditions":[{"type":"Ready","status":"True"}]}}]}`,
+		"/api/v1/pods":                 `{"kind":"PodList","items":[{"metadata":{"name":"web","namespace":"default"}}]}`,
+		"/api/v1/persistentvolumes":    `{"kind":"PersistentVolumeList","items":[]}`,
+		"/api/v1/persistentvolumes/pv": `{"kind":"PersistentVolume","metadata":{"name":"pv"}}`,
+		"/apis/extensions/v1beta1/namespaces/kube-system/daemonsets/calico-node": `{"kind":"DaemonSet","metadata":{"name":"calico-node","namespace":"kube-system"},"status":{"desiredNumberScheduled":3}}`,
+		"/apis/extensions/v1beta1/namespaces/kube-system/daemonsets":             `{"kind":"DaemonSetList","items":[{"metadata":{"name":"calico-node","namespace":"kube-system"}}]}`,
+		"/apis/extensions/v1beta1/namespaces/kube-system/deployments":            `{"kind":"DeploymentList","items":[{"metadata":{"name":"kube-dns"},"spec":{"replicas":2},"status":{"replicas":2,"availableReplicas":1}}]}`,
+		"/apis/policy/v1beta1/poddisruptionbudgets":                              `{"kind":"PodDisruptionBudgetList","items":[{"metadata":{"name":"web"},"status":{"disruptionsAllowed":1}}]}`,
+	}
+	return httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v1/namespaces/default/endpoints/broken" {
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `{"kind":"Status","message":"forbidden"}`)
+			return
+		}
+		resp, ok := responses[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"kind":"Status","reason":"NotFound"}`)
+			return
+		}
+		fmt.Fprint(w, resp)
+	}))
+}
+
+func TestAPIClient(t *testing.T) {
+	server := apiTestServer()
+	defer server.Close()
+	c := APIClient{Server: server.URL, HTTPClient: server.Client()}
+
+	if !c.Reachable() {
+		t.Errorf("expected the API server to be reachable")
+	}
+	nodes, err := c.ListNodes()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(nodes.Items) != 1 || nodes.Items[0].Name != "worker01" || !nodes.Items[0].Ready() || !nodes.Items[0].Spec.Unschedulable {
+		t.Errorf("unexpected nodes %+v", nodes)
+	}
+	pods, err := c.ListPods()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(pods.Items) != 1 || pods.Items[0].Namespace != "default" {
+		t.Errorf("unexpected pods %+v", pods)
+	}
+	pvs, err := c.ListPersistentVolumes()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(pvs.Items) != 0 {
+		t.Errorf("expected no persistent volumes, got %d", len(pvs.Items))
+	}
+	if _, err = c.GetPersistentVolume("pv"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	ds, err := c.GetDaemonSet("kube-system", "calico-node")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ds.Status.DesiredNumberScheduled != 3 {
+		t.Errorf("expected 3 desired pods, got %d", ds.Status.DesiredNumberScheduled)
+	}
+	daemonSets, err := c.ListDaemonSets("kube-system")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(daemonSets.Items) != 1 || daemonSets.Items[0].Name != "calico-node" {
+		t.Errorf("unexpected daemon sets %+v", daemonSets)
+	}
+	deployments, err := c.ListDeployments("kube-system")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(deployments.Items) != 1 || *deployments.Items[0].Spec.Replicas != 2 || deployments.Items[0].Status.AvailableReplicas != 1 {
+		t.Errorf("unexpected deployments %+v", deployments)
+	}
+	pdbs, err := c.ListPodDisruptionBudgets()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(pdbs.Items) != 1 || pdbs.Items[0].Status.PodDisruptionsAllowed != 1 {
+		t.Errorf("unexpected pod disruption budgets %+v", pdbs)
+	}
+}
+
+func TestAPIClientErrors(t *testing.T) {
+	server := apiTestServer()
+	defer server.Close()
+	c := APIClient{Server: server.URL, HTTPClient: server.Client()}
+
+	_, err := c.GetDeployment("default", "web")
+	if err == nil || err.Error() != "Deployment default/web was not found" {
+		t.Errorf("expected a not found error, got %v", err)
+	}
+	_, err = c.GetEndpoints("default", "broken")
+	if err == nil || !strings.Contains(err.Error(), "403 Forbidden") {
+		t.Errorf("expected a forbidden error, got %v", err)
+	}
+
+	// the client does not trust the certificate of the server
+	c = APIClient{Server: server.URL, HTTPClient: &http.Client{}}
+	if c.Reachable() {
+		t.Errorf("expected the API server to be unreachable")
+	}
+	if _, err = c.ListNodes(); err == nil {
+		t.Errorf("expected an error, but didn't get one")
+	}
+}
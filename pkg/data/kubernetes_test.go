@@ -0,0 +1,93 @@
+package data
+
+import "testing"
+
+func TestUnmarshalPodDisruptionBudgets(t *testing.T) {
+	raw := `{
+	"apiVersion": "v1",
+	"items": [
+		{
+			"apiVersion": "policy/v1beta1",
+			"kind": "PodDisruptionBudget",
+			"metadata": {"name": "web", "namespace": "default"},
+			"spec": {"minAvailable": 2, "selector": {"matchLabels": {"app": "web"}}},
+			"status": {"currentHealthy": 3, "desiredHealthy": 2, "disruptionsAllowed": 1, "expectedPods": 3}
+		}
+	],
+	"kind": "List"
+}`
+	pdbs, err := UnmarshalPodDisruptionBudgets(raw)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(pdbs.Items) != 1 {
+		t.Fatalf("expected 1 PodDisruptionBudget, got %d", len(pdbs.Items))
+	}
+	pdb := pdbs.Items[0]
+	if pdb.Status.PodDisruptionsAllowed != 1 {
+		t.Errorf("expected 1 disruption allowed, got %d", pdb.Status.PodDisruptionsAllowed)
+	}
+	if !pdb.Spec.Selector.Matches(map[string]string{"app": "web", "tier": "frontend"}) {
+		t.Errorf("expected selector to match the labels")
+	}
+
+	pdbs, err = UnmarshalPodDisruptionBudgets("No resources found.")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(pdbs.Items) != 0 {
+		t.Errorf("expected an empty list, got %d items", len(pdbs.Items))
+	}
+}
+
+func TestLabelSelectorMatches(t *testing.T) {
+	labels := map[string]string{"app": "web", "tier": "frontend"}
+	tests := []struct {
+		selector *LabelSelector
+		matches  bool
+	}{
+		{selector: nil, matches: false},
+		{selector: &LabelSelector{}, matches: true},
+		{selector: &LabelSelector{MatchLabels: map[string]string{"app": "web"}}, matches: true},
+		{selector: &LabelSelector{MatchLabels: map[string]string{"app": "db"}}, matches: false},
+		{selector: &LabelSelector{MatchExpressions: []LabelSelectorRequirement{{Key: "tier", Operator: "In", Values: []string{"frontend", "backend"}}}}, matches: true},
+		{selector: &LabelSelector{MatchExpressions: []LabelSelectorRequirement{{Key: "tier", Operator: "NotIn", Values: []string{"frontend"}}}}, matches: false},
+		{selector: &LabelSelector{MatchExpressions: []LabelSelectorRequirement{{Key: "app", Operator: "Exists"}}}, matches: true},
+		{selector: &LabelSelector{MatchExpressions: []LabelSelectorRequirement{{Key: "app", Operator: "DoesNotExist"}}}, matches: false},
+	}
+	for i, test := range tests {
+		if test.selector.Matches(labels) != test.matches {
+			t.Errorf("test %d: expected match to be %v", i, test.matches)
+		}
+	}
+}
+
+func TestEndpointsLeaderElectionRecord(t *testing.T) {
+	e := Endpoints{}
+	record, err := e.LeaderElectionRecord()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if record != nil {
+		t.Errorf("expected no record, got %+v", record)
+	}
+
+	e.Annotations = map[string]string{
+		"control-plane.alpha.kubernetes.io/leader": `{"holderIdentity":"master01","leaseDurationSeconds":15,"acquireTime":"2018-04-03T15:04:05Z","renewTime":"2018-04-03T16:04:05Z","leaderTransitions":1}`,
+	}
+	record, err = e.LeaderElectionRecord()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if record.HolderIdentity != "master01" || record.LeaseDurationSeconds != 15 {
+		t.Errorf("unexpected record %+v", record)
+	}
+	if record.RenewTime.Hour() != 16 {
+		t.Errorf("unexpected renew time %v", record.RenewTime)
+	}
+
+	e.Annotations["control-plane.alpha.kubernetes.io/leader"] = "master01"
+	if _, err = e.LeaderElectionRecord(); err == nil {
+		t.Errorf("expected an error, but didn't get one")
+	}
+}
@@ -1,19 +1,55 @@
 package data
 
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
 type PodList struct {
 	Items []Pod `json:"items"`
 }
 
 type Pod struct {
 	ObjectMeta `json:"metadata,omitempty"`
-	Spec       PodSpec `json:"spec,omitempty"`
+	Spec       PodSpec   `json:"spec,omitempty"`
+	Status     PodStatus `json:"status,omitempty"`
+}
+
+// PodStatus represents information about the status of a pod.
+type PodStatus struct {
+	// Phase is one of Pending, Running, Succeeded, Failed or Unknown.
+	Phase string `json:"phase,omitempty"`
 }
 
 type ObjectMeta struct {
-	Annotations map[string]string `json:"annotations,omitempty"`
-	Name        string            `json:"name,omitempty"`
-	Namespace   string            `json:"namespace,omitempty"`
-	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations     map[string]string `json:"annotations,omitempty"`
+	Name            string            `json:"name,omitempty"`
+	Namespace       string            `json:"namespace,omitempty"`
+	Labels          map[string]string `json:"labels,omitempty"`
+	OwnerReferences []OwnerReference  `json:"ownerReferences,omitempty"`
+}
+
+// OwnerReference contains enough information to let you identify an owning
+// object. Currently, an owning object must be in the same namespace, so there
+// is no namespace field.
+type OwnerReference struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+	// If true, this reference points to the managing controller.
+	Controller *bool `json:"controller,omitempty"`
+}
+
+// ControllerRef returns the reference to the managing controller of the object,
+// or nil if the object is not managed by a controller.
+func (m ObjectMeta) ControllerRef() *OwnerReference {
+	for i, r := range m.OwnerReferences {
+		if r.Controller != nil && *r.Controller {
+			return &m.OwnerReferences[i]
+		}
+	}
+	return nil
 }
 
 // ObjectReference contains enough information to let you inspect or modify the referred object.
@@ -30,9 +66,10 @@ type SerializedReference struct {
 
 // PodSpec is a description of a pod.
 type PodSpec struct {
-	NodeName   string      `json:"nodeName"`
-	Volumes    []Volume    `json:"volumes,omitempty"`
-	Containers []Container `json:"containers"`
+	NodeName       string      `json:"nodeName"`
+	Volumes        []Volume    `json:"volumes,omitempty"`
+	InitContainers []Container `json:"initContainers,omitempty"`
+	Containers     []Container `json:"containers"`
 }
 
 // Volume represents a named volume in a pod that may be accessed by any container in the pod.
@@ -70,8 +107,20 @@ type PersistentVolumeClaimVolumeSource struct {
 
 // A single application container that you want to run within a pod.
 type Container struct {
-	Name         string        `json:"name"`
-	VolumeMounts []VolumeMount `json:"volumeMounts,omitempty"`
+	Name         string               `json:"name"`
+	VolumeMounts []VolumeMount        `json:"volumeMounts,omitempty"`
+	Resources    ResourceRequirements `json:"resources,omitempty"`
+}
+
+// ResourceList is a set of resource names and quantities, such as cpu: 500m
+type ResourceList map[string]string
+
+// ResourceRequirements describes the compute resource requirements of a container.
+type ResourceRequirements struct {
+	// Limits describes the maximum amount of compute resources allowed.
+	Limits ResourceList `json:"limits,omitempty"`
+	// Requests describes the minimum amount of compute resources required.
+	Requests ResourceList `json:"requests,omitempty"`
 }
 
 // VolumeMount describes a mounting of a Volume within a container.
@@ -103,6 +152,16 @@ type PersistentVolume struct {
 type PersistentVolumeSource struct {
 	// HostPath represents a directory on the host.
 	HostPath *HostPathVolumeSource
+	// NFS represents an NFS mount on the host.
+	NFS *NFSVolumeSource `json:"nfs,omitempty"`
+}
+
+// NFSVolumeSource represents an NFS mount that lasts the lifetime of a pod.
+type NFSVolumeSource struct {
+	// Server is the hostname or IP address of the NFS server.
+	Server string `json:"server"`
+	// Path that is exported by the NFS server.
+	Path string `json:"path"`
 }
 
 // PersistentVolumeClaim is a user's request for and claim to a persistent volume
@@ -162,6 +221,52 @@ type DaemonSetList struct {
 	Items []DaemonSet `json:"items"`
 }
 
+// NodeList is a list of nodes
+type NodeList struct {
+	Items []Node `json:"items"`
+}
+
+// Node is a worker node in Kubernetes
+type Node struct {
+	ObjectMeta `json:"metadata,omitempty"`
+	Spec       NodeSpec   `json:"spec,omitempty"`
+	Status     NodeStatus `json:"status,omitempty"`
+}
+
+// NodeSpec describes the attributes that a node is created with.
+type NodeSpec struct {
+	// Unschedulable controls node schedulability of new pods.
+	Unschedulable bool `json:"unschedulable,omitempty"`
+}
+
+// NodeStatus is information about the current status of a node.
+type NodeStatus struct {
+	// Capacity represents the total resources of a node.
+	Capacity ResourceList `json:"capacity,omitempty"`
+	// Allocatable represents the resources of a node that are available for scheduling.
+	Allocatable ResourceList `json:"allocatable,omitempty"`
+	// Conditions is an array of current observed node conditions.
+	Conditions []NodeCondition `json:"conditions,omitempty"`
+}
+
+// NodeCondition contains condition information for a node.
+type NodeCondition struct {
+	// Type of node condition.
+	Type string `json:"type"`
+	// Status of the condition, one of True, False, Unknown.
+	Status string `json:"status"`
+}
+
+// Ready returns true if the node is reporting the Ready condition
+func (n Node) Ready() bool {
+	for _, c := range n.Status.Conditions {
+		if c.Type == "Ready" {
+			return c.Status == "True"
+		}
+	}
+	return false
+}
+
 // DaemonSet represents the configuration of a daemon set.
 type DaemonSet struct {
 	TypeMeta   `json:",inline"`
@@ -234,3 +339,171 @@ type StatefulSetStatus struct {
 	// Replicas is the number of actual replicas.
 	Replicas int32
 }
+
+// Deployment enables declarative updates for Pods and ReplicaSets.
+type Deployment struct {
+	TypeMeta   `json:",inline"`
+	ObjectMeta `json:"metadata,omitempty"`
+
+	// Specification of the desired behavior of the Deployment.
+	Spec DeploymentSpec
+	// Status is the most recently observed status of the Deployment.
+	Status DeploymentStatus
+}
+
+// DeploymentList is a list of Deployments.
+type DeploymentList struct {
+	TypeMeta `json:",inline"`
+	ListMeta `json:"metadata,omitempty"`
+	Items    []Deployment `json:"items"`
+}
+
+// DeploymentSpec is the specification of the desired behavior of the Deployment.
+type DeploymentSpec struct {
+	// Number of desired pods.
+	Replicas *int32
+}
+
+// DeploymentStatus is the most recently observed status of the Deployment.
+type DeploymentStatus struct {
+	// Replicas is the total number of non-terminated pods targeted by this deployment.
+	Replicas int32
+	// Total number of available pods (ready for at least minReadySeconds) targeted by this deployment.
+	AvailableReplicas int32
+}
+
+// Endpoints is a collection of endpoints that implement the actual service.
+// The endpoints of the control plane components are also used for leader election.
+type Endpoints struct {
+	TypeMeta   `json:",inline"`
+	ObjectMeta `json:"metadata,omitempty"`
+}
+
+// leaderAnnotation is the annotation that holds the leader election record
+const leaderAnnotation = "control-plane.alpha.kubernetes.io/leader"
+
+// LeaderElectionRecord is the record that is used in the leader election annotation.
+type LeaderElectionRecord struct {
+	HolderIdentity       string    `json:"holderIdentity"`
+	LeaseDurationSeconds int       `json:"leaseDurationSeconds"`
+	AcquireTime          time.Time `json:"acquireTime"`
+	RenewTime            time.Time `json:"renewTime"`
+}
+
+// LeaderElectionRecord returns the leader election record of the endpoints,
+// or nil if there is none
+func (e Endpoints) LeaderElectionRecord() (*LeaderElectionRecord, error) {
+	raw, ok := e.Annotations[leaderAnnotation]
+	if !ok {
+		return nil, nil
+	}
+	var r LeaderElectionRecord
+	if err := json.Unmarshal([]byte(raw), &r); err != nil {
+		return nil, fmt.Errorf("error unmarshalling leader election record: %v", err)
+	}
+	return &r, nil
+}
+
+// PodDisruptionBudgetList is a collection of PodDisruptionBudgets.
+type PodDisruptionBudgetList struct {
+	TypeMeta `json:",inline"`
+	ListMeta `json:"metadata,omitempty"`
+	Items    []PodDisruptionBudget `json:"items"`
+}
+
+// PodDisruptionBudget is an object to define the max disruption that can be caused to a collection of pods
+type PodDisruptionBudget struct {
+	TypeMeta   `json:",inline"`
+	ObjectMeta `json:"metadata,omitempty"`
+
+	// Specification of the desired behavior of the PodDisruptionBudget.
+	Spec PodDisruptionBudgetSpec `json:"spec,omitempty"`
+	// Most recently observed status of the PodDisruptionBudget.
+	Status PodDisruptionBudgetStatus `json:"status,omitempty"`
+}
+
+// PodDisruptionBudgetSpec is a description of a PodDisruptionBudget.
+type PodDisruptionBudgetSpec struct {
+	// Label query over pods whose evictions are managed by the disruption budget.
+	Selector *LabelSelector `json:"selector,omitempty"`
+}
+
+// PodDisruptionBudgetStatus represents information about the status of a
+// PodDisruptionBudget. Status may trail the actual state of a system.
+type PodDisruptionBudgetStatus struct {
+	// Number of pod disruptions that are currently allowed.
+	PodDisruptionsAllowed int32 `json:"disruptionsAllowed"`
+	// current number of healthy pods
+	CurrentHealthy int32 `json:"currentHealthy"`
+	// minimum desired number of healthy pods
+	DesiredHealthy int32 `json:"desiredHealthy"`
+	// total number of pods counted by this disruption budget
+	ExpectedPods int32 `json:"expectedPods"`
+}
+
+// A LabelSelector is a label query over a set of resources. The result of matchLabels and
+// matchExpressions are ANDed. An empty label selector matches all objects. A null
+// label selector matches no objects.
+type LabelSelector struct {
+	// matchLabels is a map of {key,value} pairs.
+	MatchLabels map[string]string `json:"matchLabels,omitempty"`
+	// matchExpressions is a list of label selector requirements. The requirements are ANDed.
+	MatchExpressions []LabelSelectorRequirement `json:"matchExpressions,omitempty"`
+}
+
+// A LabelSelectorRequirement is a selector that contains values, a key, and an operator that
+// relates the key and values.
+type LabelSelectorRequirement struct {
+	// key is the label key that the selector applies to.
+	Key string `json:"key"`
+	// operator represents a key's relationship to a set of values.
+	// Valid operators are In, NotIn, Exists and DoesNotExist.
+	Operator string `json:"operator"`
+	// values is an array of string values.
+	Values []string `json:"values,omitempty"`
+}
+
+// Matches returns true if the labels satisfy the selector
+func (s *LabelSelector) Matches(labels map[string]string) bool {
+	if s == nil {
+		return false
+	}
+	for k, v := range s.MatchLabels {
+		if val, ok := labels[k]; !ok || val != v {
+			return false
+		}
+	}
+	for _, r := range s.MatchExpressions {
+		val, ok := labels[r.Key]
+		switch r.Operator {
+		case "In":
+			if !ok || !containsString(r.Values, val) {
+				return false
+			}
+		case "NotIn":
+			if ok && containsString(r.Values, val) {
+				return false
+			}
+		case "Exists":
+			if !ok {
+				return false
+			}
+		case "DoesNotExist":
+			if ok {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func containsString(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
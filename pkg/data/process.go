@@ -0,0 +1,55 @@
+package data
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/apprenda/kismatic/pkg/ssh"
+)
+
+// ProcessFlagsGetter gets the command line flags of a process
+type ProcessFlagsGetter interface {
+	GetProcessFlags(name string) (map[string]string, error)
+}
+
+// RemoteProcesses inspects the processes running on the node of the SSH connection
+type RemoteProcesses struct {
+	SSHClient ssh.Client
+}
+
+// GetProcessFlags returns the flags of the first process whose executable has the given name.
+// Returns nil if the process is not running.
+func (p RemoteProcesses) GetProcessFlags(name string) (map[string]string, error) {
+	raw, err := p.SSHClient.Output(true, "ps -ww -eo args=")
+	if err != nil {
+		return nil, fmt.Errorf("error listing processes: %v", err)
+	}
+	return ParseProcessFlags(raw, name), nil
+}
+
+// ParseProcessFlags returns the flags of the first process in the output of
+// "ps -eo args=" whose executable has the given name. Flags without a value are
+// set to "true". Returns nil if the process is not in the output.
+func ParseProcessFlags(psOutput, name string) map[string]string {
+	for _, line := range strings.Split(psOutput, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || path.Base(fields[0]) != name {
+			continue
+		}
+		flags := map[string]string{}
+		for _, f := range fields[1:] {
+			if !strings.HasPrefix(f, "--") {
+				continue
+			}
+			parts := strings.SplitN(strings.TrimPrefix(f, "--"), "=", 2)
+			if len(parts) == 1 {
+				flags[parts[0]] = "true"
+				continue
+			}
+			flags[parts[0]] = parts[1]
+		}
+		return flags
+	}
+	return nil
+}
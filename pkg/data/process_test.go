@@ -0,0 +1,33 @@
+package data
+
+import "testing"
+
+func TestParseProcessFlags(t *testing.T) {
+	ps := `/sbin/init
+/usr/bin/dockerd -H fd://
+/usr/bin/kubelet --allow-privileged=true --v=2 --node-labels=kismatic/ingress=true,foo=bar --fail-swap-on=false --enable-debugging-handlers
+kube-proxy --cluster-cidr=172.16.0.0/16 --hostname-override=worker01
+`
+	flags := ParseProcessFlags(ps, "kubelet")
+	expected := map[string]string{
+		"allow-privileged":          "true",
+		"v":                         "2",
+		"node-labels":               "kismatic/ingress=true,foo=bar",
+		"fail-swap-on":              "false",
+		"enable-debugging-handlers": "true",
+	}
+	if len(flags) != len(expected) {
+		t.Errorf("expected %d flags, got %v", len(expected), flags)
+	}
+	for k, v := range expected {
+		if flags[k] != v {
+			t.Errorf("expected flag %q to be %q, got %q", k, v, flags[k])
+		}
+	}
+	if flags := ParseProcessFlags(ps, "kube-proxy"); flags["hostname-override"] != "worker01" {
+		t.Errorf("unexpected kube-proxy flags %v", flags)
+	}
+	if flags := ParseProcessFlags(ps, "kube-apiserver"); flags != nil {
+		t.Errorf("expected no flags for a process that is not running, got %v", flags)
+	}
+}
@@ -0,0 +1,82 @@
+package data
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+var quantitySuffixes = []struct {
+	suffix     string
+	multiplier float64
+}{
+	// binary suffixes must be checked before the decimal ones that they end with
+	{"Ki", 1 << 10},
+	{"Mi", 1 << 20},
+	{"Gi", 1 << 30},
+	{"Ti", 1 << 40},
+	{"Pi", 1 << 50},
+	{"Ei", 1 << 60},
+	{"n", 1e-9},
+	{"u", 1e-6},
+	{"m", 1e-3},
+	{"k", 1e3},
+	{"M", 1e6},
+	{"G", 1e9},
+	{"T", 1e12},
+	{"P", 1e15},
+	{"E", 1e18},
+}
+
+// ParseQuantity parses a Kubernetes resource quantity, such as 500m, 128Mi or 1e3
+func ParseQuantity(q string) (float64, error) {
+	q = strings.TrimSpace(q)
+	if q == "" {
+		return 0, fmt.Errorf("quantity is empty")
+	}
+	multiplier := 1.0
+	number := q
+	for _, s := range quantitySuffixes {
+		if strings.HasSuffix(q, s.suffix) {
+			number = strings.TrimSuffix(q, s.suffix)
+			multiplier = s.multiplier
+			break
+		}
+	}
+	v, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid quantity %q", q)
+	}
+	return v * multiplier, nil
+}
+
+// MilliCPU returns the CPU quantity of the resource list in millicores
+func (r ResourceList) MilliCPU() (int64, error) {
+	v, err := r.quantity("cpu")
+	return int64(math.Ceil(v * 1000)), err
+}
+
+// MemoryBytes returns the memory quantity of the resource list in bytes
+func (r ResourceList) MemoryBytes() (int64, error) {
+	v, err := r.quantity("memory")
+	return int64(math.Ceil(v)), err
+}
+
+// Pods returns the number of pods in the resource list
+func (r ResourceList) Pods() (int64, error) {
+	v, err := r.quantity("pods")
+	return int64(v), err
+}
+
+func (r ResourceList) quantity(name string) (float64, error) {
+	q, ok := r[name]
+	if !ok {
+		return 0, nil
+	}
+	v, err := ParseQuantity(q)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %v", name, err)
+	}
+	return v, nil
+}
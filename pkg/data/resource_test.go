@@ -0,0 +1,51 @@
+package data
+
+import "testing"
+
+func TestParseQuantity(t *testing.T) {
+	tests := []struct {
+		quantity string
+		value    float64
+		valid    bool
+	}{
+		{quantity: "2", value: 2, valid: true},
+		{quantity: "500m", value: 0.5, valid: true},
+		{quantity: "1.5", value: 1.5, valid: true},
+		{quantity: "128Mi", value: 128 * 1024 * 1024, valid: true},
+		{quantity: "3882364Ki", value: 3882364 * 1024, valid: true},
+		{quantity: "1G", value: 1e9, valid: true},
+		{quantity: "1e3", value: 1000, valid: true},
+		{quantity: "", valid: false},
+		{quantity: "lots", valid: false},
+		{quantity: "Mi", valid: false},
+	}
+	for _, test := range tests {
+		v, err := ParseQuantity(test.quantity)
+		if (err == nil) != test.valid {
+			t.Errorf("%q: expected valid to be %v, got error %v", test.quantity, test.valid, err)
+			continue
+		}
+		if v != test.value {
+			t.Errorf("%q: expected %v, got %v", test.quantity, test.value, v)
+		}
+	}
+}
+
+func TestResourceList(t *testing.T) {
+	r := ResourceList{"cpu": "250m", "memory": "64Mi", "pods": "110"}
+	if cpu, err := r.MilliCPU(); err != nil || cpu != 250 {
+		t.Errorf("expected 250 millicores, got %d (%v)", cpu, err)
+	}
+	if mem, err := r.MemoryBytes(); err != nil || mem != 64*1024*1024 {
+		t.Errorf("expected 64Mi, got %d (%v)", mem, err)
+	}
+	if pods, err := r.Pods(); err != nil || pods != 110 {
+		t.Errorf("expected 110 pods, got %d (%v)", pods, err)
+	}
+	if cpu, err := (ResourceList{}).MilliCPU(); err != nil || cpu != 0 {
+		t.Errorf("expected no cpu, got %d (%v)", cpu, err)
+	}
+	if _, err := (ResourceList{"cpu": "x"}).MilliCPU(); err == nil {
+		t.Errorf("expected an error, but didn't get one")
+	}
+}
@@ -25,6 +25,10 @@ type ListableNode struct {
 	Roles             []string
 	Version           semver.Version
 	ComponentVersions ComponentVersions
+	// Maintenance is set when the node was intentionally taken out of rotation
+	Maintenance *NodeMaintenance `json:",omitempty"`
+	// Facts are set when the facts of the node were gathered
+	Facts *NodeFacts `json:",omitempty"`
 }
 
 type ComponentVersions struct {
@@ -106,7 +110,7 @@ func ListVersions(plan *Plan) (ClusterVersion, error) {
 			}
 		}
 
-		cv.Nodes = append(cv.Nodes, ListableNode{node, plan.GetRolesForIP(node.IP), thisVersion, versions})
+		cv.Nodes = append(cv.Nodes, ListableNode{Node: node, Roles: plan.GetRolesForIP(node.IP), Version: thisVersion, ComponentVersions: versions})
 
 		// If looking at the first node, set the versions and move on
 		if i == 0 {
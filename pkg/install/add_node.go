@@ -7,11 +7,13 @@ import (
 	"github.com/apprenda/kismatic/pkg/util"
 )
 
-var errMissingClusterCA = errors.New("The Certificate Authority's private key and certificate used to install " +
-	"the cluster are required for adding worker nodes.")
+var errMissingClusterCA = errors.New("The Certificate Authority's certificate used to install " +
+	"the cluster is required for adding worker nodes.")
 
 // AddNode adds a worker node to the original cluster described in the plan.
-// If successful, the updated plan is returned.
+// If successful, the updated plan is returned. If existing worker nodes failed
+// to update their hosts files, but the failures were tolerated, the updated plan
+// is returned along with a FailedWorkersError.
 func (ae *ansibleExecutor) AddNode(originalPlan *Plan, newNode Node, roles []string, restartServices bool) (*Plan, error) {
 	if err := checkAddNodePrereqs(ae.pki, newNode); err != nil {
 		return nil, err
@@ -36,6 +38,7 @@ func (ae *ansibleExecutor) AddNode(originalPlan *Plan, newNode Node, roles []str
 	}
 
 	// We need to run ansible against all hosts to update the hosts files
+	var failedWorkers *FailedWorkersError
 	if updatedPlan.Cluster.Networking.UpdateHostsFiles {
 		util.PrintHeader(ae.stdout, "Updating Hosts Files On All Nodes", '=')
 		t := task{
@@ -45,9 +48,17 @@ func (ae *ansibleExecutor) AddNode(originalPlan *Plan, newNode Node, roles []str
 			inventory:      inventory,
 			clusterCatalog: *cc,
 			explainer:      ae.defaultExplainer(),
+
+			tolerateWorkerFailures: true,
+			protectedHosts:         []string{newNode.Host},
 		}
 		if err = ae.execute(t); err != nil {
-			return nil, fmt.Errorf("error updating hosts files on all nodes: %v", err)
+			fwErr, ok := err.(FailedWorkersError)
+			if !ok {
+				return nil, fmt.Errorf("error updating hosts files on all nodes: %v", err)
+			}
+			util.PrettyPrintWarn(ae.stdout, "Failed to update hosts files on %d worker node(s), continuing", len(fwErr.Hosts))
+			failedWorkers = &fwErr
 		}
 	}
 
@@ -99,6 +110,9 @@ func (ae *ansibleExecutor) AddNode(originalPlan *Plan, newNode Node, roles []str
 			return nil, fmt.Errorf("error adding new node to volume allow list: %v", err)
 		}
 	}
+	if failedWorkers != nil {
+		return &updatedPlan, *failedWorkers
+	}
 	return &updatedPlan, nil
 }
 
@@ -526,7 +526,7 @@ func (f *fakePKI) GenerateProxyClientCA(p *Plan) (*tls.CA, error) {
 func (f *fakePKI) GenerateClusterCertificates(p *Plan, clusterCA *tls.CA, proxyClientCA *tls.CA) error {
 	return f.err
 }
-func (f *fakePKI) GenerateCertificate(name string, validityPeriod string, commonName string, subjectAlternateNames []string, organizations []string, ca *tls.CA, overwrite bool) (bool, error) {
+func (f *fakePKI) GenerateCertificate(p *Plan, name string, validityPeriod string, commonName string, subjectAlternateNames []string, organizations []string, ca *tls.CA, overwrite bool) (bool, error) {
 	return false, f.err
 }
 
@@ -542,6 +542,7 @@ func (f *fakeRunner) StartPlaybook(playbookFile string, inventory ansible.Invent
 	return f.eventChan, f.err
 }
 func (f *fakeRunner) WaitPlaybook() error { return f.err }
+func (f *fakeRunner) StopPlaybook() error { return nil }
 func (f *fakeRunner) StartPlaybookOnNode(playbookFile string, inventory ansible.Inventory, cc ansible.ClusterCatalog, node ...string) (<-chan ansible.Event, error) {
 	f.incomingCatalog = cc
 	return f.eventChan, f.err
@@ -0,0 +1,296 @@
+package analyze
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/apprenda/kismatic/pkg/ansible"
+)
+
+// maxEvidenceLength is the maximum length of the output that is kept as evidence of a finding
+const maxEvidenceLength = 200
+
+var (
+	// ansibleFailedHost matches the lines of the ansible log that report a failure on a host
+	ansibleFailedHost = regexp.MustCompile(`(?:fatal|failed): \[([^\]]+)\]`)
+	// ansibleTaskStart matches the lines of the ansible log that start a play or a task
+	ansibleTaskStart = regexp.MustCompile(`(?:PLAY|TASK|RUNNING HANDLER) \[`)
+)
+
+// Finding is a known failure signature that was found in the output of a run
+type Finding struct {
+	// Rule is the name of the rule that matched
+	Rule string `json:"rule"`
+	// Cause is the probable cause of the failure
+	Cause string `json:"cause"`
+	// Remediation are the steps to fix the failure
+	Remediation string `json:"remediation,omitempty"`
+	// Host is the node where the failure happened, if known
+	Host string `json:"host,omitempty"`
+	// Source is where the failure was found, such as the name of a task or a file
+	Source string `json:"source"`
+	// Evidence is the first line of output that matched the rule
+	Evidence string `json:"evidence"`
+	// Occurrences is the number of lines that matched the rule
+	Occurrences int `json:"occurrences"`
+}
+
+// Analyzer scans the output of runs and diagnostics for known failure signatures.
+// It is safe for concurrent use.
+type Analyzer struct {
+	rules    []Rule
+	lock     sync.Mutex
+	findings []*Finding
+	index    map[string]*Finding
+	task     string
+	done     chan struct{}
+}
+
+// NewAnalyzer returns an analyzer that uses the given rules
+func NewAnalyzer(rules []Rule) (*Analyzer, error) {
+	for i := range rules {
+		if errs := rules[i].Validate(); len(errs) > 0 {
+			return nil, fmt.Errorf("rule %q is invalid: %v", rules[i].Name, errs)
+		}
+	}
+	return &Analyzer{
+		rules: rules,
+		index: map[string]*Finding{},
+		done:  make(chan struct{}),
+	}, nil
+}
+
+// Findings returns the failure signatures found so far, in the order they were found
+func (a *Analyzer) Findings() []Finding {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	findings := make([]Finding, 0, len(a.findings))
+	for _, f := range a.findings {
+		findings = append(findings, *f)
+	}
+	return findings
+}
+
+// Watch the incoming event stream. All events are forwarded to the returned channel.
+func (a *Analyzer) Watch(in <-chan ansible.Event) <-chan ansible.Event {
+	out := make(chan ansible.Event)
+	go func() {
+		defer close(a.done)
+		defer close(out)
+		for e := range in {
+			a.AnalyzeEvent(e)
+			out <- e
+		}
+	}()
+	return out
+}
+
+// Done returns a channel that is closed once the end of the watched
+// event stream has been processed.
+func (a *Analyzer) Done() <-chan struct{} {
+	return a.done
+}
+
+// AnalyzeEvent scans the output of failed and unreachable runners.
+// Failures that are ignored by the playbook are not analyzed.
+func (a *Analyzer) AnalyzeEvent(e ansible.Event) {
+	var host string
+	var output []string
+	switch event := e.(type) {
+	case *ansible.TaskStartEvent:
+		a.lock.Lock()
+		a.task = event.Name
+		a.lock.Unlock()
+		return
+	case *ansible.RunnerFailedEvent:
+		if event.IgnoreErrors {
+			return
+		}
+		host = event.Host
+		output = []string{event.Result.Stdout, event.Result.Stderr, event.Result.Message}
+	case *ansible.RunnerItemFailedEvent:
+		if event.IgnoreErrors {
+			return
+		}
+		host = event.Host
+		output = []string{event.Result.Stdout, event.Result.Stderr, event.Result.Message}
+	case *ansible.RunnerUnreachableEvent:
+		host = event.Host
+		output = []string{event.Result.Stdout, event.Result.Stderr, event.Result.Message}
+	default:
+		return
+	}
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	for _, o := range output {
+		for _, line := range strings.Split(o, "\n") {
+			a.match(host, a.task, line)
+		}
+	}
+}
+
+// AnalyzeText scans every line of the reader. When the output of an ansible
+// log reports the failure of a task, the failure is attributed to its host.
+func (a *Analyzer) AnalyzeText(source string, r io.Reader) error {
+	return a.analyzeText("", source, r)
+}
+
+func (a *Analyzer) analyzeText(host, source string, r io.Reader) error {
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 64*1024), 10*1024*1024)
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	h := host
+	for s.Scan() {
+		line := s.Text()
+		// the result of a failed task spans the lines up to the next task
+		if m := ansibleFailedHost.FindStringSubmatch(line); m != nil {
+			h = m[1]
+		} else if ansibleTaskStart.MatchString(line) {
+			h = host
+		}
+		// the output of a task is escaped within its result
+		for _, l := range strings.Split(line, `\n`) {
+			a.match(h, source, l)
+		}
+	}
+	if err := s.Err(); err != nil {
+		return fmt.Errorf("error reading %s: %v", source, err)
+	}
+	return nil
+}
+
+// AnalyzeRun scans the ansible log of a run directory
+func (a *Analyzer) AnalyzeRun(runDirectory string) error {
+	file := filepath.Join(runDirectory, "ansible.log")
+	f, err := os.Open(file)
+	if err != nil {
+		return fmt.Errorf("error reading ansible log: %v", err)
+	}
+	defer f.Close()
+	return a.AnalyzeText(file, f)
+}
+
+// AnalyzeBundle scans every file of a diagnostics archive. Failures found in the
+// diagnostics of a node are attributed to that node.
+func (a *Analyzer) AnalyzeBundle(archive string) error {
+	f, err := os.Open(archive)
+	if err != nil {
+		return fmt.Errorf("error reading diagnostics archive: %v", err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("error reading diagnostics archive %q: %v", archive, err)
+	}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("error reading diagnostics archive %q: %v", archive, err)
+		}
+		if hdr.Typeflag != tar.TypeReg && hdr.Typeflag != tar.TypeRegA {
+			continue
+		}
+		name := bundlePath(hdr.Name)
+		host := ""
+		if parts := strings.Split(name, "/"); len(parts) > 2 && parts[0] == "nodes" {
+			host = parts[1]
+		}
+		if err := a.analyzeText(host, name, tr); err != nil {
+			return err
+		}
+	}
+}
+
+// bundlePath returns the path of the file relative to the root directory of the bundle
+func bundlePath(name string) string {
+	name = strings.TrimPrefix(path.Clean(name), "./")
+	if i := strings.Index(name, "/"); i >= 0 {
+		return name[i+1:]
+	}
+	return name
+}
+
+// match records a finding for every rule that matches the line. The lock must be held.
+func (a *Analyzer) match(host, source, line string) {
+	for _, r := range a.rules {
+		if !r.re.MatchString(line) {
+			continue
+		}
+		key := strings.Join([]string{r.Name, host, source}, "\x00")
+		if f, ok := a.index[key]; ok {
+			f.Occurrences++
+			continue
+		}
+		f := &Finding{
+			Rule:        r.Name,
+			Cause:       r.Cause,
+			Remediation: r.Remediation,
+			Host:        host,
+			Source:      source,
+			Evidence:    evidence(line, r.re),
+			Occurrences: 1,
+		}
+		a.index[key] = f
+		a.findings = append(a.findings, f)
+	}
+}
+
+// evidence returns the part of the line around the match
+func evidence(line string, re *regexp.Regexp) string {
+	line = strings.TrimSpace(line)
+	if len(line) <= maxEvidenceLength {
+		return line
+	}
+	loc := re.FindStringIndex(line)
+	start := loc[0] - (maxEvidenceLength-(loc[1]-loc[0]))/2
+	if start < 0 {
+		start = 0
+	}
+	end := start + maxEvidenceLength
+	if end > len(line) {
+		end = len(line)
+		start = end - maxEvidenceLength
+	}
+	e := line[start:end]
+	if start > 0 {
+		e = "..." + e
+	}
+	if end < len(line) {
+		e = e + "..."
+	}
+	return e
+}
+
+// PrintFindings writes the findings to the writer in a human readable format
+func PrintFindings(out io.Writer, findings []Finding) {
+	for _, f := range findings {
+		where := f.Source
+		if f.Host != "" {
+			where = fmt.Sprintf("%s (%s)", f.Host, f.Source)
+		}
+		fmt.Fprintf(out, "%s on %s\n", f.Rule, where)
+		fmt.Fprintf(out, "  Evidence: %s\n", f.Evidence)
+		if f.Occurrences > 1 {
+			fmt.Fprintf(out, "  Occurrences: %d\n", f.Occurrences)
+		}
+		fmt.Fprintf(out, "  Probable cause: %s\n", f.Cause)
+		if f.Remediation != "" {
+			fmt.Fprintf(out, "  Remediation: %s\n", f.Remediation)
+		}
+		fmt.Fprintln(out)
+	}
+}
@@ -0,0 +1,173 @@
+package analyze
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/apprenda/kismatic/pkg/ansible"
+)
+
+func newTestAnalyzer(t *testing.T) *Analyzer {
+	a, err := NewAnalyzer(DefaultRules())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return a
+}
+
+func failedEvent(host, stderr string, ignoreErrors bool) *ansible.RunnerFailedEvent {
+	e := &ansible.RunnerFailedEvent{}
+	e.Host = host
+	e.Result.Stderr = stderr
+	e.IgnoreErrors = ignoreErrors
+	return e
+}
+
+func TestAnalyzeEvent(t *testing.T) {
+	a := newTestAnalyzer(t)
+	task := &ansible.TaskStartEvent{}
+	task.Name = "install packages"
+	a.AnalyzeEvent(task)
+	a.AnalyzeEvent(failedEvent("worker01", "Loaded plugins: fastestmirror\nExisting lock /var/run/yum.pid: another copy is running as pid 1234.", false))
+	a.AnalyzeEvent(failedEvent("worker01", "Existing lock /var/run/yum.pid: another copy is running as pid 1234.", false))
+	a.AnalyzeEvent(failedEvent("worker02", "listen tcp 0.0.0.0:10250: bind: address already in use", true))
+	unreachable := &ansible.RunnerUnreachableEvent{}
+	unreachable.Host = "worker03"
+	unreachable.Result.Message = "Failed to connect to the host via ssh: Permission denied (publickey,gssapi-keyex)."
+	a.AnalyzeEvent(unreachable)
+
+	findings := a.Findings()
+	if len(findings) != 2 {
+		t.Fatalf("expected 2 findings, got %+v", findings)
+	}
+	f := findings[0]
+	if f.Rule != "PackageManagerLocked" || f.Host != "worker01" || f.Source != "install packages" || f.Occurrences != 2 {
+		t.Errorf("unexpected finding %+v", f)
+	}
+	if f.Evidence != "Existing lock /var/run/yum.pid: another copy is running as pid 1234." {
+		t.Errorf("unexpected evidence %q", f.Evidence)
+	}
+	if findings[1].Rule != "SSHUnreachable" || findings[1].Host != "worker03" {
+		t.Errorf("unexpected finding %+v", findings[1])
+	}
+}
+
+func TestAnalyzeText(t *testing.T) {
+	log := `2018-04-01T10:00:00Z TASK [docker : pull images] ****
+2018-04-01T10:00:01Z fatal: [worker01]: FAILED! => {"changed": true, "stderr": "Error response from daemon: Get https://registry:8443/v2/: x509: certificate has expired or is not yet valid\nexit status 1"}
+2018-04-01T10:00:02Z TASK [etcd : verify health] ****
+2018-04-01T10:00:03Z Error: client: etcd cluster is unavailable or misconfigured
+`
+	a := newTestAnalyzer(t)
+	if err := a.AnalyzeText("ansible.log", strings.NewReader(log)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	findings := a.Findings()
+	if len(findings) != 2 {
+		t.Fatalf("expected 2 findings, got %+v", findings)
+	}
+	if findings[0].Rule != "ClockSkew" || findings[0].Host != "worker01" {
+		t.Errorf("unexpected finding %+v", findings[0])
+	}
+	if findings[1].Rule != "EtcdQuorumLost" || findings[1].Host != "" {
+		t.Errorf("unexpected finding %+v", findings[1])
+	}
+}
+
+func TestAnalyzeBundle(t *testing.T) {
+	dir, err := ioutil.TempDir("", "analyze-test")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	files := []struct{ name, contents string }{
+		{"diagnostics-1/kismatic-cluster.yaml", "cluster:\n  name: test\n"},
+		{"diagnostics-1/nodes/master01/journalctl_docker.log", "Error response from daemon: pull access denied for registry/kube-apiserver\n"},
+		{"diagnostics-1/nodes/worker01/df.log", "write /var/lib/docker/tmp: no space left on device\n"},
+	}
+	for _, f := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: f.name, Mode: 0644, Size: int64(len(f.contents))}); err != nil {
+			t.Fatalf("error writing archive: %v", err)
+		}
+		if _, err := tw.Write([]byte(f.contents)); err != nil {
+			t.Fatalf("error writing archive: %v", err)
+		}
+	}
+	tw.Close()
+	gz.Close()
+	archive := filepath.Join(dir, "diagnostics-1.tar.gz")
+	if err := ioutil.WriteFile(archive, buf.Bytes(), 0644); err != nil {
+		t.Fatalf("error writing archive: %v", err)
+	}
+
+	a := newTestAnalyzer(t)
+	if err := a.AnalyzeBundle(archive); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []Finding{
+		{Rule: "ImagePullUnauthorized", Host: "master01", Source: "nodes/master01/journalctl_docker.log"},
+		{Rule: "DiskFull", Host: "worker01", Source: "nodes/worker01/df.log"},
+	}
+	findings := a.Findings()
+	if len(findings) != len(expected) {
+		t.Fatalf("expected %d findings, got %+v", len(expected), findings)
+	}
+	for i, f := range findings {
+		if f.Rule != expected[i].Rule || f.Host != expected[i].Host || f.Source != expected[i].Source {
+			t.Errorf("finding %d: expected %+v, got %+v", i, expected[i], f)
+		}
+	}
+}
+
+func TestEvidence(t *testing.T) {
+	line := strings.Repeat("a", 300) + " address already in use " + strings.Repeat("b", 300)
+	a, err := NewAnalyzer([]Rule{{Name: "PortInUse", Match: "address already in use", Cause: "port"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	a.AnalyzeText("test", strings.NewReader(line))
+	findings := a.Findings()
+	if len(findings) != 1 {
+		t.Fatalf("expected 1 finding, got %+v", findings)
+	}
+	e := findings[0].Evidence
+	if !strings.Contains(e, "address already in use") || !strings.HasPrefix(e, "...") || !strings.HasSuffix(e, "...") {
+		t.Errorf("unexpected evidence %q", e)
+	}
+}
+
+func TestPrintFindings(t *testing.T) {
+	var buf bytes.Buffer
+	PrintFindings(&buf, []Finding{{Rule: "DiskFull", Cause: "A filesystem of the node is full.", Remediation: "Free space.", Host: "worker01", Source: "df.log", Evidence: "no space left on device", Occurrences: 3}})
+	expected := `DiskFull on worker01 (df.log)
+  Evidence: no space left on device
+  Occurrences: 3
+  Probable cause: A filesystem of the node is full.
+  Remediation: Free space.
+
+`
+	if buf.String() != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, buf.String())
+	}
+}
+
+func TestFindingJSON(t *testing.T) {
+	b, err := json.Marshal(Finding{Rule: "DiskFull", Cause: "A filesystem of the node is full.", Host: "worker01", Source: "df.log", Evidence: "no space left on device", Occurrences: 1})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := `{"rule":"DiskFull","cause":"A filesystem of the node is full.","host":"worker01","source":"df.log","evidence":"no space left on device","occurrences":1}`
+	if string(b) != expected {
+		t.Errorf("expected %s, got %s", expected, b)
+	}
+}
@@ -0,0 +1,4 @@
+// Package analyze contains a rules-driven analyzer that scans the output of
+// failed runs and the diagnostics of the cluster for known failure signatures,
+// and explains their probable cause and remediation.
+package analyze
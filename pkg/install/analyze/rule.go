@@ -0,0 +1,122 @@
+package analyze
+
+import (
+	"fmt"
+	"io/ioutil"
+	"regexp"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+/*
+- name: __RuleName__
+  match: __regular expression__
+  cause: __probable cause of the failure__
+  remediation: __steps to fix the failure__
+
+The rule matches every line of output that matches the regular expression.
+*/
+
+// defaultRuleSet is the list of failure signatures that are built into the analyzer
+const defaultRuleSet = `---
+- name: PackageManagerLocked
+  match: 'another app is currently holding the yum lock|Existing lock /var/run/yum\.pid|Could not get lock /var/lib/dpkg/lock'
+  cause: The package manager of the node is locked by another process, such as an automatic update.
+  remediation: Wait for the other package manager process to finish, or stop it (for example yum-cron or unattended-upgrades), and run the installation again.
+
+- name: ImagePullUnauthorized
+  match: 'unauthorized: authentication required|unauthorized: incorrect username or password|no basic auth credentials|pull access denied'
+  cause: Docker could not authenticate with the image registry.
+  remediation: Verify the username and password of the docker_registry in the plan file, and that the images were pushed to the registry with "kismatic seed-registry".
+
+- name: PortInUse
+  match: 'address already in use'
+  cause: A port that is required by a cluster component is already used by another process on the node.
+  remediation: Find the process that is listening on the port with "ss -tlnp" on the node, and stop it. Use "kismatic install validate" to verify the ports required by the cluster.
+
+- name: ClockSkew
+  match: 'x509: certificate has expired or is not yet valid|certificate is not yet valid|clock skew'
+  cause: The clocks of the nodes are not synchronized, so valid certificates are rejected as expired or not yet valid.
+  remediation: Synchronize the clocks of all nodes with NTP, for example using chronyd or ntpd, and verify them with "date" on each node. If the clocks are correct, verify the expiry of the certificates with "kismatic health".
+
+- name: EtcdQuorumLost
+  match: 'etcdserver: no leader|etcdserver: request timed out|cluster is unavailable or misconfigured|cluster is unhealthy|rafthttp: failed to reach the peer'
+  cause: The etcd cluster lost quorum, as more than half of its members are unreachable.
+  remediation: Verify the etcd members with "kismatic health", and bring the unreachable etcd nodes back online. Check the etcd logs on each node with "journalctl -u etcd_k8s".
+
+- name: SSHUnreachable
+  match: 'Permission denied \(publickey|ssh: connect to host .* (Connection refused|Connection timed out|No route to host)'
+  cause: The node could not be reached over SSH.
+  remediation: Verify the ssh user, key and port in the plan file, and that the node is reachable with "kismatic ssh NODE".
+
+- name: DiskFull
+  match: 'no space left on device'
+  cause: A filesystem of the node is full.
+  remediation: Free space on the node, for example by removing unused images with "docker image prune". The "/var/lib/docker" and "/var/lib/etcd" directories are the usual culprits.
+`
+
+// Rule is a failure signature, along with its probable cause and remediation
+type Rule struct {
+	Name        string
+	Match       string
+	Cause       string
+	Remediation string
+
+	re *regexp.Regexp
+}
+
+// Validate the rule, and compile its regular expression
+func (r *Rule) Validate() []error {
+	errs := []error{}
+	if r.Name == "" {
+		errs = append(errs, fmt.Errorf("name cannot be empty"))
+	}
+	if r.Match == "" {
+		errs = append(errs, fmt.Errorf("match cannot be empty"))
+	}
+	if r.Cause == "" {
+		errs = append(errs, fmt.Errorf("cause cannot be empty"))
+	}
+	re, err := regexp.Compile(r.Match)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("invalid match %q: %v", r.Match, err))
+	}
+	r.re = re
+	return errs
+}
+
+// DefaultRules returns the list of rules that are built into the analyzer
+func DefaultRules() []Rule {
+	rules, err := UnmarshalRulesYAML([]byte(defaultRuleSet))
+	if err != nil {
+		panic(fmt.Sprintf("error reading default rules: %v", err))
+	}
+	return rules
+}
+
+// ReadFromFile returns the list of rules contained in the specified file
+func ReadFromFile(file string) ([]Rule, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("error reading rules file %q: %v", file, err)
+	}
+	rules, err := UnmarshalRulesYAML(b)
+	if err != nil {
+		return nil, fmt.Errorf("error reading rules from %q: %v", file, err)
+	}
+	return rules, nil
+}
+
+// UnmarshalRulesYAML unmarshals and validates the list of rules
+func UnmarshalRulesYAML(data []byte) ([]Rule, error) {
+	rules := []Rule{}
+	if err := yaml.Unmarshal(data, &rules); err != nil {
+		return nil, err
+	}
+	for i := range rules {
+		if errs := rules[i].Validate(); len(errs) > 0 {
+			return nil, fmt.Errorf("rule %q is invalid: %v", rules[i].Name, errs)
+		}
+	}
+	return rules, nil
+}
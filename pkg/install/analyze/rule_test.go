@@ -0,0 +1,34 @@
+package analyze
+
+import "testing"
+
+func TestDefaultRules(t *testing.T) {
+	rules := DefaultRules()
+	if len(rules) == 0 {
+		t.Fatal("expected default rules")
+	}
+	names := map[string]bool{}
+	for _, r := range rules {
+		if names[r.Name] {
+			t.Errorf("rule %q is defined more than once", r.Name)
+		}
+		names[r.Name] = true
+		if r.Remediation == "" {
+			t.Errorf("rule %q has no remediation", r.Name)
+		}
+	}
+}
+
+func TestUnmarshalRulesYAMLInvalid(t *testing.T) {
+	tests := []string{
+		"- name: NoMatch\n  cause: foo",
+		"- name: BadMatch\n  match: '(foo'\n  cause: foo",
+		"- match: foo\n  cause: foo",
+		"- name: NoCause\n  match: foo",
+	}
+	for _, test := range tests {
+		if _, err := UnmarshalRulesYAML([]byte(test)); err == nil {
+			t.Errorf("expected an error for %q", test)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package install
+
+import (
+	"bytes"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/apprenda/kismatic/pkg/tls"
+	"github.com/cloudflare/cfssl/helpers"
+)
+
+const (
+	// caChainFilename is the chain of the CAs that issued the cluster CA, when the
+	// cluster CA is an intermediate CA. The chain ends with the trust anchor.
+	caChainFilename = "ca-chain"
+	// caTrustFilename is the bundle of CAs that is deployed to the nodes, instead
+	// of the CA, when the cluster CA is an intermediate CA
+	caTrustFilename = "ca-trust"
+)
+
+var errMissingCAChain = fmt.Errorf("trust_anchor_only is set, but the CA chain %s.pem was not found", caChainFilename)
+
+// clusterCAChain is the chain of an intermediate cluster CA
+type clusterCAChain struct {
+	// ca is the certificate of the cluster CA
+	ca *x509.Certificate
+	// intermediates are the CAs between the cluster CA and the trust anchor
+	intermediates []*x509.Certificate
+	// anchor is the self-signed CA at the end of the chain
+	anchor *x509.Certificate
+}
+
+// readClusterCAChain reads and verifies the chain of the cluster CA. Returns
+// nil if the cluster CA is not an intermediate CA.
+func readClusterCAChain(dir string) (*clusterCAChain, error) {
+	chainBytes, err := ioutil.ReadFile(filepath.Join(dir, caChainFilename+".pem"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading CA chain: %v", err)
+	}
+	chainCerts, err := helpers.ParseCertificatesPEM(chainBytes)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing CA chain: %v", err)
+	}
+	caBytes, err := ioutil.ReadFile(filepath.Join(dir, "ca.pem"))
+	if err != nil {
+		return nil, fmt.Errorf("error reading CA certificate: %v", err)
+	}
+	ca, err := helpers.ParseCertificatePEM(caBytes)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing CA certificate: %v", err)
+	}
+	return verifyClusterCAChain(ca, chainCerts)
+}
+
+// verifyClusterCAChain verifies that the CA was issued by the chain, and that
+// the chain ends with a self-signed trust anchor
+func verifyClusterCAChain(ca *x509.Certificate, chainCerts []*x509.Certificate) (*clusterCAChain, error) {
+	if !ca.IsCA {
+		return nil, fmt.Errorf("the cluster CA certificate %q is not a CA", ca.Subject.CommonName)
+	}
+	if len(chainCerts) == 0 {
+		return nil, fmt.Errorf("the CA chain does not contain any certificates")
+	}
+	chain := &clusterCAChain{ca: ca}
+	for _, c := range chainCerts {
+		if !c.IsCA {
+			return nil, fmt.Errorf("certificate %q of the CA chain is not a CA", c.Subject.CommonName)
+		}
+		if bytes.Equal(c.RawIssuer, c.RawSubject) && c.CheckSignatureFrom(c) == nil {
+			if chain.anchor != nil {
+				return nil, fmt.Errorf("the CA chain contains more than one self-signed CA")
+			}
+			chain.anchor = c
+			continue
+		}
+		chain.intermediates = append(chain.intermediates, c)
+	}
+	if chain.anchor == nil {
+		return nil, fmt.Errorf("the CA chain does not end with a self-signed CA")
+	}
+	if _, err := ca.Verify(chain.verifyOptions()); err != nil {
+		return nil, fmt.Errorf("the cluster CA certificate %q was not issued by the CA chain: %v", ca.Subject.CommonName, err)
+	}
+	return chain, nil
+}
+
+func (c *clusterCAChain) verifyOptions() x509.VerifyOptions {
+	opts := x509.VerifyOptions{
+		Roots:         x509.NewCertPool(),
+		Intermediates: x509.NewCertPool(),
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}
+	opts.Roots.AddCert(c.anchor)
+	for _, i := range c.intermediates {
+		opts.Intermediates.AddCert(i)
+	}
+	return opts
+}
+
+// issuerBundle returns the certificates that are bundled with the certificates issued by the cluster CA
+func (c *clusterCAChain) issuerBundle() []byte {
+	certs := [][]byte{helpers.EncodeCertificatePEM(c.ca)}
+	for _, i := range c.intermediates {
+		certs = append(certs, helpers.EncodeCertificatePEM(i))
+	}
+	return tls.Bundle(certs...)
+}
+
+// trustBundle returns the CAs that are trusted by the nodes. When anchorOnly is
+// set, only the trust anchor is trusted, and the nodes rely on the chain that is
+// bundled with the certificates to verify them.
+func (c *clusterCAChain) trustBundle(anchorOnly bool) []byte {
+	if anchorOnly {
+		return tls.Bundle(helpers.EncodeCertificatePEM(c.anchor))
+	}
+	return tls.Bundle(c.issuerBundle(), helpers.EncodeCertificatePEM(c.anchor))
+}
+
+// writeClusterCATrust writes the bundle of CAs that is deployed to the nodes when
+// the cluster CA is an intermediate CA, and removes it otherwise
+func (lp *LocalPKI) writeClusterCATrust(p *Plan) error {
+	trustFile := filepath.Join(lp.GeneratedCertsDirectory, caTrustFilename+".pem")
+	chain, err := readClusterCAChain(lp.GeneratedCertsDirectory)
+	if err != nil {
+		return err
+	}
+	if chain == nil {
+		if p.Cluster.Certificates.TrustAnchorOnly {
+			return errMissingCAChain
+		}
+		if err = os.Remove(trustFile); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("error removing CA trust bundle: %v", err)
+		}
+		return nil
+	}
+	if err = ioutil.WriteFile(trustFile, chain.trustBundle(p.Cluster.Certificates.TrustAnchorOnly), 0644); err != nil {
+		return fmt.Errorf("error writing CA trust bundle: %v", err)
+	}
+	return nil
+}
+
+// ValidateClusterCAChain verifies the chain of the cluster CA when the cluster CA
+// is an intermediate CA, and that the certificates issued by the cluster CA can be
+// verified by the trust anchor of the chain
+func (lp *LocalPKI) ValidateClusterCAChain(p *Plan) []error {
+	chain, err := readClusterCAChain(lp.GeneratedCertsDirectory)
+	if err != nil {
+		return []error{err}
+	}
+	if chain == nil {
+		if p.Cluster.Certificates.TrustAnchorOnly {
+			return []error{errMissingCAChain}
+		}
+		return nil
+	}
+	var errs []error
+	ca, err := lp.GetClusterCA()
+	if err != nil {
+		return []error{err}
+	}
+	// The private key of the CA is not available when the certificates are signed externally
+	if len(ca.Key) > 0 {
+		ok, err := tls.CertMatchesKey(chain.ca, "ca", lp.GeneratedCertsDirectory)
+		if err != nil {
+			return []error{err}
+		}
+		if !ok {
+			errs = append(errs, fmt.Errorf("the CA private key does not match the CA certificate"))
+		}
+	}
+	manifest, err := p.certSpecs(nil, nil)
+	if err != nil {
+		return append(errs, err)
+	}
+	opts := chain.verifyOptions()
+	opts.Intermediates.AddCert(chain.ca)
+	for _, s := range manifest {
+		// The proxy-client certificate is issued by the proxy-client CA
+		if s.filename == proxyClientCertFilename {
+			continue
+		}
+		certBytes, err := ioutil.ReadFile(filepath.Join(lp.GeneratedCertsDirectory, s.filename+".pem"))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("error reading certificate %q: %v", s.filename, err))
+			continue
+		}
+		certs, err := helpers.ParseCertificatesPEM(certBytes)
+		if err != nil || len(certs) == 0 {
+			errs = append(errs, fmt.Errorf("error parsing certificate %q: %v", s.filename, err))
+			continue
+		}
+		if len(certs) < 2 && p.Cluster.Certificates.TrustAnchorOnly {
+			errs = append(errs, fmt.Errorf("Certificate \"%s.pem\": the CA chain is not bundled with the certificate", s.filename))
+		}
+		if _, err := certs[0].Verify(opts); err != nil {
+			errs = append(errs, fmt.Errorf("Certificate \"%s.pem\": was not issued by the cluster CA: %v", s.filename, err))
+		}
+	}
+	return errs
+}
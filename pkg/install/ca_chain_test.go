@@ -0,0 +1,217 @@
+package install
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apprenda/kismatic/pkg/tls"
+	"github.com/apprenda/kismatic/pkg/util"
+	"github.com/cloudflare/cfssl/helpers"
+)
+
+// newIntermediateCA returns the key and certificate of a CA issued by the parent CA
+func newIntermediateCA(t *testing.T, parent *tls.CA, commonName string) (key, cert []byte) {
+	parentKey, err := helpers.ParsePrivateKeyPEM(parent.Key)
+	if err != nil {
+		t.Fatalf("error parsing parent CA key: %v", err)
+	}
+	parentCert, err := helpers.ParseCertificatePEM(parent.Cert)
+	if err != nil {
+		t.Fatalf("error parsing parent CA certificate: %v", err)
+	}
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("error generating key: %v", err)
+	}
+	tpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tpl, parentCert, &priv.PublicKey, parentKey)
+	if err != nil {
+		t.Fatalf("error creating intermediate CA: %v", err)
+	}
+	key = pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(priv)})
+	cert = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	return key, cert
+}
+
+// writeIntermediateClusterCA writes a cluster CA that is issued by an intermediate
+// CA, which is in turn issued by a root CA. Returns the root CA certificate.
+func writeIntermediateClusterCA(t *testing.T, dir string) []byte {
+	rootKey, rootCert, err := tls.NewCACert("test/ca-csr.json", "root", "2h")
+	if err != nil {
+		t.Fatalf("error creating root CA: %v", err)
+	}
+	intermediateKey, intermediateCert := newIntermediateCA(t, &tls.CA{Key: rootKey, Cert: rootCert}, "intermediate")
+	key, cert := newIntermediateCA(t, &tls.CA{Key: intermediateKey, Cert: intermediateCert}, "cluster")
+	if err = tls.WriteCert(key, cert, "ca", dir); err != nil {
+		t.Fatalf("error writing cluster CA: %v", err)
+	}
+	if err = ioutil.WriteFile(filepath.Join(dir, caChainFilename+".pem"), tls.Bundle(intermediateCert, rootCert), 0644); err != nil {
+		t.Fatalf("error writing CA chain: %v", err)
+	}
+	return rootCert
+}
+
+func mustReadCertBundle(file string, t *testing.T) []*x509.Certificate {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatalf("failed to read certificate file: %v", err)
+	}
+	certs, err := helpers.ParseCertificatesPEM(b)
+	if err != nil {
+		t.Fatalf("error parsing certificate bundle: %v", err)
+	}
+	return certs
+}
+
+func TestIntermediateClusterCA(t *testing.T) {
+	for _, anchorOnly := range []bool{false, true} {
+		assetsDir, err := ioutil.TempDir("", "pki-tests")
+		if err != nil {
+			t.Fatalf("Failed to create temp directory: %v", err)
+		}
+		defer cleanup(assetsDir, t)
+		pki := getPKI(t)
+		cleanup(pki.GeneratedCertsDirectory, t)
+		pki.GeneratedCertsDirectory = filepath.Join(assetsDir, "keys")
+		dir := pki.GeneratedCertsDirectory
+		rootPEM := writeIntermediateClusterCA(t, dir)
+		root, err := helpers.ParseCertificatePEM(rootPEM)
+		if err != nil {
+			t.Fatalf("error parsing root CA: %v", err)
+		}
+
+		p := getPlan()
+		p.Cluster.Certificates.TrustAnchorOnly = anchorOnly
+		ca, err := pki.GenerateClusterCA(p)
+		if err != nil {
+			t.Fatalf("error getting CA: %v", err)
+		}
+		proxyClientCA, err := pki.GenerateProxyClientCA(p)
+		if err != nil {
+			t.Fatalf("error generating proxy-client CA for test: %v", err)
+		}
+		if err = pki.GenerateClusterCertificates(p, ca, proxyClientCA); err != nil {
+			t.Fatalf("failed to generate certs: %v", err)
+		}
+
+		// The trust bundle is deployed instead of the CA
+		if f := deployedCAFile(dir); f != caTrustFilename+".pem" {
+			t.Errorf("expected the trust bundle to be deployed, got %q", f)
+		}
+		trust := mustReadCertBundle(filepath.Join(dir, caTrustFilename+".pem"), t)
+		expected := 3
+		if anchorOnly {
+			expected = 1
+		}
+		if len(trust) != expected {
+			t.Errorf("anchor only %v: expected %d certificates in the trust bundle, got %d", anchorOnly, expected, len(trust))
+		}
+		if !trust[len(trust)-1].Equal(root) {
+			t.Errorf("expected the trust bundle to end with the trust anchor")
+		}
+
+		// The issued certificates are bundled with the chain, excluding the trust anchor
+		bundle := mustReadCertBundle(filepath.Join(dir, "admin.pem"), t)
+		if len(bundle) != 3 {
+			t.Fatalf("expected the certificate to be bundled with 2 CAs, got %d certificates", len(bundle))
+		}
+		opts := x509.VerifyOptions{
+			Roots:         x509.NewCertPool(),
+			Intermediates: x509.NewCertPool(),
+			KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+		}
+		opts.Roots.AddCert(root)
+		for _, c := range bundle[1:] {
+			opts.Intermediates.AddCert(c)
+		}
+		if _, err = bundle[0].Verify(opts); err != nil {
+			t.Errorf("expected the certificate to be verified by the trust anchor: %v", err)
+		}
+		if cert, err := tls.ReadCert("admin", dir); err != nil || !cert.Equal(bundle[0]) {
+			t.Errorf("expected the first certificate of the bundle to be read: %v", err)
+		}
+
+		if ok, errs := ValidateCertificates(p, &pki); !ok {
+			t.Errorf("expected the certificates to be valid, but got: %v", errs)
+		}
+
+		// The kubeconfig trusts the same CAs as the nodes
+		if err = GenerateKubeconfig(p, assetsDir); err != nil {
+			t.Fatalf("error generating kubeconfig: %v", err)
+		}
+		kubeconfig, err := ioutil.ReadFile(filepath.Join(assetsDir, kubeconfigFilename))
+		if err != nil {
+			t.Fatalf("error reading kubeconfig: %v", err)
+		}
+		trustEncoded, err := util.Base64String(filepath.Join(dir, caTrustFilename+".pem"))
+		if err != nil {
+			t.Fatalf("error reading trust bundle: %v", err)
+		}
+		if !strings.Contains(string(kubeconfig), "certificate-authority-data: "+trustEncoded) {
+			t.Errorf("expected the kubeconfig to contain the trust bundle")
+		}
+	}
+}
+
+func TestValidateClusterCAChain(t *testing.T) {
+	pki := getPKI(t)
+	defer cleanup(pki.GeneratedCertsDirectory, t)
+	dir := pki.GeneratedCertsDirectory
+	p := getPlan()
+
+	// no chain
+	if errs := pki.ValidateClusterCAChain(p); len(errs) != 0 {
+		t.Errorf("expected no errors without a CA chain, got: %v", errs)
+	}
+	p.Cluster.Certificates.TrustAnchorOnly = true
+	if errs := pki.ValidateClusterCAChain(p); len(errs) != 1 {
+		t.Errorf("expected an error with trust_anchor_only set without a CA chain, got: %v", errs)
+	}
+	p.Cluster.Certificates.TrustAnchorOnly = false
+
+	// chain that did not issue the CA
+	writeIntermediateClusterCA(t, dir)
+	_, otherRoot, err := tls.NewCACert("test/ca-csr.json", "other", "2h")
+	if err != nil {
+		t.Fatalf("error creating root CA: %v", err)
+	}
+	if err = ioutil.WriteFile(filepath.Join(dir, caChainFilename+".pem"), otherRoot, 0644); err != nil {
+		t.Fatalf("error writing CA chain: %v", err)
+	}
+	if errs := pki.ValidateClusterCAChain(p); len(errs) != 1 {
+		t.Errorf("expected an error with a CA chain that did not issue the CA, got: %v", errs)
+	}
+	if _, err = pki.GenerateClusterCA(p); err == nil {
+		t.Errorf("expected an error getting a CA with an invalid chain")
+	}
+
+	// certificates that were not issued by the intermediate CA
+	writeIntermediateClusterCA(t, dir)
+	_, cert, err := tls.NewCACert("test/ca-csr.json", "other", "2h")
+	if err != nil {
+		t.Fatalf("error creating CA: %v", err)
+	}
+	if err = ioutil.WriteFile(filepath.Join(dir, "admin.pem"), cert, 0644); err != nil {
+		t.Fatalf("error writing certificate: %v", err)
+	}
+	if errs := pki.ValidateClusterCAChain(p); len(errs) != 1 {
+		t.Errorf("expected an error with a certificate that was not issued by the CA, got: %v", errs)
+	}
+}
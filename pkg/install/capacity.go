@@ -0,0 +1,292 @@
+package install
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/apprenda/kismatic/pkg/data"
+)
+
+type capacityKubeClient interface {
+	data.NodeLister
+	data.PodLister
+}
+
+// ResourceUsage is the compute resources allocated to the pods running on a
+// set of nodes. CPU is in millicores, and memory is in bytes.
+type ResourceUsage struct {
+	CPURequests    int64
+	CPULimits      int64
+	MemoryRequests int64
+	MemoryLimits   int64
+	Pods           int64
+}
+
+func (u *ResourceUsage) add(o ResourceUsage) {
+	u.CPURequests += o.CPURequests
+	u.CPULimits += o.CPULimits
+	u.MemoryRequests += o.MemoryRequests
+	u.MemoryLimits += o.MemoryLimits
+	u.Pods += o.Pods
+}
+
+// Allocatable is the compute resources of a set of nodes that are available
+// for pods. CPU is in millicores, and memory is in bytes.
+type Allocatable struct {
+	CPU    int64
+	Memory int64
+	Pods   int64
+}
+
+func (a *Allocatable) add(o Allocatable) {
+	a.CPU += o.CPU
+	a.Memory += o.Memory
+	a.Pods += o.Pods
+}
+
+// NodeCapacity is the allocatable resources of a node, and the resources
+// allocated to the pods running on it
+type NodeCapacity struct {
+	Node   string
+	Roles  []string
+	Labels map[string]string `json:",omitempty"`
+	// Registered is false when the node is not registered with the API server
+	Registered  bool
+	Allocatable Allocatable
+	Allocated   ResourceUsage
+	// Overcommitted are the resources of the node that are allocated beyond its
+	// allocatable capacity, such as "cpu limits"
+	Overcommitted []string `json:",omitempty"`
+}
+
+// CapacityGroup is the aggregated capacity of a group of nodes
+type CapacityGroup struct {
+	// Name is the role, or the label, of the nodes in the group
+	Name        string
+	Nodes       int
+	Allocatable Allocatable
+	Allocated   ResourceUsage
+	// OvercommittedNodes is the number of nodes in the group that are overcommitted
+	OvercommittedNodes int
+}
+
+func (g *CapacityGroup) add(n NodeCapacity) {
+	g.Nodes++
+	g.Allocatable.add(n.Allocatable)
+	g.Allocated.add(n.Allocated)
+	if len(n.Overcommitted) > 0 {
+		g.OvercommittedNodes++
+	}
+}
+
+// CapacityReport is the compute capacity of the cluster
+type CapacityReport struct {
+	Nodes []NodeCapacity
+	// Roles are the nodes grouped by role. Nodes with multiple roles are in multiple groups.
+	Roles []CapacityGroup
+	// Labels are the nodes grouped by the labels in the plan file
+	Labels []CapacityGroup
+	Total  CapacityGroup
+}
+
+// ClusterCapacity returns the allocatable resources of the nodes in the plan, and
+// the resources requested by the pods running on them. Etcd nodes are not part of the report,
+// as they do not run pods.
+func ClusterCapacity(plan Plan, client capacityKubeClient) (*CapacityReport, error) {
+	nodeList, err := client.ListNodes()
+	if err != nil {
+		return nil, err
+	}
+	podList, err := client.ListPods()
+	if err != nil {
+		return nil, err
+	}
+	registered := map[string]data.Node{}
+	for _, n := range nodeList.Items {
+		registered[strings.ToLower(n.Name)] = n
+	}
+	allocated := map[string]ResourceUsage{}
+	if podList != nil {
+		for _, p := range podList.Items {
+			if p.Spec.NodeName == "" || p.Status.Phase == "Succeeded" || p.Status.Phase == "Failed" {
+				continue
+			}
+			usage, err := podResourceUsage(p)
+			if err != nil {
+				return nil, fmt.Errorf("pod %s/%s: %v", p.Namespace, p.Name, err)
+			}
+			node := strings.ToLower(p.Spec.NodeName)
+			total := allocated[node]
+			total.add(usage)
+			allocated[node] = total
+		}
+	}
+
+	report := &CapacityReport{Nodes: []NodeCapacity{}, Total: CapacityGroup{Name: "total"}}
+	roleGroups := map[string]*CapacityGroup{}
+	labelGroups := map[string]*CapacityGroup{}
+	for _, n := range plan.GetUniqueNodes() {
+		nodeRoles := plan.GetRolesForIP(n.IP)
+		if len(nodeRoles) == 1 && nodeRoles[0] == "etcd" {
+			continue
+		}
+		nc := NodeCapacity{Node: n.Host, Roles: nodeRoles, Labels: planNodeLabels(plan, n.Host)}
+		if node, ok := registered[strings.ToLower(n.Host)]; ok {
+			nc.Registered = true
+			if nc.Allocatable, err = nodeAllocatable(node); err != nil {
+				return nil, fmt.Errorf("node %s: %v", n.Host, err)
+			}
+			nc.Allocated = allocated[strings.ToLower(n.Host)]
+			nc.Overcommitted = overcommitted(nc.Allocatable, nc.Allocated)
+		}
+		report.Nodes = append(report.Nodes, nc)
+		report.Total.add(nc)
+		for _, r := range nodeRoles {
+			if r == "etcd" {
+				continue
+			}
+			addToGroup(roleGroups, r, nc)
+		}
+		for k, v := range nc.Labels {
+			addToGroup(labelGroups, fmt.Sprintf("%s=%s", k, v), nc)
+		}
+	}
+	report.Roles = sortedGroups(roleGroups)
+	report.Labels = sortedGroups(labelGroups)
+	return report, nil
+}
+
+// planNodeLabels returns the labels of the node in the plan, merged across all of its roles
+func planNodeLabels(plan Plan, host string) map[string]string {
+	var labels map[string]string
+	for _, n := range plan.getAllNodes() {
+		if n.Host != host {
+			continue
+		}
+		for k, v := range n.Labels {
+			if labels == nil {
+				labels = map[string]string{}
+			}
+			labels[k] = v
+		}
+	}
+	return labels
+}
+
+func addToGroup(groups map[string]*CapacityGroup, name string, n NodeCapacity) {
+	g, ok := groups[name]
+	if !ok {
+		g = &CapacityGroup{Name: name}
+		groups[name] = g
+	}
+	g.add(n)
+}
+
+func sortedGroups(groups map[string]*CapacityGroup) []CapacityGroup {
+	names := []string{}
+	for name := range groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	sorted := []CapacityGroup{}
+	for _, name := range names {
+		sorted = append(sorted, *groups[name])
+	}
+	return sorted
+}
+
+func nodeAllocatable(n data.Node) (Allocatable, error) {
+	a := Allocatable{}
+	var err error
+	if a.CPU, err = n.Status.Allocatable.MilliCPU(); err != nil {
+		return a, err
+	}
+	if a.Memory, err = n.Status.Allocatable.MemoryBytes(); err != nil {
+		return a, err
+	}
+	if a.Pods, err = n.Status.Allocatable.Pods(); err != nil {
+		return a, err
+	}
+	return a, nil
+}
+
+// podResourceUsage returns the resources allocated to the pod. As init containers
+// run before the other containers, the pod is allocated the greater of the sum
+// of its containers, and the largest of its init containers.
+func podResourceUsage(p data.Pod) (ResourceUsage, error) {
+	usage := ResourceUsage{Pods: 1}
+	for _, c := range p.Spec.Containers {
+		cu, err := containerResourceUsage(c)
+		if err != nil {
+			return usage, err
+		}
+		usage.add(cu)
+	}
+	for _, c := range p.Spec.InitContainers {
+		cu, err := containerResourceUsage(c)
+		if err != nil {
+			return usage, err
+		}
+		usage.CPURequests = max64(usage.CPURequests, cu.CPURequests)
+		usage.CPULimits = max64(usage.CPULimits, cu.CPULimits)
+		usage.MemoryRequests = max64(usage.MemoryRequests, cu.MemoryRequests)
+		usage.MemoryLimits = max64(usage.MemoryLimits, cu.MemoryLimits)
+	}
+	return usage, nil
+}
+
+func containerResourceUsage(c data.Container) (ResourceUsage, error) {
+	u := ResourceUsage{}
+	var err error
+	if u.CPURequests, err = c.Resources.Requests.MilliCPU(); err != nil {
+		return u, err
+	}
+	if u.CPULimits, err = c.Resources.Limits.MilliCPU(); err != nil {
+		return u, err
+	}
+	if u.MemoryRequests, err = c.Resources.Requests.MemoryBytes(); err != nil {
+		return u, err
+	}
+	if u.MemoryLimits, err = c.Resources.Limits.MemoryBytes(); err != nil {
+		return u, err
+	}
+	// requests default to the limits when they are not set
+	if _, ok := c.Resources.Requests["cpu"]; !ok {
+		u.CPURequests = u.CPULimits
+	}
+	if _, ok := c.Resources.Requests["memory"]; !ok {
+		u.MemoryRequests = u.MemoryLimits
+	}
+	return u, nil
+}
+
+func overcommitted(a Allocatable, u ResourceUsage) []string {
+	over := []string{}
+	if u.CPURequests > a.CPU {
+		over = append(over, "cpu requests")
+	}
+	if u.CPULimits > a.CPU {
+		over = append(over, "cpu limits")
+	}
+	if u.MemoryRequests > a.Memory {
+		over = append(over, "memory requests")
+	}
+	if u.MemoryLimits > a.Memory {
+		over = append(over, "memory limits")
+	}
+	if u.Pods > a.Pods {
+		over = append(over, "pods")
+	}
+	if len(over) == 0 {
+		return nil
+	}
+	return over
+}
+
+func max64(a, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,143 @@
+package install
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/apprenda/kismatic/pkg/data"
+)
+
+type fakeCapacityKubeClient struct {
+	nodes *data.NodeList
+	pods  *data.PodList
+}
+
+func (f fakeCapacityKubeClient) ListNodes() (*data.NodeList, error) {
+	return f.nodes, nil
+}
+
+func (f fakeCapacityKubeClient) ListPods() (*data.PodList, error) {
+	return f.pods, nil
+}
+
+func capacityNode(name, cpu, memory string) data.Node {
+	n := data.Node{}
+	n.Name = name
+	n.Status.Allocatable = data.ResourceList{"cpu": cpu, "memory": memory, "pods": "110"}
+	return n
+}
+
+func capacityPod(node, phase string, containers ...data.ResourceRequirements) data.Pod {
+	p := data.Pod{}
+	p.Name = "pod"
+	p.Spec.NodeName = node
+	p.Status.Phase = phase
+	for _, r := range containers {
+		p.Spec.Containers = append(p.Spec.Containers, data.Container{Resources: r})
+	}
+	return p
+}
+
+func resources(requests, limits data.ResourceList) data.ResourceRequirements {
+	return data.ResourceRequirements{Requests: requests, Limits: limits}
+}
+
+func TestClusterCapacity(t *testing.T) {
+	plan := Plan{}
+	plan.Etcd.Nodes = []Node{{Host: "etcd01", IP: "10.0.0.1"}}
+	plan.Master.Nodes = []Node{{Host: "master01", IP: "10.0.0.2"}}
+	plan.Worker.Nodes = []Node{
+		{Host: "Worker01", IP: "10.0.0.3", Labels: map[string]string{"zone": "a"}},
+		{Host: "worker02", IP: "10.0.0.4", Labels: map[string]string{"zone": "b"}},
+		{Host: "worker03", IP: "10.0.0.5"},
+	}
+	plan.Ingress.Nodes = []Node{{Host: "worker02", IP: "10.0.0.4", Labels: map[string]string{"ingress": "true"}}}
+
+	client := fakeCapacityKubeClient{
+		nodes: &data.NodeList{Items: []data.Node{
+			capacityNode("master01", "2", "4Gi"),
+			capacityNode("worker01", "4", "8Gi"),
+			capacityNode("worker02", "2", "4Gi"),
+		}},
+		pods: &data.PodList{Items: []data.Pod{
+			capacityPod("master01", "Running", resources(data.ResourceList{"cpu": "250m"}, nil)),
+			capacityPod("worker01", "Running",
+				resources(data.ResourceList{"cpu": "500m", "memory": "1Gi"}, data.ResourceList{"cpu": "1", "memory": "2Gi"}),
+				resources(nil, data.ResourceList{"cpu": "500m", "memory": "512Mi"})),
+			// completed pods do not use resources
+			capacityPod("worker01", "Succeeded", resources(data.ResourceList{"cpu": "4"}, nil)),
+			capacityPod("worker02", "Running", resources(data.ResourceList{"cpu": "1"}, data.ResourceList{"cpu": "3", "memory": "5Gi"})),
+			capacityPod("", "Pending", resources(data.ResourceList{"cpu": "8"}, nil)),
+		}},
+	}
+	report, err := ClusterCapacity(plan, client)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(report.Nodes) != 4 {
+		t.Fatalf("expected 4 nodes, got %d", len(report.Nodes))
+	}
+
+	worker01 := report.Nodes[1]
+	expected := ResourceUsage{CPURequests: 1000, CPULimits: 1500, MemoryRequests: 1536 << 20, MemoryLimits: 2560 << 20, Pods: 1}
+	if !worker01.Registered || worker01.Allocated != expected {
+		t.Errorf("expected worker01 to have %+v allocated, got %+v", expected, worker01.Allocated)
+	}
+	if worker01.Allocatable != (Allocatable{CPU: 4000, Memory: 8 << 30, Pods: 110}) {
+		t.Errorf("unexpected allocatable resources %+v", worker01.Allocatable)
+	}
+	if len(worker01.Overcommitted) != 0 {
+		t.Errorf("expected worker01 not to be overcommitted, got %v", worker01.Overcommitted)
+	}
+	worker02 := report.Nodes[2]
+	if !reflect.DeepEqual(worker02.Overcommitted, []string{"cpu limits", "memory requests", "memory limits"}) {
+		t.Errorf("expected worker02 to be overcommitted, got %v", worker02.Overcommitted)
+	}
+	if !reflect.DeepEqual(worker02.Labels, map[string]string{"zone": "b", "ingress": "true"}) {
+		t.Errorf("expected the labels of worker02 to be merged, got %v", worker02.Labels)
+	}
+	if report.Nodes[3].Registered {
+		t.Errorf("expected worker03 not to be registered")
+	}
+
+	roles := []string{}
+	for _, g := range report.Roles {
+		roles = append(roles, g.Name)
+	}
+	if !reflect.DeepEqual(roles, []string{"ingress", "master", "worker"}) {
+		t.Errorf("unexpected role groups %v", roles)
+	}
+	workers := report.Roles[2]
+	if workers.Nodes != 3 || workers.OvercommittedNodes != 1 || workers.Allocatable.CPU != 6000 || workers.Allocated.Pods != 2 {
+		t.Errorf("unexpected worker group %+v", workers)
+	}
+	labels := []string{}
+	for _, g := range report.Labels {
+		labels = append(labels, g.Name)
+	}
+	if !reflect.DeepEqual(labels, []string{"ingress=true", "zone=a", "zone=b"}) {
+		t.Errorf("unexpected label groups %v", labels)
+	}
+	if report.Total.Nodes != 4 || report.Total.Allocated.CPURequests != 2250 {
+		t.Errorf("unexpected total %+v", report.Total)
+	}
+}
+
+func TestPodResourceUsageInitContainers(t *testing.T) {
+	p := capacityPod("worker01", "Running", resources(data.ResourceList{"cpu": "100m", "memory": "64Mi"}, nil))
+	p.Spec.InitContainers = []data.Container{
+		{Resources: resources(data.ResourceList{"cpu": "1", "memory": "32Mi"}, nil)},
+	}
+	usage, err := podResourceUsage(p)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if usage.CPURequests != 1000 || usage.MemoryRequests != 64<<20 {
+		t.Errorf("unexpected usage %+v", usage)
+	}
+
+	p = capacityPod("worker01", "Running", resources(data.ResourceList{"cpu": "lots"}, nil))
+	if _, err = podResourceUsage(p); err == nil {
+		t.Errorf("expected an error, but didn't get one")
+	}
+}
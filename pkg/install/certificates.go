@@ -0,0 +1,228 @@
+package install
+
+import (
+	"crypto/x509"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/apprenda/kismatic/pkg/data"
+	"github.com/apprenda/kismatic/pkg/tls"
+)
+
+// The status of a certificate
+const (
+	CertificateValid    = "valid"
+	CertificateExpiring = "expiring"
+	CertificateExpired  = "expired"
+	CertificateRevoked  = "revoked"
+)
+
+// The status of a certificate deployed on a node, compared to the local copy
+const (
+	DeployedCertificateMatch    = "match"
+	DeployedCertificateMismatch = "mismatch"
+	DeployedCertificateMissing  = "missing"
+	DeployedCertificateError    = "error"
+)
+
+const (
+	etcdK8sCertificatesDir        = "/etc/etcd_k8s"
+	etcdNetworkingCertificatesDir = "/etc/etcd_networking"
+	kubernetesCertificatesDir     = "/etc/kubernetes/pki"
+)
+
+// CertificateInfo describes a certificate in the generated assets directory
+type CertificateInfo struct {
+	// Name of the certificate file, without the extension
+	Name string `json:"name"`
+	// Entity of the plan file that the certificate belongs to, if any
+	Entity                string                `json:"entity,omitempty"`
+	CommonName            string                `json:"commonName"`
+	SubjectAlternateNames []string              `json:"subjectAlternateNames,omitempty"`
+	Organizations         []string              `json:"organizations,omitempty"`
+	Issuer                string                `json:"issuer"`
+	NotAfter              time.Time             `json:"notAfter"`
+	Status                string                `json:"status"`
+	Deployed              []DeployedCertificate `json:"deployed,omitempty"`
+}
+
+// DeployedCertificate is a copy of a certificate that is deployed on a node
+type DeployedCertificate struct {
+	Node   string `json:"node"`
+	Path   string `json:"path"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// CertificateListOptions are the options for listing the certificates of the cluster
+type CertificateListOptions struct {
+	// Now is the time used to determine if the certificates are expiring
+	Now time.Time
+	// ExpiryWarning is the time before their expiry at which certificates are reported as expiring
+	ExpiryWarning time.Duration
+	// Remote reads the certificates deployed on each node, keyed by host.
+	// The deployed certificates are not compared when nil.
+	Remote map[string]data.CertificateGetter
+}
+
+// ListCertificates describes the certificates in the directory, along with the entity
+// of the plan file they belong to. When remote clients are provided, the certificates
+// deployed on the nodes are compared with the local copies.
+func ListCertificates(plan Plan, certsDir string, opts CertificateListOptions) ([]CertificateInfo, error) {
+	names, err := certificateNames(certsDir)
+	if err != nil {
+		return nil, fmt.Errorf("error reading certificates directory: %v", err)
+	}
+	entities, err := certificateEntities(plan)
+	if err != nil {
+		return nil, err
+	}
+	caCert, crl, err := clusterCRL(certsDir)
+	if err != nil {
+		return nil, err
+	}
+	certs := []CertificateInfo{}
+	local := map[string]*x509.Certificate{}
+	index := map[string]int{}
+	for _, name := range names {
+		cert, err := tls.ReadCert(name, certsDir)
+		if err != nil {
+			return nil, err
+		}
+		local[name] = cert
+		index[name] = len(certs)
+		certs = append(certs, CertificateInfo{
+			Name:                  name,
+			Entity:                entities[name],
+			CommonName:            cert.Subject.CommonName,
+			SubjectAlternateNames: certificateSANs(cert),
+			Organizations:         cert.Subject.Organization,
+			Issuer:                cert.Issuer.CommonName,
+			NotAfter:              cert.NotAfter,
+			Status:                certificateStatus(cert, opts.Now, opts.ExpiryWarning),
+		})
+		if revokedByCA(cert, caCert, crl) {
+			certs[len(certs)-1].Status = CertificateRevoked
+		}
+	}
+	if opts.Remote == nil {
+		return certs, nil
+	}
+	// The CA is deployed from a bundle when the cluster CA is an intermediate CA,
+	// or while it is being rotated
+	caName := strings.TrimSuffix(deployedCAFile(certsDir), ".pem")
+	for _, node := range plan.GetUniqueNodes() {
+		client, ok := opts.Remote[node.Host]
+		if !ok {
+			continue
+		}
+		for _, d := range deployedCertificates(plan, node) {
+			if d.name == "ca" {
+				d.name = caName
+			}
+			i, ok := index[d.name]
+			if !ok {
+				continue
+			}
+			deployed := DeployedCertificate{Node: node.Host, Path: d.path}
+			remote, err := client.GetCertificate(d.path)
+			switch {
+			case err != nil:
+				deployed.Status = DeployedCertificateError
+				deployed.Error = err.Error()
+			case remote == nil:
+				deployed.Status = DeployedCertificateMissing
+			case !remote.Equal(local[d.name]):
+				deployed.Status = DeployedCertificateMismatch
+			default:
+				deployed.Status = DeployedCertificateMatch
+			}
+			certs[i].Deployed = append(certs[i].Deployed, deployed)
+		}
+	}
+	return certs, nil
+}
+
+func certificateStatus(cert *x509.Certificate, now time.Time, expiryWarning time.Duration) string {
+	switch {
+	case now.After(cert.NotAfter):
+		return CertificateExpired
+	case cert.NotAfter.Sub(now) < expiryWarning:
+		return CertificateExpiring
+	}
+	return CertificateValid
+}
+
+func certificateSANs(cert *x509.Certificate) []string {
+	sans := append([]string{}, cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		sans = append(sans, ip.String())
+	}
+	return sans
+}
+
+// certificateEntities returns the entity of the plan file that each certificate belongs to
+func certificateEntities(plan Plan) (map[string]string, error) {
+	manifest, err := plan.certSpecs(nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error determining the certificates of the cluster: %v", err)
+	}
+	entities := map[string]string{
+		"ca":               "cluster CA",
+		"proxy-client-ca":  "proxy-client CA",
+		caChainFilename:    "cluster CA chain",
+		caTrustFilename:    "cluster CA trust bundle",
+		caBundleFilename:   "cluster CA rotation bundle",
+		caNextFilename:     "next cluster CA",
+		caPreviousFilename: "previous cluster CA",
+	}
+	for _, s := range manifest {
+		entities[s.filename] = s.description
+	}
+	return entities, nil
+}
+
+type deployedCertificate struct {
+	name string
+	path string
+}
+
+// deployedCertificates returns the certificates that are deployed on the node,
+// and the path where they are deployed
+func deployedCertificates(plan Plan, node Node) []deployedCertificate {
+	certs := []deployedCertificate{}
+	roles := plan.GetRolesForIP(node.IP)
+	if contains("etcd", roles) {
+		// the certificates of both etcd clusters are deployed to every etcd node,
+		// regardless of the CNI provider
+		for _, dir := range []string{etcdK8sCertificatesDir, etcdNetworkingCertificatesDir} {
+			certs = append(certs,
+				deployedCertificate{"ca", path.Join(dir, "ca.pem")},
+				deployedCertificate{node.Host + "-etcd", path.Join(dir, "etcd.pem")},
+				deployedCertificate{"etcd-client", path.Join(dir, "etcd-client.pem")},
+			)
+		}
+	}
+	if containsAny([]string{"master", "worker", "ingress", "storage"}, roles) {
+		certs = append(certs,
+			deployedCertificate{"ca", path.Join(kubernetesCertificatesDir, "ca.pem")},
+			deployedCertificate{"proxy-client-ca", path.Join(kubernetesCertificatesDir, "proxy-client-ca.pem")},
+			deployedCertificate{adminCertFilename, path.Join(kubernetesCertificatesDir, "admin.pem")},
+			deployedCertificate{node.Host + "-kubelet", path.Join(kubernetesCertificatesDir, "kubelet.pem")},
+			deployedCertificate{"etcd-client", path.Join(kubernetesCertificatesDir, "etcd-client.pem")},
+		)
+	}
+	if contains("master", roles) {
+		certs = append(certs,
+			deployedCertificate{node.Host + "-apiserver", path.Join(kubernetesCertificatesDir, "api-server.pem")},
+			deployedCertificate{schedulerCertFilenamePrefix, path.Join(kubernetesCertificatesDir, "scheduler.pem")},
+			deployedCertificate{controllerManagerCertFilenamePrefix, path.Join(kubernetesCertificatesDir, "controller-manager.pem")},
+			deployedCertificate{kubeAPIServerKubeletClientClientFilename, path.Join(kubernetesCertificatesDir, "apiserver-kubelet-client.pem")},
+			deployedCertificate{proxyClientCertFilename, path.Join(kubernetesCertificatesDir, "proxy-client.pem")},
+			deployedCertificate{serviceAccountCertFilename, path.Join(kubernetesCertificatesDir, "service-account.pem")},
+		)
+	}
+	return certs
+}
@@ -0,0 +1,173 @@
+package install
+
+import (
+	"crypto/x509"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/apprenda/kismatic/pkg/data"
+	"github.com/apprenda/kismatic/pkg/tls"
+)
+
+type fakeCertificates map[string]*x509.Certificate
+
+func (f fakeCertificates) GetCertificate(path string) (*x509.Certificate, error) {
+	return f[path], nil
+}
+
+type failingCertificates struct{}
+
+func (failingCertificates) GetCertificate(path string) (*x509.Certificate, error) {
+	return nil, fmt.Errorf("permission denied")
+}
+
+func TestListCertificates(t *testing.T) {
+	pki := getPKI(t)
+	defer cleanup(pki.GeneratedCertsDirectory, t)
+	p := getPlan()
+	p.Cluster.Certificates.Expiry = "720h"
+	ca, err := pki.GenerateClusterCA(p)
+	if err != nil {
+		t.Fatalf("error generating CA for test: %v", err)
+	}
+	proxyClientCA, err := pki.GenerateProxyClientCA(p)
+	if err != nil {
+		t.Fatalf("error generating proxy-client CA for test: %v", err)
+	}
+	if err = pki.GenerateClusterCertificates(p, ca, proxyClientCA); err != nil {
+		t.Fatalf("error generating cluster certificates: %v", err)
+	}
+
+	certs, err := ListCertificates(*p, pki.GeneratedCertsDirectory, CertificateListOptions{Now: time.Now(), ExpiryWarning: 7 * 24 * time.Hour})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	byName := map[string]CertificateInfo{}
+	for _, c := range certs {
+		byName[c.Name] = c
+		if c.Entity == "" {
+			t.Errorf("expected an entity for certificate %q", c.Name)
+		}
+		if c.Status != CertificateValid {
+			t.Errorf("expected certificate %q to be valid, got %q", c.Name, c.Status)
+		}
+		if c.Deployed != nil {
+			t.Errorf("expected no deployed certificates for %q", c.Name)
+		}
+	}
+	kubelet, ok := byName["worker01-kubelet"]
+	if !ok {
+		t.Fatalf("expected the kubelet certificate of worker01, got %+v", certs)
+	}
+	if kubelet.Entity != "worker01 kubelet" || kubelet.CommonName != "system:node:worker01" || kubelet.Issuer != "someName" {
+		t.Errorf("unexpected certificate %+v", kubelet)
+	}
+	if len(kubelet.Organizations) != 1 || kubelet.Organizations[0] != kubeletGroup {
+		t.Errorf("unexpected organizations %v", kubelet.Organizations)
+	}
+	if byName["ca"].Entity != "cluster CA" {
+		t.Errorf("unexpected entity %q for the CA", byName["ca"].Entity)
+	}
+
+	// The certificates expire within the warning threshold
+	certs, err = ListCertificates(*p, pki.GeneratedCertsDirectory, CertificateListOptions{Now: time.Now(), ExpiryWarning: 60 * 24 * time.Hour})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, c := range certs {
+		if c.Name == "worker01-kubelet" && c.Status != CertificateExpiring {
+			t.Errorf("expected the certificate to be expiring, got %q", c.Status)
+		}
+	}
+	certs, err = ListCertificates(*p, pki.GeneratedCertsDirectory, CertificateListOptions{Now: time.Now().Add(1000 * time.Hour)})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, c := range certs {
+		if c.Name == "worker01-kubelet" && c.Status != CertificateExpired {
+			t.Errorf("expected the certificate to be expired, got %q", c.Status)
+		}
+	}
+}
+
+func TestListCertificatesRemote(t *testing.T) {
+	pki := getPKI(t)
+	defer cleanup(pki.GeneratedCertsDirectory, t)
+	p := &Plan{}
+	p.Cluster.Name = "test"
+	p.Cluster.Networking.ServiceCIDRBlock = "10.0.0.0/24"
+	p.Cluster.Certificates.Expiry = "1h"
+	p.AddOns.CNI = &CNI{Provider: cniProviderWeave}
+	p.Etcd.Nodes = []Node{{Host: "etcd01", IP: "10.0.0.1"}}
+	p.Master.Nodes = []Node{{Host: "master01", IP: "10.0.0.2"}}
+	p.Worker.Nodes = []Node{{Host: "worker01", IP: "10.0.0.3"}}
+	ca, err := pki.GenerateClusterCA(p)
+	if err != nil {
+		t.Fatalf("error generating CA for test: %v", err)
+	}
+	proxyClientCA, err := pki.GenerateProxyClientCA(p)
+	if err != nil {
+		t.Fatalf("error generating proxy-client CA for test: %v", err)
+	}
+	if err = pki.GenerateClusterCertificates(p, ca, proxyClientCA); err != nil {
+		t.Fatalf("error generating cluster certificates: %v", err)
+	}
+	read := func(name string) *x509.Certificate {
+		cert, err := tls.ReadCert(name, pki.GeneratedCertsDirectory)
+		if err != nil {
+			t.Fatalf("error reading certificate: %v", err)
+		}
+		return cert
+	}
+
+	worker := fakeCertificates{}
+	for _, d := range deployedCertificates(*p, p.Worker.Nodes[0]) {
+		worker[d.path] = read(d.name)
+	}
+	// the kubelet certificate of another node was deployed, and the admin certificate is missing
+	worker["/etc/kubernetes/pki/kubelet.pem"] = read("master01-kubelet")
+	delete(worker, "/etc/kubernetes/pki/admin.pem")
+
+	remote := map[string]data.CertificateGetter{
+		"etcd01":   failingCertificates{},
+		"worker01": worker,
+	}
+	certs, err := ListCertificates(*p, pki.GeneratedCertsDirectory, CertificateListOptions{Now: time.Now(), Remote: remote})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	deployed := map[string]DeployedCertificate{}
+	for _, c := range certs {
+		for _, d := range c.Deployed {
+			deployed[c.Name+" "+d.Node+" "+d.Path] = d
+		}
+	}
+	tests := map[string]string{
+		"etcd01-etcd etcd01 /etc/etcd_k8s/etcd.pem":                 DeployedCertificateError,
+		"ca worker01 /etc/kubernetes/pki/ca.pem":                    DeployedCertificateMatch,
+		"worker01-kubelet worker01 /etc/kubernetes/pki/kubelet.pem": DeployedCertificateMismatch,
+		"admin worker01 /etc/kubernetes/pki/admin.pem":              DeployedCertificateMissing,
+		"etcd-client worker01 /etc/kubernetes/pki/etcd-client.pem":  DeployedCertificateMatch,
+	}
+	for k, status := range tests {
+		d, ok := deployed[k]
+		if !ok {
+			t.Errorf("expected deployed certificate %q", k)
+			continue
+		}
+		if d.Status != status {
+			t.Errorf("%s: expected status %q, got %q", k, status, d.Status)
+		}
+	}
+	// the certificates of the networking etcd cluster are deployed with weave too
+	if _, ok := deployed["ca etcd01 /etc/etcd_networking/ca.pem"]; !ok {
+		t.Errorf("expected networking etcd certificate")
+	}
+	// master01 was not inspected
+	for k := range deployed {
+		if d := deployed[k]; d.Node == "master01" {
+			t.Errorf("unexpected deployed certificate %+v", d)
+		}
+	}
+}
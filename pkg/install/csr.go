@@ -0,0 +1,311 @@
+package install
+
+import (
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/apprenda/kismatic/pkg/tls"
+	"github.com/apprenda/kismatic/pkg/util"
+	"github.com/cloudflare/cfssl/helpers"
+)
+
+// The CAs that sign the certificates of the cluster
+const (
+	ClusterCASigner     = "cluster CA"
+	ProxyClientCASigner = "proxy-client CA"
+)
+
+// A CertificateSigningRequest is a request for a certificate of the cluster
+// that must be signed by a CA
+type CertificateSigningRequest struct {
+	// Name of the certificate file, without the extension
+	Name        string
+	Description string
+	// File is the path to the CSR
+	File string
+	// Signer is the CA that must sign the CSR
+	Signer string
+}
+
+// PendingCSRsError is returned when certificates of the cluster must be signed
+// by a CA whose private key is not available
+type PendingCSRsError struct {
+	CSRs []CertificateSigningRequest
+}
+
+func (e PendingCSRsError) Error() string {
+	lines := []string{"the private key of the CA is not available to sign the following certificates. Sign the CSRs with the CA, and import the certificates with \"kismatic certificates import\":"}
+	for _, r := range e.CSRs {
+		lines = append(lines, fmt.Sprintf("  - %s for %s, signed by the %s", r.File, r.Description, r.Signer))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// certSigners returns the certificates of the cluster, along with the CA that signs them
+func (plan Plan) certSigners() ([]certificateSpec, map[string]string, error) {
+	clusterCA := &tls.CA{}
+	proxyClientCA := &tls.CA{}
+	manifest, err := plan.certSpecs(clusterCA, proxyClientCA)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error determining the certificates of the cluster: %v", err)
+	}
+	signers := map[string]string{}
+	for i, s := range manifest {
+		signers[s.filename] = ClusterCASigner
+		if s.ca == proxyClientCA {
+			signers[s.filename] = ProxyClientCASigner
+		}
+		manifest[i].ca = nil
+	}
+	return manifest, signers, nil
+}
+
+// GenerateClusterCSRs creates a private key and a CSR for the certificates of the
+// cluster that do not exist, so that they can be signed by an external CA. Existing
+// CSRs are kept, unless overwrite is set.
+func (lp *LocalPKI) GenerateClusterCSRs(p *Plan, overwrite bool) ([]CertificateSigningRequest, error) {
+	if lp.Log == nil {
+		lp.Log = ioutil.Discard
+	}
+	manifest, signers, err := p.certSigners()
+	if err != nil {
+		return nil, err
+	}
+	csrs := []CertificateSigningRequest{}
+	for _, s := range manifest {
+		exists, err := tls.CertKeyPairExists(s.filename, lp.GeneratedCertsDirectory)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			util.PrettyPrintOk(lp.Log, "Found certificate for %s", s.description)
+			continue
+		}
+		r, err := lp.generateCSR(s, signers[s.filename], overwrite)
+		if err != nil {
+			return nil, err
+		}
+		csrs = append(csrs, *r)
+	}
+	return csrs, nil
+}
+
+// generateCSR creates a private key and a CSR for the certificate, unless they exist
+func (lp *LocalPKI) generateCSR(spec certificateSpec, signer string, overwrite bool) (*CertificateSigningRequest, error) {
+	r := &CertificateSigningRequest{
+		Name:        spec.filename,
+		Description: spec.description,
+		File:        filepath.Join(lp.GeneratedCertsDirectory, tls.CSRName(spec.filename)),
+		Signer:      signer,
+	}
+	exists, err := tls.CSRKeyPairExists(spec.filename, lp.GeneratedCertsDirectory)
+	if err != nil {
+		return nil, err
+	}
+	if exists && !overwrite {
+		util.PrettyPrintOk(lp.Log, "Found CSR for %s", spec.description)
+		return r, nil
+	}
+	key, csrBytes, err := tls.NewCSR(spec.certificateRequest())
+	if err != nil {
+		return nil, fmt.Errorf("error generating CSR for %q: %v", spec.description, err)
+	}
+	if err = tls.WriteCSR(key, csrBytes, spec.filename, lp.GeneratedCertsDirectory); err != nil {
+		return nil, fmt.Errorf("error writing CSR for %q: %v", spec.description, err)
+	}
+	util.PrettyPrintOk(lp.Log, "Generated CSR for %s", spec.description)
+	return r, nil
+}
+
+// PendingCSRs returns the CSRs of the cluster whose certificates have not been imported
+func (lp *LocalPKI) PendingCSRs(p *Plan) ([]CertificateSigningRequest, error) {
+	manifest, signers, err := p.certSigners()
+	if err != nil {
+		return nil, err
+	}
+	csrs := []CertificateSigningRequest{}
+	for _, s := range manifest {
+		exists, err := tls.CSRKeyPairExists(s.filename, lp.GeneratedCertsDirectory)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			continue
+		}
+		signed, err := fileExists(filepath.Join(lp.GeneratedCertsDirectory, s.filename+".pem"))
+		if err != nil {
+			return nil, err
+		}
+		if signed {
+			continue
+		}
+		csrs = append(csrs, CertificateSigningRequest{
+			Name:        s.filename,
+			Description: s.description,
+			File:        filepath.Join(lp.GeneratedCertsDirectory, tls.CSRName(s.filename)),
+			Signer:      signers[s.filename],
+		})
+	}
+	return csrs, nil
+}
+
+// ImportCertificates imports the certificates that were signed for the pending CSRs
+// of the cluster. The certificates are read from the directory, and are expected to
+// have the name of the CSR with the ".pem" extension. Returns the imported certificates.
+func (lp *LocalPKI) ImportCertificates(p *Plan, dir string) ([]CertificateSigningRequest, error) {
+	if lp.Log == nil {
+		lp.Log = ioutil.Discard
+	}
+	pending, err := lp.PendingCSRs(p)
+	if err != nil {
+		return nil, err
+	}
+	manifest, _, err := p.certSigners()
+	if err != nil {
+		return nil, err
+	}
+	specs := map[string]certificateSpec{}
+	for _, s := range manifest {
+		specs[s.filename] = s
+	}
+	imported := []CertificateSigningRequest{}
+	for _, r := range pending {
+		file := filepath.Join(dir, r.Name+".pem")
+		certBytes, err := ioutil.ReadFile(file)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return imported, fmt.Errorf("error reading certificate %q: %v", file, err)
+		}
+		if certBytes, err = lp.verifySignedCertificate(specs[r.Name], r.Signer, dir, certBytes); err != nil {
+			return imported, fmt.Errorf("certificate %q is not valid for %s: %v", file, r.Description, err)
+		}
+		if err = ioutil.WriteFile(filepath.Join(lp.GeneratedCertsDirectory, r.Name+".pem"), certBytes, 0644); err != nil {
+			return imported, fmt.Errorf("error writing certificate for %q: %v", r.Description, err)
+		}
+		if err = os.Remove(r.File); err != nil {
+			return imported, fmt.Errorf("error removing CSR for %q: %v", r.Description, err)
+		}
+		util.PrettyPrintOk(lp.Log, "Imported certificate for %s", r.Description)
+		imported = append(imported, r)
+	}
+	return imported, nil
+}
+
+// verifySignedCertificate verifies that the certificate was signed by the CA for the CSR,
+// and returns the certificate bundled with the chain of the cluster CA, if any
+func (lp *LocalPKI) verifySignedCertificate(spec certificateSpec, signer string, dir string, certBytes []byte) ([]byte, error) {
+	certs, err := helpers.ParseCertificatesPEM(certBytes)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing certificate: %v", err)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no certificate found")
+	}
+	cert := certs[0]
+	warn, err := tls.CertValid(spec.commonName, spec.subjectAlternateNames, spec.organizations, spec.filename, dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(warn) > 0 {
+		return nil, fmt.Errorf("%v", warn)
+	}
+	ok, err := tls.CertMatchesKey(cert, spec.filename, lp.GeneratedCertsDirectory)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("the certificate was not issued for the private key of the CSR")
+	}
+	caName := "ca"
+	if signer == ProxyClientCASigner {
+		caName = "proxy-client-ca"
+	}
+	ca, err := tls.ReadCert(caName, lp.GeneratedCertsDirectory)
+	if err != nil {
+		return nil, fmt.Errorf("error reading the %s certificate: %v", signer, err)
+	}
+	opts := x509.VerifyOptions{
+		Roots:         x509.NewCertPool(),
+		Intermediates: x509.NewCertPool(),
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}
+	opts.Roots.AddCert(ca)
+	if _, err = cert.Verify(opts); err != nil {
+		return nil, fmt.Errorf("the certificate was not signed by the %s: %v", signer, err)
+	}
+	if signer != ClusterCASigner || len(certs) > 1 {
+		return certBytes, nil
+	}
+	chain, err := readClusterCAChain(lp.GeneratedCertsDirectory)
+	if err != nil {
+		return nil, err
+	}
+	if chain != nil {
+		certBytes = tls.Bundle(certBytes, chain.issuerBundle())
+	}
+	return certBytes, nil
+}
+
+// pendingCSR creates a CSR for the certificate when the private key of its CA is
+// not available. Returns nil if the CA can sign the certificate.
+func (lp *LocalPKI) pendingCSR(p *Plan, spec certificateSpec, signer string) (*CertificateSigningRequest, error) {
+	ok, err := lp.canSign(p, spec.ca)
+	if err != nil || ok {
+		return nil, err
+	}
+	return lp.generateCSR(spec, signer, false)
+}
+
+// ValidateExternallySignedCertificates reports the certificates of the cluster that
+// must be signed externally, when the private key of their CA is not available
+func (lp *LocalPKI) ValidateExternallySignedCertificates(p *Plan) []error {
+	if b, err := lp.backend(p); err != nil || b != nil {
+		if err != nil {
+			return []error{err}
+		}
+		return nil
+	}
+	manifest, signers, err := p.certSigners()
+	if err != nil {
+		return []error{err}
+	}
+	var errs []error
+	for _, s := range manifest {
+		caName := "ca"
+		if signers[s.filename] == ProxyClientCASigner {
+			caName = "proxy-client-ca"
+		}
+		external, err := caKeyMissing(caName, lp.GeneratedCertsDirectory)
+		if err != nil {
+			return []error{err}
+		}
+		if !external {
+			continue
+		}
+		exists, err := tls.CertKeyPairExists(s.filename, lp.GeneratedCertsDirectory)
+		if err != nil {
+			return []error{err}
+		}
+		if !exists {
+			errs = append(errs, fmt.Errorf("Certificate \"%s.pem\" was not found, and the private key of the %s is not available to sign it. Generate its CSR with \"kismatic certificates csr\", and import the signed certificate with \"kismatic certificates import\"", s.filename, signers[s.filename]))
+		}
+	}
+	return errs
+}
+
+// caKeyMissing returns true when the certificate of the CA exists without its private key,
+// in which case the certificates issued by the CA are signed externally
+func caKeyMissing(name, dir string) (bool, error) {
+	cert, err := fileExists(filepath.Join(dir, name+".pem"))
+	if err != nil || !cert {
+		return false, err
+	}
+	key, err := fileExists(filepath.Join(dir, name+"-key.pem"))
+	return !key, err
+}
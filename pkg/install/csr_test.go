@@ -0,0 +1,250 @@
+package install
+
+import (
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/apprenda/kismatic/pkg/tls"
+	"github.com/cloudflare/cfssl/helpers"
+)
+
+// signCSR signs the CSR with the CA, as an external CA would
+func signCSR(t *testing.T, ca *tls.CA, csrFile string) []byte {
+	csrBytes, err := ioutil.ReadFile(csrFile)
+	if err != nil {
+		t.Fatalf("error reading CSR: %v", err)
+	}
+	block, _ := pem.Decode(csrBytes)
+	if block == nil {
+		t.Fatalf("error decoding CSR %q", csrFile)
+	}
+	req, err := x509.ParseCertificateRequest(block.Bytes)
+	if err != nil {
+		t.Fatalf("error parsing CSR: %v", err)
+	}
+	caKey, err := helpers.ParsePrivateKeyPEM(ca.Key)
+	if err != nil {
+		t.Fatalf("error parsing CA key: %v", err)
+	}
+	caCert, err := helpers.ParseCertificatePEM(ca.Cert)
+	if err != nil {
+		t.Fatalf("error parsing CA certificate: %v", err)
+	}
+	tpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      req.Subject,
+		DNSNames:     req.DNSNames,
+		IPAddresses:  req.IPAddresses,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tpl, caCert, req.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("error signing CSR: %v", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+// getExternalCAs creates the cluster and proxy-client CAs, and only writes their
+// certificates to the certificates directory
+func getExternalCAs(t *testing.T, dir string) (*tls.CA, *tls.CA) {
+	cas := []*tls.CA{}
+	for _, name := range []string{"ca", "proxy-client-ca"} {
+		key, cert, err := tls.NewCACert("test/ca-csr.json", name, "2h")
+		if err != nil {
+			t.Fatalf("error creating CA: %v", err)
+		}
+		if err = ioutil.WriteFile(filepath.Join(dir, name+".pem"), cert, 0644); err != nil {
+			t.Fatalf("error writing CA certificate: %v", err)
+		}
+		cas = append(cas, &tls.CA{Key: key, Cert: cert})
+	}
+	return cas[0], cas[1]
+}
+
+func TestGenerateClusterCSRs(t *testing.T) {
+	pki := getPKI(t)
+	defer cleanup(pki.GeneratedCertsDirectory, t)
+	p := getPlan()
+	manifest, err := p.certSpecs(nil, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	csrs, err := pki.GenerateClusterCSRs(p, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(csrs) != len(manifest) {
+		t.Errorf("expected %d CSRs, got %d", len(manifest), len(csrs))
+	}
+	for _, r := range csrs {
+		if _, err := os.Stat(r.File); err != nil {
+			t.Errorf("expected CSR %q to exist: %v", r.File, err)
+		}
+		expected := ClusterCASigner
+		if r.Name == proxyClientCertFilename {
+			expected = ProxyClientCASigner
+		}
+		if r.Signer != expected {
+			t.Errorf("expected %q to be signed by the %s, got %s", r.Name, expected, r.Signer)
+		}
+	}
+
+	// Existing CSRs are kept
+	before, err := ioutil.ReadFile(csrs[0].File)
+	if err != nil {
+		t.Fatalf("error reading CSR: %v", err)
+	}
+	if _, err = pki.GenerateClusterCSRs(p, false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	after, err := ioutil.ReadFile(csrs[0].File)
+	if err != nil {
+		t.Fatalf("error reading CSR: %v", err)
+	}
+	if string(before) != string(after) {
+		t.Errorf("expected the existing CSR to be kept")
+	}
+	if _, err = pki.GenerateClusterCSRs(p, true); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	after, err = ioutil.ReadFile(csrs[0].File)
+	if err != nil {
+		t.Fatalf("error reading CSR: %v", err)
+	}
+	if string(before) == string(after) {
+		t.Errorf("expected the existing CSR to be overwritten")
+	}
+}
+
+func TestExternallySignedCertificates(t *testing.T) {
+	pki := getPKI(t)
+	defer cleanup(pki.GeneratedCertsDirectory, t)
+	signedDir, err := ioutil.TempDir("", "signed-certs")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer cleanup(signedDir, t)
+	p := getPlan()
+	clusterCA, proxyClientCA := getExternalCAs(t, pki.GeneratedCertsDirectory)
+
+	// Without the certificates, the CSRs must be signed before installing
+	if errs := pki.ValidateExternallySignedCertificates(p); len(errs) == 0 {
+		t.Errorf("expected errors for the certificates that must be signed externally")
+	}
+	ca, err := pki.GenerateClusterCA(p)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(ca.Key) != 0 {
+		t.Errorf("expected the CA to be read without its private key")
+	}
+	proxyCA, err := pki.GenerateProxyClientCA(p)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	err = pki.GenerateClusterCertificates(p, ca, proxyCA)
+	pendingErr, ok := err.(PendingCSRsError)
+	if !ok {
+		t.Fatalf("expected a PendingCSRsError, got: %v", err)
+	}
+
+	pending, err := pki.PendingCSRs(p)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(pending) != len(pendingErr.CSRs) {
+		t.Errorf("expected %d pending CSRs, got %d", len(pendingErr.CSRs), len(pending))
+	}
+
+	// A certificate signed by another CA is rejected
+	otherKey, otherCert, err := tls.NewCACert("test/ca-csr.json", "other", "2h")
+	if err != nil {
+		t.Fatalf("error creating CA: %v", err)
+	}
+	invalid := signCSR(t, &tls.CA{Key: otherKey, Cert: otherCert}, pending[0].File)
+	if err = ioutil.WriteFile(filepath.Join(signedDir, pending[0].Name+".pem"), invalid, 0644); err != nil {
+		t.Fatalf("error writing certificate: %v", err)
+	}
+	if _, err = pki.ImportCertificates(p, signedDir); err == nil {
+		t.Errorf("expected an error importing a certificate signed by another CA")
+	}
+
+	// Sign all but one of the CSRs
+	for _, r := range pending[1:] {
+		signer := clusterCA
+		if r.Signer == ProxyClientCASigner {
+			signer = proxyClientCA
+		}
+		if err = ioutil.WriteFile(filepath.Join(signedDir, r.Name+".pem"), signCSR(t, signer, r.File), 0644); err != nil {
+			t.Fatalf("error writing certificate: %v", err)
+		}
+	}
+	os.Remove(filepath.Join(signedDir, pending[0].Name+".pem"))
+	imported, err := pki.ImportCertificates(p, signedDir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(imported) != len(pending)-1 {
+		t.Errorf("expected %d imported certificates, got %d", len(pending)-1, len(imported))
+	}
+	err = pki.GenerateClusterCertificates(p, ca, proxyCA)
+	if pendingErr, ok = err.(PendingCSRsError); !ok || len(pendingErr.CSRs) != 1 || pendingErr.CSRs[0].Name != pending[0].Name {
+		t.Errorf("expected the remaining CSR to be pending, got: %v", err)
+	}
+
+	// Once all the certificates are imported, the cluster certificates are valid
+	if err = ioutil.WriteFile(filepath.Join(signedDir, pending[0].Name+".pem"), signCSR(t, clusterCA, pending[0].File), 0644); err != nil {
+		t.Fatalf("error writing certificate: %v", err)
+	}
+	if _, err = pki.ImportCertificates(p, signedDir); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if pending, err = pki.PendingCSRs(p); err != nil || len(pending) != 0 {
+		t.Errorf("expected no pending CSRs, got %v: %v", pending, err)
+	}
+	if err = pki.GenerateClusterCertificates(p, ca, proxyCA); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if ok, errs := ValidateCertificates(p, &pki); !ok {
+		t.Errorf("expected the certificates to be valid, got: %v", errs)
+	}
+	if _, err = os.Stat(filepath.Join(pki.GeneratedCertsDirectory, "ca-key.pem")); !os.IsNotExist(err) {
+		t.Errorf("expected the CA private key not to be created")
+	}
+}
+
+func TestGenerateNodeCertificateExternallySigned(t *testing.T) {
+	pki := getPKI(t)
+	defer cleanup(pki.GeneratedCertsDirectory, t)
+	p := getPlan()
+	getExternalCAs(t, pki.GeneratedCertsDirectory)
+	ca, err := pki.GetClusterCA()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	node := Node{Host: "new-worker", IP: "10.0.0.20"}
+	updated := AddNodeToPlan(*p, node, []string{"worker"})
+	err = pki.GenerateNodeCertificate(&updated, node, ca)
+	pendingErr, ok := err.(PendingCSRsError)
+	if !ok {
+		t.Fatalf("expected a PendingCSRsError, got: %v", err)
+	}
+	names := []string{}
+	for _, r := range pendingErr.CSRs {
+		names = append(names, r.Name)
+	}
+	if !contains("new-worker-kubelet", names) {
+		t.Errorf("expected the kubelet CSR of the node to be pending, got %v", names)
+	}
+}
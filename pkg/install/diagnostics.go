@@ -0,0 +1,251 @@
+package install
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/apprenda/kismatic/pkg/tls"
+)
+
+// DiagnosticsBundleOptions are the files that are added to the diagnostics bundle
+type DiagnosticsBundleOptions struct {
+	// NodesDirectory contains the diagnostics archives fetched from the nodes
+	NodesDirectory string
+	// PlanFile is the plan file of the cluster
+	PlanFile string
+	// GeneratedAssetsDirectory contains the certificates of the cluster
+	GeneratedAssetsDirectory string
+	// RunsDirectory contains the logs of the installation runs
+	RunsDirectory string
+	// Runs is the number of most recent runs that are added to the bundle
+	Runs int
+}
+
+// WriteDiagnosticsBundle writes the diagnostics of the nodes, the plan file, the most
+// recent runs and the metadata of the certificates to a single compressed archive.
+// Secrets are redacted from every file in the archive, and private keys are never added.
+func WriteDiagnosticsBundle(plan Plan, opts DiagnosticsBundleOptions, archive string) error {
+	f, err := os.Create(archive)
+	if err != nil {
+		return fmt.Errorf("error creating diagnostics archive: %v", err)
+	}
+	defer f.Close()
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	b := &bundleWriter{
+		tw:       tw,
+		root:     strings.TrimSuffix(filepath.Base(archive), ".tar.gz"),
+		redactor: NewRedactor(plan),
+		modTime:  time.Now(),
+	}
+
+	if err := b.addFile("kismatic-cluster.yaml", opts.PlanFile); err != nil {
+		return err
+	}
+	certs, err := certificatesMetadata(filepath.Join(opts.GeneratedAssetsDirectory, "keys"))
+	if err != nil {
+		certs = fmt.Sprintf("error reading certificates: %v\n", err)
+	}
+	if err := b.add("certificates.txt", strings.NewReader(certs)); err != nil {
+		return err
+	}
+	runs, err := recentRuns(opts.RunsDirectory, opts.Runs)
+	if err != nil {
+		return err
+	}
+	for _, run := range runs {
+		if err := b.addDir(path.Join("runs", filepath.ToSlash(run)), filepath.Join(opts.RunsDirectory, run)); err != nil {
+			return err
+		}
+	}
+	nodeArchives, err := filepath.Glob(filepath.Join(opts.NodesDirectory, "*.tar.gz"))
+	if err != nil {
+		return fmt.Errorf("error listing node diagnostics: %v", err)
+	}
+	sort.Strings(nodeArchives)
+	for _, a := range nodeArchives {
+		if err := b.addArchive("nodes", a); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("error writing diagnostics archive: %v", err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("error writing diagnostics archive: %v", err)
+	}
+	return f.Close()
+}
+
+type bundleWriter struct {
+	tw       *tar.Writer
+	root     string
+	redactor Redactor
+	modTime  time.Time
+}
+
+// add redacts the contents, and adds them to the archive with the given name
+func (b *bundleWriter) add(name string, contents io.Reader) error {
+	var buf bytes.Buffer
+	if err := b.redactor.Redact(&buf, contents); err != nil {
+		return fmt.Errorf("error redacting %s: %v", name, err)
+	}
+	hdr := &tar.Header{
+		Name:    path.Join(b.root, name),
+		Mode:    0644,
+		Size:    int64(buf.Len()),
+		ModTime: b.modTime,
+	}
+	if err := b.tw.WriteHeader(hdr); err != nil {
+		return fmt.Errorf("error adding %s to the diagnostics archive: %v", name, err)
+	}
+	if _, err := io.Copy(b.tw, &buf); err != nil {
+		return fmt.Errorf("error adding %s to the diagnostics archive: %v", name, err)
+	}
+	return nil
+}
+
+func (b *bundleWriter) addFile(name, file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return fmt.Errorf("error reading %s: %v", file, err)
+	}
+	defer f.Close()
+	return b.add(name, f)
+}
+
+func (b *bundleWriter) addDir(name, dir string) error {
+	return filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		return b.addFile(path.Join(name, filepath.ToSlash(rel)), p)
+	})
+}
+
+// addArchive adds the files of a compressed archive under the given name
+func (b *bundleWriter) addArchive(name, archive string) error {
+	f, err := os.Open(archive)
+	if err != nil {
+		return fmt.Errorf("error reading %s: %v", archive, err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("error reading %s: %v", archive, err)
+	}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("error reading %s: %v", archive, err)
+		}
+		if hdr.Typeflag != tar.TypeReg && hdr.Typeflag != tar.TypeRegA {
+			continue
+		}
+		if err := b.add(path.Join(name, path.Clean(hdr.Name)), tr); err != nil {
+			return err
+		}
+	}
+}
+
+// recentRuns returns the directories of the most recent runs, relative to the runs directory.
+// Runs are stored in directories named after the run, and the time the run started.
+func recentRuns(runsDir string, n int) ([]string, error) {
+	if n <= 0 {
+		return nil, nil
+	}
+	names, err := ioutil.ReadDir(runsDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading runs directory: %v", err)
+	}
+	type run struct{ name, start string }
+	runs := []run{}
+	for _, name := range names {
+		if !name.IsDir() {
+			continue
+		}
+		starts, err := ioutil.ReadDir(filepath.Join(runsDir, name.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("error reading runs directory: %v", err)
+		}
+		for _, start := range starts {
+			if start.IsDir() {
+				runs = append(runs, run{name.Name(), start.Name()})
+			}
+		}
+	}
+	sort.Slice(runs, func(i, j int) bool { return runs[i].start > runs[j].start })
+	if len(runs) > n {
+		runs = runs[:n]
+	}
+	dirs := []string{}
+	for _, r := range runs {
+		dirs = append(dirs, filepath.Join(r.name, r.start))
+	}
+	return dirs, nil
+}
+
+// certificatesMetadata describes the certificates in the directory, without their keys
+func certificatesMetadata(certsDir string) (string, error) {
+	names, err := certificateNames(certsDir)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	for _, name := range names {
+		fmt.Fprintf(&buf, "%s.pem\n", name)
+		cert, err := tls.ReadCert(name, certsDir)
+		if err != nil {
+			fmt.Fprintf(&buf, "  Error: %v\n\n", err)
+			continue
+		}
+		writeCertificateMetadata(&buf, cert)
+		fmt.Fprintln(&buf)
+	}
+	return buf.String(), nil
+}
+
+func writeCertificateMetadata(w io.Writer, cert *x509.Certificate) {
+	fmt.Fprintf(w, "  Subject: CN=%s, O=%s\n", cert.Subject.CommonName, strings.Join(cert.Subject.Organization, ","))
+	fmt.Fprintf(w, "  Issuer: CN=%s\n", cert.Issuer.CommonName)
+	fmt.Fprintf(w, "  Serial: %s\n", cert.SerialNumber)
+	fmt.Fprintf(w, "  Not Before: %s\n", cert.NotBefore.UTC().Format(time.RFC3339))
+	fmt.Fprintf(w, "  Not After: %s\n", cert.NotAfter.UTC().Format(time.RFC3339))
+	fmt.Fprintf(w, "  CA: %t\n", cert.IsCA)
+	if len(cert.DNSNames) > 0 {
+		fmt.Fprintf(w, "  DNS Names: %s\n", strings.Join(cert.DNSNames, ", "))
+	}
+	if len(cert.IPAddresses) > 0 {
+		ips := []string{}
+		for _, ip := range cert.IPAddresses {
+			ips = append(ips, ip.String())
+		}
+		fmt.Fprintf(w, "  IP Addresses: %s\n", strings.Join(ips, ", "))
+	}
+}
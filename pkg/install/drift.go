@@ -0,0 +1,461 @@
+package install
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/apprenda/kismatic/pkg/ansible"
+	"github.com/apprenda/kismatic/pkg/data"
+	"github.com/apprenda/kismatic/pkg/util"
+)
+
+// The categories of drift
+const (
+	DriftLabel   = "label"
+	DriftFlag    = "flag"
+	DriftVersion = "version"
+	DriftAddOn   = "add-on"
+	DriftVolume  = "nfs volume"
+)
+
+// flagNotSet is the value of a flag that is not set on the command line of a component
+const flagNotSet = "(not set)"
+
+// Drift is a difference between the live state of the cluster and the plan file
+type Drift struct {
+	// Node is empty when the drift is not specific to a node
+	Node     string `json:",omitempty"`
+	Category string
+	Item     string
+	Expected string
+	Actual   string
+	// Source is "plan" when the expected value comes from the plan file, or "peers"
+	// when it is the value of most of the other nodes
+	Source string
+	// Play is the play of "kismatic install step" that fixes the drift. It is empty
+	// when the drift can not be fixed by a single play.
+	Play string `json:",omitempty"`
+	// Fix is the command that fixes the drift
+	Fix string
+}
+
+// DriftReport is the drift of the cluster from the plan file
+type DriftReport struct {
+	Drifts []Drift
+	// Errors are the parts of the cluster that could not be compared with the plan file
+	Errors []string `json:",omitempty"`
+}
+
+func (r *DriftReport) add(d Drift) {
+	if d.Source == "" {
+		d.Source = "plan"
+	}
+	switch {
+	case d.Play != "" && d.Node != "":
+		d.Fix = fmt.Sprintf("kismatic install step %s --limit %s", d.Play, d.Node)
+	case d.Play != "":
+		d.Fix = fmt.Sprintf("kismatic install step %s", d.Play)
+	}
+	r.Drifts = append(r.Drifts, d)
+}
+
+func (r *DriftReport) errorf(format string, a ...interface{}) {
+	r.Errors = append(r.Errors, fmt.Sprintf(format, a...))
+}
+
+type driftKubeClient interface {
+	data.NodeLister
+	data.DeploymentLister
+	data.DaemonSetLister
+	data.PVLister
+}
+
+// DriftClients are the clients used to get the live state of the cluster
+type DriftClients struct {
+	Kube driftKubeClient
+	// Processes inspect the processes running on the nodes, by hostname
+	Processes map[string]data.ProcessFlagsGetter
+}
+
+// DetectDrift compares the live state of the cluster with the plan file, and the
+// cluster catalog that the playbooks install from it. The versions are the versions
+// installed on the nodes, as returned by ListVersions.
+func DetectDrift(plan Plan, generatedAssetsDir string, versions []ListableNode, clients DriftClients) (*DriftReport, error) {
+	cc, err := buildClusterCatalog(&plan, filepath.Join(generatedAssetsDir, "keys"), generatedAssetsDir)
+	if err != nil {
+		return nil, fmt.Errorf("error building the cluster catalog: %v", err)
+	}
+	report := &DriftReport{Drifts: []Drift{}}
+	detectLabelDrift(plan, *cc, clients.Kube, report)
+	for _, c := range driftComponents(*cc) {
+		detectFlagDrift(plan, c, clients.Processes, report)
+	}
+	detectVersionDrift(plan, versions, report)
+	detectAddOnDrift(*cc, clients.Kube, report)
+	detectVolumeDrift(*cc, clients.Kube, report)
+	return report, nil
+}
+
+// detectLabelDrift compares the labels of the nodes with the labels in the plan,
+// and the labels that KET sets on the nodes
+func detectLabelDrift(plan Plan, cc ansible.ClusterCatalog, client driftKubeClient, report *DriftReport) {
+	nodeList, err := client.ListNodes()
+	if err != nil {
+		report.errorf("error listing nodes: %v", err)
+		return
+	}
+	registered := map[string]data.Node{}
+	for _, n := range nodeList.Items {
+		registered[strings.ToLower(n.Name)] = n
+	}
+	for _, n := range plan.GetUniqueNodes() {
+		roles := plan.GetRolesForIP(n.IP)
+		if len(roles) == 1 && roles[0] == "etcd" {
+			continue
+		}
+		node, ok := registered[strings.ToLower(n.Host)]
+		if !ok {
+			report.errorf("node %s is not registered with the API server", n.Host)
+			continue
+		}
+		expected := map[string]string{}
+		if cc.CNI.Enabled {
+			expected["kismatic/cni-provider"] = cc.CNI.Provider
+		}
+		if contains("ingress", roles) {
+			expected["kismatic/ingress"] = "true"
+		}
+		if contains("storage", roles) {
+			expected["kismatic/storage"] = "true"
+		}
+		for _, l := range cc.NodeLabels[n.Host] {
+			parts := strings.SplitN(l, "=", 2)
+			if len(parts) == 2 {
+				expected[parts[0]] = parts[1]
+			}
+		}
+		for _, k := range sortedKeys(expected) {
+			actual, ok := node.Labels[k]
+			if !ok {
+				actual = flagNotSet
+			}
+			if actual != expected[k] {
+				report.add(Drift{Node: n.Host, Category: DriftLabel, Item: k, Expected: expected[k], Actual: actual, Play: "_label-nodes.yaml"})
+			}
+		}
+	}
+}
+
+// driftComponent is a Kubernetes component whose command line flags are rendered
+// from the defaults of the playbooks, and the option overrides of the plan
+type driftComponent struct {
+	name  string
+	play  string
+	roles []string
+	// overrides returns the option overrides that apply to the node
+	overrides func(host string) map[string]string
+	// nodeFlags are the flags whose value is specific to each node
+	nodeFlags []string
+}
+
+func driftComponents(cc ansible.ClusterCatalog) []driftComponent {
+	clusterOverrides := func(o map[string]string) func(string) map[string]string {
+		return func(string) map[string]string { return o }
+	}
+	kubeletFlags := []string{"hostname-override", "node-ip", "node-labels", "register-schedulable"}
+	// flags that are overridden on a single node are expected to differ between nodes
+	for _, o := range cc.KubeletNodeOptions {
+		for k := range o {
+			kubeletFlags = append(kubeletFlags, k)
+		}
+	}
+	kubeNodeRoles := []string{"master", "worker", "ingress", "storage"}
+	return []driftComponent{
+		{
+			name:      "kube-apiserver",
+			play:      "_kube-apiserver.yaml",
+			roles:     []string{"master"},
+			overrides: clusterOverrides(cc.APIServerOptions),
+			nodeFlags: []string{"advertise-address"},
+		},
+		{
+			name:      "kube-controller-manager",
+			play:      "_kube-controller-manager.yaml",
+			roles:     []string{"master"},
+			overrides: clusterOverrides(cc.KubeControllerManagerOptions),
+		},
+		{
+			name:      "kube-scheduler",
+			play:      "_kube-scheduler.yaml",
+			roles:     []string{"master"},
+			overrides: clusterOverrides(cc.KubeSchedulerOptions),
+		},
+		{
+			name:      "kube-proxy",
+			play:      "_kube-proxy.yaml",
+			roles:     kubeNodeRoles,
+			overrides: clusterOverrides(cc.KubeProxyOptions),
+			nodeFlags: []string{"hostname-override"},
+		},
+		{
+			name:  "kubelet",
+			play:  "_kubelet.yaml",
+			roles: kubeNodeRoles,
+			overrides: func(host string) map[string]string {
+				o := map[string]string{}
+				for k, v := range cc.KubeletOptions {
+					o[k] = v
+				}
+				for k, v := range cc.KubeletNodeOptions[host] {
+					o[k] = v
+				}
+				return o
+			},
+			nodeFlags: kubeletFlags,
+		},
+	}
+}
+
+// detectFlagDrift compares the flags of the component with the option overrides
+// of the plan. The flags that are not overridden are compared with the flags of
+// the component on the other nodes.
+func detectFlagDrift(plan Plan, c driftComponent, processes map[string]data.ProcessFlagsGetter, report *DriftReport) {
+	live := map[string]map[string]string{}
+	hosts := []string{}
+	skip := map[string]bool{}
+	for _, f := range c.nodeFlags {
+		skip[f] = true
+	}
+	for _, n := range plan.GetUniqueNodes() {
+		if !util.Intersects(c.roles, plan.GetRolesForIP(n.IP)) {
+			continue
+		}
+		getter, ok := processes[n.Host]
+		if !ok {
+			report.errorf("cannot inspect the processes of node %s", n.Host)
+			continue
+		}
+		flags, err := getter.GetProcessFlags(c.name)
+		if err != nil {
+			report.errorf("error getting the flags of %s on node %s: %v", c.name, n.Host, err)
+			continue
+		}
+		if flags == nil {
+			report.add(Drift{Node: n.Host, Category: DriftFlag, Item: c.name, Expected: "running", Actual: "not running", Play: c.play})
+			continue
+		}
+		overrides := c.overrides(n.Host)
+		for _, k := range sortedKeys(overrides) {
+			skip[k] = true
+			// options that are overridden with an empty value are removed from the command line
+			expected := overrides[k]
+			if expected == "" {
+				expected = flagNotSet
+			}
+			actual, ok := flags[k]
+			if !ok {
+				actual = flagNotSet
+			}
+			if actual != expected {
+				report.add(Drift{Node: n.Host, Category: DriftFlag, Item: fmt.Sprintf("%s --%s", c.name, k), Expected: expected, Actual: actual, Play: c.play})
+			}
+		}
+		live[n.Host] = flags
+		hosts = append(hosts, n.Host)
+	}
+
+	names := map[string]string{}
+	for _, flags := range live {
+		for k := range flags {
+			if !skip[k] {
+				names[k] = k
+			}
+		}
+	}
+	for _, k := range sortedKeys(names) {
+		values := make([]string, len(hosts))
+		for i, h := range hosts {
+			v, ok := live[h][k]
+			if !ok {
+				v = flagNotSet
+			}
+			values[i] = v
+		}
+		expected, ok := majority(values)
+		if !ok {
+			continue
+		}
+		for i, h := range hosts {
+			if values[i] != expected {
+				report.add(Drift{Node: h, Category: DriftFlag, Item: fmt.Sprintf("%s --%s", c.name, k), Expected: expected, Actual: values[i], Source: "peers", Play: c.play})
+			}
+		}
+	}
+}
+
+// majority returns the value of more than half of the values
+func majority(values []string) (string, bool) {
+	counts := map[string]int{}
+	for _, v := range values {
+		counts[v]++
+		if counts[v]*2 > len(values) {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// detectVersionDrift compares the versions installed on the nodes with the version of
+// KET, and the Kubernetes version of the plan. The installed versions are updated
+// by upgrading the cluster, not by a single play.
+func detectVersionDrift(plan Plan, nodes []ListableNode, report *DriftReport) {
+	for _, n := range nodes {
+		if n.Version.NE(KismaticVersion) {
+			report.add(Drift{Node: n.Node.Host, Category: DriftVersion, Item: "kismatic", Expected: KismaticVersion.String(), Actual: n.Version.String(), Fix: "kismatic upgrade offline"})
+		}
+		if len(n.Roles) == 1 && n.Roles[0] == "etcd" {
+			continue
+		}
+		if v := n.ComponentVersions.Kubernetes; v != "" && v != plan.Cluster.Version {
+			report.add(Drift{Node: n.Node.Host, Category: DriftVersion, Item: "kubernetes", Expected: plan.Cluster.Version, Actual: v, Fix: "kismatic upgrade offline"})
+		}
+	}
+}
+
+type addOnWorkload struct {
+	kind      string
+	namespace string
+	name      string
+	play      string
+}
+
+// expectedAddOns returns the workloads of the add-ons that are enabled in the cluster catalog
+func expectedAddOns(cc ansible.ClusterCatalog) []addOnWorkload {
+	addOns := []addOnWorkload{}
+	if cc.CNI.Enabled {
+		switch cc.CNI.Provider {
+		case cniProviderCalico:
+			addOns = append(addOns, addOnWorkload{"DaemonSet", "kube-system", "calico-node", "_calico.yaml"})
+		case cniProviderWeave:
+			addOns = append(addOns, addOnWorkload{"DaemonSet", "kube-system", "weave-net", "_weave.yaml"})
+		case cniProviderContiv:
+			addOns = append(addOns, addOnWorkload{"DaemonSet", "kube-system", "contiv-netplugin", "_contiv.yaml"})
+		}
+	}
+	if cc.DNS.Enabled {
+		name := "kube-dns"
+		if cc.DNS.Provider == dnsProviderCoredns {
+			name = "coredns"
+		}
+		addOns = append(addOns, addOnWorkload{"Deployment", "kube-system", name, "_cluster-dns.yaml"})
+	}
+	if cc.EnableConfigureIngress {
+		addOns = append(addOns, addOnWorkload{"DaemonSet", "kube-system", "ingress", "_nginx-ingress.yaml"})
+	}
+	if cc.Heapster.Enabled {
+		addOns = append(addOns, addOnWorkload{"Deployment", "kube-system", "heapster", "_heapster.yaml"})
+	}
+	if cc.MetricsServer.Enabled {
+		addOns = append(addOns, addOnWorkload{"Deployment", "kube-system", "metrics-server", "_metrics-server.yaml"})
+	}
+	if cc.Dashboard.Enabled {
+		addOns = append(addOns, addOnWorkload{"Deployment", "kube-system", "kubernetes-dashboard", "_kube-dashboard.yaml"})
+	}
+	if cc.Helm.Enabled {
+		ns := cc.Helm.Namespace
+		if ns == "" {
+			ns = "kube-system"
+		}
+		addOns = append(addOns, addOnWorkload{"Deployment", ns, "tiller-deploy", "_helm.yaml"})
+	}
+	return addOns
+}
+
+// detectAddOnDrift verifies that the workloads of the enabled add-ons exist
+func detectAddOnDrift(cc ansible.ClusterCatalog, client driftKubeClient, report *DriftReport) {
+	existing := map[string]bool{}
+	listed := map[string]bool{}
+	for _, a := range expectedAddOns(cc) {
+		key := a.kind + "/" + a.namespace
+		if !listed[key] {
+			listed[key] = true
+			names, err := listWorkloads(client, a.kind, a.namespace)
+			if err != nil {
+				report.errorf("error listing %ss in namespace %s: %v", a.kind, a.namespace, err)
+				continue
+			}
+			for _, n := range names {
+				existing[key+"/"+n] = true
+			}
+		}
+		if !existing[key+"/"+a.name] {
+			report.add(Drift{Category: DriftAddOn, Item: fmt.Sprintf("%s %s/%s", a.kind, a.namespace, a.name), Expected: "present", Actual: "missing", Play: a.play})
+		}
+	}
+}
+
+func listWorkloads(client driftKubeClient, kind, namespace string) ([]string, error) {
+	names := []string{}
+	switch kind {
+	case "Deployment":
+		l, err := client.ListDeployments(namespace)
+		if err != nil {
+			return nil, err
+		}
+		for _, d := range l.Items {
+			names = append(names, d.Name)
+		}
+	case "DaemonSet":
+		l, err := client.ListDaemonSets(namespace)
+		if err != nil {
+			return nil, err
+		}
+		for _, d := range l.Items {
+			names = append(names, d.Name)
+		}
+	}
+	return names, nil
+}
+
+// detectVolumeDrift verifies that the NFS volumes of the plan exist as persistent volumes.
+// The volumes are named after their position in the plan file.
+func detectVolumeDrift(cc ansible.ClusterCatalog, client driftKubeClient, report *DriftReport) {
+	if len(cc.NFSVolumes) == 0 {
+		return
+	}
+	pvList, err := client.ListPersistentVolumes()
+	if err != nil {
+		report.errorf("error listing persistent volumes: %v", err)
+		return
+	}
+	pvs := map[string]data.PersistentVolume{}
+	if pvList != nil {
+		for _, pv := range pvList.Items {
+			pvs[pv.Name] = pv
+		}
+	}
+	for i, v := range cc.NFSVolumes {
+		name := fmt.Sprintf("pv%d", i)
+		expected := fmt.Sprintf("%s:%s", v.Host, v.Path)
+		pv, ok := pvs[name]
+		switch {
+		case !ok:
+			report.add(Drift{Category: DriftVolume, Item: name, Expected: expected, Actual: "missing", Play: "_nfs-volumes.yaml"})
+		case pv.Spec.NFS == nil:
+			report.add(Drift{Category: DriftVolume, Item: name, Expected: expected, Actual: "not an NFS volume", Play: "_nfs-volumes.yaml"})
+		case pv.Spec.NFS.Server != v.Host || pv.Spec.NFS.Path != v.Path:
+			report.add(Drift{Category: DriftVolume, Item: name, Expected: expected, Actual: fmt.Sprintf("%s:%s", pv.Spec.NFS.Server, pv.Spec.NFS.Path), Play: "_nfs-volumes.yaml"})
+		}
+	}
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,191 @@
+package install
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/apprenda/kismatic/pkg/data"
+)
+
+type fakeDriftKubeClient struct {
+	nodes       *data.NodeList
+	deployments map[string]*data.DeploymentList
+	daemonSets  map[string]*data.DaemonSetList
+	pvs         *data.PersistentVolumeList
+}
+
+func (f fakeDriftKubeClient) ListNodes() (*data.NodeList, error) {
+	return f.nodes, nil
+}
+
+func (f fakeDriftKubeClient) ListDeployments(namespace string) (*data.DeploymentList, error) {
+	if l, ok := f.deployments[namespace]; ok {
+		return l, nil
+	}
+	return &data.DeploymentList{}, nil
+}
+
+func (f fakeDriftKubeClient) ListDaemonSets(namespace string) (*data.DaemonSetList, error) {
+	if l, ok := f.daemonSets[namespace]; ok {
+		return l, nil
+	}
+	return &data.DaemonSetList{}, nil
+}
+
+func (f fakeDriftKubeClient) ListPersistentVolumes() (*data.PersistentVolumeList, error) {
+	return f.pvs, nil
+}
+
+type fakeProcesses map[string]map[string]string
+
+func (f fakeProcesses) GetProcessFlags(name string) (map[string]string, error) {
+	return f[name], nil
+}
+
+type failingProcesses struct{}
+
+func (failingProcesses) GetProcessFlags(name string) (map[string]string, error) {
+	return nil, fmt.Errorf("connection refused")
+}
+
+func driftNode(name string, labels map[string]string) data.Node {
+	n := data.Node{}
+	n.Name = name
+	n.Labels = labels
+	return n
+}
+
+func driftPlan() Plan {
+	plan := Plan{}
+	plan.Cluster.Version = "v1.10.1"
+	plan.Cluster.Networking.ServiceCIDRBlock = "172.20.0.0/16"
+	plan.Cluster.KubeletOptions.Overrides = map[string]string{"max-pods": "50"}
+	plan.Cluster.APIServerOptions.Overrides = map[string]string{"runtime-config": "", "v": "3"}
+	plan.Etcd.Nodes = []Node{{Host: "etcd01", IP: "10.0.0.1"}}
+	plan.Master.Nodes = []Node{{Host: "master01", IP: "10.0.0.2"}}
+	plan.Worker.Nodes = []Node{
+		{Host: "worker01", IP: "10.0.0.3", Labels: map[string]string{"zone": "a"}},
+		{Host: "worker02", IP: "10.0.0.4"},
+		{Host: "worker03", IP: "10.0.0.5", KubeletOptions: KubeletOptions{Overrides: map[string]string{"max-pods": "100"}}},
+	}
+	plan.Ingress.Nodes = []Node{{Host: "worker02", IP: "10.0.0.4"}}
+	plan.AddOns.CNI = &CNI{Provider: cniProviderCalico}
+	plan.AddOns.DNS.Provider = dnsProviderKubedns
+	plan.AddOns.HeapsterMonitoring = &HeapsterMonitoring{Disable: true}
+	plan.AddOns.MetricsServer.Disable = true
+	plan.AddOns.Dashboard = &Dashboard{Disable: true}
+	plan.AddOns.PackageManager.Disable = true
+	plan.NFS = NFS{Volumes: []NFSVolume{{Host: "10.0.1.1", Path: "/exports/a"}, {Host: "10.0.1.1", Path: "/exports/b"}}}
+	return plan
+}
+
+func driftClients() DriftClients {
+	kubelet := func(extra map[string]string) map[string]string {
+		flags := map[string]string{"allow-privileged": "true", "max-pods": "50", "hostname-override": "node"}
+		for k, v := range extra {
+			flags[k] = v
+		}
+		return flags
+	}
+	proxy := map[string]string{"cluster-cidr": "172.16.0.0/16"}
+	return DriftClients{
+		Kube: fakeDriftKubeClient{
+			nodes: &data.NodeList{Items: []data.Node{
+				driftNode("master01", map[string]string{"kismatic/cni-provider": "calico"}),
+				driftNode("worker01", map[string]string{"kismatic/cni-provider": "calico", "zone": "b"}),
+				driftNode("worker02", map[string]string{"kismatic/cni-provider": "calico", "kismatic/ingress": "true"}),
+				driftNode("worker03", map[string]string{"kismatic/cni-provider": "calico"}),
+			}},
+			deployments: map[string]*data.DeploymentList{
+				"kube-system": {Items: []data.Deployment{{ObjectMeta: data.ObjectMeta{Name: "kube-dns"}}}},
+			},
+			daemonSets: map[string]*data.DaemonSetList{
+				"kube-system": {Items: []data.DaemonSet{{ObjectMeta: data.ObjectMeta{Name: "calico-node"}}}},
+			},
+			pvs: &data.PersistentVolumeList{Items: []data.PersistentVolume{
+				{ObjectMeta: data.ObjectMeta{Name: "pv0"}, Spec: data.PersistentVolumeSpec{PersistentVolumeSource: data.PersistentVolumeSource{NFS: &data.NFSVolumeSource{Server: "10.0.1.1", Path: "/exports/a"}}}},
+			}},
+		},
+		Processes: map[string]data.ProcessFlagsGetter{
+			"master01": fakeProcesses{
+				"kubelet":                 kubelet(nil),
+				"kube-proxy":              proxy,
+				"kube-apiserver":          {"v": "3", "runtime-config": "batch/v2alpha1=true", "advertise-address": "10.0.0.2"},
+				"kube-controller-manager": {"v": "2"},
+			},
+			"worker01": fakeProcesses{"kubelet": kubelet(map[string]string{"hostname-override": "worker01"}), "kube-proxy": proxy},
+			"worker02": fakeProcesses{"kubelet": kubelet(map[string]string{"max-pods": "110", "v": "4"}), "kube-proxy": proxy},
+			"worker03": fakeProcesses{"kubelet": kubelet(map[string]string{"max-pods": "100"}), "kube-proxy": proxy},
+		},
+	}
+}
+
+func TestDetectDrift(t *testing.T) {
+	versions := []ListableNode{
+		{Node: Node{Host: "etcd01"}, Roles: []string{"etcd"}, Version: KismaticVersion},
+		{Node: Node{Host: "master01"}, Roles: []string{"master"}, Version: KismaticVersion, ComponentVersions: ComponentVersions{Kubernetes: "v1.10.1"}},
+		{Node: Node{Host: "worker01"}, Roles: []string{"worker"}, Version: KismaticVersion, ComponentVersions: ComponentVersions{Kubernetes: "v1.9.6"}},
+	}
+	report, err := DetectDrift(driftPlan(), "generated", versions, driftClients())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []Drift{
+		{Node: "worker01", Category: DriftLabel, Item: "zone", Expected: "a", Actual: "b", Source: "plan", Play: "_label-nodes.yaml", Fix: "kismatic install step _label-nodes.yaml --limit worker01"},
+		{Node: "master01", Category: DriftFlag, Item: "kube-apiserver --runtime-config", Expected: flagNotSet, Actual: "batch/v2alpha1=true", Source: "plan", Play: "_kube-apiserver.yaml", Fix: "kismatic install step _kube-apiserver.yaml --limit master01"},
+		{Node: "master01", Category: DriftFlag, Item: "kube-scheduler", Expected: "running", Actual: "not running", Source: "plan", Play: "_kube-scheduler.yaml", Fix: "kismatic install step _kube-scheduler.yaml --limit master01"},
+		{Node: "worker02", Category: DriftFlag, Item: "kubelet --max-pods", Expected: "50", Actual: "110", Source: "plan", Play: "_kubelet.yaml", Fix: "kismatic install step _kubelet.yaml --limit worker02"},
+		{Node: "worker02", Category: DriftFlag, Item: "kubelet --v", Expected: flagNotSet, Actual: "4", Source: "peers", Play: "_kubelet.yaml", Fix: "kismatic install step _kubelet.yaml --limit worker02"},
+		{Node: "worker01", Category: DriftVersion, Item: "kubernetes", Expected: "v1.10.1", Actual: "v1.9.6", Source: "plan", Fix: "kismatic upgrade offline"},
+		{Category: DriftAddOn, Item: "DaemonSet kube-system/ingress", Expected: "present", Actual: "missing", Source: "plan", Play: "_nginx-ingress.yaml", Fix: "kismatic install step _nginx-ingress.yaml"},
+		{Category: DriftVolume, Item: "pv1", Expected: "10.0.1.1:/exports/b", Actual: "missing", Source: "plan", Play: "_nfs-volumes.yaml", Fix: "kismatic install step _nfs-volumes.yaml"},
+	}
+	if len(report.Drifts) != len(expected) {
+		t.Fatalf("expected %d drifts, got %d: %+v", len(expected), len(report.Drifts), report.Drifts)
+	}
+	for i, d := range report.Drifts {
+		if d != expected[i] {
+			t.Errorf("drift %d: expected %+v, got %+v", i, expected[i], d)
+		}
+	}
+	if len(report.Errors) != 0 {
+		t.Errorf("unexpected errors: %v", report.Errors)
+	}
+}
+
+func TestDetectDriftNoMajority(t *testing.T) {
+	plan := driftPlan()
+	plan.Worker.Nodes = plan.Worker.Nodes[:1]
+	plan.Ingress.Nodes = nil
+	clients := driftClients()
+	// the two kubelets disagree, and neither is taken as the expected value
+	clients.Processes["master01"] = fakeProcesses{"kubelet": {"max-pods": "50", "v": "2"}}
+	clients.Processes["worker01"] = fakeProcesses{"kubelet": {"max-pods": "50", "v": "4"}}
+	report, err := DetectDrift(plan, "generated", nil, clients)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, d := range report.Drifts {
+		if d.Item == "kubelet --v" {
+			t.Errorf("unexpected drift %+v", d)
+		}
+	}
+}
+
+func TestDetectDriftErrors(t *testing.T) {
+	clients := driftClients()
+	clients.Processes["worker03"] = failingProcesses{}
+	delete(clients.Processes, "worker01")
+	report, err := DetectDrift(driftPlan(), "generated", nil, clients)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(report.Errors) != 4 {
+		t.Errorf("expected 4 errors, got %v", report.Errors)
+	}
+	for _, d := range report.Drifts {
+		if d.Node == "worker01" && d.Category == DriftFlag {
+			t.Errorf("unexpected drift for a node that could not be inspected: %+v", d)
+		}
+	}
+}
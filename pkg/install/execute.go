@@ -12,6 +12,7 @@ import (
 	"strings"
 
 	"github.com/apprenda/kismatic/pkg/ansible"
+	"github.com/apprenda/kismatic/pkg/install/analyze"
 	"github.com/apprenda/kismatic/pkg/install/explain"
 	"github.com/apprenda/kismatic/pkg/tls"
 	"github.com/apprenda/kismatic/pkg/util"
@@ -35,14 +36,24 @@ type Executor interface {
 	RunPlay(name string, plan *Plan, restartServices bool, nodes ...string) error
 	AddVolume(*Plan, StorageVolume) error
 	DeleteVolume(*Plan, string) error
-	UpgradeNodes(plan Plan, nodesToUpgrade []ListableNode, onlineUpgrade bool, maxParallelWorkers int, restartServices bool) error
+	UpgradeNodes(plan Plan, nodesToUpgrade []ListableNode, onlineUpgrade bool, strategy UpgradeStrategy, restartServices bool) error
 	ValidateControlPlane(plan Plan) error
 	UpgradeClusterServices(plan Plan) error
+	RebootNodes(plan Plan, nodes []ListableNode, opts RebootOptions) error
+	RotateCertificates(plan Plan, nodes []ListableNode) error
+	DeployCRL(plan Plan) error
+	BindUser(plan Plan, user User) error
+	UnbindUser(plan Plan, user string) error
+	CordonNodes(plan Plan, nodes ...string) error
+	DrainNodes(plan Plan, nodes ...string) error
+	UncordonNodes(plan Plan, nodes ...string) error
 }
 
 // DiagnosticsExecutor will run diagnostics on the nodes after an install
 type DiagnosticsExecutor interface {
-	DiagnoseNodes(plan Plan) error
+	// DiagnoseNodes collects the diagnostics of the nodes, and returns the
+	// directory where they were stored
+	DiagnoseNodes(plan Plan) (string, error)
 }
 
 // ExecutorOptions are used to configure the executor
@@ -58,8 +69,27 @@ type ExecutorOptions struct {
 	RunsDirectory string
 	// DiagnosticsDirecty is where the doDiagnostics information about the cluster will be dumped
 	DiagnosticsDirecty string
+	// DiagnosticsSince is how far back the logs collected by the diagnostics go
+	DiagnosticsSince time.Duration
 	// DryRun determines if the executor should actually run the task
 	DryRun bool
+	// StallTimeout is the amount of time to wait for progress from ansible
+	// before failing the run. Zero means no timeout.
+	StallTimeout time.Duration
+	// PlayTimeout is the maximum amount of time a single ansible play can take.
+	// Zero means no timeout.
+	PlayTimeout time.Duration
+	// Timeout is the maximum amount of time a single ansible run can take.
+	// Zero means no timeout.
+	Timeout time.Duration
+	// MaxFailedWorkers is the number of worker nodes that are allowed to fail
+	// before the installation is stopped. Zero means no failures are tolerated,
+	// unless MaxFailPercentage is set.
+	MaxFailedWorkers int
+	// MaxFailPercentage is the percentage of worker nodes that are allowed to
+	// fail before the installation is stopped. Zero means no failures are
+	// tolerated, unless MaxFailedWorkers is set.
+	MaxFailPercentage int
 }
 
 // NewExecutor returns an executor for performing installations according to the installation plan.
@@ -183,10 +213,15 @@ type task struct {
 	plan Plan
 	// run the task on specific nodes
 	limit []string
+	// continue the task when worker nodes fail, within the limits
+	// set in the executor options
+	tolerateWorkerFailures bool
+	// nodes that are not allowed to fail, even if they are workers
+	protectedHosts []string
 }
 
 // execute will run the given task, and setup all what's needed for us to run ansible.
-func (ae *ansibleExecutor) execute(t task) error {
+func (ae *ansibleExecutor) execute(t task) (err error) {
 	if ae.options.DryRun {
 		return nil
 	}
@@ -211,6 +246,10 @@ func (ae *ansibleExecutor) execute(t task) error {
 		return err
 	}
 
+	// Ansible stops at the first failure, unless we are tolerating failures on worker nodes
+	tolerateWorkerFailures := t.tolerateWorkerFailures && (ae.options.MaxFailedWorkers > 0 || ae.options.MaxFailPercentage > 0)
+	t.clusterCatalog.TolerateWorkerFailures = tolerateWorkerFailures
+
 	// Start running ansible with the given playbook
 	var eventStream <-chan ansible.Event
 	if t.limit != nil && len(t.limit) != 0 {
@@ -221,15 +260,109 @@ func (ae *ansibleExecutor) execute(t task) error {
 	if err != nil {
 		return fmt.Errorf("error running ansible playbook: %v", err)
 	}
+	// Watch the event stream, so that we don't wait forever on a stuck node
+	watchdog := ansible.NewWatchdog(ansible.WatchdogOptions{
+		StallTimeout: ae.options.StallTimeout,
+		PlayTimeout:  ae.options.PlayTimeout,
+		Timeout:      ae.options.Timeout,
+	}, t.inventory)
+	defer watchdog.Stop()
+	events := watchdog.Watch(eventStream)
+
+	// Keep track of the nodes that fail. Failures on worker nodes are only
+	// tolerated if enabled.
+	failures := newWorkerFailureTracker(ae.options.MaxFailedWorkers, ae.options.MaxFailPercentage, t.inventory, t.protectedHosts...)
+	var failuresExceeded <-chan error
+	if tolerateWorkerFailures {
+		failuresExceeded = failures.Exceeded()
+	}
+	events = failures.Watch(events)
+
+	// Look for known failure signatures in the output of the failed tasks
+	analyzer, err := analyze.NewAnalyzer(analyze.DefaultRules())
+	if err != nil {
+		return fmt.Errorf("error creating failure analyzer: %v", err)
+	}
+	events = analyzer.Watch(events)
+	defer func() {
+		if err != nil {
+			ae.printFindings(analyzer)
+		}
+	}()
+
 	// Ansible blocks until explainer starts reading from stream. Start
 	// explainer in a separate go routine
-	go explainer.Explain(eventStream)
+	go explainer.Explain(events)
+
+	// Wait until ansible exits, or until we give up on it
+	waitErr := make(chan error, 1)
+	go func() {
+		waitErr <- runner.WaitPlaybook()
+	}()
+	var stopReason error
+	select {
+	case err = <-waitErr:
+		if err == nil {
+			return nil
+		}
+	case stopReason = <-watchdog.Expired():
+	case stopReason = <-failuresExceeded:
+	}
+	if stopReason != nil {
+		if err = runner.StopPlaybook(); err != nil {
+			return fmt.Errorf("error running playbook: %v. %v", stopReason, err)
+		}
+		<-waitErr
+		return fmt.Errorf("error running playbook: %v", stopReason)
+	}
+	// Ansible exited with an error. Wait for the end of the event stream to be
+	// processed, and check if the failures are tolerated.
+	waitForEvents(failures.Done())
+	if !tolerateWorkerFailures {
+		return playbookFailedError{err: err, hosts: failures.FailedHosts()}
+	}
+	select {
+	case stopReason = <-failuresExceeded:
+		return playbookFailedError{err: stopReason, hosts: failures.FailedHosts()}
+	default:
+	}
+	failed := failures.Failed()
+	if len(failed) == 0 {
+		return playbookFailedError{err: err, hosts: failures.FailedHosts()}
+	}
+	// Record the failed nodes, so that they can be retried with --limit
+	failedHostsFile := filepath.Join(runDirectory, "failed-hosts")
+	if err = ioutil.WriteFile(failedHostsFile, []byte(strings.Join(failed, "\n")+"\n"), 0644); err != nil {
+		return fmt.Errorf("error recording failed nodes to %q: %v", failedHostsFile, err)
+	}
+	return FailedWorkersError{Hosts: failed, RunDirectory: runDirectory}
+}
 
-	// Wait until ansible exits
-	if err = runner.WaitPlaybook(); err != nil {
-		return fmt.Errorf("error running playbook: %v", err)
+// eventStreamTimeout is the maximum amount of time to wait for the events of a run
+// to be processed once ansible has exited
+const eventStreamTimeout = 30 * time.Second
+
+// waitForEvents waits until the end of the event stream of a run has been processed.
+// The stream ends once ansible exits, but the wait is bounded in case ansible could
+// not be stopped.
+func waitForEvents(done <-chan struct{}) {
+	select {
+	case <-done:
+	case <-time.After(eventStreamTimeout):
 	}
-	return nil
+}
+
+// printFindings prints the known failure signatures that were found during the run
+func (ae *ansibleExecutor) printFindings(analyzer *analyze.Analyzer) {
+	waitForEvents(analyzer.Done())
+	findings := analyzer.Findings()
+	if len(findings) == 0 {
+		return
+	}
+	fmt.Fprintln(ae.stdout)
+	fmt.Fprintln(ae.stdout, "Known failure signatures were found in the output of the run:")
+	fmt.Fprintln(ae.stdout)
+	analyze.PrintFindings(ae.stdout, findings)
 }
 
 // GenerateCertificatesprivate generates keys and certificates for the cluster, if needed
@@ -296,6 +429,8 @@ func (ae *ansibleExecutor) Install(p *Plan, restartServices bool, nodes ...strin
 		clusterCatalog: *cc,
 		explainer:      ae.defaultExplainer(),
 		limit:          nodes,
+
+		tolerateWorkerFailures: true,
 	}
 	util.PrintHeader(ae.stdout, "Installing Cluster", '=')
 	return ae.execute(t)
@@ -492,73 +627,379 @@ func (ae *ansibleExecutor) DeleteVolume(plan *Plan, name string) error {
 // UpgradeNodes upgrades the nodes of the cluster in the following phases:
 //   1. Etcd nodes
 //   2. Master nodes
-//   3. Worker nodes (regardless of specialization)
+//   3. Ingress and storage nodes, one at a time
+//   4. Worker nodes
 //
 // When a node is being upgraded, all the components of the node are upgraded, regardless of
 // which phase of the upgrade we are in. For example, when upgrading a node that is both an etcd and master,
 // the etcd components and the master components will be upgraded when we are in the upgrade etcd nodes
 // phase.
-func (ae *ansibleExecutor) UpgradeNodes(plan Plan, nodesToUpgrade []ListableNode, onlineUpgrade bool, maxParallelWorkers int, restartServices bool) error {
-	// Nodes can have multiple roles. For this reason, we need to keep track of which nodes
-	// have been upgraded to avoid re-upgrading them.
-	upgradedNodes := map[string]bool{}
-	// Upgrade etcd nodes
-	for _, nodeToUpgrade := range nodesToUpgrade {
-		for _, role := range nodeToUpgrade.Roles {
-			if role == "etcd" {
-				node := nodeToUpgrade
-				if err := ae.upgradeNodes(plan, onlineUpgrade, restartServices, node); err != nil {
-					return fmt.Errorf("error upgrading node %q: %v", node.Node.Host, err)
-				}
-				upgradedNodes[node.Node.IP] = true
-				break
-			}
+//
+// Worker nodes are upgraded in batches, according to the upgrade strategy. The progress
+// of the worker upgrade is recorded in the generated assets directory, so that an
+// interrupted upgrade can be resumed.
+func (ae *ansibleExecutor) UpgradeNodes(plan Plan, nodesToUpgrade []ListableNode, onlineUpgrade bool, strategy UpgradeStrategy, restartServices bool) error {
+	// Etcd, master, ingress and storage nodes are upgraded one at a time,
+	// and the rest of the nodes in batches
+	serial, workers := serialUpgradeNodes(nodesToUpgrade)
+	for _, node := range serial {
+		if err := ae.upgradeNodesWithRollback(plan, onlineUpgrade, restartServices, strategy, node); err != nil {
+			return fmt.Errorf("error upgrading node %q: %v", node.Node.Host, err)
+		}
+		if err := ae.verifyUpgradedNode(plan, node); err != nil {
+			return fmt.Errorf("node %q is not healthy after the upgrade: %v", node.Node.Host, err)
 		}
 	}
 
-	// Upgrade master nodes
-	for _, nodeToUpgrade := range nodesToUpgrade {
-		if upgradedNodes[nodeToUpgrade.Node.IP] == true {
-			continue
+	if len(workers) == 0 {
+		return nil
+	}
+	progressFile := filepath.Join(ae.options.GeneratedAssetsDirectory, "upgrade-progress.yaml")
+	progress, err := readUpgradeProgress(progressFile, plan)
+	if err != nil {
+		return err
+	}
+	if len(progress.CompletedBatches) > 0 {
+		util.PrettyPrintOk(ae.stdout, "Resuming upgrade, %d batch(es) of worker nodes were previously upgraded: %s", len(progress.CompletedBatches), strings.Join(progress.CompletedBatches, ", "))
+	}
+	// Skip the worker nodes that were upgraded before the upgrade was interrupted
+	batches, err := WorkerUpgradeBatches(progress.pending(workers), strategy, progress.CanaryUpgraded)
+	if err != nil {
+		return err
+	}
+	for i, batch := range batches {
+		if i > 0 && strategy.BetweenBatches != nil {
+			if err := strategy.BetweenBatches(batches[i-1], batch); err != nil {
+				return err
+			}
 		}
-		for _, role := range nodeToUpgrade.Roles {
-			if role == "master" {
-				node := nodeToUpgrade
-				if err := ae.upgradeNodes(plan, onlineUpgrade, restartServices, node); err != nil {
-					return fmt.Errorf("error upgrading node %q: %v", node.Node.Host, err)
+		if err := ae.upgradeNodesWithRollback(plan, onlineUpgrade, restartServices, strategy, batch.Nodes...); err != nil {
+			return fmt.Errorf("error upgrading batch %q %v: %v", batch.Name, batch.Hosts(), err)
+		}
+		if batch.Canary && strategy.CanaryHealthCheck {
+			for _, n := range batch.Nodes {
+				if err := ae.nodeSmokeTest(plan, n.Node); err != nil {
+					return fmt.Errorf("canary node %q is not healthy after the upgrade: %v", n.Node.Host, err)
 				}
-				upgradedNodes[node.Node.IP] = true
-				break
 			}
 		}
+		if ae.options.DryRun {
+			continue
+		}
+		progress.record(batch)
+		if err := progress.write(progressFile); err != nil {
+			return err
+		}
+	}
+	// The upgrade of the workers is complete, there is nothing to resume
+	if err := os.Remove(progressFile); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("error removing upgrade progress file %q: %v", progressFile, err)
 	}
+	return nil
+}
 
-	var limitNodes []ListableNode
-	// Upgrade the rest of the nodes
-	for n, nodeToUpgrade := range nodesToUpgrade {
-		if upgradedNodes[nodeToUpgrade.Node.IP] == true {
-			continue
+// RebootNodes drains and reboots the nodes, and uncordons them once they are healthy.
+// Etcd, master, ingress and storage nodes are rebooted one at a time, and the rest
+// of the nodes in batches.
+func (ae *ansibleExecutor) RebootNodes(plan Plan, nodes []ListableNode, opts RebootOptions) error {
+	serial, workers := serialUpgradeNodes(nodes)
+	for _, node := range serial {
+		if err := ae.rebootNodes(plan, opts.Command, node); err != nil {
+			return fmt.Errorf("error rebooting node %q: %v", node.Node.Host, err)
 		}
-		for _, role := range nodeToUpgrade.Roles {
-			if role != "etcd" && role != "master" {
-				node := nodeToUpgrade
-				limitNodes = append(limitNodes, node)
-				// don't forget to run the remaining nodes if its < maxParallelWorkers
-				if len(limitNodes) == maxParallelWorkers || n == len(nodesToUpgrade)-1 {
-					if err := ae.upgradeNodes(plan, onlineUpgrade, restartServices, limitNodes...); err != nil {
-						return fmt.Errorf("error upgrading node %q: %v", node.Node.Host, err)
-					}
-					// empty the slice
-					limitNodes = limitNodes[:0]
-				}
-				upgradedNodes[node.Node.IP] = true
-				break
-			}
+	}
+	batches, err := WorkerUpgradeBatches(workers, UpgradeStrategy{MaxParallelWorkers: opts.MaxParallelWorkers}, false)
+	if err != nil {
+		return err
+	}
+	for _, batch := range batches {
+		if err := ae.rebootNodes(plan, opts.Command, batch.Nodes...); err != nil {
+			return fmt.Errorf("error rebooting batch %q %v: %v", batch.Name, batch.Hosts(), err)
 		}
 	}
 	return nil
 }
 
+func (ae *ansibleExecutor) rebootNodes(plan Plan, command string, nodes ...ListableNode) error {
+	cc, err := ae.buildClusterCatalog(&plan)
+	if err != nil {
+		return err
+	}
+	cc.MaintenanceCommand = command
+	var limit []string
+	for _, node := range nodes {
+		limit = append(limit, node.Node.Host)
+	}
+	t := task{
+		name:           "reboot-nodes",
+		playbook:       "reboot-nodes.yaml",
+		inventory:      buildInventoryFromPlan(&plan),
+		clusterCatalog: *cc,
+		plan:           plan,
+		explainer:      ae.defaultExplainer(),
+		limit:          limit,
+	}
+	util.PrintHeader(ae.stdout, fmt.Sprintf("Reboot Nodes: %s", strings.Join(limit, ", ")), '=')
+	return ae.execute(t)
+}
+
+// RotateCertificates deploys the certificates to the nodes, and restarts the components
+// that use them. The nodes are restarted one at a time, and the health of the node is
+// verified before moving on to the next node.
+func (ae *ansibleExecutor) RotateCertificates(plan Plan, nodes []ListableNode) error {
+	serial, workers := serialUpgradeNodes(nodes)
+	for _, node := range append(serial, workers...) {
+		if err := ae.runNodePlaybook(plan, "rotate-certificates", "rotate-certificates.yaml", "Rotate Certificates", node.Node.Host); err != nil {
+			return fmt.Errorf("error rotating the certificates of node %q: %v", node.Node.Host, err)
+		}
+	}
+	return nil
+}
+
+// DeployCRL deploys the certificate revocation list of the cluster CA to the etcd and master nodes
+func (ae *ansibleExecutor) DeployCRL(plan Plan) error {
+	cc, err := ae.buildClusterCatalog(&plan)
+	if err != nil {
+		return err
+	}
+	t := task{
+		name:           "deploy-crl",
+		playbook:       "_certs-crl.yaml",
+		inventory:      buildInventoryFromPlan(&plan),
+		clusterCatalog: *cc,
+		plan:           plan,
+		explainer:      ae.defaultExplainer(),
+	}
+	util.PrintHeader(ae.stdout, "Deploy Certificate Revocation List", '=')
+	return ae.execute(t)
+}
+
+// BindUser binds the role of the user, without changing the bindings of the users of the plan file
+func (ae *ansibleExecutor) BindUser(plan Plan, user User) error {
+	cc, err := ae.buildClusterCatalog(&plan)
+	if err != nil {
+		return err
+	}
+	cc.UserBindings = userBindings([]User{user})
+	cc.ReconcileUserBindings = false
+	t := task{
+		name:           "bind-user",
+		playbook:       "_user-bindings.yaml",
+		inventory:      buildInventoryFromPlan(&plan),
+		clusterCatalog: *cc,
+		plan:           plan,
+		explainer:      ae.defaultExplainer(),
+	}
+	util.PrintHeader(ae.stdout, fmt.Sprintf("Bind Role %q to User %q", user.Role, user.Name), '=')
+	return ae.execute(t)
+}
+
+// UnbindUser deletes the role bindings of the user, to deny its access to the cluster
+// once its certificate has been revoked
+func (ae *ansibleExecutor) UnbindUser(plan Plan, user string) error {
+	cc, err := ae.buildClusterCatalog(&plan)
+	if err != nil {
+		return err
+	}
+	cc.RevokedUser = user
+	t := task{
+		name:           "unbind-user",
+		playbook:       "_user-revoke.yaml",
+		inventory:      buildInventoryFromPlan(&plan),
+		clusterCatalog: *cc,
+		plan:           plan,
+		explainer:      ae.defaultExplainer(),
+	}
+	util.PrintHeader(ae.stdout, fmt.Sprintf("Delete Role Bindings of User %q", user), '=')
+	return ae.execute(t)
+}
+
+// CordonNodes marks the worker nodes as unschedulable
+func (ae *ansibleExecutor) CordonNodes(plan Plan, nodes ...string) error {
+	return ae.runNodePlaybook(plan, "cordon-nodes", "_kube-cordon-node.yaml", "Cordon Nodes", nodes...)
+}
+
+// DrainNodes evicts the workloads running on the nodes, and marks the nodes as unschedulable
+func (ae *ansibleExecutor) DrainNodes(plan Plan, nodes ...string) error {
+	return ae.runNodePlaybook(plan, "drain-nodes", "_kube-drain-node.yaml", "Drain Nodes", nodes...)
+}
+
+// UncordonNodes marks the worker nodes as schedulable
+func (ae *ansibleExecutor) UncordonNodes(plan Plan, nodes ...string) error {
+	return ae.runNodePlaybook(plan, "uncordon-nodes", "_kube-uncordon-node.yaml", "Uncordon Nodes", nodes...)
+}
+
+func (ae *ansibleExecutor) runNodePlaybook(plan Plan, name string, playbook string, header string, nodes ...string) error {
+	cc, err := ae.buildClusterCatalog(&plan)
+	if err != nil {
+		return err
+	}
+	t := task{
+		name:           name,
+		playbook:       playbook,
+		inventory:      buildInventoryFromPlan(&plan),
+		clusterCatalog: *cc,
+		plan:           plan,
+		explainer:      ae.defaultExplainer(),
+		limit:          nodes,
+	}
+	util.PrintHeader(ae.stdout, fmt.Sprintf("%s: %s", header, strings.Join(nodes, ", ")), '=')
+	return ae.execute(t)
+}
+
+// nodeSmokeTest verifies that the node is registered with the API server and is Ready
+func (ae *ansibleExecutor) nodeSmokeTest(plan Plan, node Node) error {
+	cc, err := ae.buildClusterCatalog(&plan)
+	if err != nil {
+		return err
+	}
+	cc.NewNode = node.Host
+	t := task{
+		name:           "node-smoke-test",
+		playbook:       "_node-smoke-test.yaml",
+		plan:           plan,
+		inventory:      buildInventoryFromPlan(&plan),
+		clusterCatalog: *cc,
+		explainer:      ae.defaultExplainer(),
+		limit:          []string{node.Host},
+	}
+	util.PrintHeader(ae.stdout, fmt.Sprintf("Node Health Check: %s", node.Host), '=')
+	return ae.execute(t)
+}
+
+// verifyUpgradedNode checks the health of ingress and storage nodes after they
+// have been upgraded, before moving on to the next node
+func (ae *ansibleExecutor) verifyUpgradedNode(plan Plan, node ListableNode) error {
+	if !util.Contains("ingress", node.Roles) && !util.Contains("storage", node.Roles) {
+		return nil
+	}
+	if err := ae.nodeSmokeTest(plan, node.Node); err != nil {
+		return err
+	}
+	if !util.Contains("storage", node.Roles) {
+		return nil
+	}
+	cc, err := ae.buildClusterCatalog(&plan)
+	if err != nil {
+		return err
+	}
+	t := task{
+		name:           "storage-health-check",
+		playbook:       "_storage-health-check.yaml",
+		plan:           plan,
+		inventory:      buildInventoryFromPlan(&plan),
+		clusterCatalog: *cc,
+		explainer:      ae.defaultExplainer(),
+		limit:          []string{node.Node.Host},
+	}
+	util.PrintHeader(ae.stdout, fmt.Sprintf("Storage Health Check: %s", node.Node.Host), '=')
+	return ae.execute(t)
+}
+
+// upgradeNodesWithRollback takes a snapshot of the nodes before upgrading them. If the
+// upgrade fails, the nodes that failed are rolled back to the snapshot when the strategy
+// allows it. Etcd and master nodes are never rolled back.
+func (ae *ansibleExecutor) upgradeNodesWithRollback(plan Plan, onlineUpgrade bool, restartServices bool, strategy UpgradeStrategy, nodes ...ListableNode) error {
+	if err := ae.snapshotNodes(plan, nodes...); err != nil {
+		return fmt.Errorf("error taking a snapshot of the node(s) before the upgrade: %v", err)
+	}
+	upgradeErr := ae.upgradeNodes(plan, onlineUpgrade, restartServices, nodes...)
+	if upgradeErr == nil || ae.options.DryRun {
+		return upgradeErr
+	}
+	// Only the nodes that failed are rolled back, the rest of the nodes were upgraded
+	pfe, ok := upgradeErr.(playbookFailedError)
+	if !ok {
+		return upgradeErr
+	}
+	failed := []ListableNode{}
+	hosts := []string{}
+	for _, n := range nodes {
+		if !util.Contains(n.Node.Host, pfe.hosts) {
+			continue
+		}
+		// Restoring etcd and master nodes after a partial upgrade of the control
+		// plane puts the quorum of etcd at risk
+		if util.Contains("etcd", n.Roles) || util.Contains("master", n.Roles) {
+			return fmt.Errorf("%v. Node %q is an etcd or master node, and cannot be rolled back", upgradeErr, n.Node.Host)
+		}
+		failed = append(failed, n)
+		hosts = append(hosts, n.Node.Host)
+	}
+	if len(failed) == 0 {
+		return upgradeErr
+	}
+	if strategy.RollbackOnFailure == nil || !strategy.RollbackOnFailure(hosts, upgradeErr) {
+		return upgradeErr
+	}
+	rollbackErr := ae.rollbackNodes(plan, failed...)
+	record := NodeRollback{
+		Time:         time.Now(),
+		Nodes:        hosts,
+		UpgradeError: upgradeErr.Error(),
+		Succeeded:    rollbackErr == nil,
+	}
+	if rollbackErr != nil {
+		record.Error = rollbackErr.Error()
+	}
+	if err := recordRollback(filepath.Join(ae.options.RunsDirectory, rollbackHistoryFile), record); err != nil {
+		util.PrettyPrintWarn(ae.stdout, "Failed to record the rollback in the run history: %v", err)
+	}
+	if rollbackErr != nil {
+		return fmt.Errorf("%v. The node(s) could not be rolled back, and were left cordoned: %v", upgradeErr, rollbackErr)
+	}
+	util.PrettyPrintOk(ae.stdout, "Rolled back %s to the previous version", strings.Join(hosts, ", "))
+	return fmt.Errorf("%v. The node(s) %s were rolled back to the previous version", upgradeErr, strings.Join(hosts, ", "))
+}
+
+// snapshotNodes saves the component versions and configuration of the nodes,
+// to be able to roll them back if the upgrade fails
+func (ae *ansibleExecutor) snapshotNodes(plan Plan, nodes ...ListableNode) error {
+	cc, err := ae.buildClusterCatalog(&plan)
+	if err != nil {
+		return err
+	}
+	var limit []string
+	for _, node := range nodes {
+		limit = append(limit, node.Node.Host)
+	}
+	t := task{
+		name:           "snapshot-nodes",
+		playbook:       "_node-snapshot.yaml",
+		inventory:      buildInventoryFromPlan(&plan),
+		clusterCatalog: *cc,
+		plan:           plan,
+		explainer:      ae.defaultExplainer(),
+		limit:          limit,
+	}
+	util.PrintHeader(ae.stdout, "Snapshot Nodes", '=')
+	return ae.execute(t)
+}
+
+// rollbackNodes restores the packages and configuration of the nodes from the snapshot
+// taken before the upgrade. The nodes are uncordoned once they are healthy.
+func (ae *ansibleExecutor) rollbackNodes(plan Plan, nodes ...ListableNode) error {
+	cc, err := ae.buildClusterCatalog(&plan)
+	if err != nil {
+		return err
+	}
+	var limit []string
+	for _, node := range nodes {
+		limit = append(limit, node.Node.Host)
+	}
+	t := task{
+		name:           "rollback-nodes",
+		playbook:       "rollback-node.yaml",
+		inventory:      buildInventoryFromPlan(&plan),
+		clusterCatalog: *cc,
+		plan:           plan,
+		explainer:      ae.defaultExplainer(),
+		limit:          limit,
+	}
+	util.PrintHeader(ae.stdout, fmt.Sprintf("Roll Back Nodes: %s", strings.Join(limit, ", ")), '=')
+	return ae.execute(t)
+}
+
 func (ae *ansibleExecutor) upgradeNodes(plan Plan, onlineUpgrade bool, restartServices bool, nodes ...ListableNode) error {
 	inventory := buildInventoryFromPlan(&plan)
 	cc, err := ae.buildClusterCatalog(&plan)
@@ -627,16 +1068,22 @@ func (ae *ansibleExecutor) UpgradeClusterServices(plan Plan) error {
 	return ae.execute(t)
 }
 
-func (ae *ansibleExecutor) DiagnoseNodes(plan Plan) error {
+func (ae *ansibleExecutor) DiagnoseNodes(plan Plan) (string, error) {
 	inventory := buildInventoryFromPlan(&plan)
 	cc, err := ae.buildClusterCatalog(&plan)
 	if err != nil {
-		return err
+		return "", err
 	}
 	// dateTime will be appended to the diagnostics directory
 	now := time.Now().Format("2006-01-02-15-04-05")
 	cc.DiagnosticsDirectory = filepath.Join(ae.options.DiagnosticsDirecty, now)
 	cc.DiagnosticsDateTime = now
+	// journalctl accepts times relative to the current time of the node
+	since := ae.options.DiagnosticsSince
+	if since == 0 {
+		since = 24 * time.Hour
+	}
+	cc.DiagnosticsSince = fmt.Sprintf("-%ds", int64(since.Seconds()))
 	t := task{
 		name:           "diagnose",
 		playbook:       "diagnose-nodes.yaml",
@@ -645,14 +1092,20 @@ func (ae *ansibleExecutor) DiagnoseNodes(plan Plan) error {
 		plan:           plan,
 		explainer:      ae.defaultExplainer(),
 	}
-	return ae.execute(t)
+	return cc.DiagnosticsDirectory, ae.execute(t)
 }
 
 // creates the extra vars that are required for the installation playbook.
 func (ae *ansibleExecutor) buildClusterCatalog(p *Plan) (*ansible.ClusterCatalog, error) {
-	tlsDir, err := filepath.Abs(ae.certsDir)
+	return buildClusterCatalog(p, ae.certsDir, ae.options.GeneratedAssetsDirectory)
+}
+
+// buildClusterCatalog returns the catalog of the cluster described by the plan,
+// which is what the playbooks install on the nodes
+func buildClusterCatalog(p *Plan, certsDir, generatedAssetsDir string) (*ansible.ClusterCatalog, error) {
+	tlsDir, err := filepath.Abs(certsDir)
 	if err != nil {
-		return nil, fmt.Errorf("failed to determine absolute path to %s: %v", ae.certsDir, err)
+		return nil, fmt.Errorf("failed to determine absolute path to %s: %v", certsDir, err)
 	}
 
 	dnsIP, err := getDNSServiceIP(p)
@@ -664,6 +1117,8 @@ func (ae *ansibleExecutor) buildClusterCatalog(p *Plan) (*ansible.ClusterCatalog
 		ClusterName:                   p.Cluster.Name,
 		AdminPassword:                 p.Cluster.AdminPassword,
 		TLSDirectory:                  tlsDir,
+		CACertificateFile:             deployedCAFile(certsDir),
+		CRLFile:                       deployedCRLFile(certsDir),
 		ServicesCIDR:                  p.Cluster.Networking.ServiceCIDRBlock,
 		PodCIDR:                       p.Cluster.Networking.PodCIDRBlock,
 		DNSServiceIP:                  dnsIP,
@@ -692,11 +1147,11 @@ func (ae *ansibleExecutor) buildClusterCatalog(p *Plan) (*ansible.ClusterCatalog
 		cc.NoProxy = cc.NoProxy + "," + p.Cluster.Networking.NoProxy
 	}
 
-	cc.LocalKubeconfigDirectory = filepath.Join(ae.options.GeneratedAssetsDirectory, "kubeconfig")
+	cc.LocalKubeconfigDirectory = filepath.Join(generatedAssetsDir, "kubeconfig")
 	// absolute path required for ansible
-	generatedDir, err := filepath.Abs(filepath.Join(ae.options.GeneratedAssetsDirectory, "kubeconfig"))
+	generatedDir, err := filepath.Abs(filepath.Join(generatedAssetsDir, "kubeconfig"))
 	if err != nil {
-		return nil, fmt.Errorf("failed to determine absolute path to %s: %v", filepath.Join(ae.options.GeneratedAssetsDirectory, "kubeconfig"), err)
+		return nil, fmt.Errorf("failed to determine absolute path to %s: %v", filepath.Join(generatedAssetsDir, "kubeconfig"), err)
 	}
 	cc.LocalKubeconfigDirectory = generatedDir
 
@@ -749,6 +1204,10 @@ func (ae *ansibleExecutor) buildClusterCatalog(p *Plan) (*ansible.ClusterCatalog
 
 	cc.EnableGluster = p.Storage.Nodes != nil && len(p.Storage.Nodes) > 0
 
+	// The plan file declares the bindings of its users
+	cc.UserBindings = userBindings(p.Users)
+	cc.ReconcileUserBindings = true
+
 	cc.CloudProvider = p.Cluster.CloudProvider.Provider
 	cc.CloudConfig = p.Cluster.CloudProvider.Config
 
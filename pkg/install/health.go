@@ -0,0 +1,274 @@
+package install
+
+import (
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/apprenda/kismatic/pkg/data"
+	"github.com/apprenda/kismatic/pkg/tls"
+)
+
+const (
+	// HealthPass is the status of a check that succeeded
+	HealthPass = "pass"
+	// HealthWarn is the status of a check that found a condition that requires attention
+	HealthWarn = "warn"
+	// HealthFail is the status of a check that failed
+	HealthFail = "fail"
+
+	// certificates that expire within this period are reported as a warning
+	certExpiryWarning = 30 * 24 * time.Hour
+)
+
+// HealthCheck is the result of checking the health of a cluster component
+type HealthCheck struct {
+	Component string `json:"component"`
+	Check     string `json:"check"`
+	Status    string `json:"status"`
+	Message   string `json:"message,omitempty"`
+}
+
+// HealthReport is the result of checking the health of the cluster
+type HealthReport struct {
+	// Status is the worst status of all the checks
+	Status string        `json:"status"`
+	Checks []HealthCheck `json:"checks"`
+}
+
+func (r *HealthReport) add(component, check, status, message string, a ...interface{}) {
+	r.Checks = append(r.Checks, HealthCheck{
+		Component: component,
+		Check:     check,
+		Status:    status,
+		Message:   fmt.Sprintf(message, a...),
+	})
+	if healthSeverity(status) > healthSeverity(r.Status) {
+		r.Status = status
+	}
+}
+
+func healthSeverity(status string) int {
+	switch status {
+	case HealthWarn:
+		return 1
+	case HealthFail:
+		return 2
+	}
+	return 0
+}
+
+type healthKubeClient interface {
+	data.NodeLister
+	data.DeploymentLister
+	data.EndpointsGetter
+	data.APIServerHealthGetter
+}
+
+// HealthClients are the clients used for checking the health of the cluster
+type HealthClients struct {
+	Kube healthKubeClient
+	// Etcd are the clients of the members of the Kubernetes etcd cluster, keyed by host
+	Etcd map[string]data.EtcdMemberStatusGetter
+}
+
+// CheckClusterHealth checks the health of the etcd cluster, the control plane, the nodes,
+// the add-ons and the certificates in the certificates directory
+func CheckClusterHealth(plan Plan, clients HealthClients, certsDir string, now time.Time) HealthReport {
+	report := HealthReport{Status: HealthPass, Checks: []HealthCheck{}}
+	checkEtcdHealth(plan, clients.Etcd, &report)
+	checkAPIServerHealth(plan, clients.Kube, &report)
+	for _, component := range []string{"kube-controller-manager", "kube-scheduler"} {
+		checkLeaderElection(component, clients.Kube, now, &report)
+	}
+	checkNodeHealth(plan, clients.Kube, &report)
+	checkAddOnHealth(clients.Kube, &report)
+	checkCertificateExpiry(certsDir, now, &report)
+	return report
+}
+
+func checkEtcdHealth(plan Plan, clients map[string]data.EtcdMemberStatusGetter, report *HealthReport) {
+	leaders := []string{}
+	for _, n := range plan.Etcd.Nodes {
+		check := fmt.Sprintf("member %s", n.Host)
+		client, ok := clients[n.Host]
+		if !ok {
+			report.add("etcd", check, HealthFail, "no client for the member")
+			continue
+		}
+		status, err := client.GetEtcdMemberStatus()
+		if err != nil {
+			report.add("etcd", check, HealthFail, "%v", err)
+			continue
+		}
+		if !status.Healthy {
+			report.add("etcd", check, HealthFail, "member is unhealthy")
+			continue
+		}
+		if status.Stats.IsLeader() {
+			leaders = append(leaders, n.Host)
+		}
+		report.add("etcd", check, HealthPass, "member is healthy")
+	}
+	switch len(leaders) {
+	case 0:
+		report.add("etcd", "leader", HealthFail, "no member reported being the leader")
+	case 1:
+		report.add("etcd", "leader", HealthPass, "%s is the leader", leaders[0])
+	default:
+		report.add("etcd", "leader", HealthFail, "more than one member reported being the leader: %s", strings.Join(leaders, ", "))
+	}
+}
+
+func checkAPIServerHealth(plan Plan, client data.APIServerHealthGetter, report *HealthReport) {
+	check := func(name, server string) {
+		health, err := client.GetAPIServerHealth(server)
+		if err != nil {
+			report.add("kube-apiserver", name, HealthFail, "%v", err)
+			return
+		}
+		if health != "ok" {
+			report.add("kube-apiserver", name, HealthFail, "%s is not ready: %s", server, health)
+			return
+		}
+		report.add("kube-apiserver", name, HealthPass, "%s is ready", server)
+	}
+	for _, n := range plan.Master.Nodes {
+		ip := n.IP
+		if n.InternalIP != "" {
+			ip = n.InternalIP
+		}
+		check(fmt.Sprintf("master %s", n.Host), fmt.Sprintf("https://%s:6443", ip))
+	}
+	check("load balancer", fmt.Sprintf("https://%s:6443", plan.Master.LoadBalancedFQDN))
+}
+
+// checkLeaderElection verifies that the component has a leader, and that the
+// leader has renewed its lease
+func checkLeaderElection(component string, client data.EndpointsGetter, now time.Time, report *HealthReport) {
+	endpoints, err := client.GetEndpoints("kube-system", component)
+	if err != nil {
+		report.add(component, "leader", HealthFail, "%v", err)
+		return
+	}
+	record, err := endpoints.LeaderElectionRecord()
+	if err != nil {
+		report.add(component, "leader", HealthFail, "%v", err)
+		return
+	}
+	if record == nil || record.HolderIdentity == "" {
+		report.add(component, "leader", HealthFail, "no leader was elected")
+		return
+	}
+	lease := time.Duration(record.LeaseDurationSeconds) * time.Second
+	if now.Sub(record.RenewTime) > 2*lease {
+		report.add(component, "leader", HealthWarn, "%s has not renewed its lease since %s", record.HolderIdentity, record.RenewTime.Format(time.RFC3339))
+		return
+	}
+	report.add(component, "leader", HealthPass, "%s is the leader", record.HolderIdentity)
+}
+
+func checkNodeHealth(plan Plan, client data.NodeLister, report *HealthReport) {
+	nodes, err := client.ListNodes()
+	if err != nil {
+		report.add("nodes", "ready", HealthFail, "%v", err)
+		return
+	}
+	registered := map[string]data.Node{}
+	for _, n := range nodes.Items {
+		registered[strings.ToLower(n.Name)] = n
+	}
+	for _, n := range plan.GetUniqueNodes() {
+		roles := plan.GetRolesForIP(n.IP)
+		if len(roles) == 1 && roles[0] == "etcd" {
+			continue
+		}
+		check := fmt.Sprintf("node %s", n.Host)
+		node, ok := registered[strings.ToLower(n.Host)]
+		if !ok {
+			report.add("nodes", check, HealthFail, "node is not registered with the API server")
+			continue
+		}
+		if !node.Ready() {
+			report.add("nodes", check, HealthFail, "node is not Ready")
+			continue
+		}
+		if node.Spec.Unschedulable && contains("worker", roles) {
+			report.add("nodes", check, HealthWarn, "node is Ready, but unschedulable")
+			continue
+		}
+		report.add("nodes", check, HealthPass, "node is Ready")
+	}
+}
+
+func checkAddOnHealth(client data.DeploymentLister, report *HealthReport) {
+	deployments, err := client.ListDeployments("kube-system")
+	if err != nil {
+		report.add("add-ons", "deployments", HealthFail, "%v", err)
+		return
+	}
+	for _, d := range deployments.Items {
+		desired := d.Status.Replicas
+		if d.Spec.Replicas != nil {
+			desired = *d.Spec.Replicas
+		}
+		available := d.Status.AvailableReplicas
+		switch {
+		case desired > 0 && available == 0:
+			report.add("add-ons", d.Name, HealthFail, "0/%d replicas are available", desired)
+		case available < desired:
+			report.add("add-ons", d.Name, HealthWarn, "%d/%d replicas are available", available, desired)
+		default:
+			report.add("add-ons", d.Name, HealthPass, "%d/%d replicas are available", available, desired)
+		}
+	}
+}
+
+func checkCertificateExpiry(certsDir string, now time.Time, report *HealthReport) {
+	names, err := certificateNames(certsDir)
+	if err != nil {
+		report.add("certificates", "expiry", HealthFail, "error reading certificates directory: %v", err)
+		return
+	}
+	for _, name := range names {
+		cert, err := tls.ReadCert(name, certsDir)
+		if err != nil {
+			report.add("certificates", name, HealthFail, "%v", err)
+			continue
+		}
+		status, message := certificateExpiry(cert, now)
+		report.add("certificates", name, status, "%s", message)
+	}
+}
+
+func certificateExpiry(cert *x509.Certificate, now time.Time) (string, string) {
+	expiry := cert.NotAfter.Format("2006-01-02")
+	switch {
+	case now.After(cert.NotAfter):
+		return HealthFail, fmt.Sprintf("expired on %s", expiry)
+	case cert.NotAfter.Sub(now) < certExpiryWarning:
+		return HealthWarn, fmt.Sprintf("expires on %s", expiry)
+	}
+	return HealthPass, fmt.Sprintf("expires on %s", expiry)
+}
+
+// certificateNames returns the names of the certificates in the directory, without their keys
+func certificateNames(certsDir string) ([]string, error) {
+	files, err := ioutil.ReadDir(certsDir)
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for _, f := range files {
+		if f.IsDir() || filepath.Ext(f.Name()) != ".pem" || strings.HasSuffix(f.Name(), "-key.pem") {
+			continue
+		}
+		names = append(names, strings.TrimSuffix(f.Name(), ".pem"))
+	}
+	sort.Strings(names)
+	return names, nil
+}
@@ -0,0 +1,273 @@
+package install
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/apprenda/kismatic/pkg/data"
+)
+
+type fakeHealthKubeClient struct {
+	nodes       *data.NodeList
+	deployments *data.DeploymentList
+	endpoints   map[string]*data.Endpoints
+	apiServers  map[string]string
+}
+
+func (f fakeHealthKubeClient) ListNodes() (*data.NodeList, error) {
+	return f.nodes, nil
+}
+
+func (f fakeHealthKubeClient) ListDeployments(namespace string) (*data.DeploymentList, error) {
+	return f.deployments, nil
+}
+
+func (f fakeHealthKubeClient) GetEndpoints(namespace, name string) (*data.Endpoints, error) {
+	e, ok := f.endpoints[name]
+	if !ok {
+		return nil, fmt.Errorf("endpoints %q not found", name)
+	}
+	return e, nil
+}
+
+func (f fakeHealthKubeClient) GetAPIServerHealth(server string) (string, error) {
+	h, ok := f.apiServers[server]
+	if !ok {
+		return "", fmt.Errorf("error connecting to %s", server)
+	}
+	return h, nil
+}
+
+type fakeEtcdClient struct {
+	status *data.EtcdMemberStatus
+	err    error
+}
+
+func (f fakeEtcdClient) GetEtcdMemberStatus() (*data.EtcdMemberStatus, error) {
+	return f.status, f.err
+}
+
+func etcdMember(healthy bool, state string) fakeEtcdClient {
+	return fakeEtcdClient{status: &data.EtcdMemberStatus{Healthy: healthy, Stats: data.EtcdSelfStats{State: state}}}
+}
+
+func readyNode(name string) data.Node {
+	n := data.Node{Status: data.NodeStatus{Conditions: []data.NodeCondition{{Type: "Ready", Status: "True"}}}}
+	n.Name = name
+	return n
+}
+
+func leaderEndpoints(holder string, renew time.Time) *data.Endpoints {
+	e := &data.Endpoints{}
+	e.Annotations = map[string]string{
+		"control-plane.alpha.kubernetes.io/leader": fmt.Sprintf(`{"holderIdentity":%q,"leaseDurationSeconds":15,"renewTime":%q}`, holder, renew.Format(time.RFC3339)),
+	}
+	return e
+}
+
+func deployment(name string, desired, available int32) data.Deployment {
+	d := data.Deployment{}
+	d.Name = name
+	d.Spec.Replicas = &desired
+	d.Status.Replicas = desired
+	d.Status.AvailableReplicas = available
+	return d
+}
+
+func healthPlan() Plan {
+	plan := Plan{}
+	plan.Etcd.Nodes = []Node{{Host: "etcd01", IP: "10.0.0.1"}, {Host: "etcd02", IP: "10.0.0.2"}}
+	plan.Master.Nodes = []Node{{Host: "master01", IP: "10.0.0.3", InternalIP: "192.168.0.3"}}
+	plan.Master.LoadBalancedFQDN = "lb.example.com"
+	plan.Worker.Nodes = []Node{{Host: "Worker01", IP: "10.0.0.4"}}
+	return plan
+}
+
+func healthyClients(now time.Time) HealthClients {
+	return HealthClients{
+		Kube: fakeHealthKubeClient{
+			nodes: &data.NodeList{Items: []data.Node{readyNode("master01"), readyNode("worker01")}},
+			deployments: &data.DeploymentList{Items: []data.Deployment{
+				deployment("kube-dns", 2, 2),
+			}},
+			endpoints: map[string]*data.Endpoints{
+				"kube-controller-manager": leaderEndpoints("master01", now),
+				"kube-scheduler":          leaderEndpoints("master01", now),
+			},
+			apiServers: map[string]string{
+				"https://192.168.0.3:6443":    "ok",
+				"https://lb.example.com:6443": "ok",
+			},
+		},
+		Etcd: map[string]data.EtcdMemberStatusGetter{
+			"etcd01": etcdMember(true, "StateLeader"),
+			"etcd02": etcdMember(true, "StateFollower"),
+		},
+	}
+}
+
+func healthCertsDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "health-test")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	return dir
+}
+
+func checkStatus(report HealthReport, component, check string) string {
+	for _, c := range report.Checks {
+		if c.Component == component && c.Check == check {
+			return c.Status
+		}
+	}
+	return ""
+}
+
+func TestCheckClusterHealthPasses(t *testing.T) {
+	now := time.Now()
+	dir := healthCertsDir(t)
+	defer os.RemoveAll(dir)
+
+	report := CheckClusterHealth(healthPlan(), healthyClients(now), dir, now)
+	if report.Status != HealthPass {
+		t.Errorf("expected the cluster to be healthy, got %q: %+v", report.Status, report.Checks)
+	}
+	// 2 etcd members + leader, 2 API servers, 2 leaders, 2 nodes, 1 add-on
+	if len(report.Checks) != 10 {
+		t.Errorf("expected 10 checks, got %d: %+v", len(report.Checks), report.Checks)
+	}
+}
+
+func TestCheckClusterHealthFailures(t *testing.T) {
+	now := time.Now()
+	dir := healthCertsDir(t)
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name      string
+		mutate    func(c *HealthClients)
+		component string
+		check     string
+		status    string
+	}{
+		{
+			name:      "etcd member is down",
+			mutate:    func(c *HealthClients) { c.Etcd["etcd02"] = fakeEtcdClient{err: errors.New("connection refused")} },
+			component: "etcd",
+			check:     "member etcd02",
+			status:    HealthFail,
+		},
+		{
+			name:      "etcd has no leader",
+			mutate:    func(c *HealthClients) { c.Etcd["etcd01"] = etcdMember(true, "StateFollower") },
+			component: "etcd",
+			check:     "leader",
+			status:    HealthFail,
+		},
+		{
+			name: "load balancer is not reachable",
+			mutate: func(c *HealthClients) {
+				delete(c.Kube.(fakeHealthKubeClient).apiServers, "https://lb.example.com:6443")
+			},
+			component: "kube-apiserver",
+			check:     "load balancer",
+			status:    HealthFail,
+		},
+		{
+			name: "scheduler lease is stale",
+			mutate: func(c *HealthClients) {
+				c.Kube.(fakeHealthKubeClient).endpoints["kube-scheduler"] = leaderEndpoints("master01", now.Add(-time.Minute))
+			},
+			component: "kube-scheduler",
+			check:     "leader",
+			status:    HealthWarn,
+		},
+		{
+			name: "controller manager has no leader",
+			mutate: func(c *HealthClients) {
+				c.Kube.(fakeHealthKubeClient).endpoints["kube-controller-manager"] = &data.Endpoints{}
+			},
+			component: "kube-controller-manager",
+			check:     "leader",
+			status:    HealthFail,
+		},
+		{
+			name: "worker is not registered",
+			mutate: func(c *HealthClients) {
+				c.Kube.(fakeHealthKubeClient).nodes.Items = []data.Node{readyNode("master01")}
+			},
+			component: "nodes",
+			check:     "node Worker01",
+			status:    HealthFail,
+		},
+		{
+			name: "worker is cordoned",
+			mutate: func(c *HealthClients) {
+				c.Kube.(fakeHealthKubeClient).nodes.Items[1].Spec.Unschedulable = true
+			},
+			component: "nodes",
+			check:     "node Worker01",
+			status:    HealthWarn,
+		},
+		{
+			name: "add-on is partially available",
+			mutate: func(c *HealthClients) {
+				c.Kube.(fakeHealthKubeClient).deployments.Items[0] = deployment("kube-dns", 2, 1)
+			},
+			component: "add-ons",
+			check:     "kube-dns",
+			status:    HealthWarn,
+		},
+		{
+			name: "add-on is not available",
+			mutate: func(c *HealthClients) {
+				c.Kube.(fakeHealthKubeClient).deployments.Items[0] = deployment("kube-dns", 2, 0)
+			},
+			component: "add-ons",
+			check:     "kube-dns",
+			status:    HealthFail,
+		},
+	}
+	for _, test := range tests {
+		clients := healthyClients(now)
+		test.mutate(&clients)
+		report := CheckClusterHealth(healthPlan(), clients, dir, now)
+		if s := checkStatus(report, test.component, test.check); s != test.status {
+			t.Errorf("%s: expected %s %q to be %q, got %q", test.name, test.component, test.check, test.status, s)
+		}
+		if report.Status != test.status {
+			t.Errorf("%s: expected the cluster status to be %q, got %q", test.name, test.status, report.Status)
+		}
+	}
+}
+
+func TestCheckClusterHealthMissingCertificates(t *testing.T) {
+	now := time.Now()
+	report := CheckClusterHealth(healthPlan(), healthyClients(now), "/non-existent/keys", now)
+	if s := checkStatus(report, "certificates", "expiry"); s != HealthFail {
+		t.Errorf("expected the certificates check to fail, got %q", s)
+	}
+}
+
+func TestCertificateExpiry(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		notAfter time.Time
+		status   string
+	}{
+		{notAfter: now.Add(-time.Hour), status: HealthFail},
+		{notAfter: now.Add(24 * time.Hour), status: HealthWarn},
+		{notAfter: now.Add(365 * 24 * time.Hour), status: HealthPass},
+	}
+	for i, test := range tests {
+		status, _ := certificateExpiry(&x509.Certificate{NotAfter: test.notAfter}, now)
+		if status != test.status {
+			t.Errorf("test %d: expected %q, got %q", i, test.status, status)
+		}
+	}
+}
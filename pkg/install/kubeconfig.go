@@ -51,7 +51,32 @@ users:
 
 // GenerateKubeconfig generate a kubeconfig file for a specific user
 func GenerateKubeconfig(p *Plan, generatedAssetsDir string) error {
-	user := "admin"
+	return writeClientKubeconfig(p, generatedAssetsDir, adminUser, filepath.Join(generatedAssetsDir, kubeconfigFilename))
+}
+
+// GenerateUserKubeconfig generates a kubeconfig file for the user, that authenticates
+// with the client certificate of the user. Returns the path of the kubeconfig file.
+func GenerateUserKubeconfig(p *Plan, generatedAssetsDir string, user string) (string, error) {
+	file := UserKubeconfigFile(generatedAssetsDir, user)
+	return file, writeClientKubeconfig(p, generatedAssetsDir, user, file)
+}
+
+// GenerateUserKubeconfigs generates a kubeconfig file for each user of the plan file
+func GenerateUserKubeconfigs(p *Plan, generatedAssetsDir string) error {
+	for _, u := range p.Users {
+		if _, err := GenerateUserKubeconfig(p, generatedAssetsDir, u.Name); err != nil {
+			return fmt.Errorf("error generating kubeconfig file for user %q: %v", u.Name, err)
+		}
+	}
+	return nil
+}
+
+// UserKubeconfigFile returns the path of the kubeconfig file of the user
+func UserKubeconfigFile(generatedAssetsDir string, user string) string {
+	return filepath.Join(generatedAssetsDir, user+"-"+kubeconfigFilename)
+}
+
+func writeClientKubeconfig(p *Plan, generatedAssetsDir string, user string, file string) error {
 	server := "https://" + p.Master.LoadBalancedFQDN + ":6443"
 	cluster := p.Cluster.Name
 	context := p.Cluster.Name + "-" + user
@@ -59,7 +84,7 @@ func GenerateKubeconfig(p *Plan, generatedAssetsDir string) error {
 	certsDir := filepath.Join(generatedAssetsDir, "keys")
 
 	// Base64 encoded ca
-	caEncoded, err := util.Base64String(filepath.Join(certsDir, "ca.pem"))
+	caEncoded, err := util.Base64String(filepath.Join(certsDir, deployedCAFile(certsDir)))
 	if err != nil {
 		return fmt.Errorf("error reading ca file for kubeconfig: %v", err)
 	}
@@ -76,7 +101,7 @@ func GenerateKubeconfig(p *Plan, generatedAssetsDir string) error {
 
 	configOptions := ConfigOptions{caEncoded, server, cluster, user, context, certEncoded, keyEncoded, ""}
 
-	return writeTemplate(configOptions, filepath.Join(generatedAssetsDir, kubeconfigFilename))
+	return writeTemplate(configOptions, file)
 }
 
 func GenerateDashboardAdminKubeconfig(base64token string, p *Plan, generatedAssetsDir string) error {
@@ -88,7 +113,7 @@ func GenerateDashboardAdminKubeconfig(base64token string, p *Plan, generatedAsse
 	certsDir := filepath.Join(generatedAssetsDir, "keys")
 
 	// Base64 encoded ca
-	caEncoded, err := util.Base64String(filepath.Join(certsDir, "ca.pem"))
+	caEncoded, err := util.Base64String(filepath.Join(certsDir, deployedCAFile(certsDir)))
 	if err != nil {
 		return fmt.Errorf("error reading ca file for kubeconfig: %v", err)
 	}
@@ -0,0 +1,226 @@
+package install
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// The entries of the cluster in a kubeconfig file that is shared with other clusters
+type kubeconfigEntries struct {
+	cluster string
+	user    string
+	context string
+}
+
+// clusterKubeconfigEntries returns the entries of the cluster, which are all named after the cluster
+func clusterKubeconfigEntries(p *Plan) kubeconfigEntries {
+	return kubeconfigEntries{
+		cluster: p.Cluster.Name,
+		user:    p.Cluster.Name,
+		context: p.Cluster.Name,
+	}
+}
+
+// DefaultKubeconfigTarget returns the kubeconfig file used by kubectl, which is the first
+// file of the KUBECONFIG environment variable, or ~/.kube/config
+func DefaultKubeconfigTarget() (string, error) {
+	if env := os.Getenv("KUBECONFIG"); env != "" {
+		for _, f := range filepath.SplitList(env) {
+			if f != "" {
+				return f, nil
+			}
+		}
+	}
+	home := os.Getenv("HOME")
+	if home == "" {
+		return "", fmt.Errorf("the HOME environment variable is not set")
+	}
+	return filepath.Join(home, ".kube", "config"), nil
+}
+
+// MergeKubeconfig inserts or updates the cluster, user and context of the cluster in the
+// target kubeconfig file, using the generated kubeconfig file. The other entries of the
+// target are left untouched. The context of the cluster becomes the current context when
+// setCurrent is true. The previous target is backed up with the ".bak" extension.
+func MergeKubeconfig(p *Plan, generatedAssetsDir string, target string, setCurrent bool) error {
+	generated, err := readKubeconfig(filepath.Join(generatedAssetsDir, kubeconfigFilename))
+	if err != nil {
+		return err
+	}
+	if generated == nil {
+		return fmt.Errorf("the kubeconfig file was not found in %q", generatedAssetsDir)
+	}
+	cluster, err := firstEntry(generated, "clusters", "cluster")
+	if err != nil {
+		return err
+	}
+	user, err := firstEntry(generated, "users", "user")
+	if err != nil {
+		return err
+	}
+
+	config, err := readKubeconfig(target)
+	if err != nil {
+		return err
+	}
+	if config == nil {
+		config = emptyKubeconfig()
+	}
+	e := clusterKubeconfigEntries(p)
+	config = setNamedEntry(config, "clusters", e.cluster, "cluster", cluster)
+	config = setNamedEntry(config, "users", e.user, "user", user)
+	config = setNamedEntry(config, "contexts", e.context, "context", yaml.MapSlice{
+		{Key: "cluster", Value: e.cluster},
+		{Key: "user", Value: e.user},
+	})
+	if current := mapValue(config, "current-context"); setCurrent || current == nil || current == "" {
+		config = setMapValue(config, "current-context", e.context)
+	}
+	return writeKubeconfig(config, target)
+}
+
+// RemoveKubeconfig removes the cluster, user and context of the cluster from the target
+// kubeconfig file. Returns false if the target does not have any of the entries. The
+// previous target is backed up with the ".bak" extension.
+func RemoveKubeconfig(p *Plan, target string) (bool, error) {
+	config, err := readKubeconfig(target)
+	if err != nil {
+		return false, err
+	}
+	if config == nil {
+		return false, nil
+	}
+	e := clusterKubeconfigEntries(p)
+	removed := false
+	for _, n := range []struct{ list, name string }{{"clusters", e.cluster}, {"users", e.user}, {"contexts", e.context}} {
+		var ok bool
+		config, ok = removeNamedEntry(config, n.list, n.name)
+		removed = removed || ok
+	}
+	if !removed {
+		return false, nil
+	}
+	if mapValue(config, "current-context") == e.context {
+		config = setMapValue(config, "current-context", "")
+	}
+	return true, writeKubeconfig(config, target)
+}
+
+// readKubeconfig reads the kubeconfig file, keeping the order and the fields of its entries.
+// Returns nil if the file does not exist.
+func readKubeconfig(file string) (yaml.MapSlice, error) {
+	b, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading kubeconfig file %q: %v", file, err)
+	}
+	config := yaml.MapSlice{}
+	if err = yaml.Unmarshal(b, &config); err != nil {
+		return nil, fmt.Errorf("error parsing kubeconfig file %q: %v", file, err)
+	}
+	if len(config) == 0 {
+		return emptyKubeconfig(), nil
+	}
+	return config, nil
+}
+
+func writeKubeconfig(config yaml.MapSlice, file string) error {
+	b, err := yaml.Marshal(config)
+	if err != nil {
+		return fmt.Errorf("error marshalling kubeconfig: %v", err)
+	}
+	if err = os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return fmt.Errorf("error creating directory for kubeconfig file: %v", err)
+	}
+	if prev, err := ioutil.ReadFile(file); err == nil {
+		if err = ioutil.WriteFile(file+".bak", prev, 0600); err != nil {
+			return fmt.Errorf("error backing up existing kubeconfig file: %v", err)
+		}
+	}
+	if err = ioutil.WriteFile(file, b, 0600); err != nil {
+		return fmt.Errorf("error writing kubeconfig file: %v", err)
+	}
+	return nil
+}
+
+func emptyKubeconfig() yaml.MapSlice {
+	return yaml.MapSlice{
+		{Key: "apiVersion", Value: "v1"},
+		{Key: "kind", Value: "Config"},
+		{Key: "preferences", Value: yaml.MapSlice{}},
+		{Key: "clusters", Value: []interface{}{}},
+		{Key: "users", Value: []interface{}{}},
+		{Key: "contexts", Value: []interface{}{}},
+		{Key: "current-context", Value: ""},
+	}
+}
+
+// firstEntry returns the field of the first entry of the list
+func firstEntry(config yaml.MapSlice, list string, field string) (interface{}, error) {
+	entries, _ := mapValue(config, list).([]interface{})
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("the generated kubeconfig file does not have any %s", list)
+	}
+	entry, ok := entries[0].(yaml.MapSlice)
+	if !ok || mapValue(entry, field) == nil {
+		return nil, fmt.Errorf("the generated kubeconfig file has an invalid entry in %s", list)
+	}
+	return mapValue(entry, field), nil
+}
+
+// setNamedEntry inserts or updates the entry with the name in the list
+func setNamedEntry(config yaml.MapSlice, list string, name string, field string, value interface{}) yaml.MapSlice {
+	entries, _ := mapValue(config, list).([]interface{})
+	updated := false
+	for i, e := range entries {
+		if m, ok := e.(yaml.MapSlice); ok && mapValue(m, "name") == name {
+			entries[i] = setMapValue(m, field, value)
+			updated = true
+		}
+	}
+	if !updated {
+		entries = append(entries, yaml.MapSlice{{Key: "name", Value: name}, {Key: field, Value: value}})
+	}
+	return setMapValue(config, list, entries)
+}
+
+// removeNamedEntry removes the entries with the name from the list. Returns true if any entry was removed.
+func removeNamedEntry(config yaml.MapSlice, list string, name string) (yaml.MapSlice, bool) {
+	entries, _ := mapValue(config, list).([]interface{})
+	kept := []interface{}{}
+	for _, e := range entries {
+		if m, ok := e.(yaml.MapSlice); ok && mapValue(m, "name") == name {
+			continue
+		}
+		kept = append(kept, e)
+	}
+	if len(kept) == len(entries) {
+		return config, false
+	}
+	return setMapValue(config, list, kept), true
+}
+
+func mapValue(m yaml.MapSlice, key string) interface{} {
+	for _, item := range m {
+		if k, ok := item.Key.(string); ok && k == key {
+			return item.Value
+		}
+	}
+	return nil
+}
+
+func setMapValue(m yaml.MapSlice, key string, value interface{}) yaml.MapSlice {
+	for i, item := range m {
+		if k, ok := item.Key.(string); ok && k == key {
+			m[i].Value = value
+			return m
+		}
+	}
+	return append(m, yaml.MapItem{Key: key, Value: value})
+}
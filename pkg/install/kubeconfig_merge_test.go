@@ -0,0 +1,161 @@
+package install
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+const existingKubeconfig = `apiVersion: v1
+kind: Config
+clusters:
+- cluster:
+    server: https://other:6443
+  name: other
+users:
+- name: other-admin
+  user:
+    token: secret
+contexts:
+- context:
+    cluster: other
+    user: other-admin
+  name: other-admin
+current-context: other-admin
+`
+
+type testKubeconfig struct {
+	Clusters []struct {
+		Name    string
+		Cluster struct {
+			Server string
+		}
+	}
+	Users []struct {
+		Name string
+		User map[string]interface{}
+	}
+	Contexts []struct {
+		Name    string
+		Context struct {
+			Cluster string
+			User    string
+		}
+	}
+	CurrentContext string `yaml:"current-context"`
+}
+
+func readTestKubeconfig(t *testing.T, file string) testKubeconfig {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatalf("error reading kubeconfig: %v", err)
+	}
+	config := testKubeconfig{}
+	if err = yaml.Unmarshal(b, &config); err != nil {
+		t.Fatalf("error parsing kubeconfig: %v", err)
+	}
+	return config
+}
+
+func TestMergeKubeconfig(t *testing.T) {
+	path := createTempDirForRegenerateKubeconfigTests(t)
+	defer os.RemoveAll(path)
+	p := &Plan{}
+	p.Cluster.Name = "test"
+	p.Master.LoadBalancedFQDN = "test"
+	if err := GenerateKubeconfig(p, path); err != nil {
+		t.Fatalf("error generating kubeconfig: %v", err)
+	}
+	target := filepath.Join(path, "home", ".kube", "config")
+	if err := os.MkdirAll(filepath.Dir(target), 0700); err != nil {
+		t.Fatalf("error creating dir: %v", err)
+	}
+	if err := ioutil.WriteFile(target, []byte(existingKubeconfig), 0600); err != nil {
+		t.Fatalf("error writing kubeconfig: %v", err)
+	}
+
+	if err := MergeKubeconfig(p, path, target, false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	config := readTestKubeconfig(t, target)
+	if len(config.Clusters) != 2 || len(config.Users) != 2 || len(config.Contexts) != 2 {
+		t.Fatalf("expected two clusters, users and contexts, got %+v", config)
+	}
+	if config.Clusters[0].Name != "other" || config.Users[0].User["token"] != "secret" {
+		t.Errorf("the existing entries were changed: %+v", config)
+	}
+	if config.Clusters[1].Name != "test" || config.Clusters[1].Cluster.Server != "https://test:6443" {
+		t.Errorf("unexpected cluster %+v", config.Clusters[1])
+	}
+	if config.Users[1].Name != "test" || config.Users[1].User["client-certificate-data"] == nil {
+		t.Errorf("unexpected user %+v", config.Users[1])
+	}
+	if c := config.Contexts[1]; c.Name != "test" || c.Context.Cluster != "test" || c.Context.User != "test" {
+		t.Errorf("unexpected context %+v", c)
+	}
+	if config.CurrentContext != "other-admin" {
+		t.Errorf("expected the current context to be unchanged, got %q", config.CurrentContext)
+	}
+	if _, err := os.Stat(target + ".bak"); err != nil {
+		t.Errorf("expected the previous kubeconfig to be backed up: %v", err)
+	}
+
+	// Merging again updates the entries of the cluster
+	p.Master.LoadBalancedFQDN = "new"
+	if err := GenerateKubeconfig(p, path); err != nil {
+		t.Fatalf("error generating kubeconfig: %v", err)
+	}
+	if err := MergeKubeconfig(p, path, target, true); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	config = readTestKubeconfig(t, target)
+	if len(config.Clusters) != 2 || config.Clusters[1].Cluster.Server != "https://new:6443" {
+		t.Errorf("expected the cluster to be updated, got %+v", config.Clusters)
+	}
+	if config.CurrentContext != "test" {
+		t.Errorf("expected the current context to be set, got %q", config.CurrentContext)
+	}
+
+	removed, err := RemoveKubeconfig(p, target)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !removed {
+		t.Errorf("expected the entries of the cluster to be removed")
+	}
+	config = readTestKubeconfig(t, target)
+	if len(config.Clusters) != 1 || len(config.Users) != 1 || len(config.Contexts) != 1 || config.Clusters[0].Name != "other" {
+		t.Errorf("expected only the existing entries, got %+v", config)
+	}
+	if config.CurrentContext != "" {
+		t.Errorf("expected the current context to be cleared, got %q", config.CurrentContext)
+	}
+	if removed, err = RemoveKubeconfig(p, target); err != nil || removed {
+		t.Errorf("expected nothing to be removed, got %v, %v", removed, err)
+	}
+}
+
+func TestMergeKubeconfigNewTarget(t *testing.T) {
+	path := createTempDirForRegenerateKubeconfigTests(t)
+	defer os.RemoveAll(path)
+	p := &Plan{}
+	p.Cluster.Name = "test"
+	p.Master.LoadBalancedFQDN = "test"
+	if err := GenerateKubeconfig(p, path); err != nil {
+		t.Fatalf("error generating kubeconfig: %v", err)
+	}
+	target := filepath.Join(path, "home", ".kube", "config")
+	if err := MergeKubeconfig(p, path, target, false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	config := readTestKubeconfig(t, target)
+	if len(config.Clusters) != 1 || len(config.Users) != 1 || len(config.Contexts) != 1 {
+		t.Fatalf("expected the entries of the cluster, got %+v", config)
+	}
+	if config.CurrentContext != "test" {
+		t.Errorf("expected the context of the cluster to be the current context, got %q", config.CurrentContext)
+	}
+}
@@ -0,0 +1,139 @@
+package install
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/apprenda/kismatic/pkg/data"
+	yaml "gopkg.in/yaml.v2"
+)
+
+const (
+	// maintenanceStateFile is the file in the generated assets directory that keeps
+	// the record of the nodes that are in maintenance
+	maintenanceStateFile = "maintenance.yaml"
+
+	// MaintenanceCordoned is the state of a node that is not accepting new workloads
+	MaintenanceCordoned = "cordoned"
+	// MaintenanceDrained is the state of a node that has been drained of its workloads
+	MaintenanceDrained = "drained"
+
+	// mirrorPodAnnotation is set on the pods that mirror the static pods of a node
+	mirrorPodAnnotation = "kubernetes.io/config.mirror"
+)
+
+// NodeMaintenance is the record of a node that was intentionally taken out of rotation
+type NodeMaintenance struct {
+	Node   string    `yaml:"node"`
+	State  string    `yaml:"state"`
+	Reason string    `yaml:"reason,omitempty"`
+	Since  time.Time `yaml:"since"`
+}
+
+// MaintenanceStateFile returns the path of the file that keeps the maintenance
+// state of the nodes
+func MaintenanceStateFile(generatedAssetsDir string) string {
+	return filepath.Join(generatedAssetsDir, maintenanceStateFile)
+}
+
+// ReadMaintenanceState returns the nodes in maintenance, keyed by host
+func ReadMaintenanceState(file string) (map[string]NodeMaintenance, error) {
+	state := map[string]NodeMaintenance{}
+	b, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading maintenance state %q: %v", file, err)
+	}
+	nodes := []NodeMaintenance{}
+	if err = yaml.Unmarshal(b, &nodes); err != nil {
+		return nil, fmt.Errorf("error unmarshalling maintenance state from %q: %v", file, err)
+	}
+	for _, n := range nodes {
+		state[n.Node] = n
+	}
+	return state, nil
+}
+
+// RecordMaintenance records that the node is in maintenance, replacing any
+// previous record of the node
+func RecordMaintenance(file string, m NodeMaintenance) error {
+	state, err := ReadMaintenanceState(file)
+	if err != nil {
+		return err
+	}
+	state[m.Node] = m
+	return writeMaintenanceState(file, state)
+}
+
+// ClearMaintenance removes the record of the nodes
+func ClearMaintenance(file string, hosts ...string) error {
+	state, err := ReadMaintenanceState(file)
+	if err != nil {
+		return err
+	}
+	changed := false
+	for _, h := range hosts {
+		if _, ok := state[h]; ok {
+			delete(state, h)
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	return writeMaintenanceState(file, state)
+}
+
+func writeMaintenanceState(file string, state map[string]NodeMaintenance) error {
+	hosts := []string{}
+	for h := range state {
+		hosts = append(hosts, h)
+	}
+	sort.Strings(hosts)
+	nodes := []NodeMaintenance{}
+	for _, h := range hosts {
+		nodes = append(nodes, state[h])
+	}
+	b, err := yaml.Marshal(nodes)
+	if err != nil {
+		return fmt.Errorf("error marshalling maintenance state: %v", err)
+	}
+	if err = ioutil.WriteFile(file, b, 0644); err != nil {
+		return fmt.Errorf("error writing maintenance state to %q: %v", file, err)
+	}
+	return nil
+}
+
+// EvictablePods returns the pods that are still running on the node, and that are
+// evicted when the node is drained. Pods of daemon sets, static pods, and pods that
+// have completed are left on the node by a drain.
+func EvictablePods(pods *data.PodList, node string) []string {
+	evictable := []string{}
+	if pods == nil {
+		return evictable
+	}
+	for _, p := range pods.Items {
+		if !strings.EqualFold(p.Spec.NodeName, node) {
+			continue
+		}
+		if p.Status.Phase == "Succeeded" || p.Status.Phase == "Failed" {
+			continue
+		}
+		if _, ok := p.Annotations[mirrorPodAnnotation]; ok {
+			continue
+		}
+		if ref := p.ControllerRef(); ref != nil && ref.Kind == "DaemonSet" {
+			continue
+		}
+		evictable = append(evictable, p.Namespace+"/"+p.Name)
+	}
+	sort.Strings(evictable)
+	return evictable
+}
@@ -0,0 +1,88 @@
+package install
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/apprenda/kismatic/pkg/data"
+)
+
+func TestMaintenanceState(t *testing.T) {
+	dir, err := ioutil.TempDir("", "maintenance-state")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	file := MaintenanceStateFile(dir)
+
+	state, err := ReadMaintenanceState(file)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(state) != 0 {
+		t.Errorf("expected no nodes in maintenance, got %v", state)
+	}
+
+	since := time.Date(2018, 4, 1, 10, 0, 0, 0, time.UTC)
+	records := []NodeMaintenance{
+		{Node: "worker02", State: MaintenanceCordoned, Since: since},
+		{Node: "worker01", State: MaintenanceCordoned, Reason: "disk replacement", Since: since},
+		// the latest record of a node replaces the previous one
+		{Node: "worker02", State: MaintenanceDrained, Reason: "kernel update", Since: since},
+	}
+	for _, m := range records {
+		if err = RecordMaintenance(file, m); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	state, err = ReadMaintenanceState(file)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(state) != 2 {
+		t.Fatalf("expected 2 nodes in maintenance, got %v", state)
+	}
+	if state["worker02"].State != MaintenanceDrained || state["worker02"].Reason != "kernel update" {
+		t.Errorf("expected the latest record of the node, got %+v", state["worker02"])
+	}
+	if !state["worker01"].Since.Equal(since) {
+		t.Errorf("expected the time to be read back, got %v", state["worker01"].Since)
+	}
+
+	if err = ClearMaintenance(file, "worker02", "worker03"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	state, err = ReadMaintenanceState(file)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := state["worker02"]; ok || len(state) != 1 {
+		t.Errorf("expected only worker01 to be in maintenance, got %v", state)
+	}
+}
+
+func TestEvictablePods(t *testing.T) {
+	controller := true
+	pod := func(name, node string) data.Pod {
+		p := data.Pod{ObjectMeta: data.ObjectMeta{Name: name, Namespace: "default"}}
+		p.Spec.NodeName = node
+		p.Status.Phase = "Running"
+		return p
+	}
+	app := pod("app", "worker01")
+	other := pod("other", "worker02")
+	daemon := pod("daemon", "worker01")
+	daemon.OwnerReferences = []data.OwnerReference{{Kind: "DaemonSet", Name: "daemon", Controller: &controller}}
+	static := pod("static", "worker01")
+	static.Annotations = map[string]string{mirrorPodAnnotation: "hash"}
+	completed := pod("completed", "worker01")
+	completed.Status.Phase = "Succeeded"
+	pods := &data.PodList{Items: []data.Pod{app, other, daemon, static, completed}}
+
+	evictable := EvictablePods(pods, "Worker01")
+	if len(evictable) != 1 || evictable[0] != "default/app" {
+		t.Errorf("expected only default/app to be evictable, got %v", evictable)
+	}
+}
@@ -0,0 +1,253 @@
+package install
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/apprenda/kismatic/pkg/ssh"
+)
+
+// NodeFacts contains information about the operating system, the container
+// runtime and the capacity of a node
+type NodeFacts struct {
+	OS     OSFacts
+	Kernel string
+	Docker DockerFacts
+	// EtcdVersion is the version of the Kubernetes etcd member running on the node
+	EtcdVersion string `json:",omitempty"`
+	// CNIVersion is the image of the CNI plugin running on the node
+	CNIVersion    string `json:",omitempty"`
+	KubeletStatus string `json:",omitempty"`
+	CPUs          int
+	MemoryBytes   uint64
+	// DiskBytes and DiskAvailableBytes are the capacity of the root filesystem
+	DiskBytes          uint64
+	DiskAvailableBytes uint64
+	// Error is set when the facts could not be gathered from the node
+	Error string `json:",omitempty"`
+	// Differences are the facts that differ from the plan, or from the other nodes
+	Differences []FactDifference `json:",omitempty"`
+}
+
+// OSFacts contains the distribution of the operating system of a node
+type OSFacts struct {
+	Distro  string
+	Version string
+}
+
+func (o OSFacts) String() string {
+	return strings.TrimSpace(o.Distro + " " + o.Version)
+}
+
+// DockerFacts contains information about the docker engine of a node
+type DockerFacts struct {
+	Version       string
+	StorageDriver string
+}
+
+// FactDifference is a fact of a node that differs from the plan, or from the other nodes
+type FactDifference struct {
+	Fact     string
+	Value    string
+	Expected string
+	// Source is "plan" when the expected value comes from the plan file, or "peers"
+	// when it is the value of most of the other nodes
+	Source string
+}
+
+func (d FactDifference) String() string {
+	if d.Source == "plan" {
+		return fmt.Sprintf("%s is %q, but the plan file expects %q", d.Fact, d.Value, d.Expected)
+	}
+	return fmt.Sprintf("%s is %q, but most nodes have %q", d.Fact, d.Value, d.Expected)
+}
+
+// The facts that are compared between the nodes
+const (
+	FactOS                  = "os"
+	FactKernel              = "kernel"
+	FactDockerVersion       = "docker version"
+	FactDockerStorageDriver = "docker storage driver"
+	FactEtcdVersion         = "etcd version"
+	FactCNIVersion          = "cni version"
+	FactKubernetesVersion   = "kubernetes version"
+	FactKubeletStatus       = "kubelet status"
+)
+
+// nodeFactsScript prints the facts of the node as key=value lines
+const nodeFactsScript = `. /etc/os-release 2>/dev/null; echo "os_distro=$ID"; echo "os_version=$VERSION_ID"; ` +
+	`echo "kernel=$(uname -r)"; ` +
+	`echo "docker_version=$(sudo docker version --format '{{.Server.Version}}' 2>/dev/null)"; ` +
+	`echo "docker_storage_driver=$(sudo docker info --format '{{.Driver}}' 2>/dev/null)"; ` +
+	`echo "etcd_version=$(sudo docker exec etcd_k8s /usr/local/bin/etcd --version 2>/dev/null | awk '/etcd Version/ {print $3}')"; ` +
+	`echo "cni_version=$(sudo docker ps --format '{{.Image}}' 2>/dev/null | grep -E 'calico/cni|weaveworks/weave-kube|contiv/netplugin' | head -n 1)"; ` +
+	`echo "kubelet_status=$(systemctl is-active kubelet 2>/dev/null)"; ` +
+	`echo "cpus=$(nproc)"; ` +
+	`echo "memory_kb=$(awk '/MemTotal/ {print $2}' /proc/meminfo)"; ` +
+	`echo "disk_kb=$(df -Pk / | awk 'NR==2 {print $2 " " $4}')"`
+
+// GatherNodeFacts connects to the nodes in parallel and gathers their facts. Nodes
+// whose facts could not be gathered have the Error of their facts set. The facts
+// of the nodes are then compared to the plan, and to each other.
+func GatherNodeFacts(plan *Plan, nodes []ListableNode) {
+	sshDeets := plan.Cluster.SSH
+	var wg sync.WaitGroup
+	wg.Add(len(nodes))
+	for i := range nodes {
+		go func(n *ListableNode) {
+			defer wg.Done()
+			facts := &NodeFacts{}
+			n.Facts = facts
+			client, err := ssh.NewClient(n.Node.IP, sshDeets.Port, sshDeets.User, sshDeets.Key)
+			if err != nil {
+				facts.Error = fmt.Sprintf("error creating SSH client: %v", err)
+				return
+			}
+			out, err := client.Output(true, nodeFactsScript)
+			if err != nil {
+				facts.Error = fmt.Sprintf("error getting node facts: %v", err)
+				return
+			}
+			*facts = parseNodeFacts(out)
+		}(&nodes[i])
+	}
+	wg.Wait()
+	compareNodeFacts(*plan, nodes)
+}
+
+func parseNodeFacts(out string) NodeFacts {
+	facts := NodeFacts{}
+	for _, line := range strings.Split(out, "\n") {
+		parts := strings.SplitN(strings.TrimSpace(line), "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		value := strings.TrimSpace(parts[1])
+		switch parts[0] {
+		case "os_distro":
+			facts.OS.Distro = value
+		case "os_version":
+			facts.OS.Version = value
+		case "kernel":
+			facts.Kernel = value
+		case "docker_version":
+			facts.Docker.Version = value
+		case "docker_storage_driver":
+			facts.Docker.StorageDriver = value
+		case "etcd_version":
+			facts.EtcdVersion = value
+		case "cni_version":
+			facts.CNIVersion = value
+		case "kubelet_status":
+			facts.KubeletStatus = value
+		case "cpus":
+			facts.CPUs, _ = strconv.Atoi(value)
+		case "memory_kb":
+			kb, _ := strconv.ParseUint(value, 10, 64)
+			facts.MemoryBytes = kb * 1024
+		case "disk_kb":
+			fields := strings.Fields(value)
+			if len(fields) == 2 {
+				total, _ := strconv.ParseUint(fields[0], 10, 64)
+				available, _ := strconv.ParseUint(fields[1], 10, 64)
+				facts.DiskBytes = total * 1024
+				facts.DiskAvailableBytes = available * 1024
+			}
+		}
+	}
+	return facts
+}
+
+type peerFact struct {
+	fact  string
+	value func(n ListableNode) string
+}
+
+// compareNodeFacts records the facts of each node that differ from the plan,
+// or from the value of most of the other nodes
+func compareNodeFacts(plan Plan, nodes []ListableNode) {
+	peerFacts := []peerFact{
+		{FactOS, func(n ListableNode) string { return n.Facts.OS.String() }},
+		{FactKernel, func(n ListableNode) string { return n.Facts.Kernel }},
+		{FactDockerVersion, func(n ListableNode) string { return n.Facts.Docker.Version }},
+		{FactEtcdVersion, func(n ListableNode) string { return n.Facts.EtcdVersion }},
+		{FactCNIVersion, func(n ListableNode) string { return n.Facts.CNIVersion }},
+	}
+	if plan.Docker.Storage.Driver == "" {
+		peerFacts = append(peerFacts, peerFact{FactDockerStorageDriver, func(n ListableNode) string { return n.Facts.Docker.StorageDriver }})
+	}
+
+	gathered := []*ListableNode{}
+	for i := range nodes {
+		if nodes[i].Facts != nil && nodes[i].Facts.Error == "" {
+			gathered = append(gathered, &nodes[i])
+		}
+	}
+	for _, pf := range peerFacts {
+		values := []string{}
+		for _, n := range gathered {
+			values = append(values, pf.value(*n))
+		}
+		expected := mostCommon(values)
+		for _, n := range gathered {
+			if v := pf.value(*n); v != "" && v != expected {
+				n.Facts.Differences = append(n.Facts.Differences, FactDifference{Fact: pf.fact, Value: v, Expected: expected, Source: "peers"})
+			}
+		}
+	}
+
+	for _, n := range gathered {
+		if plan.Docker.Storage.Driver != "" && n.Facts.Docker.StorageDriver != "" && n.Facts.Docker.StorageDriver != plan.Docker.Storage.Driver {
+			n.Facts.Differences = append(n.Facts.Differences, FactDifference{Fact: FactDockerStorageDriver, Value: n.Facts.Docker.StorageDriver, Expected: plan.Docker.Storage.Driver, Source: "plan"})
+		}
+		// etcd nodes do not run Kubernetes components
+		if len(n.Roles) == 1 && n.Roles[0] == "etcd" {
+			continue
+		}
+		if v := n.ComponentVersions.Kubernetes; v != "" && v != plan.Cluster.Version {
+			n.Facts.Differences = append(n.Facts.Differences, FactDifference{Fact: FactKubernetesVersion, Value: v, Expected: plan.Cluster.Version, Source: "plan"})
+		}
+		if n.Facts.KubeletStatus != "active" {
+			n.Facts.Differences = append(n.Facts.Differences, FactDifference{Fact: FactKubeletStatus, Value: n.Facts.KubeletStatus, Expected: "active", Source: "plan"})
+		}
+	}
+}
+
+// mostCommon returns the most common non-empty value. Ties are broken in favour
+// of the greater value.
+func mostCommon(values []string) string {
+	counts := map[string]int{}
+	for _, v := range values {
+		if v != "" {
+			counts[v]++
+		}
+	}
+	distinct := []string{}
+	for v := range counts {
+		distinct = append(distinct, v)
+	}
+	sort.Strings(distinct)
+	common := ""
+	for _, v := range distinct {
+		if counts[v] >= counts[common] {
+			common = v
+		}
+	}
+	return common
+}
+
+// Differs returns true if the fact of the node differs from the plan, or from the other nodes
+func (f *NodeFacts) Differs(fact string) bool {
+	if f == nil {
+		return false
+	}
+	for _, d := range f.Differences {
+		if d.Fact == fact {
+			return true
+		}
+	}
+	return false
+}
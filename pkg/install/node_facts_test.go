@@ -0,0 +1,134 @@
+package install
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseNodeFacts(t *testing.T) {
+	out := `os_distro=ubuntu
+os_version=16.04
+kernel=4.4.0-116-generic
+docker_version=17.03.2-ce
+docker_storage_driver=overlay2
+etcd_version=3.1.10
+cni_version=calico/cni:v1.11.2
+kubelet_status=active
+cpus=4
+memory_kb=8167848
+disk_kb=40593708 35012340
+`
+	expected := NodeFacts{
+		OS:                 OSFacts{Distro: "ubuntu", Version: "16.04"},
+		Kernel:             "4.4.0-116-generic",
+		Docker:             DockerFacts{Version: "17.03.2-ce", StorageDriver: "overlay2"},
+		EtcdVersion:        "3.1.10",
+		CNIVersion:         "calico/cni:v1.11.2",
+		KubeletStatus:      "active",
+		CPUs:               4,
+		MemoryBytes:        8167848 * 1024,
+		DiskBytes:          40593708 * 1024,
+		DiskAvailableBytes: 35012340 * 1024,
+	}
+	if facts := parseNodeFacts(out); !reflect.DeepEqual(facts, expected) {
+		t.Errorf("expected %+v, got %+v", expected, facts)
+	}
+
+	// the facts that could not be found are left empty
+	facts := parseNodeFacts("os_distro=centos\nos_version=7\ndocker_version=\nsudo: docker: command not found\ndisk_kb=\n")
+	if facts.OS.String() != "centos 7" || facts.Docker.Version != "" || facts.DiskBytes != 0 {
+		t.Errorf("unexpected facts %+v", facts)
+	}
+}
+
+func factsNode(host string, roles []string, kubernetes string, facts NodeFacts) ListableNode {
+	return ListableNode{
+		Node:              Node{Host: host},
+		Roles:             roles,
+		ComponentVersions: ComponentVersions{Kubernetes: kubernetes},
+		Facts:             &facts,
+	}
+}
+
+func differences(n ListableNode) []string {
+	diffs := []string{}
+	for _, d := range n.Facts.Differences {
+		diffs = append(diffs, d.Fact)
+	}
+	return diffs
+}
+
+func TestCompareNodeFacts(t *testing.T) {
+	plan := Plan{}
+	plan.Cluster.Version = "v1.9.6"
+	plan.Docker.Storage.Driver = "overlay2"
+
+	facts := NodeFacts{
+		OS:            OSFacts{Distro: "ubuntu", Version: "16.04"},
+		Kernel:        "4.4.0-116-generic",
+		Docker:        DockerFacts{Version: "17.03.2-ce", StorageDriver: "overlay2"},
+		KubeletStatus: "active",
+	}
+	etcd := facts
+	etcd.EtcdVersion = "3.1.10"
+	etcd.KubeletStatus = "inactive"
+	oldEtcd := etcd
+	oldEtcd.EtcdVersion = "3.1.5"
+	oldKernel := facts
+	oldKernel.Kernel = "4.4.0-21-generic"
+	devicemapper := facts
+	devicemapper.Docker.StorageDriver = "devicemapper"
+	kubeletDown := facts
+	kubeletDown.KubeletStatus = "failed"
+
+	nodes := []ListableNode{
+		factsNode("etcd01", []string{"etcd"}, "", etcd),
+		factsNode("etcd02", []string{"etcd"}, "", etcd),
+		factsNode("etcd03", []string{"etcd"}, "", oldEtcd),
+		factsNode("master01", []string{"master"}, "v1.9.6", facts),
+		factsNode("worker01", []string{"worker"}, "v1.9.6", oldKernel),
+		factsNode("worker02", []string{"worker"}, "v1.9.2", devicemapper),
+		factsNode("worker03", []string{"worker"}, "v1.9.6", kubeletDown),
+		{Node: Node{Host: "worker04"}, Roles: []string{"worker"}, Facts: &NodeFacts{Error: "connection refused"}},
+	}
+	compareNodeFacts(plan, nodes)
+
+	expected := map[string][]string{
+		"etcd01":   {},
+		"etcd02":   {},
+		"etcd03":   {FactEtcdVersion},
+		"master01": {},
+		"worker01": {FactKernel},
+		"worker02": {FactDockerStorageDriver, FactKubernetesVersion},
+		"worker03": {FactKubeletStatus},
+		"worker04": {},
+	}
+	for _, n := range nodes {
+		if diffs := differences(n); !reflect.DeepEqual(diffs, expected[n.Node.Host]) {
+			t.Errorf("%s: expected differences %v, got %v", n.Node.Host, expected[n.Node.Host], diffs)
+		}
+	}
+	if !nodes[4].Facts.Differs(FactKernel) || nodes[4].Facts.Differs(FactOS) {
+		t.Errorf("expected only the kernel of worker01 to differ")
+	}
+	if d := nodes[4].Facts.Differences[0]; d.Expected != "4.4.0-116-generic" || d.Source != "peers" {
+		t.Errorf("unexpected difference %v", d)
+	}
+}
+
+func TestMostCommon(t *testing.T) {
+	tests := []struct {
+		values   []string
+		expected string
+	}{
+		{values: []string{}, expected: ""},
+		{values: []string{"", ""}, expected: ""},
+		{values: []string{"a", "b", "b", ""}, expected: "b"},
+		{values: []string{"17.03.2-ce", "17.09.0-ce"}, expected: "17.09.0-ce"},
+	}
+	for i, test := range tests {
+		if v := mostCommon(test.values); v != test.expected {
+			t.Errorf("test %d: expected %q, got %q", i, test.expected, v)
+		}
+	}
+}
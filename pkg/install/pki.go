@@ -45,7 +45,7 @@ type PKI interface {
 	GenerateClusterCertificates(p *Plan, clusterCA *tls.CA, proxyClientCA *tls.CA) error
 	NodeCertificateExists(node Node) (bool, error)
 	GenerateNodeCertificate(plan *Plan, node Node, ca *tls.CA) error
-	GenerateCertificate(name string, validityPeriod string, commonName string, subjectAlternateNames []string, organizations []string, ca *tls.CA, overwrite bool) (bool, error)
+	GenerateCertificate(p *Plan, name string, validityPeriod string, commonName string, subjectAlternateNames []string, organizations []string, ca *tls.CA, overwrite bool) (bool, error)
 }
 
 // LocalPKI is a file-based PKI
@@ -53,6 +53,9 @@ type LocalPKI struct {
 	CACsr                   string
 	GeneratedCertsDirectory string
 	Log                     io.Writer
+	// Backend holds the CAs, and signs the certificates. When nil, the backend
+	// is selected in the plan.
+	Backend CABackend
 }
 
 type certificateSpec struct {
@@ -62,6 +65,9 @@ type certificateSpec struct {
 	subjectAlternateNames []string
 	organizations         []string
 	ca                    *tls.CA
+	key                   tls.Key
+	// expiry overrides the certificate expiry of the plan when set
+	expiry string
 }
 
 func (s certificateSpec) equal(other certificateSpec) bool {
@@ -108,51 +114,91 @@ func (s certificateSpec) equal(other certificateSpec) bool {
 	return true
 }
 
-// CertificateAuthorityExists returns true if the CA for the cluster exists
+// CertificateAuthorityExists returns true if the CA for the cluster exists.
+// The private key of the CA does not exist when the certificates are signed externally.
 func (lp *LocalPKI) CertificateAuthorityExists() (bool, error) {
-	return tls.CertKeyPairExists("ca", lp.GeneratedCertsDirectory)
+	return fileExists(filepath.Join(lp.GeneratedCertsDirectory, "ca.pem"))
 }
 
 // GenerateClusterCA creates a Certificate Authority for the cluster
 func (lp *LocalPKI) GenerateClusterCA(p *Plan) (*tls.CA, error) {
-	exists, err := tls.CertKeyPairExists("ca", lp.GeneratedCertsDirectory)
+	b, err := lp.backend(p)
+	if err != nil {
+		return nil, err
+	}
+	if b != nil {
+		ca, err := lp.backendCA(b, clusterCAName, p.Cluster.Name, p.Cluster.Certificates.CAExpiry, p.Cluster.Certificates.caKey())
+		if err != nil {
+			return nil, err
+		}
+		if err = lp.writeClusterCATrust(p); err != nil {
+			return nil, err
+		}
+		return ca, nil
+	}
+	exists, err := lp.CertificateAuthorityExists()
 	if err != nil {
 		return nil, fmt.Errorf("error verifying CA certificate/key: %v", err)
 	}
 	if exists {
-		return lp.GetClusterCA()
+		ca, err := lp.GetClusterCA()
+		if err != nil {
+			return nil, err
+		}
+		if err = lp.writeClusterCATrust(p); err != nil {
+			return nil, err
+		}
+		return ca, nil
+	}
+	if _, err = os.Stat(filepath.Join(lp.GeneratedCertsDirectory, caChainFilename+".pem")); err == nil {
+		return nil, fmt.Errorf("found the CA chain %s.pem, but the intermediate CA certificate/key was not found", caChainFilename)
 	}
 
 	// CA keypair doesn't exist, generate one
 	util.PrettyPrintOk(lp.Log, "Generating cluster Certificate Authority")
-	key, cert, err := tls.NewCACert(lp.CACsr, p.Cluster.Name, p.Cluster.Certificates.CAExpiry)
+	key, cert, err := tls.NewCACertWithKey(lp.CACsr, p.Cluster.Name, p.Cluster.Certificates.CAExpiry, p.Cluster.Certificates.caKey())
 	if err != nil {
 		return nil, fmt.Errorf("failed to create CA Cert: %v", err)
 	}
 	if err = tls.WriteCert(key, cert, "ca", lp.GeneratedCertsDirectory); err != nil {
 		return nil, fmt.Errorf("error writing CA files: %v", err)
 	}
+	if err = lp.writeClusterCATrust(p); err != nil {
+		return nil, err
+	}
 	return &tls.CA{
 		Cert: cert,
 		Key:  key,
 	}, nil
 }
 
-// GetClusterCA returns the cluster CA
+// GetClusterCA returns the cluster CA. When the cluster CA is an intermediate CA,
+// its chain is bundled with the certificates it issues.
 func (lp *LocalPKI) GetClusterCA() (*tls.CA, error) {
-	key, cert, err := tls.ReadCACert("ca", lp.GeneratedCertsDirectory)
+	ca, err := lp.readCA("ca")
 	if err != nil {
 		return nil, fmt.Errorf("error reading CA certificate/key: %v", err)
 	}
-	return &tls.CA{
-		Cert: cert,
-		Key:  key,
-	}, nil
+	chain, err := readClusterCAChain(lp.GeneratedCertsDirectory)
+	if err != nil {
+		return nil, err
+	}
+	if chain != nil {
+		ca.Chain = chain.issuerBundle()
+	}
+	return ca, nil
 }
 
 // GenerateProxyClientCA creates a Certificate Authority for the cluster
 func (lp *LocalPKI) GenerateProxyClientCA(p *Plan) (*tls.CA, error) {
-	exists, err := tls.CertKeyPairExists("proxy-client-ca", lp.GeneratedCertsDirectory)
+	b, err := lp.backend(p)
+	if err != nil {
+		return nil, err
+	}
+	if b != nil {
+		return lp.backendCA(b, proxyClientCAName, proxyClientCACommonName, p.Cluster.Certificates.CAExpiry, p.Cluster.Certificates.caKey())
+	}
+	exists, err := fileExists(filepath.Join(lp.GeneratedCertsDirectory, "proxy-client-ca.pem"))
 	if err != nil {
 		return nil, fmt.Errorf("error verifying proxy-client CA certificate/key: %v", err)
 	}
@@ -162,7 +208,7 @@ func (lp *LocalPKI) GenerateProxyClientCA(p *Plan) (*tls.CA, error) {
 
 	// CA keypair doesn't exist, generate one
 	util.PrettyPrintOk(lp.Log, "Generating proxy-client Certificate Authority")
-	key, cert, err := tls.NewCACert(lp.CACsr, proxyClientCACommonName, p.Cluster.Certificates.CAExpiry)
+	key, cert, err := tls.NewCACertWithKey(lp.CACsr, proxyClientCACommonName, p.Cluster.Certificates.CAExpiry, p.Cluster.Certificates.caKey())
 	if err != nil {
 		return nil, fmt.Errorf("failed to create proxy-client CA Cert: %v", err)
 	}
@@ -177,14 +223,32 @@ func (lp *LocalPKI) GenerateProxyClientCA(p *Plan) (*tls.CA, error) {
 
 // GetProxyClientCA returns the cluster CA
 func (lp *LocalPKI) GetProxyClientCA() (*tls.CA, error) {
-	key, cert, err := tls.ReadCACert("proxy-client-ca", lp.GeneratedCertsDirectory)
+	ca, err := lp.readCA("proxy-client-ca")
 	if err != nil {
 		return nil, fmt.Errorf("error reading proxy-client CA certificate/key: %v", err)
 	}
-	return &tls.CA{
-		Cert: cert,
-		Key:  key,
-	}, nil
+	return ca, nil
+}
+
+// readCA reads the certificate and private key of the CA. The private key is not
+// read when it does not exist, as the certificates issued by the CA are signed externally.
+func (lp *LocalPKI) readCA(name string) (*tls.CA, error) {
+	external, err := caKeyMissing(name, lp.GeneratedCertsDirectory)
+	if err != nil {
+		return nil, err
+	}
+	if !external {
+		key, cert, err := tls.ReadCACert(name, lp.GeneratedCertsDirectory)
+		if err != nil {
+			return nil, err
+		}
+		return &tls.CA{Cert: cert, Key: key}, nil
+	}
+	cert, err := ioutil.ReadFile(filepath.Join(lp.GeneratedCertsDirectory, name+".pem"))
+	if err != nil {
+		return nil, fmt.Errorf("error reading certificate: %v", err)
+	}
+	return &tls.CA{Cert: cert}, nil
 }
 
 // GenerateClusterCertificates creates all certificates required for the cluster
@@ -199,6 +263,7 @@ func (lp *LocalPKI) GenerateClusterCertificates(p *Plan, clusterCA *tls.CA, prox
 		return err
 	}
 
+	var pending []CertificateSigningRequest
 	for _, s := range manifest {
 		exists, err := tls.CertKeyPairExists(s.filename, lp.GeneratedCertsDirectory)
 		if err != nil {
@@ -234,12 +299,27 @@ func (lp *LocalPKI) GenerateClusterCertificates(p *Plan, clusterCA *tls.CA, prox
 			continue
 		}
 
-		// Cert doesn't exist. Generate it
-		if err := generateCert(lp.GeneratedCertsDirectory, s, p.Cluster.Certificates.Expiry); err != nil {
+		// Cert doesn't exist. Generate it, unless it must be signed externally
+		signer := ClusterCASigner
+		if s.ca == proxyClientCA {
+			signer = ProxyClientCASigner
+		}
+		r, err := lp.pendingCSR(p, s, signer)
+		if err != nil {
+			return err
+		}
+		if r != nil {
+			pending = append(pending, *r)
+			continue
+		}
+		if err := lp.issueCert(p, s); err != nil {
 			return err
 		}
 		util.PrettyPrintOk(lp.Log, "Generated certificate for %s", s.description)
 	}
+	if len(pending) > 0 {
+		return PendingCSRsError{CSRs: pending}
+	}
 	return nil
 }
 
@@ -293,10 +373,46 @@ func (lp *LocalPKI) ValidateClusterCertificates(p *Plan) (warns []error, errs []
 		if len(warn) > 0 {
 			warns = append(warns, warn...)
 		}
+		if err = lp.warnKeyMismatch(s.filename, s.description, s.key); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	caKey := p.Cluster.Certificates.caKey()
+	if caKey != (tls.Key{}) {
+		for _, ca := range []struct{ name, description string }{{clusterCAName, ClusterCASigner}, {proxyClientCAName, ProxyClientCASigner}} {
+			exists, err := fileExists(filepath.Join(lp.GeneratedCertsDirectory, ca.name+".pem"))
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			if !exists {
+				continue
+			}
+			if err = lp.warnKeyMismatch(ca.name, ca.description, caKey); err != nil {
+				errs = append(errs, err)
+			}
+		}
 	}
 	return warns, errs
 }
 
+// warnKeyMismatch prints a warning when the private key of the certificate does not have
+// the configured algorithm and size. The certificate remains valid until it is rotated.
+func (lp *LocalPKI) warnKeyMismatch(name, description string, expected tls.Key) error {
+	cert, err := tls.ReadCert(name, lp.GeneratedCertsDirectory)
+	if err != nil {
+		return err
+	}
+	key, err := tls.CertKey(cert)
+	if err != nil {
+		return fmt.Errorf("error reading the key of the certificate for %s: %v", description, err)
+	}
+	if key != expected.WithDefaults() {
+		util.PrettyPrintWarn(lp.Log, "Certificate for %s has a %s key, but %s keys are configured. Schedule a rotation with \"kismatic certificates rotate\"", description, key, expected)
+	}
+	return nil
+}
+
 // NodeCertificateExists returns true if the node's key and certificate exist
 func (lp *LocalPKI) NodeCertificateExists(node Node) (bool, error) {
 	return tls.CertKeyPairExists(node.Host, lp.GeneratedCertsDirectory)
@@ -308,6 +424,7 @@ func (lp *LocalPKI) GenerateNodeCertificate(plan *Plan, node Node, ca *tls.CA) e
 	if err != nil {
 		return err
 	}
+	var pending []CertificateSigningRequest
 	for _, s := range m {
 		exists, err := tls.CertKeyPairExists(s.filename, lp.GeneratedCertsDirectory)
 		if err != nil {
@@ -327,19 +444,31 @@ func (lp *LocalPKI) GenerateNodeCertificate(plan *Plan, node Node, ca *tls.CA) e
 			util.PrettyPrintOk(lp.Log, "Found valid certificate for %s", s.description)
 			continue
 		}
-		// Cert doesn't exist. Generate it
-		if err := generateCert(lp.GeneratedCertsDirectory, s, plan.Cluster.Certificates.Expiry); err != nil {
+		// Cert doesn't exist. Generate it, unless it must be signed externally
+		r, err := lp.pendingCSR(plan, s, ClusterCASigner)
+		if err != nil {
+			return err
+		}
+		if r != nil {
+			pending = append(pending, *r)
+			continue
+		}
+		if err := lp.issueCert(plan, s); err != nil {
 			return err
 		}
 		util.PrettyPrintOk(lp.Log, "Generated certificate for %s", s.description)
 	}
+	if len(pending) > 0 {
+		return PendingCSRsError{CSRs: pending}
+	}
 	return nil
 }
 
 // GenerateCertificate creates a private key and certificate for the given name, CN, subjectAlternateNames and organizations
+// The certificate is signed by the certificates backend of the plan, or by the given CA when the backend is local
 // If cert exists, will not fail
 // Pass overwrite to replace an existing cert
-func (lp *LocalPKI) GenerateCertificate(name string, validityPeriod string, commonName string, subjectAlternateNames []string, organizations []string, ca *tls.CA, overwrite bool) (bool, error) {
+func (lp *LocalPKI) GenerateCertificate(p *Plan, name string, validityPeriod string, commonName string, subjectAlternateNames []string, organizations []string, ca *tls.CA, overwrite bool) (bool, error) {
 	if name == "" {
 		return false, fmt.Errorf("name cannot be empty")
 	}
@@ -366,38 +495,66 @@ func (lp *LocalPKI) GenerateCertificate(name string, validityPeriod string, comm
 		subjectAlternateNames: subjectAlternateNames,
 		organizations:         organizations,
 		ca:                    ca,
+		key:                   p.Cluster.Certificates.key(),
 	}
 
-	if err := generateCert(lp.GeneratedCertsDirectory, spec, validityPeriod); err != nil {
+	b, err := lp.backend(p)
+	if err != nil {
+		return exists, err
+	}
+	if err = lp.signCert(b, spec, validityPeriod); err != nil {
 		return exists, fmt.Errorf("could not generate certificate %s: %v", name, err)
 	}
 
 	return exists, nil
 }
 
-func generateCert(certDir string, spec certificateSpec, expiryStr string) error {
-	expiry, err := time.ParseDuration(expiryStr)
-	if err != nil {
-		return fmt.Errorf("%q is not a valid duration for certificate expiry", expiryStr)
-	}
+// certificateRequest returns the request for the certificate
+func (s certificateSpec) certificateRequest() csr.CertificateRequest {
 	req := csr.CertificateRequest{
-		CN: spec.commonName,
-		KeyRequest: &csr.BasicKeyRequest{
-			A: "rsa",
-			S: 2048,
-		},
+		CN:         s.commonName,
+		KeyRequest: s.key.KeyRequest(),
 	}
 
-	if len(spec.subjectAlternateNames) > 0 {
-		req.Hosts = spec.subjectAlternateNames
+	if len(s.subjectAlternateNames) > 0 {
+		req.Hosts = s.subjectAlternateNames
 	}
 
-	for _, org := range spec.organizations {
+	for _, org := range s.organizations {
 		name := csr.Name{O: org}
 		req.Names = append(req.Names, name)
 	}
+	return req
+}
+
+// key returns the private key of the certificates of the cluster
+func (c CertsConfig) key() tls.Key {
+	return tls.Key{Algorithm: c.KeyAlgorithm, Size: c.KeySize}
+}
+
+// caKey returns the private key of the CAs of the cluster
+func (c CertsConfig) caKey() tls.Key {
+	return tls.Key{Algorithm: c.CAKeyAlgorithm, Size: c.CAKeySize}
+}
+
+// withKey sets the private key of the certificates
+func withKey(specs []certificateSpec, key tls.Key) []certificateSpec {
+	for i := range specs {
+		specs[i].key = key
+	}
+	return specs
+}
+
+func generateCert(certDir string, spec certificateSpec, expiryStr string) error {
+	expiry, err := time.ParseDuration(expiryStr)
+	if err != nil {
+		return fmt.Errorf("%q is not a valid duration for certificate expiry", expiryStr)
+	}
+	if spec.ca == nil || len(spec.ca.Key) == 0 {
+		return fmt.Errorf("the private key of the CA is not available to sign the certificate for %q", spec.description)
+	}
 
-	key, cert, err := tls.NewCert(spec.ca, req, expiry)
+	key, cert, err := tls.NewCert(spec.ca, spec.certificateRequest(), expiry)
 	if err != nil {
 		return fmt.Errorf("error generating certs for %q: %v", spec.description, err)
 	}
@@ -0,0 +1,133 @@
+package install
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/apprenda/kismatic/pkg/tls"
+)
+
+const (
+	pkiBackendLocal = "local"
+	pkiBackendVault = "vault"
+)
+
+// The names of the CAs of the cluster
+const (
+	clusterCAName     = "ca"
+	proxyClientCAName = "proxy-client-ca"
+)
+
+// A CABackend holds the private keys of the CAs of the cluster, and signs the
+// certificates issued by them. The certificates are cached in the generated
+// assets directory by the LocalPKI, for distribution to the nodes.
+type CABackend interface {
+	// CACertificate returns the PEM encoded certificate of the CA with the given name,
+	// or nil if the CA does not exist
+	CACertificate(name string) ([]byte, error)
+	// GenerateCA creates the CA with the given name and private key, and returns its PEM encoded certificate
+	GenerateCA(name string, commonName string, expiry string, key tls.Key) ([]byte, error)
+	// Sign signs the PEM encoded CSR with the CA that has the given certificate,
+	// and returns the PEM encoded certificate
+	Sign(caCert []byte, csr []byte, expiry time.Duration) ([]byte, error)
+}
+
+// backend returns the CA backend that is selected in the plan. Returns nil
+// when the CAs are kept in the generated assets directory.
+func (lp *LocalPKI) backend(p *Plan) (CABackend, error) {
+	if lp.Backend != nil {
+		return lp.Backend, nil
+	}
+	switch p.Cluster.Certificates.Backend {
+	case "", pkiBackendLocal:
+		return nil, nil
+	case pkiBackendVault:
+		if p.Cluster.Certificates.Vault == nil {
+			return nil, fmt.Errorf("the vault certificates backend is not configured")
+		}
+		b, err := NewVaultBackend(*p.Cluster.Certificates.Vault, os.Getenv("VAULT_TOKEN"))
+		if err != nil {
+			return nil, err
+		}
+		lp.Backend = b
+		return b, nil
+	}
+	return nil, fmt.Errorf("%q is not a valid certificates backend", p.Cluster.Certificates.Backend)
+}
+
+// backendCA returns the CA with the given name from the backend, creating it if it
+// does not exist. The certificate of the CA is cached in the generated assets directory.
+func (lp *LocalPKI) backendCA(b CABackend, name string, commonName string, expiry string, key tls.Key) (*tls.CA, error) {
+	cert, err := b.CACertificate(name)
+	if err != nil {
+		return nil, fmt.Errorf("error reading the %s certificate from the backend: %v", name, err)
+	}
+	if cert == nil {
+		if cert, err = b.GenerateCA(name, commonName, expiry, key); err != nil {
+			return nil, fmt.Errorf("error generating the %s in the backend: %v", name, err)
+		}
+	}
+	if err = os.MkdirAll(lp.GeneratedCertsDirectory, 0744); err != nil {
+		return nil, err
+	}
+	if err = ioutil.WriteFile(filepath.Join(lp.GeneratedCertsDirectory, name+".pem"), cert, 0644); err != nil {
+		return nil, fmt.Errorf("error writing the %s certificate: %v", name, err)
+	}
+	return &tls.CA{Cert: cert}, nil
+}
+
+// canSign returns true if the certificates issued by the CA can be signed
+func (lp *LocalPKI) canSign(p *Plan, ca *tls.CA) (bool, error) {
+	b, err := lp.backend(p)
+	if err != nil {
+		return false, err
+	}
+	return b != nil || (ca != nil && len(ca.Key) > 0), nil
+}
+
+// issueCert creates the private key of the certificate, and has the certificate
+// signed by the backend of the plan
+func (lp *LocalPKI) issueCert(p *Plan, spec certificateSpec) error {
+	b, err := lp.backend(p)
+	if err != nil {
+		return err
+	}
+	expiry := p.Cluster.Certificates.Expiry
+	if spec.expiry != "" {
+		expiry = spec.expiry
+	}
+	return lp.signCert(b, spec, expiry)
+}
+
+// signCert creates the private key of the certificate, and has the certificate signed
+// by the backend, or by the CA of the certificate when there is no backend
+func (lp *LocalPKI) signCert(b CABackend, spec certificateSpec, expiryStr string) error {
+	if b == nil {
+		return generateCert(lp.GeneratedCertsDirectory, spec, expiryStr)
+	}
+	expiry, err := time.ParseDuration(expiryStr)
+	if err != nil {
+		return fmt.Errorf("%q is not a valid duration for certificate expiry", expiryStr)
+	}
+	if spec.ca == nil {
+		return fmt.Errorf("the CA of the certificate for %q is not available", spec.description)
+	}
+	key, csrBytes, err := tls.NewCSR(spec.certificateRequest())
+	if err != nil {
+		return fmt.Errorf("error generating certs for %q: %v", spec.description, err)
+	}
+	cert, err := b.Sign(spec.ca.Cert, csrBytes, expiry)
+	if err != nil {
+		return fmt.Errorf("error signing the certificate for %q: %v", spec.description, err)
+	}
+	if len(spec.ca.Chain) > 0 {
+		cert = tls.Bundle(cert, spec.ca.Chain)
+	}
+	if err = tls.WriteCert(key, cert, spec.filename, lp.GeneratedCertsDirectory); err != nil {
+		return fmt.Errorf("error writing cert for %q: %v", spec.description, err)
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package install
 
 import (
+	"bytes"
 	"crypto/x509"
 	"fmt"
 	"io/ioutil"
@@ -762,6 +763,69 @@ func TestValidateClusterCertificatesWithValidExistingCerts(t *testing.T) {
 	}
 }
 
+func TestGenerateClusterCertificatesKeyAlgorithm(t *testing.T) {
+	pki := getPKI(t)
+	defer cleanup(pki.GeneratedCertsDirectory, t)
+	log := &bytes.Buffer{}
+	pki.Log = log
+
+	p := getPlan()
+	p.Cluster.Certificates.CAKeyAlgorithm = tls.KeyAlgorithmECDSA
+	p.Cluster.Certificates.KeyAlgorithm = tls.KeyAlgorithmECDSA
+	p.Cluster.Certificates.KeySize = 384
+	ca, err := pki.GenerateClusterCA(p)
+	if err != nil {
+		t.Fatalf("error generating CA for test: %v", err)
+	}
+	proxyClientCA, err := pki.GenerateProxyClientCA(p)
+	if err != nil {
+		t.Fatalf("error generating proxy-client CA for test: %v", err)
+	}
+	if err = pki.GenerateClusterCertificates(p, ca, proxyClientCA); err != nil {
+		t.Fatalf("failed to generate certs: %v", err)
+	}
+	if _, err = pki.GenerateCertificate(p, "alice", "1h", "alice", nil, nil, ca, false); err != nil {
+		t.Fatalf("failed to generate certificate: %v", err)
+	}
+	expected := map[string]tls.Key{
+		"ca":                    {Algorithm: tls.KeyAlgorithmECDSA, Size: 256},
+		"proxy-client-ca":       {Algorithm: tls.KeyAlgorithmECDSA, Size: 256},
+		"admin":                 {Algorithm: tls.KeyAlgorithmECDSA, Size: 384},
+		proxyClientCertFilename: {Algorithm: tls.KeyAlgorithmECDSA, Size: 384},
+		"alice":                 {Algorithm: tls.KeyAlgorithmECDSA, Size: 384},
+	}
+	for name, key := range expected {
+		actual, err := tls.CertKey(mustReadCertFile(filepath.Join(pki.GeneratedCertsDirectory, name+".pem"), t))
+		if err != nil {
+			t.Fatalf("error reading the key of %q: %v", name, err)
+		}
+		if actual != key {
+			t.Errorf("expected %q to have a %s key, got %s", name, key, actual)
+		}
+	}
+	if _, errs := pki.ValidateClusterCertificates(p); len(errs) != 0 {
+		t.Errorf("expected no errors, got: %v", errs)
+	}
+	if strings.Contains(log.String(), "Schedule a rotation") {
+		t.Errorf("expected no key mismatch warnings, got: %s", log.String())
+	}
+
+	// The certificates are valid, but do not have the configured keys
+	p.Cluster.Certificates.KeyAlgorithm = ""
+	p.Cluster.Certificates.KeySize = 0
+	p.Cluster.Certificates.CAKeyAlgorithm = tls.KeyAlgorithmRSA
+	warn, errs := pki.ValidateClusterCertificates(p)
+	if len(errs) != 0 || len(warn) != 0 {
+		t.Errorf("expected the certificates to be valid, got warnings %v and errors %v", warn, errs)
+	}
+	if !strings.Contains(log.String(), "Certificate for admin client has a ecdsa 384 key, but rsa 2048 keys are configured") {
+		t.Errorf("expected a key mismatch warning for the admin certificate, got: %s", log.String())
+	}
+	if !strings.Contains(log.String(), "Certificate for cluster CA has a ecdsa 256 key") {
+		t.Errorf("expected a key mismatch warning for the CA, got: %s", log.String())
+	}
+}
+
 func TestValidateClusterCertificatesInvalidCerts(t *testing.T) {
 	pki := getPKI(t)
 	defer cleanup(pki.GeneratedCertsDirectory, t)
@@ -1099,7 +1163,7 @@ func TestGenerateCertificate(t *testing.T) {
 		},
 	}
 	for i, test := range tests {
-		exists, err := pki.GenerateCertificate(test.name, test.validityPeriod, test.commonName, test.subjectAlternateNames, test.organizations, test.ca, test.overwrite)
+		exists, err := pki.GenerateCertificate(getPlan(), test.name, test.validityPeriod, test.commonName, test.subjectAlternateNames, test.organizations, test.ca, test.overwrite)
 
 		if (err != nil) == test.valid {
 			t.Errorf("test %d: expect valid to be %t, but got %v", i, test.valid, err)
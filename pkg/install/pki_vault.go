@@ -0,0 +1,217 @@
+package install
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	kismatictls "github.com/apprenda/kismatic/pkg/tls"
+)
+
+const (
+	defaultVaultMount            = "pki"
+	defaultVaultProxyClientMount = "pki_proxy_client"
+)
+
+// VaultBackend is a CABackend that uses the PKI secrets engines of a HashiCorp Vault server.
+// Each CA of the cluster is held by its own secrets engine.
+type VaultBackend struct {
+	Address string
+	Token   string
+	// Mounts are the paths of the secrets engines, keyed by the name of the CA
+	Mounts     map[string]string
+	HTTPClient *http.Client
+}
+
+// NewVaultBackend returns a Vault backend for the configuration, that authenticates with the token
+func NewVaultBackend(config VaultPKIConfig, token string) (*VaultBackend, error) {
+	if config.Address == "" {
+		return nil, fmt.Errorf("the address of the Vault server is required")
+	}
+	if token == "" {
+		return nil, fmt.Errorf("the VAULT_TOKEN environment variable must be set to use the vault certificates backend")
+	}
+	mount := config.Mount
+	if mount == "" {
+		mount = defaultVaultMount
+	}
+	proxyClientMount := config.ProxyClientMount
+	if proxyClientMount == "" {
+		proxyClientMount = defaultVaultProxyClientMount
+	}
+	transport := &http.Transport{}
+	if config.CACert != "" {
+		pem, err := ioutil.ReadFile(config.CACert)
+		if err != nil {
+			return nil, fmt.Errorf("error reading the Vault CA certificate: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in the Vault CA certificate file %q", config.CACert)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+	return &VaultBackend{
+		Address: strings.TrimSuffix(config.Address, "/"),
+		Token:   token,
+		Mounts: map[string]string{
+			clusterCAName:     mount,
+			proxyClientCAName: proxyClientMount,
+		},
+		HTTPClient: &http.Client{Transport: transport, Timeout: 30 * time.Second},
+	}, nil
+}
+
+// CACertificate returns the certificate of the CA held by the secrets engine
+func (v *VaultBackend) CACertificate(name string) ([]byte, error) {
+	mount, err := v.mount(name)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodGet, v.url(mount, "ca/pem"), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := v.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading response from Vault: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		return nil, vaultError(resp.StatusCode, body)
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		return nil, nil
+	}
+	return append(bytes.TrimSpace(body), '\n'), nil
+}
+
+// GenerateCA generates a self-signed root CA in the secrets engine.
+// The private key of the CA does not leave the Vault server.
+func (v *VaultBackend) GenerateCA(name string, commonName string, expiry string, key kismatictls.Key) ([]byte, error) {
+	mount, err := v.mount(name)
+	if err != nil {
+		return nil, err
+	}
+	key = key.WithDefaults()
+	keyType := "rsa"
+	if key.Algorithm == kismatictls.KeyAlgorithmECDSA {
+		keyType = "ec"
+	}
+	return v.certificate(mount, "root/generate/internal", map[string]interface{}{
+		"common_name": commonName,
+		"ttl":         expiry,
+		"key_type":    keyType,
+		"key_bits":    key.Size,
+	})
+}
+
+// Sign signs the CSR with the secrets engine that holds the CA. The subject and the
+// subject alternative names of the CSR are kept as they are.
+func (v *VaultBackend) Sign(caCert []byte, csr []byte, expiry time.Duration) ([]byte, error) {
+	mount, err := v.signingMount(caCert)
+	if err != nil {
+		return nil, err
+	}
+	return v.certificate(mount, "sign-verbatim", map[string]interface{}{
+		"csr": string(csr),
+		"ttl": expiry.String(),
+	})
+}
+
+// signingMount returns the secrets engine that holds the CA with the certificate
+func (v *VaultBackend) signingMount(caCert []byte) (string, error) {
+	for name, mount := range v.Mounts {
+		cert, err := v.CACertificate(name)
+		if err != nil {
+			return "", err
+		}
+		if cert != nil && bytes.Equal(bytes.TrimSpace(cert), bytes.TrimSpace(caCert)) {
+			return mount, nil
+		}
+	}
+	return "", fmt.Errorf("the CA is not held by any of the Vault secrets engines of the cluster")
+}
+
+func (v *VaultBackend) mount(name string) (string, error) {
+	mount, ok := v.Mounts[name]
+	if !ok {
+		return "", fmt.Errorf("no Vault secrets engine is configured for the %s", name)
+	}
+	return mount, nil
+}
+
+func (v *VaultBackend) url(mount, path string) string {
+	return fmt.Sprintf("%s/v1/%s/%s", v.Address, strings.Trim(mount, "/"), path)
+}
+
+func (v *VaultBackend) do(req *http.Request) (*http.Response, error) {
+	req.Header.Set("X-Vault-Token", v.Token)
+	client := v.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to Vault: %v", err)
+	}
+	return resp, nil
+}
+
+// certificate posts the request to the path of the secrets engine, and returns the issued certificate
+func (v *VaultBackend) certificate(mount, path string, body map[string]interface{}) ([]byte, error) {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodPost, v.url(mount, path), bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := v.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading response from Vault: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, vaultError(resp.StatusCode, respBody)
+	}
+	secret := struct {
+		Data struct {
+			Certificate string `json:"certificate"`
+		} `json:"data"`
+	}{}
+	if err = json.Unmarshal(respBody, &secret); err != nil {
+		return nil, fmt.Errorf("error decoding response from Vault: %v", err)
+	}
+	if secret.Data.Certificate == "" {
+		return nil, fmt.Errorf("no certificate was returned by Vault")
+	}
+	return []byte(strings.TrimSpace(secret.Data.Certificate) + "\n"), nil
+}
+
+// vaultError returns the errors reported by Vault in the response body
+func vaultError(status int, body []byte) error {
+	resp := struct {
+		Errors []string `json:"errors"`
+	}{}
+	if err := json.Unmarshal(body, &resp); err == nil && len(resp.Errors) > 0 {
+		return fmt.Errorf("Vault returned %d: %s", status, strings.Join(resp.Errors, "; "))
+	}
+	return fmt.Errorf("Vault returned %d", status)
+}
@@ -0,0 +1,259 @@
+package install
+
+import (
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/apprenda/kismatic/pkg/tls"
+	"github.com/cloudflare/cfssl/helpers"
+)
+
+// fakeVault is a stand-in for a Vault server in dev mode, with the PKI secrets
+// engine enabled at the given mounts
+type fakeVault struct {
+	token string
+	sync.Mutex
+	cas    map[string]*tls.CA
+	signed int
+}
+
+func newFakeVault(token string, mounts ...string) (*fakeVault, *httptest.Server) {
+	v := &fakeVault{token: token, cas: map[string]*tls.CA{}}
+	mux := http.NewServeMux()
+	for _, m := range mounts {
+		mount := m
+		mux.HandleFunc("/v1/"+mount+"/", func(w http.ResponseWriter, r *http.Request) {
+			v.handle(mount, strings.TrimPrefix(r.URL.Path, "/v1/"+mount+"/"), w, r)
+		})
+	}
+	return v, httptest.NewServer(mux)
+}
+
+func (v *fakeVault) handle(mount, path string, w http.ResponseWriter, r *http.Request) {
+	v.Lock()
+	defer v.Unlock()
+	if r.Header.Get("X-Vault-Token") != v.token {
+		vaultErrorResponse(w, http.StatusForbidden, "permission denied")
+		return
+	}
+	ca := v.cas[mount]
+	switch {
+	case path == "ca/pem" && r.Method == http.MethodGet:
+		if ca == nil {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Write(ca.Cert)
+	case path == "root/generate/internal" && r.Method == http.MethodPost:
+		req := struct {
+			CommonName string `json:"common_name"`
+			TTL        string `json:"ttl"`
+			KeyType    string `json:"key_type"`
+			KeyBits    int    `json:"key_bits"`
+		}{}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			vaultErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		caKey := tls.Key{Algorithm: tls.KeyAlgorithmRSA, Size: req.KeyBits}
+		if req.KeyType == "ec" {
+			caKey.Algorithm = tls.KeyAlgorithmECDSA
+		}
+		key, cert, err := tls.NewCACertWithKey("test/ca-csr.json", req.CommonName, req.TTL, caKey)
+		if err != nil {
+			vaultErrorResponse(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		v.cas[mount] = &tls.CA{Key: key, Cert: cert}
+		vaultCertificateResponse(w, cert)
+	case path == "sign-verbatim" && r.Method == http.MethodPost:
+		if ca == nil {
+			vaultErrorResponse(w, http.StatusBadRequest, "no default issuer currently configured")
+			return
+		}
+		req := map[string]string{}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			vaultErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		cert, err := signVerbatim(ca, []byte(req["csr"]))
+		if err != nil {
+			vaultErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		v.signed++
+		vaultCertificateResponse(w, cert)
+	default:
+		vaultErrorResponse(w, http.StatusNotFound, "unsupported path")
+	}
+}
+
+func vaultErrorResponse(w http.ResponseWriter, status int, msg string) {
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string][]string{"errors": {msg}})
+}
+
+func vaultCertificateResponse(w http.ResponseWriter, cert []byte) {
+	json.NewEncoder(w).Encode(map[string]map[string]string{"data": {"certificate": string(cert)}})
+}
+
+// signVerbatim signs the CSR with the CA, keeping its subject and subject alternative names
+func signVerbatim(ca *tls.CA, csrPEM []byte) ([]byte, error) {
+	block, _ := pem.Decode(csrPEM)
+	if block == nil {
+		return nil, fmt.Errorf("error decoding CSR")
+	}
+	req, err := x509.ParseCertificateRequest(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	caKey, err := helpers.ParsePrivateKeyPEM(ca.Key)
+	if err != nil {
+		return nil, err
+	}
+	caCert, err := helpers.ParseCertificatePEM(ca.Cert)
+	if err != nil {
+		return nil, err
+	}
+	tpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      req.Subject,
+		DNSNames:     req.DNSNames,
+		IPAddresses:  req.IPAddresses,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tpl, caCert, req.PublicKey, caKey)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), nil
+}
+
+func getVaultPlan(address string) *Plan {
+	p := getPlan()
+	p.Cluster.Certificates.Backend = pkiBackendVault
+	p.Cluster.Certificates.Vault = &VaultPKIConfig{Address: address}
+	return p
+}
+
+func TestVaultBackend(t *testing.T) {
+	vault, srv := newFakeVault("root", defaultVaultMount, defaultVaultProxyClientMount)
+	defer srv.Close()
+	os.Setenv("VAULT_TOKEN", "root")
+	defer os.Unsetenv("VAULT_TOKEN")
+	pki := getPKI(t)
+	defer cleanup(pki.GeneratedCertsDirectory, t)
+	dir := pki.GeneratedCertsDirectory
+	p := getVaultPlan(srv.URL)
+
+	ca, err := pki.GenerateClusterCA(p)
+	if err != nil {
+		t.Fatalf("unexpected error generating the CA: %v", err)
+	}
+	if len(ca.Key) != 0 {
+		t.Errorf("expected the private key of the CA to be kept in Vault")
+	}
+	if vault.cas[defaultVaultMount] == nil || string(vault.cas[defaultVaultMount].Cert) != string(ca.Cert) {
+		t.Fatalf("expected the CA to be generated in the %q secrets engine", defaultVaultMount)
+	}
+	if _, err = os.Stat(filepath.Join(dir, "ca-key.pem")); !os.IsNotExist(err) {
+		t.Errorf("expected the private key of the CA not to be written")
+	}
+	proxyClientCA, err := pki.GenerateProxyClientCA(p)
+	if err != nil {
+		t.Fatalf("unexpected error generating the proxy-client CA: %v", err)
+	}
+	if vault.cas[defaultVaultProxyClientMount] == nil {
+		t.Fatalf("expected the proxy-client CA to be generated in the %q secrets engine", defaultVaultProxyClientMount)
+	}
+
+	// The existing CA is read from Vault
+	again, err := pki.GenerateClusterCA(p)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(again.Cert) != string(ca.Cert) {
+		t.Errorf("expected the existing CA to be returned")
+	}
+
+	if err = pki.GenerateClusterCertificates(p, ca, proxyClientCA); err != nil {
+		t.Fatalf("unexpected error generating certificates: %v", err)
+	}
+	manifest, err := p.certSpecs(nil, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if vault.signed != len(manifest) {
+		t.Errorf("expected %d certificates to be signed by Vault, got %d", len(manifest), vault.signed)
+	}
+	admin := mustReadCertFile(filepath.Join(dir, "admin.pem"), t)
+	if !contains(adminGroup, admin.Subject.Organization) {
+		t.Errorf("expected the admin certificate to keep its organizations, got %v", admin.Subject.Organization)
+	}
+	for name, caCert := range map[string][]byte{"admin": ca.Cert, proxyClientCertFilename: proxyClientCA.Cert} {
+		root, err := helpers.ParseCertificatePEM(caCert)
+		if err != nil {
+			t.Fatalf("error parsing CA: %v", err)
+		}
+		opts := x509.VerifyOptions{Roots: x509.NewCertPool(), KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny}}
+		opts.Roots.AddCert(root)
+		if _, err = mustReadCertFile(filepath.Join(dir, name+".pem"), t).Verify(opts); err != nil {
+			t.Errorf("expected %q to be signed by its CA: %v", name, err)
+		}
+	}
+	if ok, errs := ValidateCertificates(p, &pki); !ok {
+		t.Errorf("expected the certificates to be valid, got: %v", errs)
+	}
+
+	// New nodes are signed with the cached CA certificate
+	cached, err := pki.GetClusterCA()
+	if err != nil {
+		t.Fatalf("unexpected error reading the cached CA: %v", err)
+	}
+	node := Node{Host: "new-worker", IP: "10.0.0.20"}
+	updated := AddNodeToPlan(*p, node, []string{"worker"})
+	if err = pki.GenerateNodeCertificate(&updated, node, cached); err != nil {
+		t.Errorf("unexpected error generating the node certificate: %v", err)
+	}
+
+	if _, err = pki.RotateClusterCA(p, proxyClientCA); err == nil {
+		t.Errorf("expected an error rotating a CA that is held by Vault")
+	}
+}
+
+func TestVaultBackendToken(t *testing.T) {
+	_, srv := newFakeVault("root", defaultVaultMount, defaultVaultProxyClientMount)
+	defer srv.Close()
+	p := getVaultPlan(srv.URL)
+
+	os.Unsetenv("VAULT_TOKEN")
+	pki := getPKI(t)
+	defer cleanup(pki.GeneratedCertsDirectory, t)
+	if _, err := pki.GenerateClusterCA(p); err == nil {
+		t.Errorf("expected an error without a Vault token")
+	}
+
+	os.Setenv("VAULT_TOKEN", "invalid")
+	defer os.Unsetenv("VAULT_TOKEN")
+	pki = getPKI(t)
+	defer cleanup(pki.GeneratedCertsDirectory, t)
+	_, err := pki.GenerateClusterCA(p)
+	if err == nil || !strings.Contains(err.Error(), "permission denied") {
+		t.Errorf("expected the error returned by Vault, got: %v", err)
+	}
+}
@@ -84,6 +84,9 @@ type Plan struct {
 	Storage OptionalNodeGroup
 	// NFS volumes of the cluster.
 	NFS NFS
+	// Users of the cluster, that authenticate with a client certificate.
+	// A certificate and a kubeconfig file are generated for each user.
+	Users []User `yaml:"users,omitempty"`
 }
 
 // Cluster describes a Kubernetes cluster
@@ -207,6 +210,55 @@ type CertsConfig struct {
 	// For example: "17520h" for 2 years.
 	// +required.
 	CAExpiry string `yaml:"ca_expiry"`
+	// The algorithm of the private keys of the generated certificates.
+	// +default=rsa
+	// +options=rsa,ecdsa
+	KeyAlgorithm string `yaml:"key_algorithm,omitempty"`
+	// The size of the private keys of the generated certificates, in bits.
+	// The size of an ECDSA key is the size of its curve.
+	// Supported sizes are 2048, 3072 and 4096 for RSA keys, and 256 and 384 for ECDSA keys.
+	// Defaults to 2048 for RSA keys, and 256 for ECDSA keys.
+	KeySize int `yaml:"key_size,omitempty"`
+	// The algorithm of the private keys of the generated Certificate Authorities.
+	// +default=rsa
+	// +options=rsa,ecdsa
+	CAKeyAlgorithm string `yaml:"ca_key_algorithm,omitempty"`
+	// The size of the private keys of the generated Certificate Authorities, in bits.
+	// Supported sizes are the same as for key_size.
+	// Defaults to 2048 for RSA keys, and 256 for ECDSA keys.
+	CAKeySize int `yaml:"ca_key_size,omitempty"`
+	// Set to true to only distribute the trust anchor of the CA chain to the nodes,
+	// when the cluster CA is an intermediate CA provided with its chain.
+	// The chain is bundled with the certificates issued by the cluster CA.
+	// +default=false
+	TrustAnchorOnly bool `yaml:"trust_anchor_only,omitempty"`
+	// The backend that holds the private keys of the CAs, and signs the certificates of the cluster.
+	// The CAs and the private keys are kept in the generated assets directory with the "local" backend.
+	// The certificates are signed by the PKI secrets engine of a HashiCorp Vault server with the "vault" backend,
+	// and the private keys of the CAs are never written to the generated assets directory.
+	// +default=local
+	// +options=local,vault
+	Backend string `yaml:"backend,omitempty"`
+	// The configuration of the Vault PKI backend.
+	Vault *VaultPKIConfig `yaml:"vault,omitempty"`
+}
+
+// VaultPKIConfig describes the PKI secrets engines of a HashiCorp Vault server that
+// hold the CAs of the cluster. The Vault token is read from the VAULT_TOKEN environment variable.
+type VaultPKIConfig struct {
+	// The address of the Vault server.
+	// For example: "https://vault.example.com:8200"
+	// +required
+	Address string
+	// The path where the PKI secrets engine of the cluster CA is mounted.
+	// +default=pki
+	Mount string `yaml:"mount,omitempty"`
+	// The path where the PKI secrets engine of the proxy-client CA is mounted.
+	// +default=pki_proxy_client
+	ProxyClientMount string `yaml:"proxy_client_mount,omitempty"`
+	// Path to the CA certificate file that is used to verify the Vault server.
+	// The system CAs are used when empty.
+	CACert string `yaml:"ca_cert,omitempty"`
 }
 
 // SSHConfig describes the cluster's SSH configuration for accessing nodes
@@ -319,6 +371,20 @@ type AdditionalFile struct {
 	SkipValidation bool `yaml:"skip_validation"`
 }
 
+// User is a user of the cluster, that authenticates with a client certificate
+type User struct {
+	// Name of the user, set as the common name of its certificate.
+	// +required
+	Name string
+	// Groups of the user, set as the organizations of its certificate.
+	Groups []string
+	// Name of the ClusterRole that is bound to the user. No role is bound when empty.
+	Role string
+	// Namespace where the role is bound to the user with a RoleBinding.
+	// When empty, the role is bound in all namespaces with a ClusterRoleBinding.
+	Namespace string
+}
+
 // DockerRegistry details for docker registry, either confgiured by the cli or customer provided
 type DockerRegistry struct {
 	// The hostname or IP address and port of a private container image registry.
@@ -954,7 +1020,7 @@ func (node Node) certSpecs(plan Plan, ca *tls.CA) ([]certificateSpec, error) {
 		})
 	}
 
-	return m, nil
+	return withKey(m, plan.Cluster.Certificates.key()), nil
 }
 
 // returns a list of cert specs for the cluster described in the plan file
@@ -1015,5 +1081,10 @@ func (plan Plan) certSpecs(clusterCA *tls.CA, proxyClientCA *tls.CA) ([]certific
 		ca:            clusterCA,
 	})
 
-	return m, nil
+	// User certificates
+	for _, u := range plan.Users {
+		m = append(m, userCertSpec(u, clusterCA))
+	}
+
+	return withKey(m, plan.Cluster.Certificates.key()), nil
 }
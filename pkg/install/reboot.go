@@ -0,0 +1,44 @@
+package install
+
+import "fmt"
+
+// RebootOptions control how the nodes of the cluster are rebooted
+type RebootOptions struct {
+	// MaxParallelWorkers is the maximum number of worker nodes that are rebooted at the same time.
+	// Etcd, master, ingress and storage nodes are always rebooted one at a time.
+	MaxParallelWorkers int
+	// Command is run on each node once it has been drained, before the node is rebooted
+	Command string
+}
+
+// SelectNodes returns the nodes of the plan that have any of the roles, or that
+// are in the list of hosts. All the nodes are returned if no roles and no hosts are given.
+func SelectNodes(plan Plan, withRoles []string, hosts []string) ([]ListableNode, error) {
+	for _, r := range withRoles {
+		if !plan.ValidRole(r) {
+			return nil, fmt.Errorf("invalid role %q, options %v", r, roles())
+		}
+	}
+	for _, h := range hosts {
+		if !plan.HostExists(h) {
+			return nil, fmt.Errorf("node %q is not in the plan file", h)
+		}
+	}
+	nodes := []ListableNode{}
+	for _, n := range plan.GetUniqueNodes() {
+		nodeRoles := plan.GetRolesForIP(n.IP)
+		selected := len(withRoles) == 0 && len(hosts) == 0
+		for _, r := range withRoles {
+			if contains(r, nodeRoles) {
+				selected = true
+			}
+		}
+		if contains(n.Host, hosts) {
+			selected = true
+		}
+		if selected {
+			nodes = append(nodes, ListableNode{Node: n, Roles: nodeRoles})
+		}
+	}
+	return nodes, nil
+}
@@ -0,0 +1,57 @@
+package install
+
+import (
+	"reflect"
+	"testing"
+)
+
+func rebootTestPlan() Plan {
+	plan := Plan{}
+	plan.Etcd.Nodes = []Node{{Host: "etcd01", IP: "10.0.0.1"}}
+	plan.Master.Nodes = []Node{{Host: "master01", IP: "10.0.0.2"}}
+	plan.Worker.Nodes = []Node{{Host: "worker01", IP: "10.0.0.3"}, {Host: "worker02", IP: "10.0.0.4"}}
+	plan.Ingress.Nodes = []Node{{Host: "worker02", IP: "10.0.0.4"}}
+	return plan
+}
+
+func TestSelectNodes(t *testing.T) {
+	tests := []struct {
+		roles    []string
+		hosts    []string
+		expected []string
+	}{
+		{expected: []string{"etcd01", "master01", "worker01", "worker02"}},
+		{roles: []string{"worker"}, expected: []string{"worker01", "worker02"}},
+		{roles: []string{"ingress"}, expected: []string{"worker02"}},
+		{roles: []string{"etcd"}, hosts: []string{"worker01"}, expected: []string{"etcd01", "worker01"}},
+		{roles: []string{"storage"}, expected: []string{}},
+	}
+	for i, test := range tests {
+		nodes, err := SelectNodes(rebootTestPlan(), test.roles, test.hosts)
+		if err != nil {
+			t.Errorf("test %d: unexpected error: %v", i, err)
+			continue
+		}
+		hosts := []string{}
+		for _, n := range nodes {
+			hosts = append(hosts, n.Node.Host)
+		}
+		if !reflect.DeepEqual(hosts, test.expected) {
+			t.Errorf("test %d: expected nodes %v, got %v", i, test.expected, hosts)
+		}
+	}
+
+	nodes, _ := SelectNodes(rebootTestPlan(), nil, []string{"worker02"})
+	if len(nodes) != 1 || !reflect.DeepEqual(nodes[0].Roles, []string{"worker", "ingress"}) {
+		t.Errorf("expected the roles of the node to be listed, got %+v", nodes)
+	}
+}
+
+func TestSelectNodesInvalid(t *testing.T) {
+	if _, err := SelectNodes(rebootTestPlan(), []string{"foo"}, nil); err == nil {
+		t.Errorf("expected an error with an invalid role, but didn't get one")
+	}
+	if _, err := SelectNodes(rebootTestPlan(), nil, []string{"worker03"}); err == nil {
+		t.Errorf("expected an error with a node that is not in the plan, but didn't get one")
+	}
+}
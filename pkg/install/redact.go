@@ -0,0 +1,93 @@
+package install
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+// minRedactedSecretLength is the length under which the secrets of the plan file are
+// not redacted where they appear on their own, as short values would match unrelated text.
+// They are still redacted when they are the value of a secret key, such as "password: ..."
+const minRedactedSecretLength = 4
+
+var (
+	// secretValue matches the value of keys and flags that hold secrets, such as
+	// "admin_password: ...", "--token=..." or "client-key-data: ..."
+	secretValue = regexp.MustCompile(`(?i)([a-z0-9_.-]*(?:password|passwd|token|secret|key-data)["']?\s*[:=]\s*["']?)([^\s"',]+)`)
+	bearerToken = regexp.MustCompile(`(?i)(bearer\s+)[a-z0-9._~+/-]+=*`)
+	beginPEMKey = regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----`)
+	endPEMKey   = regexp.MustCompile(`-----END [A-Z ]*PRIVATE KEY-----`)
+)
+
+// Redactor removes secrets, such as passwords, tokens and private keys, from text
+type Redactor struct {
+	secrets []string
+}
+
+// NewRedactor returns a redactor that also removes the passwords of the plan
+func NewRedactor(plan Plan) Redactor {
+	r := Redactor{}
+	values := []string{plan.Cluster.AdminPassword, plan.DockerRegistry.Password}
+	if plan.AddOns.CNI != nil {
+		values = append(values, plan.AddOns.CNI.Options.Weave.Password)
+	}
+	for _, v := range values {
+		if len(v) >= minRedactedSecretLength {
+			r.secrets = append(r.secrets, v)
+		}
+	}
+	return r
+}
+
+// Redact copies the text from the reader to the writer, removing the secrets it contains.
+// Private keys in PEM format are removed entirely.
+func (r Redactor) Redact(w io.Writer, in io.Reader) error {
+	br := bufio.NewReader(in)
+	inKey := false
+	for {
+		line, err := br.ReadString('\n')
+		if line != "" {
+			out := ""
+			switch {
+			case inKey:
+				if loc := endPEMKey.FindStringIndex(line); loc != nil {
+					inKey = false
+					out = r.RedactLine(line[loc[1]:])
+				}
+			case beginPEMKey.MatchString(line):
+				loc := beginPEMKey.FindStringIndex(line)
+				out = r.RedactLine(line[:loc[0]]) + "[REDACTED PRIVATE KEY]"
+				if end := endPEMKey.FindStringIndex(line[loc[1]:]); end != nil {
+					out += r.RedactLine(line[loc[1]+end[1]:])
+				} else {
+					// the rest of the line that ends the key is kept
+					inKey = true
+				}
+			default:
+				out = r.RedactLine(line)
+			}
+			if _, werr := io.WriteString(w, out); werr != nil {
+				return werr
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// RedactLine returns the line without the secrets it contains
+func (r Redactor) RedactLine(line string) string {
+	for _, s := range r.secrets {
+		line = strings.Replace(line, s, redacted, -1)
+	}
+	line = secretValue.ReplaceAllString(line, "${1}"+redacted)
+	return bearerToken.ReplaceAllString(line, "${1}"+redacted)
+}
@@ -0,0 +1,110 @@
+package install
+
+import (
+	"bytes"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/apprenda/kismatic/pkg/tls"
+	"github.com/apprenda/kismatic/pkg/util"
+)
+
+// RevokeCertificate revokes the certificate with the given name, by adding it to the
+// certificate revocation list of the cluster CA. The certificates of the plan file
+// cannot be revoked, as they are in use by the cluster.
+func (lp *LocalPKI) RevokeCertificate(p *Plan, name string) error {
+	if lp.Log == nil {
+		lp.Log = ioutil.Discard
+	}
+	entities, err := certificateEntities(*p)
+	if err != nil {
+		return err
+	}
+	if entity, ok := entities[name]; ok {
+		return fmt.Errorf("certificate %q belongs to the %s of the plan file, and cannot be revoked", name, entity)
+	}
+	dir := lp.GeneratedCertsDirectory
+	exists, err := fileExists(filepath.Join(dir, name+".pem"))
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("certificate %q was not found in %q", name+".pem", dir)
+	}
+	cert, err := tls.ReadCert(name, dir)
+	if err != nil {
+		return err
+	}
+	ca, err := lp.GetClusterCA()
+	if err != nil {
+		return err
+	}
+	caCert, err := tls.ReadCert(clusterCAName, dir)
+	if err != nil {
+		return err
+	}
+	if err = cert.CheckSignatureFrom(caCert); err != nil {
+		return fmt.Errorf("certificate %q was not issued by the cluster CA: %v", name, err)
+	}
+	crl, err := tls.ReadCRL(clusterCAName, dir, caCert)
+	if err != nil {
+		return err
+	}
+	if tls.IsRevoked(crl, cert) {
+		util.PrettyPrintWarn(lp.Log, "Certificate %q is already revoked", name)
+	}
+	expiry, err := time.ParseDuration(p.Cluster.Certificates.Expiry)
+	if err != nil {
+		return fmt.Errorf("%q is not a valid duration for certificate expiry", p.Cluster.Certificates.Expiry)
+	}
+	crlBytes, err := tls.RevokeCert(ca, crl, cert, time.Now(), expiry)
+	if err != nil {
+		return fmt.Errorf("error revoking certificate %q: %v", name, err)
+	}
+	if err = tls.WriteCRL(crlBytes, clusterCAName, dir); err != nil {
+		return err
+	}
+	util.PrettyPrintOk(lp.Log, "Revoked certificate %q", name)
+	return nil
+}
+
+// clusterCRL returns the certificate revocation list of the cluster CA, along with
+// the certificate of the CA. The CRL is nil when no certificate has been revoked.
+func clusterCRL(certsDir string) (*x509.Certificate, *pkix.CertificateList, error) {
+	exists, err := fileExists(filepath.Join(certsDir, tls.CRLName(clusterCAName)))
+	if err != nil || !exists {
+		return nil, nil, err
+	}
+	caCert, err := tls.ReadCert(clusterCAName, certsDir)
+	if err != nil {
+		return nil, nil, err
+	}
+	crl, err := tls.ReadCRL(clusterCAName, certsDir, caCert)
+	if err != nil {
+		return nil, nil, err
+	}
+	return caCert, crl, nil
+}
+
+// revokedByCA returns true if the certificate was issued by the CA, and revoked by its CRL
+func revokedByCA(cert *x509.Certificate, caCert *x509.Certificate, crl *pkix.CertificateList) bool {
+	if caCert == nil || !bytes.Equal(cert.RawIssuer, caCert.RawSubject) {
+		return false
+	}
+	return tls.IsRevoked(crl, cert)
+}
+
+// deployedCRLFile returns the CRL of the cluster CA that is deployed to the nodes,
+// or an empty string when no certificate has been revoked
+func deployedCRLFile(certsDir string) string {
+	f := tls.CRLName(clusterCAName)
+	if _, err := os.Stat(filepath.Join(certsDir, f)); err != nil {
+		return ""
+	}
+	return f
+}
@@ -0,0 +1,80 @@
+package install
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRevokeCertificate(t *testing.T) {
+	pki := getPKI(t)
+	defer cleanup(pki.GeneratedCertsDirectory, t)
+	p := getPlan()
+	ca, err := pki.GenerateClusterCA(p)
+	if err != nil {
+		t.Fatalf("error generating CA for test: %v", err)
+	}
+	proxyClientCA, err := pki.GenerateProxyClientCA(p)
+	if err != nil {
+		t.Fatalf("error generating proxy-client CA for test: %v", err)
+	}
+	if err = pki.GenerateClusterCertificates(p, ca, proxyClientCA); err != nil {
+		t.Fatalf("error generating cluster certificates: %v", err)
+	}
+	if _, err = pki.GenerateCertificate(p, "alice", "1h", "alice", nil, []string{"dev"}, ca, false); err != nil {
+		t.Fatalf("error generating certificate: %v", err)
+	}
+	if f := deployedCRLFile(pki.GeneratedCertsDirectory); f != "" {
+		t.Errorf("expected no CRL to be deployed, got %q", f)
+	}
+
+	if err = pki.RevokeCertificate(p, "alice"); err != nil {
+		t.Fatalf("error revoking certificate: %v", err)
+	}
+	if f := deployedCRLFile(pki.GeneratedCertsDirectory); f != "ca.crl" {
+		t.Errorf("expected the CRL to be deployed, got %q", f)
+	}
+	certs, err := ListCertificates(*p, pki.GeneratedCertsDirectory, CertificateListOptions{Now: time.Now()})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, c := range certs {
+		if c.Name == "alice" && c.Status != CertificateRevoked {
+			t.Errorf("expected the certificate to be revoked, got %q", c.Status)
+		}
+		if c.Name != "alice" && c.Status == CertificateRevoked {
+			t.Errorf("expected certificate %q not to be revoked", c.Name)
+		}
+	}
+
+	// The certificates of the plan file cannot be revoked
+	if err = pki.RevokeCertificate(p, "worker01-kubelet"); err == nil {
+		t.Errorf("expected an error revoking the certificate of a node")
+	}
+	if err = pki.RevokeCertificate(p, "bob"); err == nil {
+		t.Errorf("expected an error revoking a certificate that does not exist")
+	}
+}
+
+func TestRevokeCertificateNotIssuedByClusterCA(t *testing.T) {
+	pki := getPKI(t)
+	defer cleanup(pki.GeneratedCertsDirectory, t)
+	p := getPlan()
+	if _, err := pki.GenerateClusterCA(p); err != nil {
+		t.Fatalf("error generating CA for test: %v", err)
+	}
+	proxyClientCA, err := pki.GenerateProxyClientCA(p)
+	if err != nil {
+		t.Fatalf("error generating proxy-client CA for test: %v", err)
+	}
+	if _, err = pki.GenerateCertificate(p, "alice", "1h", "alice", nil, nil, proxyClientCA, false); err != nil {
+		t.Fatalf("error generating certificate: %v", err)
+	}
+	if err = pki.RevokeCertificate(p, "alice"); err == nil {
+		t.Errorf("expected an error revoking a certificate of the proxy-client CA")
+	}
+	if _, err = os.Stat(filepath.Join(pki.GeneratedCertsDirectory, "ca.crl")); !os.IsNotExist(err) {
+		t.Errorf("expected no CRL to be written")
+	}
+}
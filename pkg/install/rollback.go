@@ -0,0 +1,55 @@
+package install
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// rollbackHistoryFile is the file in the runs directory that keeps the record of rollbacks
+const rollbackHistoryFile = "rollbacks.yaml"
+
+// NodeRollback is the record of nodes that were rolled back after their upgrade failed
+type NodeRollback struct {
+	Time         time.Time `yaml:"time"`
+	Nodes        []string  `yaml:"nodes"`
+	UpgradeError string    `yaml:"upgrade_error"`
+	Succeeded    bool      `yaml:"succeeded"`
+	Error        string    `yaml:"error,omitempty"`
+}
+
+// readRollbackHistory returns the rollbacks recorded in the file
+func readRollbackHistory(file string) ([]NodeRollback, error) {
+	history := []NodeRollback{}
+	b, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return history, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading rollback history %q: %v", file, err)
+	}
+	if err = yaml.Unmarshal(b, &history); err != nil {
+		return nil, fmt.Errorf("error unmarshalling rollback history from %q: %v", file, err)
+	}
+	return history, nil
+}
+
+// recordRollback appends the rollback to the history kept in the file
+func recordRollback(file string, r NodeRollback) error {
+	history, err := readRollbackHistory(file)
+	if err != nil {
+		return err
+	}
+	history = append(history, r)
+	b, err := yaml.Marshal(history)
+	if err != nil {
+		return fmt.Errorf("error marshalling rollback history: %v", err)
+	}
+	if err = ioutil.WriteFile(file, b, 0644); err != nil {
+		return fmt.Errorf("error writing rollback history to %q: %v", file, err)
+	}
+	return nil
+}
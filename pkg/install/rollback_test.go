@@ -0,0 +1,44 @@
+package install
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRecordRollback(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rollback-history")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, rollbackHistoryFile)
+
+	history, err := readRollbackHistory(file)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(history) != 0 {
+		t.Errorf("expected empty history, got %v", history)
+	}
+
+	records := []NodeRollback{
+		{Time: time.Date(2018, 4, 1, 10, 0, 0, 0, time.UTC), Nodes: []string{"worker01"}, UpgradeError: "error running playbook", Succeeded: true},
+		{Time: time.Date(2018, 4, 1, 11, 0, 0, 0, time.UTC), Nodes: []string{"worker02", "worker03"}, UpgradeError: "error running playbook", Error: "node is not Ready"},
+	}
+	for _, r := range records {
+		if err = recordRollback(file, r); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	history, err = readRollbackHistory(file)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(history, records) {
+		t.Errorf("expected history %+v, got %+v", records, history)
+	}
+}
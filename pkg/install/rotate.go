@@ -0,0 +1,320 @@
+package install
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/apprenda/kismatic/pkg/tls"
+	"github.com/apprenda/kismatic/pkg/util"
+)
+
+const (
+	// caBundleFilename is the bundle of the current and the next CA, which is deployed
+	// to the nodes instead of the CA while the CA is being rotated
+	caBundleFilename = "ca-bundle"
+	// caNextFilename is the CA that replaces the current CA once it is trusted by the nodes
+	caNextFilename = "ca-next"
+	// caPreviousFilename is the backup of the CA that was replaced
+	caPreviousFilename = "ca-previous"
+	// caRotationPendingFilename records the stage of the CA rotation that has not been deployed yet
+	caRotationPendingFilename = "ca-rotation-pending"
+)
+
+// The stages of the rotation of the cluster CA
+const (
+	// CARotationTrust generates the next CA, and deploys a bundle of the current and the next CA
+	CARotationTrust = "trust"
+	// CARotationSign replaces the CA with the next CA, and signs new certificates with it
+	CARotationSign = "sign"
+	// CARotationFinish stops trusting the replaced CA
+	CARotationFinish = "finish"
+)
+
+// rotatableComponents are the components whose certificates can be rotated
+var rotatableComponents = []string{"etcd", "apiserver", "kubelet", "controller-manager", "scheduler", "etcd-client", "apiserver-kubelet-client", "proxy-client", "admin", "service-account"}
+
+// RotateOptions select the certificates that are rotated
+type RotateOptions struct {
+	// Nodes limits the rotation to the certificates that belong to the nodes.
+	// Certificates that are shared by the nodes are not rotated when set.
+	Nodes []string
+	// Components limits the rotation to the certificates of the components.
+	// The service account certificate is only rotated when it is selected, as
+	// it invalidates the service account tokens of the cluster.
+	Components []string
+}
+
+// CertificatesToRotate returns the names of the certificates of the cluster that are selected for rotation
+func CertificatesToRotate(plan Plan, opts RotateOptions) ([]string, error) {
+	for _, c := range opts.Components {
+		if !contains(c, rotatableComponents) {
+			return nil, fmt.Errorf("invalid component %q, options %v", c, rotatableComponents)
+		}
+	}
+	for _, n := range opts.Nodes {
+		if !plan.HostExists(n) {
+			return nil, fmt.Errorf("node %q is not in the plan file", n)
+		}
+	}
+	manifest, err := plan.certSpecs(nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error determining the certificates of the cluster: %v", err)
+	}
+	names := []string{}
+	for _, s := range manifest {
+		component, host := certificateComponent(s.filename)
+		if !contains(component, rotatableComponents) {
+			continue
+		}
+		if len(opts.Components) == 0 && component == "service-account" {
+			continue
+		}
+		if len(opts.Components) > 0 && !contains(component, opts.Components) {
+			continue
+		}
+		if len(opts.Nodes) > 0 && !contains(host, opts.Nodes) {
+			continue
+		}
+		names = append(names, s.filename)
+	}
+	return names, nil
+}
+
+// certificateComponent returns the component that uses the certificate, and the
+// node the certificate belongs to, if any
+func certificateComponent(filename string) (component string, host string) {
+	switch filename {
+	case controllerManagerCertFilenamePrefix:
+		return "controller-manager", ""
+	case schedulerCertFilenamePrefix:
+		return "scheduler", ""
+	case serviceAccountCertFilename:
+		return "service-account", ""
+	case kubeAPIServerKubeletClientClientFilename:
+		return "apiserver-kubelet-client", ""
+	case proxyClientCertFilename:
+		return "proxy-client", ""
+	case adminCertFilename:
+		return "admin", ""
+	case "etcd-client":
+		return "etcd-client", ""
+	}
+	for _, c := range []string{"etcd", "apiserver", "kubelet"} {
+		if strings.HasSuffix(filename, "-"+c) {
+			return c, strings.TrimSuffix(filename, "-"+c)
+		}
+	}
+	return "", ""
+}
+
+// NodesWithCertificates returns the nodes on which any of the certificates are deployed
+func NodesWithCertificates(plan Plan, names []string) []ListableNode {
+	nodes := []ListableNode{}
+	for _, n := range plan.GetUniqueNodes() {
+		for _, d := range deployedCertificates(plan, n) {
+			if contains(d.name, names) {
+				nodes = append(nodes, ListableNode{Node: n, Roles: plan.GetRolesForIP(n.IP)})
+				break
+			}
+		}
+	}
+	return nodes
+}
+
+// RotateCertificates backs up the certificates with the given names, and generates
+// new certificates in their place, signed by the CAs
+func (lp *LocalPKI) RotateCertificates(p *Plan, names []string, clusterCA *tls.CA, proxyClientCA *tls.CA) error {
+	if lp.Log == nil {
+		lp.Log = ioutil.Discard
+	}
+	manifest, err := p.certSpecs(clusterCA, proxyClientCA)
+	if err != nil {
+		return err
+	}
+	for _, s := range manifest {
+		if !contains(s.filename, names) {
+			continue
+		}
+		if ok, err := lp.canSign(p, s.ca); err != nil || !ok {
+			if err != nil {
+				return err
+			}
+			return fmt.Errorf("the private key of the CA is not available to sign the certificate for %q", s.description)
+		}
+		if err := backupCertificate(s.filename, lp.GeneratedCertsDirectory); err != nil {
+			return err
+		}
+		if err := lp.issueCert(p, s); err != nil {
+			return err
+		}
+		util.PrettyPrintOk(lp.Log, "Rotated certificate for %s", s.description)
+	}
+	return nil
+}
+
+// backupCertificate renames the certificate and its key, replacing any previous backup
+func backupCertificate(name, dir string) error {
+	for _, f := range []string{name + ".pem", name + "-key.pem"} {
+		file := filepath.Join(dir, f)
+		if _, err := os.Stat(file); os.IsNotExist(err) {
+			continue
+		}
+		if err := os.Rename(file, file+".bak"); err != nil {
+			return fmt.Errorf("error backing up %q: %v", file, err)
+		}
+	}
+	return nil
+}
+
+// CARotationStage returns the next stage of the rotation of the cluster CA. A stage that
+// was performed, but not deployed to the nodes, is returned until it is completed.
+func (lp *LocalPKI) CARotationStage() (string, error) {
+	pending, err := ioutil.ReadFile(filepath.Join(lp.GeneratedCertsDirectory, caRotationPendingFilename))
+	if err == nil {
+		return strings.TrimSpace(string(pending)), nil
+	}
+	if !os.IsNotExist(err) {
+		return "", err
+	}
+	bundle, err := fileExists(filepath.Join(lp.GeneratedCertsDirectory, caBundleFilename+".pem"))
+	if err != nil {
+		return "", err
+	}
+	if !bundle {
+		return CARotationTrust, nil
+	}
+	next, err := tls.CertKeyPairExists(caNextFilename, lp.GeneratedCertsDirectory)
+	if err != nil {
+		return "", err
+	}
+	if next {
+		return CARotationSign, nil
+	}
+	return CARotationFinish, nil
+}
+
+// RotateClusterCA performs the next stage of the rotation of the cluster CA on the
+// generated assets, and returns the stage. The assets must be deployed to every node
+// of the cluster, and the stage completed, before the next stage is performed:
+//
+//   - trust: the next CA is generated, and the bundle of the current and the next CA is deployed as the CA of the nodes
+//   - sign: the current CA is replaced by the next CA, and the certificates of the cluster are signed by it.
+//     The service account certificate is not rotated, so that the service account tokens remain valid.
+//   - finish: the CA is deployed instead of the bundle, so that the replaced CA is no longer trusted
+func (lp *LocalPKI) RotateClusterCA(p *Plan, proxyClientCA *tls.CA) (string, error) {
+	if lp.Log == nil {
+		lp.Log = ioutil.Discard
+	}
+	stage, err := lp.CARotationStage()
+	if err != nil {
+		return "", fmt.Errorf("error determining the stage of the CA rotation: %v", err)
+	}
+	if b, err := lp.backend(p); err != nil || b != nil {
+		if err != nil {
+			return "", err
+		}
+		return "", fmt.Errorf("the cluster CA is held by the certificates backend, and must be rotated in the backend")
+	}
+	dir := lp.GeneratedCertsDirectory
+	intermediate, err := fileExists(filepath.Join(dir, caChainFilename+".pem"))
+	if err != nil {
+		return "", err
+	}
+	if intermediate {
+		return "", fmt.Errorf("the cluster CA is an intermediate CA, and must be rotated by replacing ca.pem, ca-key.pem and %s.pem", caChainFilename)
+	}
+	pendingFile := filepath.Join(dir, caRotationPendingFilename)
+	if ok, err := fileExists(pendingFile); err != nil || ok {
+		util.PrettyPrintWarn(lp.Log, "The %s stage of the CA rotation was not deployed to the nodes, deploying it again", stage)
+		return stage, err
+	}
+	switch stage {
+	case CARotationTrust:
+		key, cert, err := tls.NewCACertWithKey(lp.CACsr, p.Cluster.Name, p.Cluster.Certificates.CAExpiry, p.Cluster.Certificates.caKey())
+		if err != nil {
+			return "", fmt.Errorf("failed to create CA Cert: %v", err)
+		}
+		if err = tls.WriteCert(key, cert, caNextFilename, dir); err != nil {
+			return "", fmt.Errorf("error writing CA files: %v", err)
+		}
+		current, err := ioutil.ReadFile(filepath.Join(dir, "ca.pem"))
+		if err != nil {
+			return "", fmt.Errorf("error reading CA certificate: %v", err)
+		}
+		bundle := append(append(current, '\n'), cert...)
+		if err = ioutil.WriteFile(filepath.Join(dir, caBundleFilename+".pem"), bundle, 0644); err != nil {
+			return "", fmt.Errorf("error writing CA bundle: %v", err)
+		}
+		util.PrettyPrintOk(lp.Log, "Generated the next cluster Certificate Authority")
+	case CARotationSign:
+		for _, f := range []string{"", "-key"} {
+			if err := os.Rename(filepath.Join(dir, "ca"+f+".pem"), filepath.Join(dir, caPreviousFilename+f+".pem")); err != nil {
+				return "", fmt.Errorf("error backing up the CA: %v", err)
+			}
+			if err := os.Rename(filepath.Join(dir, caNextFilename+f+".pem"), filepath.Join(dir, "ca"+f+".pem")); err != nil {
+				return "", fmt.Errorf("error replacing the CA: %v", err)
+			}
+		}
+		// The CRL of the replaced CA is kept with it
+		crlFile := filepath.Join(dir, tls.CRLName(clusterCAName))
+		if err := os.Rename(crlFile, filepath.Join(dir, tls.CRLName(caPreviousFilename))); err != nil && !os.IsNotExist(err) {
+			return "", fmt.Errorf("error backing up the CRL: %v", err)
+		}
+		ca, err := lp.GetClusterCA()
+		if err != nil {
+			return "", err
+		}
+		names, err := CertificatesToRotate(*p, RotateOptions{})
+		if err != nil {
+			return "", err
+		}
+		if err := lp.RotateCertificates(p, names, ca, proxyClientCA); err != nil {
+			return "", err
+		}
+		util.PrettyPrintOk(lp.Log, "Replaced the cluster Certificate Authority")
+	case CARotationFinish:
+		if err := os.Remove(filepath.Join(dir, caBundleFilename+".pem")); err != nil {
+			return "", fmt.Errorf("error removing CA bundle: %v", err)
+		}
+		util.PrettyPrintOk(lp.Log, "Removed the replaced cluster Certificate Authority from the CA bundle")
+	}
+	if err := ioutil.WriteFile(pendingFile, []byte(stage+"\n"), 0644); err != nil {
+		return "", fmt.Errorf("error recording the stage of the CA rotation: %v", err)
+	}
+	return stage, nil
+}
+
+// CompleteCARotationStage records that the stage of the CA rotation was deployed to the nodes
+func (lp *LocalPKI) CompleteCARotationStage() error {
+	err := os.Remove(filepath.Join(lp.GeneratedCertsDirectory, caRotationPendingFilename))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("error recording the stage of the CA rotation: %v", err)
+	}
+	return nil
+}
+
+// deployedCAFile returns the file of the certificates directory that is deployed
+// as the CA of the nodes. The CA bundle is deployed while the CA is being rotated,
+// and the trust bundle when the cluster CA is an intermediate CA.
+func deployedCAFile(certsDir string) string {
+	for _, f := range []string{caBundleFilename, caTrustFilename} {
+		if ok, _ := fileExists(filepath.Join(certsDir, f+".pem")); ok {
+			return f + ".pem"
+		}
+	}
+	return "ca.pem"
+}
+
+func fileExists(file string) (bool, error) {
+	_, err := os.Stat(file)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,207 @@
+package install
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/apprenda/kismatic/pkg/tls"
+)
+
+func rotatePlan() *Plan {
+	p := &Plan{}
+	p.Cluster.Name = "test"
+	p.Cluster.Certificates.Expiry = "1h"
+	p.Cluster.Networking.ServiceCIDRBlock = "10.0.0.0/24"
+	p.AddOns.CNI = &CNI{Provider: cniProviderCalico}
+	p.Etcd.Nodes = []Node{{Host: "etcd01", IP: "10.0.0.1"}}
+	p.Master.Nodes = []Node{{Host: "master01", IP: "10.0.0.2"}}
+	p.Worker.Nodes = []Node{{Host: "worker01", IP: "10.0.0.3"}, {Host: "worker02", IP: "10.0.0.4"}}
+	return p
+}
+
+func generateRotateCertificates(t *testing.T, pki LocalPKI, p *Plan) (*tls.CA, *tls.CA) {
+	ca, err := pki.GenerateClusterCA(p)
+	if err != nil {
+		t.Fatalf("error generating CA for test: %v", err)
+	}
+	proxyClientCA, err := pki.GenerateProxyClientCA(p)
+	if err != nil {
+		t.Fatalf("error generating proxy-client CA for test: %v", err)
+	}
+	if err = pki.GenerateClusterCertificates(p, ca, proxyClientCA); err != nil {
+		t.Fatalf("error generating cluster certificates: %v", err)
+	}
+	return ca, proxyClientCA
+}
+
+func TestCertificatesToRotate(t *testing.T) {
+	tests := []struct {
+		opts     RotateOptions
+		expected []string
+	}{
+		{
+			opts:     RotateOptions{},
+			expected: []string{"etcd01-etcd", "master01-apiserver", "kube-controller-manager", "kube-scheduler", "master01-kubelet", "etcd-client", "worker01-kubelet", "worker02-kubelet", "apiserver-kubelet-client", "proxy-client", "admin"},
+		},
+		{
+			opts:     RotateOptions{Nodes: []string{"master01", "worker02"}},
+			expected: []string{"master01-apiserver", "master01-kubelet", "worker02-kubelet"},
+		},
+		{
+			opts:     RotateOptions{Components: []string{"kubelet", "service-account"}},
+			expected: []string{"service-account", "master01-kubelet", "worker01-kubelet", "worker02-kubelet"},
+		},
+		{
+			opts:     RotateOptions{Nodes: []string{"worker01"}, Components: []string{"kubelet"}},
+			expected: []string{"worker01-kubelet"},
+		},
+		{
+			opts:     RotateOptions{Nodes: []string{"worker01"}, Components: []string{"admin"}},
+			expected: []string{},
+		},
+	}
+	for _, test := range tests {
+		names, err := CertificatesToRotate(*rotatePlan(), test.opts)
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+			continue
+		}
+		if strings.Join(names, ",") != strings.Join(test.expected, ",") {
+			t.Errorf("%+v: expected %v, got %v", test.opts, test.expected, names)
+		}
+	}
+	if _, err := CertificatesToRotate(*rotatePlan(), RotateOptions{Components: []string{"foo"}}); err == nil {
+		t.Errorf("expected an error for an invalid component")
+	}
+	if _, err := CertificatesToRotate(*rotatePlan(), RotateOptions{Nodes: []string{"foo"}}); err == nil {
+		t.Errorf("expected an error for a node that is not in the plan")
+	}
+}
+
+func TestNodesWithCertificates(t *testing.T) {
+	tests := []struct {
+		names    []string
+		expected []string
+	}{
+		{[]string{"worker01-kubelet"}, []string{"worker01"}},
+		{[]string{"etcd-client"}, []string{"etcd01", "master01", "worker01", "worker02"}},
+		{[]string{"kube-scheduler", "etcd01-etcd"}, []string{"etcd01", "master01"}},
+	}
+	for _, test := range tests {
+		hosts := []string{}
+		for _, n := range NodesWithCertificates(*rotatePlan(), test.names) {
+			hosts = append(hosts, n.Node.Host)
+		}
+		if strings.Join(hosts, ",") != strings.Join(test.expected, ",") {
+			t.Errorf("%v: expected %v, got %v", test.names, test.expected, hosts)
+		}
+	}
+}
+
+func TestRotateCertificates(t *testing.T) {
+	pki := getPKI(t)
+	defer cleanup(pki.GeneratedCertsDirectory, t)
+	p := rotatePlan()
+	ca, proxyClientCA := generateRotateCertificates(t, pki, p)
+	before := mustReadCertFile(filepath.Join(pki.GeneratedCertsDirectory, "worker01-kubelet.pem"), t)
+	other := mustReadCertFile(filepath.Join(pki.GeneratedCertsDirectory, "worker02-kubelet.pem"), t)
+
+	if err := pki.RotateCertificates(p, []string{"worker01-kubelet"}, ca, proxyClientCA); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	after := mustReadCertFile(filepath.Join(pki.GeneratedCertsDirectory, "worker01-kubelet.pem"), t)
+	if after.Equal(before) {
+		t.Errorf("expected the certificate to be rotated")
+	}
+	if after.Subject.CommonName != before.Subject.CommonName {
+		t.Errorf("expected common name %q, got %q", before.Subject.CommonName, after.Subject.CommonName)
+	}
+	backup := mustReadCertFile(filepath.Join(pki.GeneratedCertsDirectory, "worker01-kubelet.pem.bak"), t)
+	if !backup.Equal(before) {
+		t.Errorf("expected the previous certificate to be backed up")
+	}
+	if _, err := os.Stat(filepath.Join(pki.GeneratedCertsDirectory, "worker01-kubelet-key.pem.bak")); err != nil {
+		t.Errorf("expected the previous key to be backed up: %v", err)
+	}
+	if !mustReadCertFile(filepath.Join(pki.GeneratedCertsDirectory, "worker02-kubelet.pem"), t).Equal(other) {
+		t.Errorf("expected the certificate of worker02 not to be rotated")
+	}
+}
+
+func TestRotateClusterCA(t *testing.T) {
+	pki := getPKI(t)
+	defer cleanup(pki.GeneratedCertsDirectory, t)
+	p := rotatePlan()
+	_, proxyClientCA := generateRotateCertificates(t, pki, p)
+	dir := pki.GeneratedCertsDirectory
+	oldCA := mustReadCertFile(filepath.Join(dir, "ca.pem"), t)
+	serviceAccount := mustReadCertFile(filepath.Join(dir, "service-account.pem"), t)
+
+	// trust
+	stage, err := pki.RotateClusterCA(p, proxyClientCA)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stage != CARotationTrust {
+		t.Fatalf("expected stage %q, got %q", CARotationTrust, stage)
+	}
+	if f := deployedCAFile(dir); f != "ca-bundle.pem" {
+		t.Errorf("expected the CA bundle to be deployed, got %q", f)
+	}
+	bundle, err := ioutil.ReadFile(filepath.Join(dir, "ca-bundle.pem"))
+	if err != nil {
+		t.Fatalf("error reading CA bundle: %v", err)
+	}
+	if n := strings.Count(string(bundle), "BEGIN CERTIFICATE"); n != 2 {
+		t.Errorf("expected 2 certificates in the CA bundle, got %d", n)
+	}
+	// the stage is deployed again until it is completed
+	if stage, err = pki.RotateClusterCA(p, proxyClientCA); err != nil || stage != CARotationTrust {
+		t.Fatalf("expected stage %q to be deployed again, got %q: %v", CARotationTrust, stage, err)
+	}
+	if err = pki.CompleteCARotationStage(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	newCA := mustReadCertFile(filepath.Join(dir, "ca-next.pem"), t)
+
+	// sign
+	if stage, err = pki.RotateClusterCA(p, proxyClientCA); err != nil || stage != CARotationSign {
+		t.Fatalf("expected stage %q, got %q: %v", CARotationSign, stage, err)
+	}
+	if err = pki.CompleteCARotationStage(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !mustReadCertFile(filepath.Join(dir, "ca.pem"), t).Equal(newCA) {
+		t.Errorf("expected the CA to be replaced")
+	}
+	if !mustReadCertFile(filepath.Join(dir, "ca-previous.pem"), t).Equal(oldCA) {
+		t.Errorf("expected the previous CA to be backed up")
+	}
+	kubelet := mustReadCertFile(filepath.Join(dir, "worker01-kubelet.pem"), t)
+	if err = kubelet.CheckSignatureFrom(newCA); err != nil {
+		t.Errorf("expected the certificates to be signed by the new CA: %v", err)
+	}
+	if !mustReadCertFile(filepath.Join(dir, "service-account.pem"), t).Equal(serviceAccount) {
+		t.Errorf("expected the service account certificate not to be rotated")
+	}
+	if f := deployedCAFile(dir); f != "ca-bundle.pem" {
+		t.Errorf("expected the CA bundle to be deployed, got %q", f)
+	}
+
+	// finish
+	if stage, err = pki.RotateClusterCA(p, proxyClientCA); err != nil || stage != CARotationFinish {
+		t.Fatalf("expected stage %q, got %q: %v", CARotationFinish, stage, err)
+	}
+	if f := deployedCAFile(dir); f != "ca.pem" {
+		t.Errorf("expected the CA to be deployed, got %q", f)
+	}
+	if err = pki.CompleteCARotationStage(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stage, err = pki.CARotationStage(); err != nil || stage != CARotationTrust {
+		t.Errorf("expected a new rotation to start with stage %q, got %q: %v", CARotationTrust, stage, err)
+	}
+}
@@ -2,6 +2,7 @@ package install
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 
@@ -18,6 +19,15 @@ type upgradeKubeInfoClient interface {
 	data.PersistentVolumeClaimGetter
 	data.PersistentVolumeGetter
 	data.StatefulSetGetter
+	data.DeploymentGetter
+	data.PodDisruptionBudgetLister
+	data.NodeLister
+}
+
+type upgradeGlusterInfoClient interface {
+	data.GlusterClient
+	data.GlusterVolumeStatusLister
+	data.GlusterHealInfoGetter
 }
 
 type etcdNodeCountErr struct{}
@@ -41,16 +51,49 @@ func (e masterNodeLoadBalancingErr) Error() string {
 		"Upgrading it may make the cluster unavailable"
 }
 
-type ingressNotSupportedErr struct{}
+type ingressNodeCountErr struct{}
+
+func (e ingressNodeCountErr) Error() string {
+	return "This is the only ingress node in the cluster. " +
+		"Upgrading it will make the ingress point unavailable."
+}
+
+type ingressNodesNotReadyErr struct {
+	nodes []string
+}
+
+func (e ingressNodesNotReadyErr) Error() string {
+	return fmt.Sprintf("None of the other ingress nodes (%s) are Ready. ", strings.Join(e.nodes, ", ")) +
+		"Upgrading this node will make the ingress point unavailable."
+}
+
+type glusterVolumeUnhealthyErr struct {
+	volume string
+	reason string
+}
+
+func (e glusterVolumeUnhealthyErr) Error() string {
+	return fmt.Sprintf("Storage volume %q is not healthy: %s. ", e.volume, e.reason) +
+		"Upgrading a storage node may make the volume unavailable."
+}
+
+type glusterPendingHealErr struct {
+	volume  string
+	entries int
+}
 
-func (e ingressNotSupportedErr) Error() string {
-	return "Upgrading this node may result in storage volumes becoming temporarily unavailable."
+func (e glusterPendingHealErr) Error() string {
+	return fmt.Sprintf("Storage volume %q has %d entries pending heal. ", e.volume, e.entries) +
+		"Upgrading a storage node before the volume is healed may result in data loss."
 }
 
-type storageNotSupportedErr struct{}
+type glusterNoReplicaErr struct {
+	volume string
+}
 
-func (e storageNotSupportedErr) Error() string {
-	return "Upgrading this node may result in service unavailability if clients are accessing services directly through this ingress point."
+func (e glusterNoReplicaErr) Error() string {
+	return fmt.Sprintf("Storage volume %q has a brick on this node that is not replicated to another available node. ", e.volume) +
+		"Upgrading this node will make the volume temporarily unavailable."
 }
 
 type workerNodeCountErr struct{}
@@ -133,10 +176,136 @@ func (e podRunningJobErr) Error() string {
 	return fmt.Sprintf(`Pod that belongs to job "%s/%s" is running on this node.`, e.name, e.namespace)
 }
 
+type podDisruptionBudgetErr struct {
+	namespace string
+	name      string
+	pods      int32
+	allowed   int32
+}
+
+func (e podDisruptionBudgetErr) Error() string {
+	return fmt.Sprintf(`Draining this node would evict %d pod(s) covered by PodDisruptionBudget "%s/%s", `+
+		"which currently allows %d disruption(s).", e.pods, e.namespace, e.name, e.allowed)
+}
+
+type podDisruptionBudgetConcurrentErr struct {
+	namespace string
+	name      string
+	pods      int32
+	allowed   int32
+	nodes     []string
+}
+
+func (e podDisruptionBudgetConcurrentErr) Error() string {
+	return fmt.Sprintf(`Draining this node would evict %d pod(s) covered by PodDisruptionBudget "%s/%s". `+
+		"The budget allows %d disruption(s), which are shared with node(s) %s that are upgraded at the same time.",
+		e.pods, e.namespace, e.name, e.allowed, strings.Join(e.nodes, ", "))
+}
+
+// disruptionHeadroom keeps track of the disruptions allowed by the PodDisruptionBudgets
+// of the cluster, as the nodes that are upgraded at the same time are drained.
+type disruptionHeadroom struct {
+	budgets []data.PodDisruptionBudget
+	// disruptions used by the nodes that have been drained, keyed by budget
+	used map[string]int32
+	// the nodes that have used the disruptions, keyed by budget
+	usedBy map[string][]string
+}
+
+func newDisruptionHeadroom(budgets []data.PodDisruptionBudget) *disruptionHeadroom {
+	return &disruptionHeadroom{
+		budgets: budgets,
+		used:    map[string]int32{},
+		usedBy:  map[string][]string{},
+	}
+}
+
+// drain the pods from the node, and return an error for each budget that would be violated
+func (h *disruptionHeadroom) drain(node string, pods []data.Pod) []error {
+	errs := []error{}
+	for _, pdb := range h.budgets {
+		var count int32
+		for _, p := range pods {
+			// DaemonSet managed pods are not evicted when draining the node
+			if ref, err := podController(p); err == nil && ref != nil && strings.ToLower(ref.Kind) == "daemonset" {
+				continue
+			}
+			if p.Namespace == pdb.Namespace && pdb.Spec.Selector.Matches(p.Labels) {
+				count++
+			}
+		}
+		if count == 0 {
+			continue
+		}
+		key := pdb.Namespace + "/" + pdb.Name
+		allowed := pdb.Status.PodDisruptionsAllowed
+		switch {
+		case count > allowed:
+			errs = append(errs, podDisruptionBudgetErr{namespace: pdb.Namespace, name: pdb.Name, pods: count, allowed: allowed})
+		case h.used[key]+count > allowed:
+			errs = append(errs, podDisruptionBudgetConcurrentErr{namespace: pdb.Namespace, name: pdb.Name, pods: count, allowed: allowed, nodes: h.usedBy[key]})
+		}
+		h.used[key] += count
+		h.usedBy[key] = append(h.usedBy[key], node)
+	}
+	return errs
+}
+
+// DetectUpgradeSafety determines whether it's safe to upgrade the nodes, taking into
+// account the order in which the nodes are upgraded according to the upgrade strategy.
+// Nodes that are upgraded at the same time share the disruptions allowed by the
+// PodDisruptionBudgets of the cluster. The conditions that make the upgrade of a node
+// unsafe are returned as errors, keyed by the node's host.
+func DetectUpgradeSafety(plan Plan, nodes []ListableNode, strategy UpgradeStrategy, kubeClient upgradeKubeInfoClient, glusterClient upgradeGlusterInfoClient) (map[string][]error, error) {
+	batches, err := upgradeBatches(nodes, strategy)
+	if err != nil {
+		return nil, err
+	}
+	errs := map[string][]error{}
+	for _, batch := range batches {
+		headroom, err := getDisruptionHeadroom(kubeClient)
+		for _, n := range batch {
+			errs[n.Node.Host] = detectNodeUpgradeSafety(plan, n.Node, kubeClient, glusterClient, headroom, err)
+		}
+	}
+	return errs, nil
+}
+
+// upgradeBatches returns the nodes in the order they are upgraded. Etcd, master,
+// ingress and storage nodes are upgraded one at a time, and the rest of the nodes in batches.
+func upgradeBatches(nodes []ListableNode, strategy UpgradeStrategy) ([][]ListableNode, error) {
+	batches := [][]ListableNode{}
+	serial, workers := serialUpgradeNodes(nodes)
+	for _, n := range serial {
+		batches = append(batches, []ListableNode{n})
+	}
+	workerBatches, err := WorkerUpgradeBatches(workers, strategy, false)
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range workerBatches {
+		batches = append(batches, b.Nodes)
+	}
+	return batches, nil
+}
+
+func getDisruptionHeadroom(kubeClient upgradeKubeInfoClient) (*disruptionHeadroom, error) {
+	pdbs, err := kubeClient.ListPodDisruptionBudgets()
+	if err != nil || pdbs == nil {
+		return nil, fmt.Errorf("unable to determine node upgrade safety: failed to list PodDisruptionBudgets: %v", err)
+	}
+	return newDisruptionHeadroom(pdbs.Items), nil
+}
+
 // DetectNodeUpgradeSafety determines whether it's safe to upgrade a specific node
 // listed in the plan file. If any condition that could result in data or availability
 // loss is detected, the upgrade is deemed unsafe, and the conditions are returned as errors.
-func DetectNodeUpgradeSafety(plan Plan, node Node, kubeClient upgradeKubeInfoClient) []error {
+func DetectNodeUpgradeSafety(plan Plan, node Node, kubeClient upgradeKubeInfoClient, glusterClient upgradeGlusterInfoClient) []error {
+	headroom, err := getDisruptionHeadroom(kubeClient)
+	return detectNodeUpgradeSafety(plan, node, kubeClient, glusterClient, headroom, err)
+}
+
+func detectNodeUpgradeSafety(plan Plan, node Node, kubeClient upgradeKubeInfoClient, glusterClient upgradeGlusterInfoClient, headroom *disruptionHeadroom, headroomErr error) []error {
 	errs := []error{}
 	roles := plan.GetRolesForIP(node.IP)
 	for _, role := range roles {
@@ -154,18 +323,16 @@ func DetectNodeUpgradeSafety(plan Plan, node Node, kubeClient upgradeKubeInfoCli
 				errs = append(errs, masterNodeLoadBalancingErr{})
 			}
 		case "ingress":
-			// we don't control load balancing of ingress nodes. therefore,
-			// upgrading an ingress node is potentially unsafe
-			errs = append(errs, ingressNotSupportedErr{})
+			// we don't control load balancing of ingress nodes, but we can
+			// ensure that there are other ingress nodes ready to take the traffic
+			errs = append(errs, detectIngressNodeUpgradeSafety(plan, node, kubeClient)...)
 		case "storage":
-			// we could potentially detect safety of upgrading storage nodes by inspecting
-			// the volumes on the node. for now, we are choosing not to support online upgrade of storage nodes
-			errs = append(errs, storageNotSupportedErr{})
+			errs = append(errs, detectStorageNodeUpgradeSafety(node, glusterClient)...)
 		case "worker":
 			if plan.Worker.ExpectedCount < 2 {
 				errs = append(errs, workerNodeCountErr{})
 			}
-			if workerErrs := detectWorkerNodeUpgradeSafety(node, kubeClient); workerErrs != nil {
+			if workerErrs := detectWorkerNodeUpgradeSafety(node, kubeClient, headroom, headroomErr); workerErrs != nil {
 				errs = append(errs, workerErrs...)
 			}
 		}
@@ -173,7 +340,138 @@ func DetectNodeUpgradeSafety(plan Plan, node Node, kubeClient upgradeKubeInfoCli
 	return errs
 }
 
-func detectWorkerNodeUpgradeSafety(node Node, kubeClient upgradeKubeInfoClient) []error {
+func detectIngressNodeUpgradeSafety(plan Plan, node Node, kubeClient upgradeKubeInfoClient) []error {
+	others := []string{}
+	for _, n := range plan.Ingress.Nodes {
+		if !n.Equal(node) {
+			others = append(others, n.Host)
+		}
+	}
+	if len(others) == 0 {
+		return []error{ingressNodeCountErr{}}
+	}
+	nodeList, err := kubeClient.ListNodes()
+	if err != nil || nodeList == nil {
+		return []error{fmt.Errorf("unable to determine node upgrade safety: %v", err)}
+	}
+	for _, n := range nodeList.Items {
+		for _, other := range others {
+			if strings.EqualFold(n.Name, other) && n.Ready() {
+				return nil
+			}
+		}
+	}
+	return []error{ingressNodesNotReadyErr{nodes: others}}
+}
+
+func detectStorageNodeUpgradeSafety(node Node, glusterClient upgradeGlusterInfoClient) []error {
+	if glusterClient == nil {
+		return []error{errors.New("unable to determine node upgrade safety: a storage client is not available")}
+	}
+	volumeInfo, err := glusterClient.ListVolumes()
+	if err != nil {
+		return []error{fmt.Errorf("unable to determine node upgrade safety: %v", err)}
+	}
+	// there are no volumes in the cluster
+	if volumeInfo == nil {
+		return nil
+	}
+	volumeStatus, err := glusterClient.ListVolumeStatus()
+	if err != nil {
+		return []error{fmt.Errorf("unable to determine node upgrade safety: %v", err)}
+	}
+	onlineBricks := map[string]bool{}
+	if volumeStatus != nil {
+		for _, v := range volumeStatus.VolumeStatus.Volumes.Volume {
+			for _, n := range v.Nodes {
+				if n.IsBrick() && n.Online() {
+					onlineBricks[n.Hostname+":"+n.Path] = true
+				}
+			}
+		}
+	}
+
+	errs := []error{}
+	for _, v := range volumeInfo.VolumeInfo.Volumes.Volume {
+		// Is the volume started, and are all the bricks of the volume online?
+		if v.StatusStr != "" && v.StatusStr != "Started" {
+			errs = append(errs, glusterVolumeUnhealthyErr{volume: v.Name, reason: fmt.Sprintf("the volume is in the %q state", v.StatusStr)})
+			continue
+		}
+		bricks := []string{}
+		offline := []string{}
+		if v.Bricks != nil {
+			for _, b := range v.Bricks.Brick {
+				brick := strings.TrimSpace(b.Text)
+				bricks = append(bricks, brick)
+				if !onlineBricks[brick] {
+					offline = append(offline, brick)
+				}
+			}
+		}
+		if len(offline) > 0 {
+			errs = append(errs, glusterVolumeUnhealthyErr{volume: v.Name, reason: fmt.Sprintf("brick(s) %s are offline", strings.Join(offline, ", "))})
+		}
+		// Are there any entries pending heal?
+		healInfo, err := glusterClient.GetHealInfo(v.Name)
+		if err != nil || healInfo == nil {
+			errs = append(errs, fmt.Errorf("Failed to get the heal information of storage volume %q", v.Name))
+		} else if healInfo.HealInfo.Bricks != nil {
+			var pending int
+			for _, b := range healInfo.HealInfo.Bricks.Brick {
+				if n := b.PendingHeals(); n > 0 {
+					pending += n
+				}
+			}
+			if pending > 0 {
+				errs = append(errs, glusterPendingHealErr{volume: v.Name, entries: pending})
+			}
+		}
+		// Are the bricks on this node replicated to other nodes that are available?
+		if !glusterReplicaAvailable(node, int(v.ReplicaCount), bricks, onlineBricks) {
+			errs = append(errs, glusterNoReplicaErr{volume: v.Name})
+		}
+	}
+	return errs
+}
+
+// glusterReplicaAvailable returns true if each brick on the node has a replica that is
+// online on another node. The bricks of a volume are grouped into replica sets in the
+// order they are listed.
+func glusterReplicaAvailable(node Node, replicaCount int, bricks []string, onlineBricks map[string]bool) bool {
+	if replicaCount < 1 {
+		replicaCount = 1
+	}
+	for i := 0; i < len(bricks); i += replicaCount {
+		end := i + replicaCount
+		if end > len(bricks) {
+			end = len(bricks)
+		}
+		set := bricks[i:end]
+		onNode := false
+		available := false
+		for _, b := range set {
+			if brickOnNode(b, node) {
+				onNode = true
+				continue
+			}
+			if onlineBricks[b] {
+				available = true
+			}
+		}
+		if onNode && !available {
+			return false
+		}
+	}
+	return true
+}
+
+func brickOnNode(brick string, node Node) bool {
+	host := strings.Split(brick, ":")[0]
+	return strings.EqualFold(host, node.Host) || host == node.IP
+}
+
+func detectWorkerNodeUpgradeSafety(node Node, kubeClient upgradeKubeInfoClient, headroom *disruptionHeadroom, headroomErr error) []error {
 	errs := []error{}
 	podList, err := kubeClient.ListPods()
 	if err != nil || podList == nil {
@@ -216,11 +514,17 @@ func detectWorkerNodeUpgradeSafety(node Node, kubeClient upgradeKubeInfoClient)
 		}
 	}
 
-	// Keep track of how many pods managed by replication controllers and replicasets
-	// are running on this node. If all replicas are running on the node, we need to
+	// Would draining the node violate any PodDisruptionBudget?
+	if headroomErr != nil {
+		errs = append(errs, headroomErr)
+	} else {
+		errs = append(errs, headroom.drain(node.Host, nodePods)...)
+	}
+
+	// Keep track of how many pods managed by replicated controllers are running
+	// on this node. If all replicas are running on the node, we need to
 	// return an error, as it would take the workload down.
-	rcPods := map[string]int32{}
-	rsPods := map[string]int32{}
+	controllerPods := map[string]int32{}
 
 	// 1. Are there any pods running on this node that are not managed by a controller?
 	// 2. Are there any pods running on this node that are managed by a controller,
@@ -229,69 +533,97 @@ func detectWorkerNodeUpgradeSafety(node Node, kubeClient upgradeKubeInfoClient)
 	//    verify that it is not the only one
 	// 4. Are there any pods that belong to a job running on this node?
 	for _, p := range nodePods {
-		creator, ok := p.Annotations[kubeCreatedBy]
-		if !ok {
-			errs = append(errs, unmanagedPodErr{namespace: p.Namespace, name: p.Name})
+		ref, err := podController(p)
+		if err != nil {
+			errs = append(errs, err)
 			continue
 		}
-		var r data.SerializedReference
-		err := json.Unmarshal([]byte(creator), &r)
-		if err != nil {
-			errs = append(errs, fmt.Errorf("Unable to determine the creator of pod %s/%s", p.Namespace, p.Name))
+		if ref == nil {
+			errs = append(errs, unmanagedPodErr{namespace: p.Namespace, name: p.Name})
 			continue
 		}
-		switch strings.ToLower(r.Reference.Kind) {
+		var replicas int32
+		switch strings.ToLower(ref.Kind) {
 		default:
-			errs = append(errs, fmt.Errorf("Unable to determine upgrade safety for a pod managed by a controller of type %q", r.Reference.Kind))
+			errs = append(errs, fmt.Errorf("Unable to determine upgrade safety for a pod managed by a controller of type %q", ref.Kind))
+			continue
 		case "daemonset":
-			ds, err := kubeClient.GetDaemonSet(r.Reference.Namespace, r.Reference.Name)
+			ds, err := kubeClient.GetDaemonSet(ref.Namespace, ref.Name)
 			if err != nil || ds == nil {
-				errs = append(errs, fmt.Errorf("Failed to get information about DaemonSet %s/%s", r.Reference.Namespace, r.Reference.Name))
+				errs = append(errs, fmt.Errorf("Failed to get information about DaemonSet %s/%s", ref.Namespace, ref.Name))
 				continue
 			}
 			// Check if other nodes should be running this DS
 			if ds.Status.DesiredNumberScheduled < 2 {
-				errs = append(errs, podUnsafeDaemonErr{dsNamespace: r.Reference.Namespace, dsName: r.Reference.Name})
+				errs = append(errs, podUnsafeDaemonErr{dsNamespace: ref.Namespace, dsName: ref.Name})
 			}
+			continue
 		case "job":
-			errs = append(errs, podRunningJobErr{namespace: r.Reference.Namespace, name: r.Reference.Name})
+			errs = append(errs, podRunningJobErr{namespace: ref.Namespace, name: ref.Name})
+			continue
 		case "replicationcontroller":
-			rc, err := kubeClient.GetReplicationController(r.Reference.Namespace, r.Reference.Name)
+			rc, err := kubeClient.GetReplicationController(ref.Namespace, ref.Name)
 			if err != nil || rc == nil {
-				errs = append(errs, fmt.Errorf(`Failed to get information about ReplicationController "%s/%s"`, r.Reference.Namespace, r.Reference.Name))
+				errs = append(errs, fmt.Errorf(`Failed to get information about ReplicationController "%s/%s"`, ref.Namespace, ref.Name))
 				continue
 			}
-			if rc.Status.Replicas < 2 {
-				errs = append(errs, unsafeReplicaCountErr{kind: r.Reference.Kind, namespace: r.Reference.Namespace, name: r.Reference.Name})
-			}
-			rcPods[r.Reference.Namespace+r.Reference.Name]++
-			if rcPods[r.Reference.Namespace+r.Reference.Name] == rc.Status.Replicas {
-				errs = append(errs, replicasOnSingleNodeErr{kind: r.Reference.Kind, namespace: r.Reference.Namespace, name: r.Reference.Name})
-			}
+			replicas = rc.Status.Replicas
 		case "replicaset":
-			rs, err := kubeClient.GetReplicaSet(r.Reference.Namespace, r.Reference.Name)
+			rs, err := kubeClient.GetReplicaSet(ref.Namespace, ref.Name)
 			if err != nil || rs == nil {
-				errs = append(errs, fmt.Errorf(`Failed to get information about ReplicaSet "%s/%s"`, r.Reference.Namespace, r.Reference.Name))
+				errs = append(errs, fmt.Errorf(`Failed to get information about ReplicaSet "%s/%s"`, ref.Namespace, ref.Name))
 				continue
 			}
-			if rs.Status.Replicas < 2 {
-				errs = append(errs, unsafeReplicaCountErr{kind: r.Reference.Kind, namespace: r.Reference.Namespace, name: r.Reference.Name})
-			}
-			rsPods[r.Reference.Namespace+r.Reference.Name]++
-			if rsPods[r.Reference.Namespace+r.Reference.Name] == rs.Status.Replicas {
-				errs = append(errs, replicasOnSingleNodeErr{kind: r.Reference.Kind, namespace: r.Reference.Namespace, name: r.Reference.Name})
+			replicas = rs.Status.Replicas
+			// ReplicaSets that are managed by a Deployment are evaluated using the
+			// Deployment, as it might own multiple ReplicaSets during a rollout
+			if owner := rs.ControllerRef(); owner != nil && strings.ToLower(owner.Kind) == "deployment" {
+				d, err := kubeClient.GetDeployment(ref.Namespace, owner.Name)
+				if err != nil || d == nil {
+					errs = append(errs, fmt.Errorf(`Failed to get information about Deployment "%s/%s"`, ref.Namespace, owner.Name))
+					continue
+				}
+				ref = &data.ObjectReference{Kind: owner.Kind, Namespace: ref.Namespace, Name: owner.Name}
+				replicas = d.Status.Replicas
 			}
 		case "statefulset":
-			sts, err := kubeClient.GetStatefulSet(r.Reference.Namespace, r.Reference.Name)
+			sts, err := kubeClient.GetStatefulSet(ref.Namespace, ref.Name)
 			if err != nil || sts == nil {
-				errs = append(errs, fmt.Errorf(`Failed to get information about StatefulSet "%s/%s"`, r.Reference.Namespace, r.Reference.Name))
+				errs = append(errs, fmt.Errorf(`Failed to get information about StatefulSet "%s/%s"`, ref.Namespace, ref.Name))
 				continue
 			}
 			if sts.Status.Replicas < 2 {
-				errs = append(errs, unsafeReplicaCountErr{kind: r.Reference.Kind, namespace: r.Reference.Namespace, name: r.Reference.Name})
+				errs = append(errs, unsafeReplicaCountErr{kind: ref.Kind, namespace: ref.Namespace, name: ref.Name})
 			}
+			continue
+		}
+		if replicas < 2 {
+			errs = append(errs, unsafeReplicaCountErr{kind: ref.Kind, namespace: ref.Namespace, name: ref.Name})
+		}
+		key := ref.Kind + "/" + ref.Namespace + "/" + ref.Name
+		controllerPods[key]++
+		if controllerPods[key] == replicas {
+			errs = append(errs, replicasOnSingleNodeErr{kind: ref.Kind, namespace: ref.Namespace, name: ref.Name})
 		}
 	}
 
 	return errs
 }
+
+// podController returns a reference to the controller that manages the pod, or nil
+// if the pod is not managed by a controller. The owner references of the pod are used,
+// falling back to the created-by annotation set by older versions of Kubernetes.
+func podController(p data.Pod) (*data.ObjectReference, error) {
+	if owner := p.ControllerRef(); owner != nil {
+		return &data.ObjectReference{Kind: owner.Kind, Namespace: p.Namespace, Name: owner.Name}, nil
+	}
+	creator, ok := p.Annotations[kubeCreatedBy]
+	if !ok {
+		return nil, nil
+	}
+	var r data.SerializedReference
+	if err := json.Unmarshal([]byte(creator), &r); err != nil {
+		return nil, fmt.Errorf("Unable to determine the creator of pod %s/%s", p.Namespace, p.Name)
+	}
+	return &r.Reference, nil
+}
@@ -0,0 +1,245 @@
+package install
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/blang/semver"
+)
+
+// UpgradePath is the sequence of Kubernetes versions that a cluster must go through
+// to get from its current version to the target version
+type UpgradePath struct {
+	// Current is the lowest Kubernetes version running in the cluster
+	Current string
+	// Target is the Kubernetes version in the plan file
+	Target string
+	// Hops are the upgrades that must be performed, in order
+	Hops []UpgradeHop
+}
+
+// UpgradeHop is an upgrade from one Kubernetes version to the next
+type UpgradeHop struct {
+	From string
+	To   string
+	// Changes are the plan fields and option overrides in use that are deprecated
+	// or removed in the version of the hop
+	Changes []PlanFieldChange
+}
+
+// PlanFieldChange is a plan field or component option override that is
+// deprecated or removed in a Kubernetes release
+type PlanFieldChange struct {
+	// Field is the path of the plan field, or the option override
+	Field string
+	// Removed is true when the field is no longer supported, and must be removed
+	// from the plan before upgrading
+	Removed bool
+	// Message explains what to use instead
+	Message string
+}
+
+func (c PlanFieldChange) String() string {
+	state := "deprecated"
+	if c.Removed {
+		state = "removed"
+	}
+	return fmt.Sprintf("%s is %s: %s", c.Field, state, c.Message)
+}
+
+// kubernetesRelease is a minor release of Kubernetes that a cluster can be upgraded to
+type kubernetesRelease struct {
+	minor uint64
+	// the patch version that is installed when the release is an intermediate hop
+	version string
+	changes []planFieldChange
+}
+
+type planFieldChange struct {
+	PlanFieldChange
+	// returns true if the field is set in the plan
+	used func(p Plan) bool
+}
+
+// The minor releases of Kubernetes 1.x, in order. A cluster must go through each
+// minor release between its current version and the target version.
+var kubernetesReleases = []kubernetesRelease{
+	{
+		minor:   7,
+		version: "v1.7.16",
+	},
+	{
+		minor:   8,
+		version: "v1.8.11",
+		changes: []planFieldChange{
+			optionOverrideChange("kubelet", "api-servers", true, "use a kubeconfig file instead"),
+			optionOverrideChange("kubelet", "require-kubeconfig", false, "the kubeconfig file is always required"),
+			optionOverrideChange("kubelet", "experimental-bootstrap-kubeconfig", false, "use bootstrap-kubeconfig instead"),
+			optionOverrideChange("kube_apiserver", "experimental-bootstrap-token-auth", false, "use enable-bootstrap-token-auth instead"),
+		},
+	},
+	{
+		minor:   9,
+		version: kubernetesVersionString,
+		changes: []planFieldChange{
+			optionOverrideChange("kube_apiserver", "etcd-quorum-read", false, "quorum reads are always enabled"),
+			optionOverrideChange("kubelet", "enable-custom-metrics", false, "use the metrics server add-on instead"),
+			planChange("cluster.admin_password", false, "use RBAC instead of ABAC", func(p Plan) bool { return p.Cluster.AdminPassword != "" }),
+			planChange("cluster.allow_package_installation", false, "use cluster.disable_package_installation instead", func(p Plan) bool { return p.Cluster.AllowPackageInstallation != nil }),
+			planChange("cluster.networking.type", false, "use add_ons.cni.options.calico.mode instead", func(p Plan) bool { return p.Cluster.Networking.Type != "" }),
+			planChange("docker_registry.address", false, "use docker_registry.server instead", func(p Plan) bool { return p.DockerRegistry.Address != "" }),
+			planChange("features.package_manager", false, "use add_ons.package_manager instead", func(p Plan) bool { return p.Features != nil && p.Features.PackageManager != nil }),
+			planChange("add_ons.dashbard", false, "use add_ons.dashboard instead", func(p Plan) bool { return p.AddOns.DashboardDeprecated != nil }),
+			planChange("add_ons.heapster.options.heapster_replicas", false, "use add_ons.heapster.options.heapster.replicas instead", func(p Plan) bool {
+				return p.AddOns.HeapsterMonitoring != nil && p.AddOns.HeapsterMonitoring.Options.HeapsterReplicas != 0
+			}),
+			planChange("add_ons.heapster.options.influxdb_pvc_name", false, "use add_ons.heapster.options.influxdb.pvc_name instead", func(p Plan) bool {
+				return p.AddOns.HeapsterMonitoring != nil && p.AddOns.HeapsterMonitoring.Options.InfluxDBPVCName != ""
+			}),
+		},
+	},
+}
+
+func planChange(field string, removed bool, message string, used func(p Plan) bool) planFieldChange {
+	return planFieldChange{
+		PlanFieldChange: PlanFieldChange{Field: field, Removed: removed, Message: message},
+		used:            used,
+	}
+}
+
+func optionOverrideChange(component, option string, removed bool, message string) planFieldChange {
+	field := fmt.Sprintf("cluster.%s.option_overrides.%s", component, option)
+	return planChange(field, removed, message, func(p Plan) bool {
+		_, ok := componentOptionOverrides(p, component)[option]
+		return ok
+	})
+}
+
+func componentOptionOverrides(p Plan, component string) map[string]string {
+	switch component {
+	case "kube_apiserver":
+		return p.Cluster.APIServerOptions.Overrides
+	case "kube_controller_manager":
+		return p.Cluster.KubeControllerManagerOptions.Overrides
+	case "kube_scheduler":
+		return p.Cluster.KubeSchedulerOptions.Overrides
+	case "kube_proxy":
+		return p.Cluster.KubeProxyOptions.Overrides
+	case "kubelet":
+		return p.Cluster.KubeletOptions.Overrides
+	}
+	return nil
+}
+
+// PlanUpgradePath computes the Kubernetes versions that the cluster must be upgraded
+// to, in order, to reach the version in the plan file. Upgrades within the same
+// minor version are a single hop, while upgrades across minor versions go through
+// each minor version in between. A single hop is returned if the Kubernetes
+// version of the nodes is not known.
+func PlanUpgradePath(plan Plan, cv ClusterVersion) (*UpgradePath, error) {
+	target, err := parseKubernetesVersion(plan.Cluster.Version)
+	if err != nil {
+		return nil, err
+	}
+	path := &UpgradePath{
+		Current: plan.Cluster.Version,
+		Target:  plan.Cluster.Version,
+	}
+	current, found, err := lowestKubernetesVersion(cv)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		path.Hops = []UpgradeHop{{From: path.Current, To: path.Target, Changes: planChanges(plan, target.Minor)}}
+		return path, nil
+	}
+	path.Current = "v" + current.String()
+	if current.Major != target.Major || current.GT(target) {
+		return nil, fmt.Errorf("cannot upgrade from Kubernetes %s to %s", path.Current, path.Target)
+	}
+	if current.Minor == target.Minor {
+		path.Hops = []UpgradeHop{{From: path.Current, To: path.Target, Changes: planChanges(plan, target.Minor)}}
+		return path, nil
+	}
+	from := path.Current
+	for minor := current.Minor + 1; minor <= target.Minor; minor++ {
+		r, ok := kubernetesReleaseFor(minor)
+		if !ok {
+			return nil, fmt.Errorf("no supported upgrade path from Kubernetes %s to %s: v%d.%d is not a supported release", path.Current, path.Target, target.Major, minor)
+		}
+		to := r.version
+		if minor == target.Minor {
+			to = path.Target
+		}
+		path.Hops = append(path.Hops, UpgradeHop{From: from, To: to, Changes: planChanges(plan, minor)})
+		from = to
+	}
+	return path, nil
+}
+
+// Removed returns the fields in use that must be removed from the plan before
+// the hop can be performed
+func (h UpgradeHop) Removed() []PlanFieldChange {
+	removed := []PlanFieldChange{}
+	for _, c := range h.Changes {
+		if c.Removed {
+			removed = append(removed, c)
+		}
+	}
+	return removed
+}
+
+func kubernetesReleaseFor(minor uint64) (kubernetesRelease, bool) {
+	for _, r := range kubernetesReleases {
+		if r.minor == minor {
+			return r, true
+		}
+	}
+	return kubernetesRelease{}, false
+}
+
+// planChanges returns the changes of the release that apply to the plan
+func planChanges(plan Plan, minor uint64) []PlanFieldChange {
+	changes := []PlanFieldChange{}
+	r, ok := kubernetesReleaseFor(minor)
+	if !ok {
+		return changes
+	}
+	for _, c := range r.changes {
+		if c.used(plan) {
+			changes = append(changes, c.PlanFieldChange)
+		}
+	}
+	return changes
+}
+
+// lowestKubernetesVersion returns the lowest Kubernetes version running on the
+// nodes of the cluster. Etcd nodes and nodes without version information are ignored.
+func lowestKubernetesVersion(cv ClusterVersion) (semver.Version, bool, error) {
+	var lowest semver.Version
+	found := false
+	for _, n := range cv.Nodes {
+		if len(n.Roles) == 1 && n.Roles[0] == "etcd" {
+			continue
+		}
+		if strings.TrimSpace(n.ComponentVersions.Kubernetes) == "" {
+			continue
+		}
+		v, err := parseKubernetesVersion(n.ComponentVersions.Kubernetes)
+		if err != nil {
+			return lowest, false, fmt.Errorf("node %q: %v", n.Node.Host, err)
+		}
+		if !found || v.LT(lowest) {
+			lowest = v
+			found = true
+		}
+	}
+	return lowest, found, nil
+}
+
+func parseKubernetesVersion(version string) (semver.Version, error) {
+	if version == "" {
+		return semver.Version{}, fmt.Errorf("Kubernetes version is empty")
+	}
+	return parseVersion(strings.TrimSpace(version))
+}
@@ -0,0 +1,129 @@
+package install
+
+import (
+	"reflect"
+	"testing"
+)
+
+func upgradePathCluster(versions ...string) ClusterVersion {
+	cv := ClusterVersion{
+		Nodes: []ListableNode{
+			// etcd nodes do not run Kubernetes components
+			{Node: Node{Host: "etcd"}, Roles: []string{"etcd"}, ComponentVersions: ComponentVersions{Kubernetes: "v1.5.0"}},
+		},
+	}
+	for _, v := range versions {
+		cv.Nodes = append(cv.Nodes, ListableNode{Node: Node{Host: v}, Roles: []string{"worker"}, ComponentVersions: ComponentVersions{Kubernetes: v}})
+	}
+	return cv
+}
+
+func hopVersions(path *UpgradePath) [][]string {
+	hops := [][]string{}
+	for _, h := range path.Hops {
+		hops = append(hops, []string{h.From, h.To})
+	}
+	return hops
+}
+
+func TestPlanUpgradePath(t *testing.T) {
+	tests := []struct {
+		nodes   []string
+		current string
+		hops    [][]string
+	}{
+		{
+			nodes:   []string{"v1.9.2", "v1.9.6"},
+			current: "v1.9.2",
+			hops:    [][]string{{"v1.9.2", "v1.9.6"}},
+		},
+		{
+			nodes:   []string{"v1.9.2", "v1.8.4"},
+			current: "v1.8.4",
+			hops:    [][]string{{"v1.8.4", "v1.9.6"}},
+		},
+		{
+			nodes:   []string{"v1.7.5", "v1.8.4"},
+			current: "v1.7.5",
+			hops:    [][]string{{"v1.7.5", "v1.8.11"}, {"v1.8.11", "v1.9.6"}},
+		},
+		{
+			// the version of the nodes is not known
+			nodes:   []string{""},
+			current: "v1.9.6",
+			hops:    [][]string{{"v1.9.6", "v1.9.6"}},
+		},
+	}
+	for i, test := range tests {
+		plan := Plan{}
+		plan.Cluster.Version = "v1.9.6"
+		path, err := PlanUpgradePath(plan, upgradePathCluster(test.nodes...))
+		if err != nil {
+			t.Errorf("test %d: unexpected error: %v", i, err)
+			continue
+		}
+		if path.Current != test.current {
+			t.Errorf("test %d: expected current version %q, got %q", i, test.current, path.Current)
+		}
+		if !reflect.DeepEqual(hopVersions(path), test.hops) {
+			t.Errorf("test %d: expected hops %v, got %v", i, test.hops, hopVersions(path))
+		}
+	}
+}
+
+func TestPlanUpgradePathUnsupported(t *testing.T) {
+	tests := []struct {
+		target string
+		nodes  []string
+	}{
+		// downgrade
+		{target: "v1.9.6", nodes: []string{"v1.9.7"}},
+		// no supported release in between
+		{target: "v1.9.6", nodes: []string{"v1.5.2"}},
+		{target: "v1.9.6", nodes: []string{"v2.0.0"}},
+		{target: "v1.9.6", nodes: []string{"foo"}},
+		{target: "", nodes: []string{"v1.9.6"}},
+	}
+	for i, test := range tests {
+		plan := Plan{}
+		plan.Cluster.Version = test.target
+		if _, err := PlanUpgradePath(plan, upgradePathCluster(test.nodes...)); err == nil {
+			t.Errorf("test %d: expected an error, but didn't get one", i)
+		}
+	}
+}
+
+func TestPlanUpgradePathChanges(t *testing.T) {
+	plan := Plan{}
+	plan.Cluster.Version = "v1.9.6"
+	plan.Cluster.KubeletOptions.Overrides = map[string]string{"api-servers": "https://10.0.0.1:6443", "max-pods": "50"}
+	plan.Cluster.APIServerOptions.Overrides = map[string]string{"etcd-quorum-read": "true"}
+	plan.DockerRegistry.Address = "10.0.0.2"
+
+	path, err := PlanUpgradePath(plan, upgradePathCluster("v1.7.5"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(path.Hops) != 2 {
+		t.Fatalf("expected 2 hops, got %d", len(path.Hops))
+	}
+	expected := []PlanFieldChange{
+		{Field: "cluster.kubelet.option_overrides.api-servers", Removed: true, Message: "use a kubeconfig file instead"},
+	}
+	if !reflect.DeepEqual(path.Hops[0].Changes, expected) {
+		t.Errorf("expected changes %v, got %v", expected, path.Hops[0].Changes)
+	}
+	if !reflect.DeepEqual(path.Hops[0].Removed(), expected) {
+		t.Errorf("expected removed fields %v, got %v", expected, path.Hops[0].Removed())
+	}
+	fields := []string{}
+	for _, c := range path.Hops[1].Changes {
+		fields = append(fields, c.Field)
+	}
+	if !reflect.DeepEqual(fields, []string{"cluster.kube_apiserver.option_overrides.etcd-quorum-read", "docker_registry.address"}) {
+		t.Errorf("unexpected changes in the last hop: %v", fields)
+	}
+	if len(path.Hops[1].Removed()) != 0 {
+		t.Errorf("expected no removed fields, got %v", path.Hops[1].Removed())
+	}
+}
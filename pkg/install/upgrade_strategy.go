@@ -0,0 +1,252 @@
+package install
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/apprenda/kismatic/pkg/util"
+	yaml "gopkg.in/yaml.v2"
+)
+
+const canaryBatchName = "canary"
+
+// UpgradeStrategy controls the order in which worker nodes are upgraded, and
+// what happens when the upgrade of a node fails
+type UpgradeStrategy struct {
+	// MaxParallelWorkers is the maximum number of worker nodes that are upgraded at the same time
+	MaxParallelWorkers int
+	// Canary is the number of worker nodes that are upgraded before the rest of the workers
+	Canary int
+	// CanaryHealthCheck verifies that the canary nodes are Ready once they are upgraded
+	CanaryHealthCheck bool
+	// BatchSelectors are used for upgrading the worker nodes in stages, in the order
+	// they are defined. Each selector is a comma-separated list of key=value node labels.
+	// Worker nodes that do not match any selector are upgraded last.
+	BatchSelectors []string
+	// BetweenBatches is called after a batch of worker nodes has been upgraded,
+	// and before upgrading the next one. The upgrade is stopped if it returns an error.
+	BetweenBatches func(upgraded UpgradeBatch, next UpgradeBatch) error
+	// RollbackOnFailure is called when the upgrade of one or more nodes fails. The
+	// nodes are rolled back to the version they were running before the upgrade
+	// if it returns true.
+	RollbackOnFailure func(nodes []string, err error) bool
+}
+
+// UpgradeBatch is a set of worker nodes that are upgraded at the same time
+type UpgradeBatch struct {
+	// Name of the batch
+	Name string
+	// Canary is true if the batch contains canary nodes
+	Canary bool
+	// Nodes in the batch
+	Nodes []ListableNode
+}
+
+// Hosts returns the hostnames of the nodes in the batch
+func (b UpgradeBatch) Hosts() []string {
+	hosts := []string{}
+	for _, n := range b.Nodes {
+		hosts = append(hosts, n.Node.Host)
+	}
+	return hosts
+}
+
+// ParseBatchSelector parses a comma-separated list of key=value node labels
+func ParseBatchSelector(selector string) (map[string]string, error) {
+	labels := map[string]string{}
+	for _, l := range strings.Split(selector, ",") {
+		pair := strings.Split(l, "=")
+		if len(pair) != 2 || strings.TrimSpace(pair[0]) == "" {
+			return nil, fmt.Errorf("invalid batch selector %q, must be a comma-separated list of key=value pairs", selector)
+		}
+		labels[strings.TrimSpace(pair[0])] = strings.TrimSpace(pair[1])
+	}
+	return labels, nil
+}
+
+// serialUpgradeNodes splits the nodes into the nodes that are upgraded one at a time,
+// in the order they are upgraded, and the worker nodes that are upgraded in batches.
+// Etcd nodes are upgraded first, followed by the master nodes, and the ingress and
+// storage nodes.
+func serialUpgradeNodes(nodes []ListableNode) (serial []ListableNode, workers []ListableNode) {
+	added := make([]bool, len(nodes))
+	for _, roles := range [][]string{{"etcd"}, {"master"}, {"ingress", "storage"}} {
+		for i, n := range nodes {
+			if added[i] {
+				continue
+			}
+			for _, r := range roles {
+				if util.Contains(r, n.Roles) {
+					serial = append(serial, n)
+					added[i] = true
+					break
+				}
+			}
+		}
+	}
+	for i, n := range nodes {
+		if !added[i] {
+			workers = append(workers, n)
+		}
+	}
+	return serial, workers
+}
+
+// WorkerUpgradeBatches splits the worker nodes into the batches that are to be
+// upgraded, in order, according to the upgrade strategy. The canary batch is
+// not included when skipCanary is true.
+func WorkerUpgradeBatches(workers []ListableNode, strategy UpgradeStrategy, skipCanary bool) ([]UpgradeBatch, error) {
+	maxParallel := strategy.MaxParallelWorkers
+	if maxParallel < 1 {
+		maxParallel = 1
+	}
+	// Group the nodes according to the selectors
+	type group struct {
+		name  string
+		nodes []ListableNode
+	}
+	groups := []group{}
+	assigned := make([]bool, len(workers))
+	for _, s := range strategy.BatchSelectors {
+		labels, err := ParseBatchSelector(s)
+		if err != nil {
+			return nil, err
+		}
+		g := group{name: s}
+		for i, n := range workers {
+			if !assigned[i] && matchesLabels(n.Node.Labels, labels) {
+				g.nodes = append(g.nodes, n)
+				assigned[i] = true
+			}
+		}
+		groups = append(groups, g)
+	}
+	remaining := group{name: "remaining"}
+	if len(strategy.BatchSelectors) == 0 {
+		remaining.name = "workers"
+	}
+	for i, n := range workers {
+		if !assigned[i] {
+			remaining.nodes = append(remaining.nodes, n)
+		}
+	}
+	groups = append(groups, remaining)
+
+	// The canary nodes are the first nodes to be upgraded
+	batches := []UpgradeBatch{}
+	if strategy.Canary > 0 && !skipCanary {
+		canary := []ListableNode{}
+		for i := range groups {
+			for len(canary) < strategy.Canary && len(groups[i].nodes) > 0 {
+				canary = append(canary, groups[i].nodes[0])
+				groups[i].nodes = groups[i].nodes[1:]
+			}
+		}
+		batches = append(batches, splitBatch(canaryBatchName, true, canary, maxParallel)...)
+	}
+	for _, g := range groups {
+		batches = append(batches, splitBatch(g.name, false, g.nodes, maxParallel)...)
+	}
+	return batches, nil
+}
+
+// split the nodes into batches of at most max nodes
+func splitBatch(name string, canary bool, nodes []ListableNode, max int) []UpgradeBatch {
+	batches := []UpgradeBatch{}
+	count := (len(nodes) + max - 1) / max
+	for i := 0; i < count; i++ {
+		end := (i + 1) * max
+		if end > len(nodes) {
+			end = len(nodes)
+		}
+		b := UpgradeBatch{
+			Name:   name,
+			Canary: canary,
+			Nodes:  nodes[i*max : end],
+		}
+		if count > 1 {
+			b.Name = fmt.Sprintf("%s (%d/%d)", name, i+1, count)
+		}
+		batches = append(batches, b)
+	}
+	return batches
+}
+
+func matchesLabels(nodeLabels map[string]string, selector map[string]string) bool {
+	for k, v := range selector {
+		if nodeLabels[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// UpgradeProgress is the record of a rolling upgrade of the worker nodes.
+// It is used for resuming an upgrade that was interrupted.
+type UpgradeProgress struct {
+	KismaticVersion   string   `yaml:"kismatic_version"`
+	KubernetesVersion string   `yaml:"kubernetes_version"`
+	CompletedBatches  []string `yaml:"completed_batches"`
+	UpgradedNodes     []string `yaml:"upgraded_nodes"`
+	CanaryUpgraded    bool     `yaml:"canary_upgraded"`
+}
+
+// readUpgradeProgress returns the progress of the upgrade to the version in
+// the plan. An empty progress is returned if there is no record of a previous
+// upgrade, or if the record is for a different version.
+func readUpgradeProgress(file string, plan Plan) (*UpgradeProgress, error) {
+	empty := &UpgradeProgress{
+		KismaticVersion:   KismaticVersion.String(),
+		KubernetesVersion: plan.Cluster.Version,
+	}
+	b, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return empty, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading upgrade progress file %q: %v", file, err)
+	}
+	p := &UpgradeProgress{}
+	if err = yaml.Unmarshal(b, p); err != nil {
+		return nil, fmt.Errorf("error unmarshalling upgrade progress from %q: %v", file, err)
+	}
+	if p.KismaticVersion != empty.KismaticVersion || p.KubernetesVersion != empty.KubernetesVersion {
+		return empty, nil
+	}
+	return p, nil
+}
+
+// record the upgraded batch
+func (p *UpgradeProgress) record(b UpgradeBatch) {
+	p.CompletedBatches = append(p.CompletedBatches, b.Name)
+	p.UpgradedNodes = append(p.UpgradedNodes, b.Hosts()...)
+	sort.Strings(p.UpgradedNodes)
+	if b.Canary {
+		p.CanaryUpgraded = true
+	}
+}
+
+// pending returns the nodes that were not upgraded yet
+func (p *UpgradeProgress) pending(nodes []ListableNode) []ListableNode {
+	pending := []ListableNode{}
+	for _, n := range nodes {
+		if !util.Contains(n.Node.Host, p.UpgradedNodes) {
+			pending = append(pending, n)
+		}
+	}
+	return pending
+}
+
+func (p *UpgradeProgress) write(file string) error {
+	b, err := yaml.Marshal(p)
+	if err != nil {
+		return fmt.Errorf("error marshalling upgrade progress: %v", err)
+	}
+	if err = ioutil.WriteFile(file, b, 0644); err != nil {
+		return fmt.Errorf("error writing upgrade progress to %q: %v", file, err)
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package install
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func batchTestWorkers() []ListableNode {
+	return []ListableNode{
+		{Node: Node{Host: "w1", Labels: map[string]string{"zone": "b"}}, Roles: []string{"worker"}},
+		{Node: Node{Host: "w2", Labels: map[string]string{"zone": "a"}}, Roles: []string{"worker"}},
+		{Node: Node{Host: "w3", Labels: map[string]string{"zone": "a", "tier": "gpu"}}, Roles: []string{"worker"}},
+		{Node: Node{Host: "w4"}, Roles: []string{"worker"}},
+		{Node: Node{Host: "w5", Labels: map[string]string{"zone": "b"}}, Roles: []string{"ingress"}},
+	}
+}
+
+type expectedBatch struct {
+	name   string
+	canary bool
+	hosts  []string
+}
+
+func assertBatches(t *testing.T, batches []UpgradeBatch, expected []expectedBatch) {
+	if len(batches) != len(expected) {
+		t.Fatalf("expected %d batches, got %d: %+v", len(expected), len(batches), batches)
+	}
+	for i, b := range batches {
+		if b.Name != expected[i].name {
+			t.Errorf("batch %d: expected name %q, got %q", i, expected[i].name, b.Name)
+		}
+		if b.Canary != expected[i].canary {
+			t.Errorf("batch %d: expected canary %v, got %v", i, expected[i].canary, b.Canary)
+		}
+		if !reflect.DeepEqual(b.Hosts(), expected[i].hosts) {
+			t.Errorf("batch %d: expected hosts %v, got %v", i, expected[i].hosts, b.Hosts())
+		}
+	}
+}
+
+func TestWorkerUpgradeBatchesMaxParallel(t *testing.T) {
+	batches, err := WorkerUpgradeBatches(batchTestWorkers(), UpgradeStrategy{MaxParallelWorkers: 2}, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertBatches(t, batches, []expectedBatch{
+		{name: "workers (1/3)", hosts: []string{"w1", "w2"}},
+		{name: "workers (2/3)", hosts: []string{"w3", "w4"}},
+		{name: "workers (3/3)", hosts: []string{"w5"}},
+	})
+}
+
+func TestWorkerUpgradeBatchesSelectors(t *testing.T) {
+	strategy := UpgradeStrategy{
+		MaxParallelWorkers: 5,
+		BatchSelectors:     []string{"zone=a,tier=gpu", "zone=a", "zone=b"},
+	}
+	batches, err := WorkerUpgradeBatches(batchTestWorkers(), strategy, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertBatches(t, batches, []expectedBatch{
+		{name: "zone=a,tier=gpu", hosts: []string{"w3"}},
+		{name: "zone=a", hosts: []string{"w2"}},
+		{name: "zone=b", hosts: []string{"w1", "w5"}},
+		{name: "remaining", hosts: []string{"w4"}},
+	})
+}
+
+func TestWorkerUpgradeBatchesCanary(t *testing.T) {
+	strategy := UpgradeStrategy{
+		MaxParallelWorkers: 1,
+		Canary:             2,
+		BatchSelectors:     []string{"zone=a"},
+	}
+	batches, err := WorkerUpgradeBatches(batchTestWorkers(), strategy, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// the canary nodes are taken from the first batches
+	assertBatches(t, batches, []expectedBatch{
+		{name: "canary (1/2)", canary: true, hosts: []string{"w2"}},
+		{name: "canary (2/2)", canary: true, hosts: []string{"w3"}},
+		{name: "remaining (1/3)", hosts: []string{"w1"}},
+		{name: "remaining (2/3)", hosts: []string{"w4"}},
+		{name: "remaining (3/3)", hosts: []string{"w5"}},
+	})
+
+	// the canary is skipped when resuming an upgrade
+	batches, err = WorkerUpgradeBatches(batchTestWorkers()[2:], strategy, true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertBatches(t, batches, []expectedBatch{
+		{name: "zone=a", hosts: []string{"w3"}},
+		{name: "remaining (1/2)", hosts: []string{"w4"}},
+		{name: "remaining (2/2)", hosts: []string{"w5"}},
+	})
+}
+
+func TestWorkerUpgradeBatchesInvalidSelector(t *testing.T) {
+	for _, s := range []string{"zone", "=a", "zone=a,", "zone=a=b"} {
+		_, err := WorkerUpgradeBatches(batchTestWorkers(), UpgradeStrategy{BatchSelectors: []string{s}}, false)
+		if err == nil {
+			t.Errorf("expected an error with selector %q, but didn't get one", s)
+		}
+	}
+}
+
+func TestSerialUpgradeNodes(t *testing.T) {
+	nodes := []ListableNode{
+		{Node: Node{Host: "worker"}, Roles: []string{"worker"}},
+		{Node: Node{Host: "storage"}, Roles: []string{"worker", "storage"}},
+		{Node: Node{Host: "master"}, Roles: []string{"master"}},
+		{Node: Node{Host: "ingress"}, Roles: []string{"ingress"}},
+		{Node: Node{Host: "etcd"}, Roles: []string{"etcd", "master"}},
+	}
+	serial, workers := serialUpgradeNodes(nodes)
+	hosts := []string{}
+	for _, n := range serial {
+		hosts = append(hosts, n.Node.Host)
+	}
+	if !reflect.DeepEqual(hosts, []string{"etcd", "master", "storage", "ingress"}) {
+		t.Errorf("unexpected upgrade order: %v", hosts)
+	}
+	if len(workers) != 1 || workers[0].Node.Host != "worker" {
+		t.Errorf("unexpected workers: %v", workers)
+	}
+}
+
+func TestUpgradeProgress(t *testing.T) {
+	dir, err := ioutil.TempDir("", "upgrade-progress")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "upgrade-progress.yaml")
+	plan := Plan{}
+	plan.Cluster.Version = "v1.9.6"
+
+	p, err := readUpgradeProgress(file, plan)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if p.CanaryUpgraded || len(p.CompletedBatches) != 0 {
+		t.Errorf("expected empty progress, got %+v", p)
+	}
+	p.record(UpgradeBatch{Name: canaryBatchName, Canary: true, Nodes: []ListableNode{{Node: Node{Host: "w2"}}}})
+	if err = p.write(file); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	p, err = readUpgradeProgress(file, plan)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !p.CanaryUpgraded || !reflect.DeepEqual(p.UpgradedNodes, []string{"w2"}) {
+		t.Errorf("progress was not read back, got %+v", p)
+	}
+	pending := p.pending([]ListableNode{{Node: Node{Host: "w1"}}, {Node: Node{Host: "w2"}}, {Node: Node{Host: "w3"}}})
+	if len(pending) != 2 || pending[0].Node.Host != "w1" || pending[1].Node.Host != "w3" {
+		t.Errorf("expected the upgraded node to be skipped, got %v", pending)
+	}
+
+	// progress of an upgrade to a different version is ignored
+	plan.Cluster.Version = "v1.9.7"
+	p, err = readUpgradeProgress(file, plan)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if p.CanaryUpgraded {
+		t.Errorf("expected progress of a different version to be ignored, got %+v", p)
+	}
+}
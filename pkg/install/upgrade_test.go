@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"reflect"
 	"strings"
 	"testing"
 
@@ -18,6 +19,9 @@ type fakeUpgradeKubeClient struct {
 	getPersistentVolume      func(name string) (*data.PersistentVolume, error)
 	getPersistentVolumeClaim func(name string) (*data.PersistentVolumeClaim, error)
 	getStatefulSet           func() (*data.StatefulSet, error)
+	getDeployment            func() (*data.Deployment, error)
+	listPodDisruptionBudgets func() (*data.PodDisruptionBudgetList, error)
+	listNodes                func() (*data.NodeList, error)
 }
 
 func (f fakeUpgradeKubeClient) ListPods() (*data.PodList, error) {
@@ -69,6 +73,91 @@ func (f fakeUpgradeKubeClient) GetStatefulSet(namespace, name string) (*data.Sta
 	return nil, errors.New("StatefulSet not found")
 }
 
+func (f fakeUpgradeKubeClient) GetDeployment(namespace, name string) (*data.Deployment, error) {
+	if f.getDeployment != nil {
+		return f.getDeployment()
+	}
+	return nil, errors.New("Deployment not found")
+}
+
+func (f fakeUpgradeKubeClient) ListPodDisruptionBudgets() (*data.PodDisruptionBudgetList, error) {
+	if f.listPodDisruptionBudgets != nil {
+		return f.listPodDisruptionBudgets()
+	}
+	return &data.PodDisruptionBudgetList{}, nil
+}
+
+func (f fakeUpgradeKubeClient) ListNodes() (*data.NodeList, error) {
+	if f.listNodes != nil {
+		return f.listNodes()
+	}
+	return &data.NodeList{}, nil
+}
+
+type fakeUpgradeGlusterClient struct {
+	volumes      []*data.GlusterVolume
+	online       []string
+	pendingHeals string
+}
+
+func (f fakeUpgradeGlusterClient) ListVolumes() (*data.GlusterVolumeInfoCliOutput, error) {
+	if len(f.volumes) == 0 {
+		return nil, nil
+	}
+	return &data.GlusterVolumeInfoCliOutput{
+		VolumeInfo: &data.GlusterVolumeInfo{
+			Volumes: &data.GlusterVolumes{Volume: f.volumes},
+		},
+	}, nil
+}
+
+func (f fakeUpgradeGlusterClient) GetQuota(volume string) (*data.GlusterVolumeQuotaCliOutput, error) {
+	return nil, nil
+}
+
+func (f fakeUpgradeGlusterClient) ListVolumeStatus() (*data.GlusterVolumeStatusCliOutput, error) {
+	nodes := []*data.GlusterVolumeStatusNode{}
+	for _, b := range f.online {
+		brick := strings.Split(b, ":")
+		nodes = append(nodes, &data.GlusterVolumeStatusNode{Hostname: brick[0], Path: brick[1], Status: 1})
+	}
+	return &data.GlusterVolumeStatusCliOutput{
+		VolumeStatus: &data.GlusterVolumeStatus{
+			Volumes: &data.GlusterVolumeStatusVolumes{
+				Volume: []*data.GlusterVolumeStatusVolume{{Name: "vol1", Nodes: nodes}},
+			},
+		},
+	}, nil
+}
+
+func (f fakeUpgradeGlusterClient) GetHealInfo(volume string) (*data.GlusterVolumeHealInfoCliOutput, error) {
+	entries := f.pendingHeals
+	if entries == "" {
+		entries = "0"
+	}
+	return &data.GlusterVolumeHealInfoCliOutput{
+		HealInfo: &data.GlusterHealInfo{
+			Bricks: &data.GlusterHealInfoBricks{
+				Brick: []*data.GlusterHealInfoBrick{{Name: "foo:/data/vol1", Status: "Connected", NumberOfEntries: entries}},
+			},
+		},
+	}, nil
+}
+
+func glusterVolume(name string, replicaCount uint, bricks ...string) *data.GlusterVolume {
+	v := &data.GlusterVolume{
+		Name:         name,
+		ReplicaCount: replicaCount,
+		BrickCount:   uint(len(bricks)),
+		StatusStr:    "Started",
+		Bricks:       &data.GlusterBricks{},
+	}
+	for _, b := range bricks {
+		v.Bricks.Brick = append(v.Bricks.Brick, &data.GlusterBrick{Text: b})
+	}
+	return v
+}
+
 func getSafePodWithCreatedByRef(t *testing.T, nodeName string, createdByKind string) data.Pod {
 	createdByRef := data.SerializedReference{
 		Reference: data.ObjectReference{
@@ -105,7 +194,7 @@ func TestDetectNodeUpgradeSafetyEtcdCountUnsafe(t *testing.T) {
 	}
 	node := plan.Etcd.Nodes[0]
 	k8sClient := fakeUpgradeKubeClient{}
-	errs := DetectNodeUpgradeSafety(plan, node, k8sClient)
+	errs := DetectNodeUpgradeSafety(plan, node, k8sClient, fakeUpgradeGlusterClient{})
 	if len(errs) != 1 {
 		t.Errorf("Expected %d errors, but got %v", 1, errs)
 	} else if _, ok := errs[0].(etcdNodeCountErr); !ok {
@@ -127,7 +216,7 @@ func TestDetectNodeUpgradeSafetyMasterCountUnsafe(t *testing.T) {
 	}
 	node := plan.Master.Nodes[0]
 	k8sClient := fakeUpgradeKubeClient{}
-	errs := DetectNodeUpgradeSafety(plan, node, k8sClient)
+	errs := DetectNodeUpgradeSafety(plan, node, k8sClient, fakeUpgradeGlusterClient{})
 	if len(errs) != 1 {
 		t.Errorf("Expected %d errors, but got %v", 1, errs)
 	} else if _, ok := errs[0].(masterNodeCountErr); !ok {
@@ -154,7 +243,7 @@ func TestDetectNodeUpgradeSafetyMasterLoadBalancingUnsafe(t *testing.T) {
 	}
 	node := plan.Master.Nodes[0]
 	k8sClient := fakeUpgradeKubeClient{}
-	errs := DetectNodeUpgradeSafety(plan, node, k8sClient)
+	errs := DetectNodeUpgradeSafety(plan, node, k8sClient, fakeUpgradeGlusterClient{})
 	if len(errs) != 1 {
 		t.Errorf("Expected %d errors, but got %v", 1, errs)
 	} else if _, ok := errs[0].(masterNodeLoadBalancingErr); !ok {
@@ -181,7 +270,7 @@ func TestDetectNodeUpgradeSafetyMasterLoadBalancingSafe(t *testing.T) {
 	}
 	node := plan.Master.Nodes[0]
 	k8sClient := fakeUpgradeKubeClient{}
-	errs := DetectNodeUpgradeSafety(plan, node, k8sClient)
+	errs := DetectNodeUpgradeSafety(plan, node, k8sClient, fakeUpgradeGlusterClient{})
 	if len(errs) != 0 {
 		t.Errorf("did not expect an error, but got %d", len(errs))
 	}
@@ -201,11 +290,55 @@ func TestDetectNodeUpgradeSafetyIngress(t *testing.T) {
 	}
 	node := plan.Ingress.Nodes[0]
 	k8sClient := fakeUpgradeKubeClient{}
-	errs := DetectNodeUpgradeSafety(plan, node, k8sClient)
+	errs := DetectNodeUpgradeSafety(plan, node, k8sClient, fakeUpgradeGlusterClient{})
 	if len(errs) != 1 {
 		t.Errorf("Expected %d errors, but got %v", 1, errs)
-	} else if _, ok := errs[0].(ingressNotSupportedErr); !ok {
-		t.Errorf("Expected ingressUnsupportedError, but got %v", errs[0])
+	} else if _, ok := errs[0].(ingressNodeCountErr); !ok {
+		t.Errorf("Expected ingressNodeCountErr, but got %v", errs[0])
+	}
+}
+
+func TestDetectNodeUpgradeSafetyIngressOtherNodesReady(t *testing.T) {
+	plan := Plan{
+		Ingress: OptionalNodeGroup{
+			ExpectedCount: 2,
+			Nodes: []Node{
+				{Host: "foo", IP: "10.0.0.1"},
+				{Host: "bar", IP: "10.0.0.2"},
+			},
+		},
+	}
+	node := plan.Ingress.Nodes[0]
+	tests := []struct {
+		ready       string
+		expectedErr bool
+	}{
+		{ready: "True"},
+		{ready: "False", expectedErr: true},
+		{ready: "Unknown", expectedErr: true},
+	}
+	for _, test := range tests {
+		k8sClient := fakeUpgradeKubeClient{
+			listNodes: func() (*data.NodeList, error) {
+				n := data.Node{ObjectMeta: data.ObjectMeta{Name: "bar"}}
+				// Ingress nodes are unschedulable
+				n.Spec.Unschedulable = true
+				n.Status.Conditions = []data.NodeCondition{{Type: "Ready", Status: test.ready}}
+				return &data.NodeList{Items: []data.Node{n}}, nil
+			},
+		}
+		errs := DetectNodeUpgradeSafety(plan, node, k8sClient, fakeUpgradeGlusterClient{})
+		if !test.expectedErr {
+			if len(errs) != 0 {
+				t.Errorf("did not expect an error, but got %v", errs)
+			}
+			continue
+		}
+		if len(errs) != 1 {
+			t.Errorf("Expected %d errors, but got %v", 1, errs)
+		} else if _, ok := errs[0].(ingressNodesNotReadyErr); !ok {
+			t.Errorf("Expected ingressNodesNotReadyErr, but got %v", errs[0])
+		}
 	}
 }
 
@@ -223,11 +356,124 @@ func TestDetectNodeUpgradeSafetyStorage(t *testing.T) {
 	}
 	node := plan.Storage.Nodes[0]
 	k8sClient := fakeUpgradeKubeClient{}
-	errs := DetectNodeUpgradeSafety(plan, node, k8sClient)
+	glusterClient := fakeUpgradeGlusterClient{
+		volumes: []*data.GlusterVolume{glusterVolume("vol1", 1, "foo:/data/vol1")},
+		online:  []string{"foo:/data/vol1"},
+	}
+	errs := DetectNodeUpgradeSafety(plan, node, k8sClient, glusterClient)
 	if len(errs) != 1 {
 		t.Errorf("Expected %d errors, but got %v", 1, errs)
-	} else if _, ok := errs[0].(storageNotSupportedErr); !ok {
-		t.Errorf("Expected storageUnsupportedError, but got %v", errs[0])
+	} else if _, ok := errs[0].(glusterNoReplicaErr); !ok {
+		t.Errorf("Expected glusterNoReplicaErr, but got %v", errs[0])
+	}
+}
+
+func TestDetectNodeUpgradeSafetyStorageNoVolumes(t *testing.T) {
+	plan := Plan{
+		Storage: OptionalNodeGroup{
+			ExpectedCount: 1,
+			Nodes: []Node{
+				{Host: "foo", IP: "10.0.0.1"},
+			},
+		},
+	}
+	node := plan.Storage.Nodes[0]
+	errs := DetectNodeUpgradeSafety(plan, node, fakeUpgradeKubeClient{}, fakeUpgradeGlusterClient{})
+	if len(errs) != 0 {
+		t.Errorf("did not expect an error, but got %v", errs)
+	}
+}
+
+func TestDetectNodeUpgradeSafetyStorageNoClient(t *testing.T) {
+	plan := Plan{
+		Storage: OptionalNodeGroup{
+			ExpectedCount: 1,
+			Nodes: []Node{
+				{Host: "foo", IP: "10.0.0.1"},
+			},
+		},
+	}
+	node := plan.Storage.Nodes[0]
+	errs := DetectNodeUpgradeSafety(plan, node, fakeUpgradeKubeClient{}, nil)
+	if len(errs) != 1 {
+		t.Errorf("Expected %d errors, but got %v", 1, errs)
+	}
+}
+
+func TestDetectNodeUpgradeSafetyStorageVolumes(t *testing.T) {
+	plan := Plan{
+		Storage: OptionalNodeGroup{
+			ExpectedCount: 3,
+			Nodes: []Node{
+				{Host: "foo", IP: "10.0.0.1"},
+				{Host: "bar", IP: "10.0.0.2"},
+				{Host: "baz", IP: "10.0.0.3"},
+			},
+		},
+	}
+	node := plan.Storage.Nodes[0]
+	tests := []struct {
+		name         string
+		volume       *data.GlusterVolume
+		online       []string
+		pendingHeals string
+		expectedErrs []error
+	}{
+		{
+			name:   "replicated volume",
+			volume: glusterVolume("vol1", 2, "foo:/data/vol1", "bar:/data/vol1"),
+			online: []string{"foo:/data/vol1", "bar:/data/vol1"},
+		},
+		{
+			name:   "volume without a brick on the node",
+			volume: glusterVolume("vol1", 1, "bar:/data/vol1"),
+			online: []string{"bar:/data/vol1"},
+		},
+		{
+			name:         "replica is offline",
+			volume:       glusterVolume("vol1", 2, "foo:/data/vol1", "bar:/data/vol1"),
+			online:       []string{"foo:/data/vol1"},
+			expectedErrs: []error{glusterVolumeUnhealthyErr{}, glusterNoReplicaErr{}},
+		},
+		{
+			name:         "distributed replicated volume with an offline replica",
+			volume:       glusterVolume("vol1", 2, "bar:/data/vol1", "baz:/data/vol1", "foo:/data/vol1", "baz:/data/vol1b"),
+			online:       []string{"bar:/data/vol1", "foo:/data/vol1", "baz:/data/vol1"},
+			expectedErrs: []error{glusterVolumeUnhealthyErr{}, glusterNoReplicaErr{}},
+		},
+		{
+			name:         "pending heals",
+			volume:       glusterVolume("vol1", 2, "foo:/data/vol1", "bar:/data/vol1"),
+			online:       []string{"foo:/data/vol1", "bar:/data/vol1"},
+			pendingHeals: "3",
+			expectedErrs: []error{glusterPendingHealErr{}},
+		},
+		{
+			name: "stopped volume",
+			volume: func() *data.GlusterVolume {
+				v := glusterVolume("vol1", 2, "foo:/data/vol1", "bar:/data/vol1")
+				v.StatusStr = "Stopped"
+				return v
+			}(),
+			expectedErrs: []error{glusterVolumeUnhealthyErr{}},
+		},
+	}
+	for _, test := range tests {
+		glusterClient := fakeUpgradeGlusterClient{
+			volumes:      []*data.GlusterVolume{test.volume},
+			online:       test.online,
+			pendingHeals: test.pendingHeals,
+		}
+		errs := DetectNodeUpgradeSafety(plan, node, fakeUpgradeKubeClient{}, glusterClient)
+		if len(errs) != len(test.expectedErrs) {
+			t.Errorf("%s: expected %d errors, but got %v", test.name, len(test.expectedErrs), errs)
+			continue
+		}
+		for i := range errs {
+			if reflect.TypeOf(errs[i]) != reflect.TypeOf(test.expectedErrs[i]) {
+				t.Errorf("%s: expected %T, but got %v", test.name, test.expectedErrs[i], errs[i])
+			}
+		}
 	}
 }
 
@@ -245,7 +491,7 @@ func TestDetectNodeUpgradeSafetyWorkerCountUnsafe(t *testing.T) {
 	}
 	node := plan.Worker.Nodes[0]
 	k8sClient := fakeUpgradeKubeClient{}
-	errs := DetectNodeUpgradeSafety(plan, node, k8sClient)
+	errs := DetectNodeUpgradeSafety(plan, node, k8sClient, fakeUpgradeGlusterClient{})
 	if len(errs) != 1 {
 		t.Errorf("Expected %d errors, but got %v", 1, errs)
 	} else if _, ok := errs[0].(workerNodeCountErr); !ok {
@@ -271,7 +517,7 @@ func TestDetectNodeUpgradeSafetyWorkerPodListError(t *testing.T) {
 	}
 	node := plan.Worker.Nodes[0]
 	k8sClient := fakeUpgradeKubeClient{listPods: func() (*data.PodList, error) { return nil, errors.New("some error") }}
-	errs := DetectNodeUpgradeSafety(plan, node, k8sClient)
+	errs := DetectNodeUpgradeSafety(plan, node, k8sClient, fakeUpgradeGlusterClient{})
 	if len(errs) != 1 {
 		t.Errorf("Expected %d errors, but got %v", 1, errs)
 	} else if !strings.Contains(errs[0].Error(), "some error") {
@@ -318,7 +564,7 @@ func TestDetectNodeUpgradeSafetyWorkerPodHostPathVol(t *testing.T) {
 			}, nil
 		},
 	}
-	errs := DetectNodeUpgradeSafety(plan, node, k8sClient)
+	errs := DetectNodeUpgradeSafety(plan, node, k8sClient, fakeUpgradeGlusterClient{})
 	if len(errs) != 1 {
 		t.Errorf("Expected %d errors, but got %v", 1, errs)
 	} else if _, ok := errs[0].(podUnsafeVolumeErr); !ok {
@@ -367,7 +613,7 @@ func TestDetectNodeUpgradeSafetyWorkerPodEmptyDirVol(t *testing.T) {
 			}, nil
 		},
 	}
-	errs := DetectNodeUpgradeSafety(plan, node, k8sClient)
+	errs := DetectNodeUpgradeSafety(plan, node, k8sClient, fakeUpgradeGlusterClient{})
 	if len(errs) != 1 {
 		t.Errorf("Expected %d errors, but got %v", 1, errs)
 	} else if _, ok := errs[0].(podUnsafeVolumeErr); !ok {
@@ -438,7 +684,7 @@ func TestDetectNodeUpgradeSafetyWorkerPodHostPathPersistentVol(t *testing.T) {
 			return nil, fmt.Errorf("PV not found")
 		},
 	}
-	errs := DetectNodeUpgradeSafety(plan, node, k8sClient)
+	errs := DetectNodeUpgradeSafety(plan, node, k8sClient, fakeUpgradeGlusterClient{})
 	if len(errs) != 1 {
 		t.Errorf("Expected %d errors, but got %v", 1, errs)
 	} else if _, ok := errs[0].(podUnsafePersistentVolumeErr); !ok {
@@ -482,7 +728,7 @@ func TestDetectNodeUpgradeSafetyWorkerSingleDaemon(t *testing.T) {
 			}, nil
 		},
 	}
-	errs := DetectNodeUpgradeSafety(plan, node, k8sClient)
+	errs := DetectNodeUpgradeSafety(plan, node, k8sClient, fakeUpgradeGlusterClient{})
 	if len(errs) != 1 {
 		t.Errorf("Expected %d errors, but got %v", 1, errs)
 	} else if _, ok := errs[0].(podUnsafeDaemonErr); !ok {
@@ -526,7 +772,7 @@ func TestDetectNodeUpgradeSafetyWorkerSafeDaemon(t *testing.T) {
 			}, nil
 		},
 	}
-	errs := DetectNodeUpgradeSafety(plan, node, k8sClient)
+	errs := DetectNodeUpgradeSafety(plan, node, k8sClient, fakeUpgradeGlusterClient{})
 	if len(errs) != 0 {
 		t.Errorf("Did not expect errors, but got: %v", errs)
 	}
@@ -560,7 +806,7 @@ func TestDetectNodeUpgradeSafetyLonePod(t *testing.T) {
 			}, nil
 		},
 	}
-	errs := DetectNodeUpgradeSafety(plan, node, k8sClient)
+	errs := DetectNodeUpgradeSafety(plan, node, k8sClient, fakeUpgradeGlusterClient{})
 	if len(errs) != 1 {
 		t.Errorf("Expected %d errors, but got %v", 1, errs)
 	} else if _, ok := errs[0].(unmanagedPodErr); !ok {
@@ -603,7 +849,7 @@ func TestDetectNodeUpgradeSafetyUnreplicatedController(t *testing.T) {
 			}, nil
 		},
 	}
-	errs := DetectNodeUpgradeSafety(plan, node, k8sClient)
+	errs := DetectNodeUpgradeSafety(plan, node, k8sClient, fakeUpgradeGlusterClient{})
 	if len(errs) != 2 {
 		t.Fatalf("Expected %d errors, but got %v", 2, errs)
 	}
@@ -649,7 +895,7 @@ func TestDetectNodeUpgradeSafetyUnreplicatedReplicaSet(t *testing.T) {
 			}, nil
 		},
 	}
-	errs := DetectNodeUpgradeSafety(plan, node, k8sClient)
+	errs := DetectNodeUpgradeSafety(plan, node, k8sClient, fakeUpgradeGlusterClient{})
 	if len(errs) != 2 {
 		t.Fatalf("Expected %d errors, but got %v", 2, errs)
 	}
@@ -695,7 +941,7 @@ func TestDetectNodeUpgradeSafetyUnreplicatedStatefulSet(t *testing.T) {
 			}, nil
 		},
 	}
-	errs := DetectNodeUpgradeSafety(plan, node, k8sClient)
+	errs := DetectNodeUpgradeSafety(plan, node, k8sClient, fakeUpgradeGlusterClient{})
 	if len(errs) != 1 {
 		t.Errorf("Expected %d errors, but got %v", 1, errs)
 	} else if _, ok := errs[0].(unsafeReplicaCountErr); !ok {
@@ -730,7 +976,7 @@ func TestDetectNodeUpgradeSafetyJobRunningOnNode(t *testing.T) {
 			}, nil
 		},
 	}
-	errs := DetectNodeUpgradeSafety(plan, node, k8sClient)
+	errs := DetectNodeUpgradeSafety(plan, node, k8sClient, fakeUpgradeGlusterClient{})
 	if len(errs) != 1 {
 		t.Errorf("Expected %d errors, but got %v", 1, errs)
 	} else if _, ok := errs[0].(podRunningJobErr); !ok {
@@ -773,7 +1019,7 @@ func TestDetectNodeUpgradeSafetyAllReplicaSetPodsSameNode(t *testing.T) {
 			}, nil
 		},
 	}
-	errs := DetectNodeUpgradeSafety(plan, node, k8sClient)
+	errs := DetectNodeUpgradeSafety(plan, node, k8sClient, fakeUpgradeGlusterClient{})
 	if len(errs) != 1 {
 		t.Errorf("Expected %d errors, but got %v", 1, errs)
 	} else if _, ok := errs[0].(replicasOnSingleNodeErr); !ok {
@@ -816,10 +1062,257 @@ func TestDetectNodeUpgradeSafetyAllReplicaControllerPodsSameNode(t *testing.T) {
 			}, nil
 		},
 	}
-	errs := DetectNodeUpgradeSafety(plan, node, k8sClient)
+	errs := DetectNodeUpgradeSafety(plan, node, k8sClient, fakeUpgradeGlusterClient{})
 	if len(errs) != 1 {
 		t.Errorf("Expected %d errors, but got %v", 1, errs)
 	} else if _, ok := errs[0].(replicasOnSingleNodeErr); !ok {
 		t.Errorf("expected replicasOnSingleNodeErr, but got %T", errs[0])
 	}
 }
+
+func getPodWithOwnerRef(nodeName string, ownerKind string, labels map[string]string) data.Pod {
+	controller := true
+	return data.Pod{
+		ObjectMeta: data.ObjectMeta{
+			Name:      "foo",
+			Namespace: "foo",
+			Labels:    labels,
+			OwnerReferences: []data.OwnerReference{
+				{
+					Kind:       ownerKind,
+					Name:       "bar",
+					Controller: &controller,
+				},
+			},
+		},
+		Spec: data.PodSpec{
+			NodeName: nodeName,
+		},
+	}
+}
+
+func replicatedReplicaSet() (*data.ReplicaSet, error) {
+	return &data.ReplicaSet{
+		Status: data.ReplicaSetStatus{
+			Replicas: 10,
+		},
+	}, nil
+}
+
+func appDisruptionBudget(allowed int32) (*data.PodDisruptionBudgetList, error) {
+	pdb := data.PodDisruptionBudget{
+		ObjectMeta: data.ObjectMeta{
+			Name:      "app",
+			Namespace: "foo",
+		},
+		Spec: data.PodDisruptionBudgetSpec{
+			Selector: &data.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+		},
+		Status: data.PodDisruptionBudgetStatus{
+			PodDisruptionsAllowed: allowed,
+		},
+	}
+	return &data.PodDisruptionBudgetList{Items: []data.PodDisruptionBudget{pdb}}, nil
+}
+
+func TestDetectNodeUpgradeSafetyOwnerReference(t *testing.T) {
+	plan := Plan{
+		Worker: NodeGroup{
+			ExpectedCount: 2,
+			Nodes: []Node{
+				{Host: "foo", IP: "10.0.0.1"},
+				{Host: "bar", IP: "10.0.0.2"},
+			},
+		},
+	}
+	node := plan.Worker.Nodes[0]
+	k8sClient := fakeUpgradeKubeClient{
+		listPods: func() (*data.PodList, error) {
+			return &data.PodList{
+				Items: []data.Pod{getPodWithOwnerRef(node.Host, "ReplicaSet", nil)},
+			}, nil
+		},
+		getReplicaSet: replicatedReplicaSet,
+	}
+	errs := DetectNodeUpgradeSafety(plan, node, k8sClient, fakeUpgradeGlusterClient{})
+	if len(errs) != 0 {
+		t.Errorf("did not expect an error, but got %v", errs)
+	}
+}
+
+func TestDetectNodeUpgradeSafetyUnreplicatedDeployment(t *testing.T) {
+	plan := Plan{
+		Worker: NodeGroup{
+			ExpectedCount: 2,
+			Nodes: []Node{
+				{Host: "foo", IP: "10.0.0.1"},
+				{Host: "bar", IP: "10.0.0.2"},
+			},
+		},
+	}
+	node := plan.Worker.Nodes[0]
+	controller := true
+	k8sClient := fakeUpgradeKubeClient{
+		listPods: func() (*data.PodList, error) {
+			return &data.PodList{
+				Items: []data.Pod{getPodWithOwnerRef(node.Host, "ReplicaSet", nil)},
+			}, nil
+		},
+		// The ReplicaSet of a deployment that is being rolled out
+		getReplicaSet: func() (*data.ReplicaSet, error) {
+			return &data.ReplicaSet{
+				ObjectMeta: data.ObjectMeta{
+					OwnerReferences: []data.OwnerReference{{Kind: "Deployment", Name: "bar", Controller: &controller}},
+				},
+				Status: data.ReplicaSetStatus{Replicas: 2},
+			}, nil
+		},
+		getDeployment: func() (*data.Deployment, error) {
+			return &data.Deployment{Status: data.DeploymentStatus{Replicas: 1}}, nil
+		},
+	}
+	errs := DetectNodeUpgradeSafety(plan, node, k8sClient, fakeUpgradeGlusterClient{})
+	if len(errs) != 2 {
+		t.Fatalf("Expected %d errors, but got %v", 2, errs)
+	}
+	if e, ok := errs[0].(unsafeReplicaCountErr); !ok || e.kind != "Deployment" {
+		t.Errorf("expected unsafeReplicaCountErr for the Deployment, but got %v", errs[0])
+	}
+	if _, ok := errs[1].(replicasOnSingleNodeErr); !ok {
+		t.Errorf("expected replicasOnSingleNodeErr, but got %T", errs[1])
+	}
+}
+
+func TestDetectNodeUpgradeSafetyPodDisruptionBudget(t *testing.T) {
+	plan := Plan{
+		Worker: NodeGroup{
+			ExpectedCount: 2,
+			Nodes: []Node{
+				{Host: "foo", IP: "10.0.0.1"},
+				{Host: "bar", IP: "10.0.0.2"},
+			},
+		},
+	}
+	node := plan.Worker.Nodes[0]
+	tests := []struct {
+		allowed     int32
+		labels      map[string]string
+		expectedErr bool
+	}{
+		{allowed: 2, labels: map[string]string{"app": "web"}},
+		{allowed: 1, labels: map[string]string{"app": "web"}, expectedErr: true},
+		// the pods are not covered by the budget
+		{allowed: 0, labels: map[string]string{"app": "db"}},
+	}
+	for _, test := range tests {
+		pdbs, _ := appDisruptionBudget(test.allowed)
+		k8sClient := fakeUpgradeKubeClient{
+			listPods: func() (*data.PodList, error) {
+				return &data.PodList{
+					Items: []data.Pod{
+						getPodWithOwnerRef(node.Host, "ReplicaSet", test.labels),
+						getPodWithOwnerRef(node.Host, "ReplicaSet", test.labels),
+					},
+				}, nil
+			},
+			getReplicaSet:            replicatedReplicaSet,
+			listPodDisruptionBudgets: func() (*data.PodDisruptionBudgetList, error) { return pdbs, nil },
+		}
+		errs := DetectNodeUpgradeSafety(plan, node, k8sClient, fakeUpgradeGlusterClient{})
+		if !test.expectedErr {
+			if len(errs) != 0 {
+				t.Errorf("did not expect an error, but got %v", errs)
+			}
+			continue
+		}
+		if len(errs) != 1 {
+			t.Errorf("Expected %d errors, but got %v", 1, errs)
+		} else if e, ok := errs[0].(podDisruptionBudgetErr); !ok || e.pods != 2 || e.allowed != test.allowed {
+			t.Errorf("expected podDisruptionBudgetErr, but got %v", errs[0])
+		}
+	}
+}
+
+func TestDetectNodeUpgradeSafetyPodDisruptionBudgetListError(t *testing.T) {
+	plan := Plan{
+		Worker: NodeGroup{
+			ExpectedCount: 2,
+			Nodes: []Node{
+				{Host: "foo", IP: "10.0.0.1"},
+				{Host: "bar", IP: "10.0.0.2"},
+			},
+		},
+	}
+	node := plan.Worker.Nodes[0]
+	k8sClient := fakeUpgradeKubeClient{
+		listPods: func() (*data.PodList, error) {
+			return &data.PodList{
+				Items: []data.Pod{getPodWithOwnerRef(node.Host, "ReplicaSet", nil)},
+			}, nil
+		},
+		getReplicaSet: replicatedReplicaSet,
+		listPodDisruptionBudgets: func() (*data.PodDisruptionBudgetList, error) {
+			return nil, errors.New("some error")
+		},
+	}
+	errs := DetectNodeUpgradeSafety(plan, node, k8sClient, fakeUpgradeGlusterClient{})
+	if len(errs) != 1 {
+		t.Errorf("Expected %d errors, but got %v", 1, errs)
+	}
+}
+
+func TestDetectUpgradeSafetyPodDisruptionBudgetBatches(t *testing.T) {
+	plan := Plan{
+		Worker: NodeGroup{
+			ExpectedCount: 3,
+			Nodes: []Node{
+				{Host: "w1", IP: "10.0.0.1"},
+				{Host: "w2", IP: "10.0.0.2"},
+				{Host: "w3", IP: "10.0.0.3"},
+			},
+		},
+	}
+	nodes := []ListableNode{}
+	pods := []data.Pod{}
+	for _, n := range plan.Worker.Nodes {
+		nodes = append(nodes, ListableNode{Node: n, Roles: []string{"worker"}})
+		pods = append(pods, getPodWithOwnerRef(n.Host, "ReplicaSet", map[string]string{"app": "web"}))
+	}
+	k8sClient := fakeUpgradeKubeClient{
+		listPods: func() (*data.PodList, error) {
+			return &data.PodList{Items: pods}, nil
+		},
+		getReplicaSet:            replicatedReplicaSet,
+		listPodDisruptionBudgets: func() (*data.PodDisruptionBudgetList, error) { return appDisruptionBudget(2) },
+	}
+
+	// Nodes are drained one at a time, so the budget is never exceeded
+	errs, err := DetectUpgradeSafety(plan, nodes, UpgradeStrategy{MaxParallelWorkers: 1}, k8sClient, fakeUpgradeGlusterClient{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for host, e := range errs {
+		if len(e) != 0 {
+			t.Errorf("did not expect an error on node %q, but got %v", host, e)
+		}
+	}
+
+	// The third node of the batch is over the budget
+	errs, err = DetectUpgradeSafety(plan, nodes, UpgradeStrategy{MaxParallelWorkers: 3}, k8sClient, fakeUpgradeGlusterClient{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(errs["w1"]) != 0 || len(errs["w2"]) != 0 {
+		t.Errorf("did not expect errors on the first nodes of the batch, but got %v", errs)
+	}
+	if len(errs["w3"]) != 1 {
+		t.Fatalf("Expected %d errors, but got %v", 1, errs["w3"])
+	}
+	e, ok := errs["w3"][0].(podDisruptionBudgetConcurrentErr)
+	if !ok {
+		t.Fatalf("expected podDisruptionBudgetConcurrentErr, but got %T", errs["w3"][0])
+	}
+	if !reflect.DeepEqual(e.nodes, []string{"w1", "w2"}) {
+		t.Errorf("expected the budget to be shared with [w1 w2], but got %v", e.nodes)
+	}
+}
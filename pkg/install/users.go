@@ -0,0 +1,74 @@
+package install
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/apprenda/kismatic/pkg/ansible"
+	"github.com/apprenda/kismatic/pkg/tls"
+)
+
+// userBindings returns the bindings of the roles of the users. Users without a role are not bound.
+func userBindings(users []User) []ansible.UserBinding {
+	bindings := []ansible.UserBinding{}
+	for _, u := range users {
+		if u.Role == "" {
+			continue
+		}
+		// The role is part of the name, as the role of a binding cannot be changed
+		b := ansible.UserBinding{
+			Name:      fmt.Sprintf("kismatic:user:%s:%s", u.Name, u.Role),
+			Kind:      "ClusterRoleBinding",
+			User:      u.Name,
+			Role:      u.Role,
+			Namespace: u.Namespace,
+		}
+		if u.Namespace != "" {
+			b.Kind = "RoleBinding"
+		}
+		b.Key = strings.Join([]string{b.Kind, b.Namespace, b.Name}, ",")
+		bindings = append(bindings, b)
+	}
+	return bindings
+}
+
+// CreateUser issues a client certificate for the user, signed by the cluster CA, that is valid
+// for the expiry. The kubeconfig file of the user is generated, and its path is returned.
+func CreateUser(p *Plan, pki *LocalPKI, generatedAssetsDir string, user User, expiry string, overwrite bool) (string, error) {
+	if ok, errs := (&userList{Users: []User{user}, Plan: p}).validate(); !ok {
+		msgs := []string{}
+		for _, err := range errs {
+			msgs = append(msgs, err.Error())
+		}
+		return "", fmt.Errorf("invalid user: %s", strings.Join(msgs, "; "))
+	}
+	exists, err := tls.CertKeyPairExists(user.Name, pki.GeneratedCertsDirectory)
+	if err != nil {
+		return "", fmt.Errorf("could not determine if certificate for %s exists: %v", user.Name, err)
+	}
+	if exists && !overwrite {
+		return "", fmt.Errorf("certificate %q already exists, use --overwrite to issue a new certificate", user.Name+".pem")
+	}
+	ca, err := pki.GetClusterCA()
+	if err != nil {
+		return "", err
+	}
+	spec := userCertSpec(user, ca)
+	spec.key = p.Cluster.Certificates.key()
+	spec.expiry = expiry
+	if err = pki.issueCert(p, spec); err != nil {
+		return "", fmt.Errorf("could not generate certificate %s: %v", user.Name, err)
+	}
+	return GenerateUserKubeconfig(p, generatedAssetsDir, user.Name)
+}
+
+// userCertSpec returns the specification of the client certificate of the user
+func userCertSpec(user User, ca *tls.CA) certificateSpec {
+	return certificateSpec{
+		description:   fmt.Sprintf("user %s", user.Name),
+		filename:      user.Name,
+		commonName:    user.Name,
+		organizations: user.Groups,
+		ca:            ca,
+	}
+}
@@ -0,0 +1,121 @@
+package install
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apprenda/kismatic/pkg/tls"
+)
+
+func TestUserBindings(t *testing.T) {
+	users := []User{
+		{Name: "alice", Role: "view"},
+		{Name: "bob", Role: "edit", Namespace: "dev"},
+		{Name: "carol"},
+	}
+	bindings := userBindings(users)
+	if len(bindings) != 2 {
+		t.Fatalf("expected 2 bindings, got %+v", bindings)
+	}
+	if b := bindings[0]; b.Kind != "ClusterRoleBinding" || b.Name != "kismatic:user:alice:view" || b.User != "alice" || b.Role != "view" || b.Key != "ClusterRoleBinding,,kismatic:user:alice:view" {
+		t.Errorf("unexpected binding %+v", b)
+	}
+	if b := bindings[1]; b.Kind != "RoleBinding" || b.Namespace != "dev" || b.Key != "RoleBinding,dev,kismatic:user:bob:edit" {
+		t.Errorf("unexpected binding %+v", b)
+	}
+}
+
+func TestCreateUser(t *testing.T) {
+	generatedDir, err := ioutil.TempDir("", "users-tests")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(generatedDir)
+	pki := &LocalPKI{
+		CACsr:                   "test/ca-csr.json",
+		GeneratedCertsDirectory: filepath.Join(generatedDir, "keys"),
+		Log:                     ioutil.Discard,
+	}
+	p := getPlan()
+	p.Cluster.Certificates.KeyAlgorithm = tls.KeyAlgorithmECDSA
+	if _, err = pki.GenerateClusterCA(p); err != nil {
+		t.Fatalf("error generating CA for test: %v", err)
+	}
+
+	user := User{Name: "alice", Groups: []string{"dev-team"}}
+	file, err := CreateUser(p, pki, generatedDir, user, "720h", false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if file != filepath.Join(generatedDir, "alice-kubeconfig") {
+		t.Errorf("unexpected kubeconfig file %q", file)
+	}
+	kubeconfig, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatalf("error reading kubeconfig: %v", err)
+	}
+	if !strings.Contains(string(kubeconfig), "name: alice") || !strings.Contains(string(kubeconfig), "client-certificate-data:") {
+		t.Errorf("unexpected kubeconfig:\n%s", kubeconfig)
+	}
+	cert, err := tls.ReadCert("alice", pki.GeneratedCertsDirectory)
+	if err != nil {
+		t.Fatalf("error reading certificate: %v", err)
+	}
+	if cert.Subject.CommonName != "alice" || len(cert.Subject.Organization) != 1 || cert.Subject.Organization[0] != "dev-team" {
+		t.Errorf("unexpected subject %+v", cert.Subject)
+	}
+	if key, err := tls.CertKey(cert); err != nil || key.Algorithm != tls.KeyAlgorithmECDSA {
+		t.Errorf("expected an ecdsa key, got %s (%v)", key, err)
+	}
+	if validity := cert.NotAfter.Sub(cert.NotBefore); validity > 721*time.Hour || validity < 719*time.Hour {
+		t.Errorf("expected the certificate to be valid for 720h, got %s", validity)
+	}
+
+	// The certificate is only issued again with overwrite
+	if _, err = CreateUser(p, pki, generatedDir, user, "720h", false); err == nil {
+		t.Errorf("expected an error creating a user that already exists")
+	}
+	if _, err = CreateUser(p, pki, generatedDir, user, "720h", true); err != nil {
+		t.Errorf("unexpected error overwriting the user: %v", err)
+	}
+	// The certificates of the cluster cannot be replaced
+	if _, err = CreateUser(p, pki, generatedDir, User{Name: "admin"}, "720h", true); err == nil {
+		t.Errorf("expected an error creating the admin user")
+	}
+}
+
+func TestGenerateClusterCertificatesUsers(t *testing.T) {
+	pki := getPKI(t)
+	defer cleanup(pki.GeneratedCertsDirectory, t)
+	p := getPlan()
+	p.Users = []User{{Name: "alice", Groups: []string{"dev-team"}, Role: "view"}}
+	ca, err := pki.GenerateClusterCA(p)
+	if err != nil {
+		t.Fatalf("error generating CA for test: %v", err)
+	}
+	proxyClientCA, err := pki.GenerateProxyClientCA(p)
+	if err != nil {
+		t.Fatalf("error generating proxy-client CA for test: %v", err)
+	}
+	if err = pki.GenerateClusterCertificates(p, ca, proxyClientCA); err != nil {
+		t.Fatalf("error generating cluster certificates: %v", err)
+	}
+	entities, err := certificateEntities(*p)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if entities["alice"] != "user alice" {
+		t.Errorf("unexpected entity %q for the user", entities["alice"])
+	}
+	cert, err := tls.ReadCert("alice", pki.GeneratedCertsDirectory)
+	if err != nil {
+		t.Fatalf("error reading the certificate of the user: %v", err)
+	}
+	if cert.Subject.CommonName != "alice" || len(cert.Subject.Organization) != 1 || cert.Subject.Organization[0] != "dev-team" {
+		t.Errorf("unexpected subject %+v", cert.Subject)
+	}
+}
@@ -82,6 +82,8 @@ func ValidateCertificates(p *Plan, pki *LocalPKI) (bool, []error) {
 	if warn != nil && len(warn) > 0 {
 		v.addError(warn...)
 	}
+	v.addError(pki.ValidateClusterCAChain(p)...)
+	v.addError(pki.ValidateExternallySignedCertificates(p)...)
 
 	return v.valid()
 }
@@ -153,6 +155,7 @@ func (p *Plan) validate() (bool, []error) {
 	v.validateWithErrPrefix("Ingress nodes", &p.Ingress)
 	v.validate(&p.NFS)
 	v.validateWithErrPrefix("Storage nodes", &p.Storage)
+	v.validate(&userList{Users: p.Users, Plan: p})
 
 	return v.valid()
 }
@@ -222,6 +225,26 @@ func (c *CertsConfig) validate() (bool, []error) {
 	if _, err := time.ParseDuration(c.CAExpiry); c.CAExpiry != "" && err != nil { // don't error when empty for backwards compat
 		v.addError(fmt.Errorf("Invalid CA certificate expiry %q provider: %v", c.CAExpiry, err))
 	}
+	if err := c.key().Validate(); err != nil {
+		v.addError(fmt.Errorf("Invalid certificate key: %v", err))
+	}
+	if err := c.caKey().Validate(); err != nil {
+		v.addError(fmt.Errorf("Invalid CA certificate key: %v", err))
+	}
+	switch c.Backend {
+	case "", pkiBackendLocal:
+	case pkiBackendVault:
+		if c.Vault == nil || c.Vault.Address == "" {
+			v.addError(errors.New("Vault address is required when using the vault certificates backend"))
+		}
+		if c.Vault != nil && c.Vault.CACert != "" {
+			if _, err := os.Stat(c.Vault.CACert); os.IsNotExist(err) {
+				v.addError(fmt.Errorf("Vault CA certificate file was not found at %q", c.Vault.CACert))
+			}
+		}
+	default:
+		v.addError(fmt.Errorf("%q is not a valid certificates backend. Options are %v", c.Backend, []string{pkiBackendLocal, pkiBackendVault}))
+	}
 	return v.valid()
 }
 
@@ -260,6 +283,59 @@ func (c *CloudProvider) validate() (bool, []error) {
 	return v.valid()
 }
 
+var userNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._@-]*$`)
+var namespaceRegexp = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+
+type userList struct {
+	Users []User
+	Plan  *Plan
+}
+
+func (ul *userList) validate() (bool, []error) {
+	v := newValidator()
+	if len(ul.Users) == 0 {
+		return v.valid()
+	}
+	// The certificates of the users cannot replace the certificates of the cluster
+	withoutUsers := *ul.Plan
+	withoutUsers.Users = nil
+	entities, err := certificateEntities(withoutUsers)
+	if err != nil {
+		v.addError(err)
+		return v.valid()
+	}
+	// The kubeconfig file of a user cannot replace the kubeconfig of the dashboard
+	entities["dashboard-admin"] = "dashboard admin"
+	names := map[string]bool{}
+	for _, u := range ul.Users {
+		if !userNameRegexp.MatchString(u.Name) {
+			v.addError(fmt.Errorf("User name %q is invalid, it must start with a letter or a digit, and contain only letters, digits, '.', '_', '@' and '-'", u.Name))
+			continue
+		}
+		if names[u.Name] {
+			v.addError(fmt.Errorf("User %q is defined more than once", u.Name))
+		}
+		names[u.Name] = true
+		if entity, ok := entities[u.Name]; ok {
+			v.addError(fmt.Errorf("User name %q is reserved for the %s", u.Name, entity))
+		}
+		for _, g := range u.Groups {
+			if g == "" {
+				v.addError(fmt.Errorf("User %q has an empty group", u.Name))
+			}
+		}
+		if u.Namespace != "" {
+			if u.Role == "" {
+				v.addError(fmt.Errorf("User %q has a namespace, but no role to bind in it", u.Name))
+			}
+			if !namespaceRegexp.MatchString(u.Namespace) {
+				v.addError(fmt.Errorf("User %q has an invalid namespace %q", u.Name, u.Namespace))
+			}
+		}
+	}
+	return v.valid()
+}
+
 type additionalFilesGroup struct {
 	AdditionalFiles []AdditionalFile
 	Plan            *Plan
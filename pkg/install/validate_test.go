@@ -359,6 +359,85 @@ func TestValidatePlanInvalidCACertificatesExpiry(t *testing.T) {
 	assertInvalidPlan(t, p)
 }
 
+func TestValidatePlanCertificatesBackend(t *testing.T) {
+	p := validPlan
+	p.Cluster.Certificates.Backend = "foo"
+	assertInvalidPlan(t, p)
+
+	p.Cluster.Certificates.Backend = pkiBackendVault
+	assertInvalidPlan(t, p)
+
+	p.Cluster.Certificates.Vault = &VaultPKIConfig{Address: "https://vault.example.com:8200", CACert: "/nonexistent/vault-ca.pem"}
+	assertInvalidPlan(t, p)
+
+	p.Cluster.Certificates.Vault = &VaultPKIConfig{Address: "https://vault.example.com:8200"}
+	if valid, errs := p.validate(); !valid {
+		t.Errorf("expected the plan with the vault backend to be valid, got: %v", errs)
+	}
+}
+
+func TestValidatePlanCertificatesKey(t *testing.T) {
+	tests := []struct {
+		algorithm string
+		size      int
+		valid     bool
+	}{
+		{"", 0, true},
+		{"rsa", 0, true},
+		{"rsa", 4096, true},
+		{"", 3072, true},
+		{"ecdsa", 0, true},
+		{"ecdsa", 384, true},
+		{"rsa", 1024, false},
+		{"rsa", 256, false},
+		{"ecdsa", 2048, false},
+		{"ecdsa", 521, false},
+		{"dsa", 0, false},
+	}
+	for _, test := range tests {
+		p := validPlan
+		p.Cluster.Certificates.KeyAlgorithm = test.algorithm
+		p.Cluster.Certificates.KeySize = test.size
+		if valid, _ := p.validate(); valid != test.valid {
+			t.Errorf("key %q %d: expected valid to be %v, but got %v", test.algorithm, test.size, test.valid, valid)
+		}
+		p = validPlan
+		p.Cluster.Certificates.CAKeyAlgorithm = test.algorithm
+		p.Cluster.Certificates.CAKeySize = test.size
+		if valid, _ := p.validate(); valid != test.valid {
+			t.Errorf("CA key %q %d: expected valid to be %v, but got %v", test.algorithm, test.size, test.valid, valid)
+		}
+	}
+}
+
+func TestValidatePlanUsers(t *testing.T) {
+	tests := []struct {
+		users []User
+		valid bool
+	}{
+		{[]User{{Name: "alice"}}, true},
+		{[]User{{Name: "alice@example.com", Groups: []string{"dev-team"}, Role: "view"}}, true},
+		{[]User{{Name: "alice", Role: "edit", Namespace: "dev"}}, true},
+		{[]User{{Name: "alice"}, {Name: "bob"}}, true},
+		{[]User{{Name: ""}}, false},
+		{[]User{{Name: "alice/bob"}}, false},
+		{[]User{{Name: "alice"}, {Name: "alice"}}, false},
+		{[]User{{Name: "admin"}}, false},
+		{[]User{{Name: "ca"}}, false},
+		{[]User{{Name: "dashboard-admin"}}, false},
+		{[]User{{Name: "alice", Groups: []string{""}}}, false},
+		{[]User{{Name: "alice", Namespace: "dev"}}, false},
+		{[]User{{Name: "alice", Role: "edit", Namespace: "Dev_Team"}}, false},
+	}
+	for i, test := range tests {
+		p := validPlan
+		p.Users = test.users
+		if valid, errs := p.validate(); valid != test.valid {
+			t.Errorf("test %d: expected valid to be %v, but got %v: %v", i, test.valid, valid, errs)
+		}
+	}
+}
+
 func TestValidatePlanEmptySSHUser(t *testing.T) {
 	p := validPlan
 	p.Cluster.SSH.User = ""
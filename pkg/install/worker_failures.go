@@ -0,0 +1,185 @@
+package install
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/apprenda/kismatic/pkg/ansible"
+)
+
+// FailedWorkersError is returned when the execution failed on one or more
+// worker nodes, but the number of failures was within the tolerated limits.
+// The rest of the cluster was configured successfully.
+type FailedWorkersError struct {
+	// Hosts is the list of worker nodes that failed
+	Hosts []string
+	// RunDirectory is the directory that contains the record of the run
+	RunDirectory string
+}
+
+func (e FailedWorkersError) Error() string {
+	return fmt.Sprintf("%d worker node(s) failed: %s", len(e.Hosts), strings.Join(e.Hosts, ", "))
+}
+
+// playbookFailedError is returned when the execution failed. It contains the
+// nodes that reported a failure, if any.
+type playbookFailedError struct {
+	err   error
+	hosts []string
+}
+
+func (e playbookFailedError) Error() string {
+	return fmt.Sprintf("error running playbook: %v", e.err)
+}
+
+// workerFailureTracker keeps track of the nodes that failed during an ansible
+// run, and signals when the failures go over the tolerated limits.
+type workerFailureTracker struct {
+	maxFailed     int
+	maxPercentage int
+	// workers that are allowed to fail
+	workers map[string]bool
+
+	lock     sync.Mutex
+	failed   []string
+	fatal    []string
+	exceeded chan error
+	done     chan struct{}
+	finished bool
+}
+
+// newWorkerFailureTracker returns a tracker that tolerates failures on the
+// worker nodes of the inventory. Nodes that have other roles, or that are
+// listed in the protected hosts, are not allowed to fail.
+func newWorkerFailureTracker(maxFailed int, maxPercentage int, inventory ansible.Inventory, protected ...string) *workerFailureTracker {
+	workers := map[string]bool{}
+	others := map[string]bool{}
+	for _, r := range inventory.Roles {
+		for _, n := range r.Nodes {
+			if r.Name == "worker" {
+				workers[n.Host] = true
+				continue
+			}
+			others[n.Host] = true
+		}
+	}
+	for h := range others {
+		delete(workers, h)
+	}
+	for _, h := range protected {
+		delete(workers, h)
+	}
+	return &workerFailureTracker{
+		maxFailed:     maxFailed,
+		maxPercentage: maxPercentage,
+		workers:       workers,
+		exceeded:      make(chan error, 1),
+		done:          make(chan struct{}),
+	}
+}
+
+// Watch the incoming event stream. All events are forwarded to the returned channel.
+func (t *workerFailureTracker) Watch(in <-chan ansible.Event) <-chan ansible.Event {
+	out := make(chan ansible.Event)
+	go func() {
+		defer close(out)
+		defer t.finish()
+		for e := range in {
+			t.track(e)
+			out <- e
+		}
+	}()
+	return out
+}
+
+// Exceeded returns a channel that receives an error when the failures
+// are no longer tolerated.
+func (t *workerFailureTracker) Exceeded() <-chan error {
+	return t.exceeded
+}
+
+// Done returns a channel that is closed once the end of the playbook,
+// or the end of the watched event stream, has been processed.
+func (t *workerFailureTracker) Done() <-chan struct{} {
+	return t.done
+}
+
+// Failed returns the worker nodes that have failed
+func (t *workerFailureTracker) Failed() []string {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	failed := make([]string, len(t.failed))
+	copy(failed, t.failed)
+	sort.Strings(failed)
+	return failed
+}
+
+// FailedHosts returns all the nodes that have failed, including the nodes
+// that are not allowed to fail
+func (t *workerFailureTracker) FailedHosts() []string {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	failed := append([]string{}, t.failed...)
+	failed = append(failed, t.fatal...)
+	sort.Strings(failed)
+	return failed
+}
+
+func (t *workerFailureTracker) track(e ansible.Event) {
+	var host string
+	switch event := e.(type) {
+	case *ansible.RunnerFailedEvent:
+		if event.IgnoreErrors {
+			return
+		}
+		host = event.Host
+	case *ansible.RunnerUnreachableEvent:
+		host = event.Host
+	case *ansible.PlaybookEndEvent:
+		t.finish()
+		return
+	default:
+		return
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if !t.workers[host] {
+		if !contains(host, t.fatal) {
+			t.fatal = append(t.fatal, host)
+			t.signal(fmt.Errorf("node %q failed, and only failures on worker nodes are tolerated", host))
+		}
+		return
+	}
+	if contains(host, t.failed) {
+		return
+	}
+	t.failed = append(t.failed, host)
+	if t.maxFailed > 0 && len(t.failed) > t.maxFailed {
+		t.signal(fmt.Errorf("%d worker nodes failed, which is more than the maximum of %d", len(t.failed), t.maxFailed))
+		return
+	}
+	if t.maxPercentage > 0 && len(t.failed)*100 > t.maxPercentage*len(t.workers) {
+		t.signal(fmt.Errorf("%d out of %d worker nodes failed, which is more than the maximum of %d%%", len(t.failed), len(t.workers), t.maxPercentage))
+	}
+}
+
+// finish closes the done channel, once
+func (t *workerFailureTracker) finish() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if !t.finished {
+		t.finished = true
+		close(t.done)
+	}
+}
+
+// signal that the failures are not tolerated. Only the first error is kept.
+func (t *workerFailureTracker) signal(err error) {
+	select {
+	case t.exceeded <- err:
+	default:
+	}
+}
@@ -0,0 +1,150 @@
+package install
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/apprenda/kismatic/pkg/ansible"
+)
+
+func failureTrackerInventory() ansible.Inventory {
+	return ansible.Inventory{
+		Roles: []ansible.Role{
+			{Name: "etcd", Nodes: []ansible.Node{{Host: "etcd01"}}},
+			{Name: "master", Nodes: []ansible.Node{{Host: "master01"}}},
+			{Name: "worker", Nodes: []ansible.Node{{Host: "worker01"}, {Host: "worker02"}, {Host: "worker03"}, {Host: "worker04"}, {Host: "ingress01"}}},
+			{Name: "ingress", Nodes: []ansible.Node{{Host: "ingress01"}}},
+		},
+	}
+}
+
+func failedEvent(host string) *ansible.RunnerFailedEvent {
+	e := &ansible.RunnerFailedEvent{}
+	e.Host = host
+	return e
+}
+
+func unreachableEvent(host string) *ansible.RunnerUnreachableEvent {
+	e := &ansible.RunnerUnreachableEvent{}
+	e.Host = host
+	return e
+}
+
+func expectNotExceeded(t *testing.T, tracker *workerFailureTracker) {
+	select {
+	case err := <-tracker.Exceeded():
+		t.Errorf("unexpected error: %v", err)
+	default:
+	}
+}
+
+func expectExceeded(t *testing.T, tracker *workerFailureTracker) {
+	select {
+	case <-tracker.Exceeded():
+	default:
+		t.Errorf("expected the failures to exceed the limit, but they didn't")
+	}
+}
+
+func TestWorkerFailureTrackerWithinMaxFailed(t *testing.T) {
+	tracker := newWorkerFailureTracker(2, 0, failureTrackerInventory())
+	tracker.track(failedEvent("worker01"))
+	tracker.track(unreachableEvent("worker03"))
+	// Failures on the same node are only counted once
+	tracker.track(failedEvent("worker01"))
+	expectNotExceeded(t, tracker)
+	if !reflect.DeepEqual(tracker.Failed(), []string{"worker01", "worker03"}) {
+		t.Errorf("unexpected failed nodes: %v", tracker.Failed())
+	}
+}
+
+func TestWorkerFailureTrackerExceedsMaxFailed(t *testing.T) {
+	tracker := newWorkerFailureTracker(1, 0, failureTrackerInventory())
+	tracker.track(failedEvent("worker01"))
+	expectNotExceeded(t, tracker)
+	tracker.track(failedEvent("worker02"))
+	expectExceeded(t, tracker)
+}
+
+func TestWorkerFailureTrackerPercentage(t *testing.T) {
+	// there are 4 workers that can fail
+	tracker := newWorkerFailureTracker(0, 50, failureTrackerInventory())
+	tracker.track(failedEvent("worker01"))
+	tracker.track(failedEvent("worker02"))
+	expectNotExceeded(t, tracker)
+	tracker.track(failedEvent("worker03"))
+	expectExceeded(t, tracker)
+}
+
+func TestWorkerFailureTrackerIgnoredErrors(t *testing.T) {
+	tracker := newWorkerFailureTracker(1, 0, failureTrackerInventory())
+	e := failedEvent("master01")
+	e.IgnoreErrors = true
+	tracker.track(e)
+	expectNotExceeded(t, tracker)
+	if len(tracker.Failed()) != 0 {
+		t.Errorf("expected no failed nodes, got %v", tracker.Failed())
+	}
+}
+
+func TestWorkerFailureTrackerNonWorkerFailures(t *testing.T) {
+	tests := []struct {
+		host      string
+		protected []string
+	}{
+		{host: "master01"},
+		{host: "etcd01"},
+		// worker that is also an ingress node
+		{host: "ingress01"},
+		{host: "worker01", protected: []string{"worker01"}},
+	}
+	for _, test := range tests {
+		tracker := newWorkerFailureTracker(10, 100, failureTrackerInventory(), test.protected...)
+		tracker.track(failedEvent(test.host))
+		expectExceeded(t, tracker)
+	}
+}
+
+func TestWorkerFailureTrackerDone(t *testing.T) {
+	tracker := newWorkerFailureTracker(1, 0, failureTrackerInventory())
+	in := make(chan ansible.Event)
+	out := tracker.Watch(in)
+	go func() {
+		in <- failedEvent("worker01")
+		in <- &ansible.PlaybookEndEvent{}
+		close(in)
+	}()
+	for range out {
+	}
+	select {
+	case <-tracker.Done():
+	default:
+		t.Errorf("expected tracker to be done after the end of the playbook")
+	}
+}
+
+func TestWorkerFailureTrackerDoneAtEndOfStream(t *testing.T) {
+	tracker := newWorkerFailureTracker(1, 0, failureTrackerInventory())
+	in := make(chan ansible.Event)
+	out := tracker.Watch(in)
+	go func() {
+		in <- failedEvent("worker01")
+		close(in)
+	}()
+	for range out {
+	}
+	select {
+	case <-tracker.Done():
+	default:
+		t.Errorf("expected tracker to be done after the end of the event stream")
+	}
+}
+
+func TestWorkerFailureTrackerFailedHosts(t *testing.T) {
+	tracker := newWorkerFailureTracker(0, 0, failureTrackerInventory())
+	tracker.track(failedEvent("worker02"))
+	tracker.track(unreachableEvent("master01"))
+	if !reflect.DeepEqual(tracker.FailedHosts(), []string{"master01", "worker02"}) {
+		t.Errorf("unexpected failed hosts: %v", tracker.FailedHosts())
+	}
+}
@@ -27,6 +27,12 @@ type Subject struct {
 
 // NewCACert creates a new Certificate Authority and returns it's private key and public certificate.
 func NewCACert(csrFile string, commonName string, expiry string) (key, cert []byte, err error) {
+	return NewCACertWithKey(csrFile, commonName, expiry, Key{})
+}
+
+// NewCACertWithKey creates a new Certificate Authority with the given private key algorithm and size.
+// The key of the CSR file is used when the key is empty.
+func NewCACertWithKey(csrFile string, commonName string, expiry string, caKey Key) (key, cert []byte, err error) {
 	// Open CSR file
 	f, err := os.Open(csrFile)
 	if os.IsNotExist(err) {
@@ -44,6 +50,9 @@ func NewCACert(csrFile string, commonName string, expiry string) (key, cert []by
 		return nil, nil, fmt.Errorf("error decoding CSR: %v", err)
 	}
 	caCSR.CN = commonName
+	if caKey != (Key{}) {
+		caCSR.KeyRequest = caKey.KeyRequest()
+	}
 	caCSR.CA = &csr.CAConfig{Expiry: expiry}
 	// Generate CA Cert according to CSR
 	cert, _, key, err = initca.New(caCSR)
@@ -1,6 +1,7 @@
 package tls
 
 import (
+	"bytes"
 	"crypto/x509"
 	"fmt"
 	"io/ioutil"
@@ -26,14 +27,17 @@ type CA struct {
 	Password string
 	// Cert is the CA's public certificate.
 	Cert []byte
+	// Chain is bundled with the certificates issued by the CA, when the CA is
+	// an intermediate CA. It contains the CA's certificate, followed by the
+	// certificates of the intermediate CAs that issued it, excluding the trust anchor.
+	Chain []byte
 }
 
 // NewCert creates a new certificate/key pair using the CertificateAuthority provided
 func NewCert(ca *CA, req csr.CertificateRequest, expiry time.Duration) (key, cert []byte, err error) {
-	g := &csr.Generator{Validator: genkey.Validator}
-	csrBytes, key, err := g.ProcessRequest(&req)
+	key, csrBytes, err := NewCSR(req)
 	if err != nil {
-		return nil, nil, fmt.Errorf("error processing CSR: %v", err)
+		return nil, nil, err
 	}
 	// Get CA private key
 	caPriv, err := helpers.ParsePrivateKeyPEMWithPassword(ca.Key, []byte(ca.Password))
@@ -65,9 +69,75 @@ func NewCert(ca *CA, req csr.CertificateRequest, expiry time.Duration) (key, cer
 	if err != nil {
 		return nil, nil, fmt.Errorf("error signing certificate: %v", err)
 	}
+	if len(ca.Chain) > 0 {
+		cert = Bundle(cert, ca.Chain)
+	}
 	return key, cert, nil
 }
 
+// NewCSR creates a new private key and a certificate signing request for it
+func NewCSR(req csr.CertificateRequest) (key, csrBytes []byte, err error) {
+	g := &csr.Generator{Validator: genkey.Validator}
+	csrBytes, key, err = g.ProcessRequest(&req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error processing CSR: %v", err)
+	}
+	return key, csrBytes, nil
+}
+
+// WriteCSR writes the CSR and key files
+func WriteCSR(key, csrBytes []byte, name, dir string) error {
+	err := util.CreateDir(dir, 0744)
+	if err != nil {
+		return err
+	}
+	err = ioutil.WriteFile(filepath.Join(dir, keyName(name)), key, 0600)
+	if err != nil {
+		return fmt.Errorf("error writing private key: %v", err)
+	}
+	err = ioutil.WriteFile(filepath.Join(dir, CSRName(name)), csrBytes, 0644)
+	if err != nil {
+		return fmt.Errorf("error writing CSR: %v", err)
+	}
+	return nil
+}
+
+// CSRKeyPairExists returns true if a key and matching CSR exist.
+// No validation is performed on the actual bytes of the CSR/key
+func CSRKeyPairExists(name, dir string) (bool, error) {
+	for _, f := range []string{keyName(name), CSRName(name)} {
+		_, err := os.Stat(filepath.Join(dir, f))
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// CertMatchesKey returns true if the certificate was issued for the private key with the given name
+func CertMatchesKey(cert *x509.Certificate, name, dir string) (bool, error) {
+	keyBytes, err := ioutil.ReadFile(filepath.Join(dir, keyName(name)))
+	if err != nil {
+		return false, fmt.Errorf("error reading private key: %v", err)
+	}
+	key, err := helpers.ParsePrivateKeyPEM(keyBytes)
+	if err != nil {
+		return false, fmt.Errorf("error parsing private key: %v", err)
+	}
+	public, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		return false, fmt.Errorf("error parsing private key: %v", err)
+	}
+	certPublic, err := x509.MarshalPKIXPublicKey(cert.PublicKey)
+	if err != nil {
+		return false, fmt.Errorf("error parsing certificate: %v", err)
+	}
+	return bytes.Equal(public, certPublic), nil
+}
+
 // WriteCert writes cert and key files
 func WriteCert(key, cert []byte, name, dir string) error {
 	// Create destination dir if it doesn't exist
@@ -95,11 +165,7 @@ func ReadCert(name, dir string) (*x509.Certificate, error) {
 	if err != nil {
 		return nil, err
 	}
-	cert, err := helpers.ParseCertificatePEM(certBytes)
-	if err != nil {
-		return nil, err
-	}
-	return cert, nil
+	return parseFirstCertificatePEM(certBytes)
 }
 
 // CertKeyPairExists returns true if a key and matching certificate exist.
@@ -149,7 +215,7 @@ func CertValid(commonName string, SANs []string, organizations []string, name, d
 	}
 
 	// verify certificate
-	cert, err := helpers.ParseCertificatePEM(certBytes)
+	cert, err := parseFirstCertificatePEM(certBytes)
 	if err != nil {
 		return nil, fmt.Errorf("error parsing cert %s: %v", name, err)
 	}
@@ -189,6 +255,32 @@ func CertValid(commonName string, SANs []string, organizations []string, name, d
 	return warn, nil
 }
 
+// Bundle concatenates the PEM encoded certificates
+func Bundle(certs ...[]byte) []byte {
+	var b []byte
+	for _, c := range certs {
+		b = append(b, bytes.TrimSpace(c)...)
+		b = append(b, '\n')
+	}
+	return b
+}
+
+// parseFirstCertificatePEM returns the first certificate of the PEM encoded data,
+// which can be a bundle of the certificate and the chain of its issuers.
+func parseFirstCertificatePEM(certBytes []byte) (*x509.Certificate, error) {
+	certs, err := helpers.ParseCertificatesPEM(certBytes)
+	if err != nil {
+		return nil, err
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no certificate found")
+	}
+	return certs[0], nil
+}
+
 func keyName(s string) string { return fmt.Sprintf("%s-key.pem", s) }
 
 func certName(s string) string { return fmt.Sprintf("%s.pem", s) }
+
+// CSRName returns the name of the CSR file with the given name
+func CSRName(s string) string { return fmt.Sprintf("%s.csr", s) }
@@ -350,3 +350,57 @@ func cleanup(dir string, t *testing.T) {
 		t.Fatalf("failed cleaning up temp directory: %v", err)
 	}
 }
+
+func TestNewCertBundlesChain(t *testing.T) {
+	key, caCert, err := NewCACert("test/ca-csr.json", "someCN", "1h")
+	if err != nil {
+		t.Fatalf("error creating CA: %v", err)
+	}
+	_, otherCACert, err := NewCACert("test/ca-csr.json", "otherCN", "1h")
+	if err != nil {
+		t.Fatalf("error creating CA: %v", err)
+	}
+	ca := &CA{
+		Key:   key,
+		Cert:  caCert,
+		Chain: Bundle(caCert, otherCACert),
+	}
+	req := csr.CertificateRequest{
+		CN:         "testKube",
+		KeyRequest: &csr.BasicKeyRequest{A: "rsa", S: 2048},
+	}
+	key, cert, err := NewCert(ca, req, time.Hour)
+	if err != nil {
+		t.Fatalf("error creating certificate: %v", err)
+	}
+	certs, err := helpers.ParseCertificatesPEM(cert)
+	if err != nil {
+		t.Fatalf("error parsing certificate bundle: %v", err)
+	}
+	if len(certs) != 3 {
+		t.Fatalf("expected the certificate to be bundled with the chain, got %d certificates", len(certs))
+	}
+	if certs[0].Subject.CommonName != "testKube" || certs[1].Subject.CommonName != "someCN" || certs[2].Subject.CommonName != "otherCN" {
+		t.Errorf("unexpected order of the certificate bundle")
+	}
+
+	dir, err := ioutil.TempDir("", "cert-bundle")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	if err = WriteCert(key, cert, "bundle", dir); err != nil {
+		t.Fatalf("error writing certificate: %v", err)
+	}
+	readCert, err := ReadCert("bundle", dir)
+	if err != nil {
+		t.Fatalf("error reading certificate bundle: %v", err)
+	}
+	if !readCert.Equal(certs[0]) {
+		t.Errorf("expected the first certificate of the bundle to be read")
+	}
+	warn, err := CertValid("testKube", nil, nil, "bundle", dir)
+	if err != nil || len(warn) != 0 {
+		t.Errorf("expected the certificate bundle to be valid, got %v %v", warn, err)
+	}
+}
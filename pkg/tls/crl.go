@@ -0,0 +1,100 @@
+package tls
+
+import (
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/apprenda/kismatic/pkg/util"
+	"github.com/cloudflare/cfssl/helpers"
+)
+
+// NewCRL creates a PEM encoded certificate revocation list, signed by the CA, that
+// revokes the given certificates. The CRL must be updated before the expiry.
+func NewCRL(ca *CA, revoked []pkix.RevokedCertificate, now time.Time, expiry time.Duration) ([]byte, error) {
+	if len(ca.Key) == 0 {
+		return nil, fmt.Errorf("the private key of the CA is not available to sign the CRL")
+	}
+	caPriv, err := helpers.ParsePrivateKeyPEMWithPassword(ca.Key, []byte(ca.Password))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing private key: %v", err)
+	}
+	caCert, err := helpers.ParseCertificatePEM(ca.Cert)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing CA cert: %v", err)
+	}
+	der, err := caCert.CreateCRL(rand.Reader, caPriv, revoked, now, now.Add(expiry))
+	if err != nil {
+		return nil, fmt.Errorf("error creating CRL: %v", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: der}), nil
+}
+
+// RevokeCert returns the CRL, signed by the CA, with the certificate added to the
+// certificates revoked by the CRL. The CRL is created when it is nil.
+func RevokeCert(ca *CA, crl *pkix.CertificateList, cert *x509.Certificate, now time.Time, expiry time.Duration) ([]byte, error) {
+	revoked := []pkix.RevokedCertificate{}
+	if crl != nil {
+		revoked = append(revoked, crl.TBSCertList.RevokedCertificates...)
+	}
+	if !IsRevoked(crl, cert) {
+		revoked = append(revoked, pkix.RevokedCertificate{
+			SerialNumber:   cert.SerialNumber,
+			RevocationTime: now.UTC(),
+		})
+	}
+	return NewCRL(ca, revoked, now, expiry)
+}
+
+// IsRevoked returns true if the certificate is revoked by the CRL
+func IsRevoked(crl *pkix.CertificateList, cert *x509.Certificate) bool {
+	if crl == nil {
+		return false
+	}
+	for _, r := range crl.TBSCertList.RevokedCertificates {
+		if r.SerialNumber.Cmp(cert.SerialNumber) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// ReadCRL reads the CRL with the given name, and verifies that it was signed by the CA.
+// Returns nil if the CRL does not exist.
+func ReadCRL(name, dir string, caCert *x509.Certificate) (*pkix.CertificateList, error) {
+	b, err := ioutil.ReadFile(filepath.Join(dir, CRLName(name)))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading CRL: %v", err)
+	}
+	crl, err := x509.ParseCRL(b)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing CRL: %v", err)
+	}
+	if err = caCert.CheckCRLSignature(crl); err != nil {
+		return nil, fmt.Errorf("the CRL was not signed by the CA: %v", err)
+	}
+	return crl, nil
+}
+
+// WriteCRL writes the CRL file
+func WriteCRL(crl []byte, name, dir string) error {
+	if err := util.CreateDir(dir, 0744); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, CRLName(name)), crl, 0644); err != nil {
+		return fmt.Errorf("error writing CRL: %v", err)
+	}
+	return nil
+}
+
+// CRLName returns the name of the CRL file
+func CRLName(s string) string { return fmt.Sprintf("%s.crl", s) }
@@ -0,0 +1,95 @@
+package tls
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/cloudflare/cfssl/csr"
+	"github.com/cloudflare/cfssl/helpers"
+)
+
+func TestRevokeCert(t *testing.T) {
+	key, caCert, err := NewCACert("test/ca-csr.json", "someCN", "1h")
+	if err != nil {
+		t.Fatalf("error creating CA: %v", err)
+	}
+	ca := &CA{Key: key, Cert: caCert}
+	parsedCACert, err := helpers.ParseCertificatePEM(caCert)
+	if err != nil {
+		t.Fatalf("error parsing CA Certificate: %v", err)
+	}
+	req := csr.CertificateRequest{CN: "alice", KeyRequest: &csr.BasicKeyRequest{A: "rsa", S: 2048}}
+	_, certPEM, err := NewCert(ca, req, time.Hour)
+	if err != nil {
+		t.Fatalf("error creating certificate: %v", err)
+	}
+	cert, err := helpers.ParseCertificatePEM(certPEM)
+	if err != nil {
+		t.Fatalf("error parsing certificate: %v", err)
+	}
+	dir, err := ioutil.TempDir("", "crl-tests")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// No CRL exists yet
+	crl, err := ReadCRL("ca", dir, parsedCACert)
+	if err != nil {
+		t.Fatalf("unexpected error reading CRL: %v", err)
+	}
+	if crl != nil || IsRevoked(crl, cert) {
+		t.Fatalf("expected no CRL")
+	}
+
+	crlPEM, err := RevokeCert(ca, crl, cert, time.Now(), time.Hour)
+	if err != nil {
+		t.Fatalf("error revoking certificate: %v", err)
+	}
+	if err = WriteCRL(crlPEM, "ca", dir); err != nil {
+		t.Fatalf("error writing CRL: %v", err)
+	}
+	crl, err = ReadCRL("ca", dir, parsedCACert)
+	if err != nil {
+		t.Fatalf("error reading CRL: %v", err)
+	}
+	if !IsRevoked(crl, cert) {
+		t.Errorf("expected the certificate to be revoked")
+	}
+
+	// Revoking the certificate again does not add it twice
+	crlPEM, err = RevokeCert(ca, crl, cert, time.Now(), time.Hour)
+	if err != nil {
+		t.Fatalf("error revoking certificate: %v", err)
+	}
+	if err = WriteCRL(crlPEM, "ca", dir); err != nil {
+		t.Fatalf("error writing CRL: %v", err)
+	}
+	crl, err = ReadCRL("ca", dir, parsedCACert)
+	if err != nil {
+		t.Fatalf("error reading CRL: %v", err)
+	}
+	if n := len(crl.TBSCertList.RevokedCertificates); n != 1 {
+		t.Errorf("expected 1 revoked certificate, got %d", n)
+	}
+
+	// The CRL is not trusted when signed by another CA
+	_, otherCACert, err := NewCACert("test/ca-csr.json", "otherCN", "1h")
+	if err != nil {
+		t.Fatalf("error creating CA: %v", err)
+	}
+	parsedOther, err := helpers.ParseCertificatePEM(otherCACert)
+	if err != nil {
+		t.Fatalf("error parsing CA Certificate: %v", err)
+	}
+	if _, err = ReadCRL("ca", dir, parsedOther); err == nil {
+		t.Errorf("expected an error reading a CRL signed by another CA")
+	}
+
+	// The CRL cannot be signed without the private key of the CA
+	if _, err = NewCRL(&CA{Cert: caCert}, nil, time.Now(), time.Hour); err == nil {
+		t.Errorf("expected an error creating a CRL without the CA key")
+	}
+}
@@ -0,0 +1,78 @@
+package tls
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"fmt"
+
+	"github.com/cloudflare/cfssl/csr"
+)
+
+// The algorithms of the private keys
+const (
+	KeyAlgorithmRSA   = "rsa"
+	KeyAlgorithmECDSA = "ecdsa"
+)
+
+// The key sizes that are supported by the Kubernetes components, for each algorithm.
+// The size of an ECDSA key is the size of its curve. The first size is the default.
+var supportedKeySizes = map[string][]int{
+	KeyAlgorithmRSA:   {2048, 3072, 4096},
+	KeyAlgorithmECDSA: {256, 384},
+}
+
+// Key describes the algorithm and the size of a private key.
+// When empty, a 2048 bit RSA key is generated.
+type Key struct {
+	Algorithm string
+	Size      int
+}
+
+// WithDefaults returns the key with the default algorithm and size set, when they are empty
+func (k Key) WithDefaults() Key {
+	if k.Algorithm == "" {
+		k.Algorithm = KeyAlgorithmRSA
+	}
+	if sizes, ok := supportedKeySizes[k.Algorithm]; ok && k.Size == 0 {
+		k.Size = sizes[0]
+	}
+	return k
+}
+
+// Validate returns an error if the key is not supported
+func (k Key) Validate() error {
+	k = k.WithDefaults()
+	sizes, ok := supportedKeySizes[k.Algorithm]
+	if !ok {
+		return fmt.Errorf("%q is not a supported key algorithm. Options are %v", k.Algorithm, []string{KeyAlgorithmRSA, KeyAlgorithmECDSA})
+	}
+	for _, s := range sizes {
+		if k.Size == s {
+			return nil
+		}
+	}
+	return fmt.Errorf("%d is not a supported size for %s keys. Options are %v", k.Size, k.Algorithm, sizes)
+}
+
+func (k Key) String() string {
+	k = k.WithDefaults()
+	return fmt.Sprintf("%s %d", k.Algorithm, k.Size)
+}
+
+// KeyRequest returns the cfssl request for the key
+func (k Key) KeyRequest() *csr.BasicKeyRequest {
+	k = k.WithDefaults()
+	return &csr.BasicKeyRequest{A: k.Algorithm, S: k.Size}
+}
+
+// CertKey returns the algorithm and the size of the key of the certificate
+func CertKey(cert *x509.Certificate) (Key, error) {
+	switch pub := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		return Key{Algorithm: KeyAlgorithmRSA, Size: pub.N.BitLen()}, nil
+	case *ecdsa.PublicKey:
+		return Key{Algorithm: KeyAlgorithmECDSA, Size: pub.Curve.Params().BitSize}, nil
+	}
+	return Key{}, fmt.Errorf("unsupported public key of type %T", cert.PublicKey)
+}
@@ -0,0 +1,48 @@
+package tls
+
+import (
+	"testing"
+
+	"github.com/cloudflare/cfssl/helpers"
+)
+
+func TestNewCACertWithKey(t *testing.T) {
+	tests := []Key{
+		{},
+		{Algorithm: KeyAlgorithmRSA, Size: 3072},
+		{Algorithm: KeyAlgorithmECDSA},
+		{Algorithm: KeyAlgorithmECDSA, Size: 384},
+	}
+	for _, key := range tests {
+		_, cert, err := NewCACertWithKey("test/ca-csr.json", "someCommonName", "1h", key)
+		if err != nil {
+			t.Fatalf("error creating CA cert with %s key: %v", key, err)
+		}
+		parsed, err := helpers.ParseCertificatePEM(cert)
+		if err != nil {
+			t.Fatalf("error parsing certificate: %v", err)
+		}
+		actual, err := CertKey(parsed)
+		if err != nil {
+			t.Fatalf("error reading the key of the certificate: %v", err)
+		}
+		if actual != key.WithDefaults() {
+			t.Errorf("expected a %s key, got %s", key, actual)
+		}
+	}
+}
+
+func TestKeyValidate(t *testing.T) {
+	valid := []Key{{}, {Algorithm: KeyAlgorithmRSA, Size: 4096}, {Algorithm: KeyAlgorithmECDSA, Size: 256}}
+	for _, k := range valid {
+		if err := k.Validate(); err != nil {
+			t.Errorf("expected %s to be valid, got: %v", k, err)
+		}
+	}
+	invalid := []Key{{Algorithm: KeyAlgorithmRSA, Size: 1024}, {Algorithm: KeyAlgorithmECDSA, Size: 521}, {Algorithm: "dsa"}}
+	for _, k := range invalid {
+		if err := k.Validate(); err == nil {
+			t.Errorf("expected %s to be invalid", k)
+		}
+	}
+}